@@ -0,0 +1,223 @@
+package wrapper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	config "codeagent-wrapper/internal/config"
+	executor "codeagent-wrapper/internal/executor"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+)
+
+// agentDetail is the fully resolved configuration printed by `agents show`.
+type agentDetail struct {
+	config.AgentInfo
+	Profile         string   `json:"profile,omitempty"`
+	Reasoning       string   `json:"reasoning,omitempty"`
+	Yolo            bool     `json:"yolo"`
+	BaseURL         string   `json:"base_url,omitempty"`
+	APIKey          string   `json:"api_key,omitempty"`
+	AllowedTools    []string `json:"allowed_tools,omitempty"`
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+
+	MaxConcurrency          int    `json:"max_concurrency,omitempty"`
+	MinStartInterval        string `json:"min_start_interval,omitempty"`
+	BackendMaxConcurrency   int    `json:"backend_max_concurrency,omitempty"`
+	BackendMinStartInterval string `json:"backend_min_start_interval,omitempty"`
+
+	Env            []string `json:"env,omitempty"` // KEY=value with secrets masked, sorted by key
+	EnvPassthrough []string `json:"env_passthrough,omitempty"`
+	EnvUnset       []string `json:"env_unset,omitempty"`
+}
+
+func newAgentsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "agents",
+		Short:         "List and inspect agent presets",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newAgentsListCommand(), newAgentsShowCommand())
+	return cmd
+}
+
+func newAgentsListCommand() *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:           "list",
+		Short:         "List agents from models.json and ~/.codeagent/agents/*.md",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := activateProject(defaultWorkdir, false); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			agents, err := config.ListAgents()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %s\n", strings.SplitN(err.Error(), "\n\n", 2)[0])
+				return exitError{code: 1}
+			}
+			if jsonOutput {
+				return printJSON(cmd, agents)
+			}
+			if len(agents) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No agents configured (see README: Agent Presets)")
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tBACKEND\tMODEL\tSOURCE\tDESCRIPTION")
+			for _, a := range agents {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.Name, valueOr(a.Backend, "-"), valueOr(a.Model, "-"), a.Source, a.Description)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print agents as JSON")
+	return cmd
+}
+
+func newAgentsShowCommand() *cobra.Command {
+	var jsonOutput bool
+	var profile string
+	cmd := &cobra.Command{
+		Use:           "show <name>",
+		Short:         "Show the fully resolved configuration of an agent",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := activateProject(defaultWorkdir, false); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if err := config.SetAgentProfile(profile); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %s\n", strings.SplitN(err.Error(), "\n\n", 2)[0])
+				return exitError{code: 1}
+			}
+			detail, err := resolveAgentDetail(args[0])
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if jsonOutput {
+				return printJSON(cmd, detail)
+			}
+			fmt.Fprint(cmd.OutOrStdout(), renderAgentDetail(detail))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the agent as JSON")
+	cmd.Flags().StringVar(&profile, "profile", "", "Apply a models.json profile before resolving")
+	return cmd
+}
+
+func resolveAgentDetail(name string) (agentDetail, error) {
+	backendName, model, promptFile, reasoning, baseURL, apiKey, yolo, allowedTools, disallowedTools, err := config.ResolveAgentConfig(name)
+	if err != nil {
+		return agentDetail{}, fmt.Errorf("%s", strings.SplitN(err.Error(), "\n\n", 2)[0])
+	}
+
+	detail := agentDetail{
+		AgentInfo: config.AgentInfo{
+			Name:       name,
+			Backend:    backendName,
+			Model:      model,
+			Source:     config.AgentSourceDynamic,
+			PromptFile: promptFile,
+		},
+		Profile:         config.AgentProfile(),
+		Reasoning:       reasoning,
+		Yolo:            yolo,
+		BaseURL:         baseURL,
+		APIKey:          executor.MaskSensitiveValue("api_key", apiKey),
+		AllowedTools:    allowedTools,
+		DisallowedTools: disallowedTools,
+	}
+	if agents, err := config.ListAgents(); err == nil {
+		for _, a := range agents {
+			if a.Name == name {
+				detail.Source = a.Source
+				detail.Description = a.Description
+				detail.Extends = a.Extends
+				break
+			}
+		}
+	}
+
+	agentLimit := config.ResolveAgentConcurrency(name)
+	detail.MaxConcurrency = agentLimit.MaxConcurrency
+	if agentLimit.MinStartInterval > 0 {
+		detail.MinStartInterval = agentLimit.MinStartInterval.String()
+	}
+	backendLimit := config.ResolveBackendConcurrency(backendName)
+	detail.BackendMaxConcurrency = backendLimit.MaxConcurrency
+	if backendLimit.MinStartInterval > 0 {
+		detail.BackendMinStartInterval = backendLimit.MinStartInterval.String()
+	}
+
+	envPolicy := config.ResolveAgentEnvPolicy(name)
+	for k, v := range envPolicy.Env {
+		detail.Env = append(detail.Env, k+"="+executor.MaskSensitiveValue(k, v))
+	}
+	sort.Strings(detail.Env)
+	detail.EnvPassthrough = envPolicy.Passthrough
+	detail.EnvUnset = envPolicy.Unset
+	return detail, nil
+}
+
+func renderAgentDetail(d agentDetail) string {
+	var sb strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			sb.WriteString(fmt.Sprintf("%-20s %s\n", label+":", value))
+		}
+	}
+	line("Name", d.Name)
+	line("Source", d.Source)
+	line("Description", d.Description)
+	line("Extends", d.Extends)
+	line("Profile", d.Profile)
+	line("Backend", d.Backend)
+	line("Model", d.Model)
+	line("Reasoning", valueOr(d.Reasoning, "(backend default)"))
+	line("Prompt file", d.PromptFile)
+	line("Yolo", fmt.Sprint(d.Yolo))
+	line("Base URL", d.BaseURL)
+	line("API key", d.APIKey)
+	line("Allowed tools", strings.Join(d.AllowedTools, ", "))
+	line("Disallowed tools", strings.Join(d.DisallowedTools, ", "))
+	if d.MaxConcurrency > 0 {
+		line("Max concurrency", fmt.Sprint(d.MaxConcurrency))
+	}
+	line("Min start interval", d.MinStartInterval)
+	if d.BackendMaxConcurrency > 0 {
+		line("Backend concurrency", fmt.Sprint(d.BackendMaxConcurrency))
+	}
+	line("Backend interval", d.BackendMinStartInterval)
+	line("Env", strings.Join(d.Env, ", "))
+	line("Env passthrough", strings.Join(d.EnvPassthrough, ", "))
+	line("Env unset", strings.Join(d.EnvUnset, ", "))
+	return sb.String()
+}
+
+func valueOr(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
+
+func printJSON(cmd *cobra.Command, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return nil
+}
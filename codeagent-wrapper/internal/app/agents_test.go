@@ -0,0 +1,199 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	backend "codeagent-wrapper/internal/backend"
+	config "codeagent-wrapper/internal/config"
+
+	"github.com/goccy/go-json"
+)
+
+func runWithArgs(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	defer resetTestHooks()
+	os.Args = append([]string{"codeagent-wrapper"}, args...)
+	exitCode := 0
+	output := captureStdout(t, func() { exitCode = run() })
+	return exitCode, output
+}
+
+func TestAgentsListAndShow(t *testing.T) {
+	home := writeDoctorHome(t, `{
+  "default_backend": "claude",
+  "default_model": "sonnet",
+  "backends": { "claude": { "api_key": "sk-ant-1234567890", "max_concurrency": 2 } },
+  "agents": {
+    "develop": { "backend": "codex", "model": "gpt-x", "description": "Writes code", "max_concurrency": 1 },
+    "review": { "model": "opus", "env": { "MY_API_TOKEN": "tok-abcdef123456", "LANG": "C" }, "env_unset": ["AWS_*"] }
+  }
+}`)
+	agentsDir := filepath.Join(home, ".codeagent", "agents")
+	if err := os.MkdirAll(agentsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"docs.md":    "# Technical writer\n\nWrite docs.",
+		"develop.md": "shadowed by models.json",
+	} {
+		if err := os.WriteFile(filepath.Join(agentsDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, output := runWithArgs(t, "agents", "list", "--json")
+	if code != 0 {
+		t.Fatalf("agents list exit = %d", code)
+	}
+	var agents []config.AgentInfo
+	if err := json.Unmarshal([]byte(output), &agents); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	want := []config.AgentInfo{
+		{Name: "develop", Backend: "codex", Model: "gpt-x", Description: "Writes code", Source: config.AgentSourceModels},
+		{Name: "docs", Backend: "claude", Model: "sonnet", Description: "Technical writer", Source: config.AgentSourceDynamic, PromptFile: "~/.codeagent/agents/docs.md"},
+		{Name: "review", Backend: "claude", Model: "opus", Source: config.AgentSourceModels},
+	}
+	if len(agents) != len(want) {
+		t.Fatalf("agents = %+v", agents)
+	}
+	for i := range want {
+		if agents[i] != want[i] {
+			t.Errorf("agents[%d] = %+v, want %+v", i, agents[i], want[i])
+		}
+	}
+
+	code, output = runWithArgs(t, "agents", "list")
+	if code != 0 || !strings.Contains(output, "NAME") || !strings.Contains(output, "Technical writer") {
+		t.Fatalf("table output (exit %d):\n%s", code, output)
+	}
+
+	config.ResetModelsConfigCacheForTest()
+	code, output = runWithArgs(t, "agents", "show", "review", "--json")
+	if code != 0 {
+		t.Fatalf("agents show exit = %d", code)
+	}
+	var detail agentDetail
+	if err := json.Unmarshal([]byte(output), &detail); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if detail.Backend != "claude" || detail.Model != "opus" || detail.Source != config.AgentSourceModels {
+		t.Errorf("detail = %+v", detail)
+	}
+	if detail.APIKey != "sk-a****7890" || detail.BackendMaxConcurrency != 2 {
+		t.Errorf("api key should be masked and backend limit resolved: %+v", detail)
+	}
+	if len(detail.Env) != 2 || detail.Env[0] != "LANG=C" || strings.Contains(detail.Env[1], "abcdef") || len(detail.EnvUnset) != 1 {
+		t.Errorf("env should be sorted with secrets masked: %+v", detail)
+	}
+
+	code, _ = runWithArgs(t, "agents", "show", "missing")
+	if code != 1 {
+		t.Errorf("unknown agent exit = %d, want 1", code)
+	}
+}
+
+func TestConfigValidateCommand(t *testing.T) {
+	writeDoctorHome(t, `{
+  "default_backend": "codex",
+  "agents": {
+    "develop": { "model": "gpt-x", "reasonig": "high" }
+  }
+}`)
+
+	code, output := runWithArgs(t, "config", "validate")
+	if code != 1 {
+		t.Fatalf("exit = %d, want 1\n%s", code, output)
+	}
+	if !strings.Contains(output, "models.json:4:36: agents.develop.reasonig: unknown field") ||
+		!strings.Contains(output, `did you mean "reasoning"?`) {
+		t.Fatalf("unexpected output:\n%s", output)
+	}
+
+	good := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(good, []byte(`{"default_backend": "codex", "agents": {"a": {"model": "m"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, output = runWithArgs(t, "config", "validate", good, "--json")
+	if code != 0 {
+		t.Fatalf("exit = %d\n%s", code, output)
+	}
+	var reports []configValidateReport
+	if err := json.Unmarshal([]byte(output), &reports); err != nil || len(reports) != 1 || !reports[0].Valid || len(reports[0].Issues) != 0 {
+		t.Fatalf("reports = %+v (%v)", reports, err)
+	}
+
+	code, _ = runWithArgs(t, "config", "validate", filepath.Join(t.TempDir(), "absent.json"))
+	if code != 1 {
+		t.Fatalf("missing file exit = %d, want 1", code)
+	}
+}
+
+func TestModelsConfigSchemaListsRegisteredBackends(t *testing.T) {
+	var schema struct {
+		Defs struct {
+			BackendName struct {
+				Enum []string `json:"enum"`
+			} `json:"backendName"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(config.ModelsConfigSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	var registered []string
+	for name := range backend.Registry() {
+		registered = append(registered, name)
+	}
+	enum := append([]string(nil), schema.Defs.BackendName.Enum...)
+	sort.Strings(enum)
+	sort.Strings(registered)
+	if strings.Join(enum, ",") != strings.Join(registered, ",") {
+		t.Fatalf("schema backends %v do not match registry %v", enum, registered)
+	}
+
+	code, output := runWithArgs(t, "config", "schema")
+	if code != 0 || !strings.Contains(output, `"$defs"`) {
+		t.Fatalf("config schema exit %d:\n%s", code, output)
+	}
+}
+
+func TestProfileFlag(t *testing.T) {
+	writeDoctorHome(t, `{
+  "agents": {
+    "base": { "backend": "codex", "model": "gpt-x", "reasoning": "medium" },
+    "develop": { "extends": "base", "description": "Dev" }
+  },
+  "profiles": { "thorough": { "model": "gpt-big", "reasoning": "high" } }
+}`)
+
+	code, output := runWithArgs(t, "agents", "show", "develop", "--profile", "thorough", "--json")
+	if code != 0 {
+		t.Fatalf("exit = %d", code)
+	}
+	var detail agentDetail
+	if err := json.Unmarshal([]byte(output), &detail); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if detail.Model != "gpt-big" || detail.Reasoning != "high" || detail.Extends != "base" || detail.Profile != "thorough" || detail.Description != "Dev" {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--profile", "thorough", "task"}
+	if _, err := parseArgs(); err == nil || !strings.Contains(err.Error(), "--profile requires --agent") {
+		t.Errorf("err = %v", err)
+	}
+
+	config.ResetModelsConfigCacheForTest()
+	code, _ = runWithArgs(t, "--profile", "missing", "--agent", "develop", "--dry-run", "task")
+	if code != 1 {
+		t.Errorf("unknown profile exit = %d, want 1", code)
+	}
+	code, output = runWithArgs(t, "--agent", "develop", "--profile", "thorough", "--dry-run", "task")
+	if code != 0 || !strings.Contains(output, "Model: gpt-big") || !strings.Contains(output, "Reasoning: high") {
+		t.Errorf("dry run (exit %d):\n%s", code, output)
+	}
+}
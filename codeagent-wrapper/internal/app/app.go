@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	config "codeagent-wrapper/internal/config"
 )
 
 var version = "dev"
@@ -105,6 +107,12 @@ func runCleanupMode() int {
 			fmt.Printf("  - %s\n", f)
 		}
 	}
+	if stats.Archived > 0 {
+		fmt.Printf("Files archived: %d\n", stats.Archived)
+		for _, f := range stats.ArchivedFiles {
+			fmt.Printf("  - %s\n", f)
+		}
+	}
 	if stats.Errors > 0 {
 		fmt.Printf("Deletion errors: %d\n", stats.Errors)
 	}
@@ -147,6 +155,11 @@ func readAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 			filepath.Clean(filepath.Join(home, ".claude")),
 			filepath.Clean(filepath.Join(home, ".codeagent", "agents")),
 		}
+		errOutside := fmt.Errorf("prompt file must be under ~/.claude or ~/.codeagent/agents")
+		if projectDirs := config.ProjectPromptDirs(); len(projectDirs) > 0 {
+			allowedDirs = append(allowedDirs, projectDirs...)
+			errOutside = fmt.Errorf("prompt file must be under ~/.claude, ~/.codeagent/agents or %s", projectDirs[0])
+		}
 		for i := range allowedDirs {
 			allowedAbs, err := filepath.Abs(allowedDirs[i])
 			if err == nil {
@@ -180,7 +193,7 @@ func readAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 			}
 			if !withinAllowed {
 				logWarn(fmt.Sprintf("Refusing to read prompt file outside allowed dirs (%s): %s", strings.Join(allowedDirs, ", "), absPath))
-				return "", fmt.Errorf("prompt file must be under ~/.claude or ~/.codeagent/agents")
+				return "", errOutside
 			}
 
 			resolvedPath, errPath := filepath.EvalSymlinks(absPath)
@@ -204,7 +217,7 @@ func readAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 					}
 					if !withinResolved {
 						logWarn(fmt.Sprintf("Refusing to read prompt file outside allowed dirs (%s) (resolved): %s", strings.Join(resolvedAllowed, ", "), resolvedPath))
-						return "", fmt.Errorf("prompt file must be under ~/.claude or ~/.codeagent/agents")
+						return "", errOutside
 					}
 				}
 			}
@@ -0,0 +1,68 @@
+package wrapper
+
+import (
+	"fmt"
+
+	cache "codeagent-wrapper/internal/cache"
+	config "codeagent-wrapper/internal/config"
+	utils "codeagent-wrapper/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+func newCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "cache",
+		Short:         "Inspect and clear the result cache (~/.codeagent/cache)",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newCacheStatsCommand(), newCacheClearCommand())
+	return cmd
+}
+
+func newCacheStatsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "stats",
+		Short:         "Show the number and total size of cached results",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, size, err := cache.Stats()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			ttl := "none"
+			if d := config.ResolveCacheTTL(); d > 0 {
+				ttl = d.String()
+			}
+			maxSize := "none"
+			if n := config.ResolveCacheMaxSize(); n > 0 {
+				maxSize = utils.FormatByteSize(n)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%d cached results, %s (ttl %s, max size %s)\n", entries, utils.FormatByteSize(size), ttl, maxSize)
+			return nil
+		},
+	}
+}
+
+func newCacheClearCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "clear",
+		Short:         "Remove every cached result",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, freed, err := cache.Clear()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cached results (%s)\n", removed, utils.FormatByteSize(freed))
+			return nil
+		},
+	}
+}
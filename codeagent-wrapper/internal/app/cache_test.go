@@ -0,0 +1,28 @@
+package wrapper
+
+import (
+	"strings"
+	"testing"
+
+	cache "codeagent-wrapper/internal/cache"
+)
+
+func TestCacheCommands(t *testing.T) {
+	writeDoctorHome(t, "")
+	key := cache.Key{Prompt: "explain", Backend: "claude", Tree: "abc"}.Hash()
+	if err := cache.Put(key, []byte(`{"message":"done"}`), cache.Limits{}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runWithArgs(t, "cache", "stats")
+	if code != 0 || !strings.HasPrefix(out, "1 cached results, ") || !strings.Contains(out, "ttl 24h0m0s") {
+		t.Fatalf("cache stats: code=%d out=%q", code, out)
+	}
+	code, out = runWithArgs(t, "cache", "clear")
+	if code != 0 || !strings.HasPrefix(out, "Removed 1 cached results") {
+		t.Fatalf("cache clear: code=%d out=%q", code, out)
+	}
+	if _, _, ok := cache.Get(key, 0); ok {
+		t.Fatal("entry survived cache clear")
+	}
+}
@@ -0,0 +1,35 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParallelModeReplaysCassettes(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, `{"default_backend": "codex"}`)
+	dir := t.TempDir()
+	for _, id := range []string{"plan", "build"} {
+		cassette := fmt.Sprintf(`{"version":1,"task_id":%q,"prompt_hash":"","backend":"codex","command":"codex","args":[],"stdout":[
+  "{\"type\":\"thread.started\",\"thread_id\":\"thread-%s\"}",
+  "{\"type\":\"item.completed\",\"item\":{\"type\":\"agent_message\",\"text\":\"replayed %s\"}}"
+],"exit_code":0}`, id, id, id)
+		if err := os.WriteFile(filepath.Join(dir, id+".json"), []byte(cassette), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdinReader = bytes.NewReader([]byte("---TASK---\nid: plan\n---CONTENT---\nplan it\n---TASK---\nid: build\ndependencies: plan\n---CONTENT---\nbuild it\n"))
+	code, output := runWithArgs(t, "--parallel", "--full-output", "--replay", dir)
+	if code != 0 || !strings.Contains(output, "replayed plan") || !strings.Contains(output, "replayed build") {
+		t.Fatalf("replay: code=%d\n%s", code, output)
+	}
+
+	if code, _ := runWithArgs(t, "--record", dir, "--replay", dir, "task"); code != 1 {
+		t.Fatalf("--record with --replay: code=%d", code)
+	}
+}
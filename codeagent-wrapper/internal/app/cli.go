@@ -9,6 +9,9 @@ import (
 	"strings"
 
 	config "codeagent-wrapper/internal/config"
+	resources "codeagent-wrapper/internal/resources"
+	session "codeagent-wrapper/internal/session"
+	tracing "codeagent-wrapper/internal/tracing"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -28,14 +31,25 @@ type cliOptions struct {
 	Model           string
 	ReasoningEffort string
 	Agent           string
+	Profile         string
 	PromptFile      string
 	Output          string
 	Skills          string
 	SkipPermissions bool
 	Worktree        bool
+	TranscriptDir   string
+	MetricsFile     string
+	MetricsListen   string
+	Sandbox         bool
+	SandboxWrite    []string
+	Cache           bool
+	Record          string
+	Replay          string
 
 	Parallel   bool
 	FullOutput bool
+	DryRun     bool
+	Graph      string
 
 	Cleanup    bool
 	Version    bool
@@ -87,13 +101,35 @@ func newRootCommand() *cobra.Command {
 				return exitError{code: code}
 			}
 
-			exitCode := runWithLoggerAndCleanup(func() int {
+			exitCode := runWithLoggerAndCleanup(func() (code int) {
+				if err := activateProject(projectWorkDir(args, opts.Parallel), true); err != nil {
+					logError(err.Error())
+					return 1
+				}
+
 				v, err := config.NewViper(opts.ConfigFile)
 				if err != nil {
 					logError(err.Error())
 					return 1
 				}
 
+				if cmd.Flags().Changed("graph") && !opts.DryRun {
+					logError("--graph requires --dry-run")
+					return 1
+				}
+				if err := applyProfileOption(cmd, opts, v); err != nil {
+					logError(err.Error())
+					return 1
+				}
+				if !opts.DryRun {
+					rm, err := startMetrics(cmd, opts, v)
+					if err != nil {
+						logError(err.Error())
+						return 1
+					}
+					defer rm.finish(&code)
+				}
+
 				if opts.Parallel {
 					return runParallelMode(cmd, args, opts, v, name)
 				}
@@ -106,6 +142,9 @@ func newRootCommand() *cobra.Command {
 					return 1
 				}
 				logInfo(fmt.Sprintf("Parsed args: mode=%s, task_len=%d, backend=%s", cfg.Mode, len(cfg.Task), cfg.Backend))
+				if opts.DryRun {
+					return runDryRunSingle(cfg)
+				}
 				return runSingleMode(cfg, name)
 			})
 
@@ -118,7 +157,7 @@ func newRootCommand() *cobra.Command {
 	cmd.CompletionOptions.DisableDefaultCmd = true
 
 	addRootFlags(cmd.Flags(), opts)
-	cmd.AddCommand(newVersionCommand(name), newCleanupCommand())
+	cmd.AddCommand(newVersionCommand(name), newCleanupCommand(), newDoctorCommand(name), newAgentsCommand(), newConfigCommand(), newProjectCommand(), newSessionsCommand(), newTranscriptCommand(), newLogsCommand(), newCacheCommand(), newLaunchCommand(), newMockCommand())
 
 	return cmd
 }
@@ -130,11 +169,14 @@ func addRootFlags(fs *pflag.FlagSet, opts *cliOptions) {
 
 	fs.BoolVar(&opts.Parallel, "parallel", false, "Run tasks in parallel (config from stdin)")
 	fs.BoolVar(&opts.FullOutput, "full-output", false, "Parallel mode: include full task output (legacy)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Print resolved backend, model, prompt, skills, env and command without running anything")
+	fs.StringVar(&opts.Graph, "graph", "mermaid", "Dry-run parallel mode: dependency graph format (mermaid, dot)")
 
-	fs.StringVar(&opts.Backend, "backend", defaultBackendName, "Backend to use (codex, claude, gemini, opencode)")
+	fs.StringVar(&opts.Backend, "backend", defaultBackendName, "Backend to use (codex, claude, gemini, opencode, mock)")
 	fs.StringVar(&opts.Model, "model", "", "Model override")
 	fs.StringVar(&opts.ReasoningEffort, "reasoning-effort", "", "Reasoning effort (backend-specific)")
 	fs.StringVar(&opts.Agent, "agent", "", "Agent preset name (from ~/.codeagent/models.json)")
+	fs.StringVar(&opts.Profile, "profile", "", "Profile from models.json applied on top of the selected agent(s)")
 	fs.StringVar(&opts.PromptFile, "prompt-file", "", "Prompt file path")
 	fs.StringVar(&opts.Output, "output", "", "Write structured JSON output to file")
 	fs.StringVar(&opts.Skills, "skills", "", "Comma-separated skill names for spec injection")
@@ -142,6 +184,14 @@ func addRootFlags(fs *pflag.FlagSet, opts *cliOptions) {
 	fs.BoolVar(&opts.SkipPermissions, "skip-permissions", false, "Skip permissions prompts (also via CODEAGENT_SKIP_PERMISSIONS)")
 	fs.BoolVar(&opts.SkipPermissions, "dangerously-skip-permissions", false, "Alias for --skip-permissions")
 	fs.BoolVar(&opts.Worktree, "worktree", false, "Execute in a new git worktree (auto-generates task ID)")
+	fs.StringVar(&opts.TranscriptDir, "transcript-dir", "", "Write a normalized JSONL transcript of each task to this directory")
+	fs.StringVar(&opts.MetricsFile, "metrics-file", "", "Merge Prometheus metrics into this node_exporter textfile (*.prom) after the run")
+	fs.BoolVar(&opts.Sandbox, "sandbox", false, "Linux: confine the backend with Landlock to writing its workdir, temp dirs and state dirs")
+	fs.StringArrayVar(&opts.SandboxWrite, "sandbox-write", nil, "With --sandbox: also allow writes beneath this path (repeatable)")
+	fs.BoolVar(&opts.Cache, "cache", false, "Reuse the cached result of an identical task against an unchanged git tree (never when resuming)")
+	fs.StringVar(&opts.Record, "record", "", "Save each backend's args, stdin, output and exit code as a cassette in this directory")
+	fs.StringVar(&opts.Replay, "replay", "", "Serve backend output from cassettes in this directory instead of running backends")
+	fs.StringVar(&opts.MetricsListen, "metrics-listen", "", "Serve Prometheus metrics on this address (e.g. 127.0.0.1:9464) while running")
 }
 
 func newVersionCommand(name string) *cobra.Command {
@@ -204,7 +254,13 @@ func runWithLoggerAndCleanup(fn func() int) (exitCode int) {
 			}
 		}
 	}()
+	defer finishTracing(startTracing(), &exitCode)
 	defer runCleanupHook()
+	defer func() {
+		if err := resources.Release(); err != nil {
+			logWarn(err.Error())
+		}
+	}()
 
 	// Clean up stale logs from previous runs.
 	scheduleStartupCleanup()
@@ -241,6 +297,12 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 	outputPath := ""
 	yolo := false
 
+	var recorded *session.Entry
+	if len(args) > 1 && args[0] == "resume" {
+		recorded = lookupRecordedSession(args[1])
+	}
+
+	agentFromSession := false
 	if cmd.Flags().Changed("agent") {
 		agentName = strings.TrimSpace(opts.Agent)
 		if agentName == "" {
@@ -255,19 +317,31 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 			if err := config.ValidateAgentName(agentName); err != nil {
 				return nil, fmt.Errorf("--agent flag invalid value: %w", err)
 			}
+		} else if recorded != nil && recorded.Agent != "" && config.ValidateAgentName(recorded.Agent) == nil {
+			agentName = recorded.Agent
+			agentFromSession = true
 		}
 	}
 
+	if cmd.Flags().Changed("profile") && agentName == "" {
+		return nil, fmt.Errorf("--profile requires --agent")
+	}
+
 	var resolvedBackend, resolvedModel, resolvedPromptFile, resolvedReasoning string
 	var resolvedAllowedTools, resolvedDisallowedTools []string
 	if agentName != "" {
 		var resolvedYolo bool
 		var err error
 		resolvedBackend, resolvedModel, resolvedPromptFile, resolvedReasoning, _, _, resolvedYolo, resolvedAllowedTools, resolvedDisallowedTools, err = config.ResolveAgentConfig(agentName)
-		if err != nil {
+		switch {
+		case err != nil && agentFromSession:
+			logWarn(fmt.Sprintf("Ignoring agent %q recorded for session %s: %s", agentName, recorded.SessionID, strings.SplitN(err.Error(), "\n\n", 2)[0]))
+			agentName = ""
+		case err != nil:
 			return nil, fmt.Errorf("failed to resolve agent %q: %w", agentName, err)
+		default:
+			yolo = resolvedYolo
 		}
-		yolo = resolvedYolo
 	}
 
 	if cmd.Flags().Changed("prompt-file") {
@@ -277,6 +351,8 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		}
 		promptFileExplicit = true
 	} else if val := strings.TrimSpace(v.GetString("prompt-file")); val != "" {
+		// An untrusted project cannot set prompt-file (see
+		// config.sensitiveConfigKeys), so this is the user's own choice.
 		promptFile = val
 		promptFileExplicit = true
 	} else {
@@ -292,6 +368,19 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		outputPath = val
 	}
 
+	transcriptDir, err := resolveTranscriptDir(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+	recordDir, replayDir, err := resolveCassetteDirs(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+	sandboxPolicy, err := resolveSandbox(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+
 	agentFlagChanged := cmd.Flags().Changed("agent")
 	backendFlagChanged := cmd.Flags().Changed("backend")
 	if backendFlagChanged {
@@ -312,6 +401,12 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		}
 	}
 
+	if recorded != nil && recorded.Backend != "" {
+		if !backendFlagChanged {
+			backendName = recorded.Backend
+		}
+	}
+
 	modelFlagChanged := cmd.Flags().Changed("model")
 	if modelFlagChanged {
 		model = strings.TrimSpace(opts.Model)
@@ -328,6 +423,9 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 	case !modelFlagChanged:
 		model = strings.TrimSpace(v.GetString("model"))
 	}
+	if recorded != nil && !modelFlagChanged && recorded.Model != "" && backendName == recorded.Backend {
+		model = recorded.Model
+	}
 
 	if cmd.Flags().Changed("reasoning-effort") {
 		reasoningEffort = strings.TrimSpace(opts.ReasoningEffort)
@@ -378,6 +476,11 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		DisallowedTools:    resolvedDisallowedTools,
 		Skills:             skills,
 		Worktree:           opts.Worktree,
+		TranscriptDir:      transcriptDir,
+		Sandbox:            sandboxPolicy,
+		Cache:              resolveCache(cmd, opts, v),
+		RecordDir:          recordDir,
+		ReplayDir:          replayDir,
 	}
 
 	if args[0] == "resume" {
@@ -396,6 +499,20 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 				return nil, fmt.Errorf("invalid workdir: '-' is not a valid directory path")
 			}
 			cfg.WorkDir = args[3]
+		} else if recorded != nil {
+			if dir := recorded.ResumeDir(); dir != "" {
+				cfg.WorkDir = dir
+			}
+		}
+		// An explicit --backend that does not own the session replays its
+		// transcript into a new session on that backend.
+		if backendFlagChanged {
+			cfg.HandoffBackend = handoffSource(cfg.SessionID, cfg.Backend, recorded)
+		}
+		if cfg.HandoffBackend != "" {
+			logInfo(fmt.Sprintf("Session %s belongs to %s; handing it off to %s", cfg.SessionID, cfg.HandoffBackend, cfg.Backend))
+		} else if recorded != nil {
+			logInfo(fmt.Sprintf("Resuming recorded session %s: backend=%s, agent=%s, workdir=%s", recorded.SessionID, cfg.Backend, valueOr(cfg.Agent, "-"), cfg.WorkDir))
 		}
 	} else {
 		cfg.Mode = "new"
@@ -412,6 +529,75 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 	return cfg, nil
 }
 
+// resolveTranscriptDir returns --transcript-dir, falling back to the
+// transcript-dir config key (CODEAGENT_TRANSCRIPT_DIR). Empty disables
+// transcripts.
+func resolveTranscriptDir(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (string, error) {
+	if cmd.Flags().Changed("transcript-dir") {
+		dir := strings.TrimSpace(opts.TranscriptDir)
+		if dir == "" {
+			return "", fmt.Errorf("--transcript-dir flag requires a value")
+		}
+		return dir, nil
+	}
+	return strings.TrimSpace(v.GetString("transcript-dir")), nil
+}
+
+// resolveCassetteDirs returns --record and --replay, falling back to the
+// record and replay config keys (CODEAGENT_RECORD, CODEAGENT_REPLAY).
+func resolveCassetteDirs(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (record, replay string, err error) {
+	resolve := func(flag, value string) (string, error) {
+		if cmd.Flags().Changed(flag) {
+			dir := strings.TrimSpace(value)
+			if dir == "" {
+				return "", fmt.Errorf("--%s flag requires a value", flag)
+			}
+			return dir, nil
+		}
+		return strings.TrimSpace(v.GetString(flag)), nil
+	}
+	if record, err = resolve("record", opts.Record); err != nil {
+		return "", "", err
+	}
+	if replay, err = resolve("replay", opts.Replay); err != nil {
+		return "", "", err
+	}
+	if record != "" && replay != "" {
+		return "", "", fmt.Errorf("--record and --replay cannot be combined")
+	}
+	return record, replay, nil
+}
+
+// resolveCache returns --cache, falling back to the cache config key
+// (CODEAGENT_CACHE).
+func resolveCache(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) bool {
+	if cmd.Flags().Changed("cache") {
+		return opts.Cache
+	}
+	return v.GetBool("cache")
+}
+
+// applyProfileOption activates --profile (or CODEAGENT_PROFILE) for every
+// agent resolved in this run.
+func applyProfileOption(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) error {
+	profile := ""
+	if cmd.Flags().Changed("profile") {
+		profile = strings.TrimSpace(opts.Profile)
+		if profile == "" {
+			return fmt.Errorf("--profile flag requires a value")
+		}
+	} else {
+		profile = strings.TrimSpace(v.GetString("profile"))
+	}
+	if profile == "" {
+		return nil
+	}
+	if err := config.SetAgentProfile(profile); err != nil {
+		return fmt.Errorf("--profile: %s", strings.SplitN(err.Error(), "\n\n", 2)[0])
+	}
+	return nil
+}
+
 func lastFlagIndex(argv []string, name string) int {
 	if len(argv) == 0 {
 		return -1
@@ -444,7 +630,7 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 	}
 
 	if cmd.Flags().Changed("agent") || cmd.Flags().Changed("prompt-file") || cmd.Flags().Changed("reasoning-effort") || cmd.Flags().Changed("skills") {
-		fmt.Fprintln(os.Stderr, "ERROR: --parallel reads its task configuration from stdin; only --backend, --model, --output, --full-output and --skip-permissions are allowed.")
+		fmt.Fprintln(os.Stderr, "ERROR: --parallel reads its task configuration from stdin; only --backend, --model, --profile, --output, --full-output, --transcript-dir, --metrics-file, --metrics-listen, --sandbox, --sandbox-write, --cache, --record, --replay, --skip-permissions, --dry-run and --graph are allowed.")
 		return 1
 	}
 
@@ -486,6 +672,24 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 		outputPath = val
 	}
 
+	transcriptDir, err := resolveTranscriptDir(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	recordDir, replayDir, err := resolveCassetteDirs(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	sandboxPolicy, err := resolveSandbox(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	useCache := resolveCache(cmd, opts, v)
+
 	skipChanged := cmd.Flags().Changed("skip-permissions") || cmd.Flags().Changed("dangerously-skip-permissions")
 	skipPermissions := false
 	if skipChanged {
@@ -523,6 +727,11 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 			cfg.Tasks[i].Model = model
 		}
 		cfg.Tasks[i].SkipPermissions = cfg.Tasks[i].SkipPermissions || skipPermissions
+		cfg.Tasks[i].TranscriptDir = transcriptDir
+		cfg.Tasks[i].Sandbox = sandboxPolicy
+		cfg.Tasks[i].Cache = useCache
+		cfg.Tasks[i].RecordDir = recordDir
+		cfg.Tasks[i].ReplayDir = replayDir
 	}
 
 	timeoutSec := resolveTimeout()
@@ -532,6 +741,15 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 		return 1
 	}
 
+	if opts.DryRun {
+		return runDryRunParallel(layers, opts.Graph)
+	}
+
+	tracing.RunSpan().SetAttributes(
+		tracing.String("codeagent.mode", "parallel"),
+		tracing.Int("codeagent.tasks", len(cfg.Tasks)),
+		tracing.Int("codeagent.layers", len(layers)),
+	)
 	results := executeConcurrent(layers, timeoutSec)
 
 	for i := range results {
@@ -561,7 +779,7 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 			exitCode = res.ExitCode
 		}
 	}
-	return exitCode
+	return finishRun(results, exitCode)
 }
 
 func runSingleMode(cfg *Config, name string) int {
@@ -582,6 +800,7 @@ func runSingleMode(cfg *Config, name string) int {
 		buildCodexArgsFn = backend.BuildArgs
 	}
 	logInfo(fmt.Sprintf("Selected backend: %s", backend.Name()))
+	tracing.RunSpan().SetAttributes(tracing.String("codeagent.mode", cfg.Mode), tracing.String("codeagent.backend", cfg.Backend))
 
 	timeoutSec := resolveTimeout()
 	logInfo(fmt.Sprintf("Timeout: %ds", timeoutSec))
@@ -617,13 +836,20 @@ func runSingleMode(cfg *Config, name string) int {
 		}
 	}
 
-	if strings.TrimSpace(cfg.PromptFile) != "" {
-		prompt, err := readAgentPromptFile(cfg.PromptFile, cfg.PromptFileExplicit)
+	userTask := taskText
+	hasPrompt := strings.TrimSpace(cfg.PromptFile) != ""
+	var agentPrompt string
+	if hasPrompt {
+		prompt, err := renderAgentPromptFile(cfg.PromptFile, cfg.PromptFileExplicit, PromptVars{
+			WorkDir: cfg.WorkDir,
+			Backend: cfg.Backend,
+			Model:   cfg.Model,
+		})
 		if err != nil {
-			logError("Failed to read prompt file: " + err.Error())
+			logError("Failed to load prompt file: " + err.Error())
 			return 1
 		}
-		taskText = wrapTaskWithAgentPrompt(prompt, taskText)
+		agentPrompt = prompt
 	}
 
 	// Resolve skills: explicit > auto-detect from workdir
@@ -631,11 +857,22 @@ func runSingleMode(cfg *Config, name string) int {
 	if len(skills) == 0 {
 		skills = detectProjectSkills(cfg.WorkDir)
 	}
-	if len(skills) > 0 {
-		if content := resolveSkillContent(skills, 0); content != "" {
-			taskText = taskText + "\n\n# Domain Best Practices\n\n" + content
+	// injectContext adds the prompt file and skills to a task text. As in
+	// parallel mode, pre_task hooks see the text before this and a prompt
+	// they rewrite goes through it again.
+	injectContext := func(task string) string {
+		text := task
+		if hasPrompt {
+			text = wrapTaskWithAgentPrompt(agentPrompt, text)
 		}
+		if len(skills) > 0 {
+			if content := resolveSkillContent(cfg.WorkDir, task, skills, 0); content != "" {
+				text = text + "\n\n# Domain Best Practices\n\n" + content
+			}
+		}
+		return text
 	}
+	taskText = injectContext(userTask)
 
 	useStdin := cfg.ExplicitStdin || shouldUseStdin(taskText, piped)
 
@@ -699,7 +936,7 @@ func runSingleMode(cfg *Config, name string) int {
 	logInfo(fmt.Sprintf("%s running...", cfg.Backend))
 
 	taskSpec := TaskSpec{
-		Task:            taskText,
+		Task:            userTask,
 		WorkDir:         cfg.WorkDir,
 		Mode:            cfg.Mode,
 		SessionID:       cfg.SessionID,
@@ -712,9 +949,26 @@ func runSingleMode(cfg *Config, name string) int {
 		AllowedTools:    cfg.AllowedTools,
 		DisallowedTools: cfg.DisallowedTools,
 		UseStdin:        useStdin,
+		HandoffBackend:  cfg.HandoffBackend,
+		TranscriptDir:   cfg.TranscriptDir,
+		Sandbox:         cfg.Sandbox,
+		Cache:           cfg.Cache,
+		RecordDir:       cfg.RecordDir,
+		ReplayDir:       cfg.ReplayDir,
 	}
 
-	result := runTaskFn(taskSpec, false, cfg.Timeout)
+	result := runTaskHooks(taskSpec, func(ts TaskSpec) TaskResult {
+		if ts.Task == userTask {
+			ts.Task = taskText
+		} else {
+			ts.Task = injectContext(ts.Task)
+			ts.UseStdin = ts.UseStdin || shouldUseStdin(ts.Task, piped)
+		}
+		return runTaskFn(ts, false, cfg.Timeout)
+	})
+	if result.TranscriptPath != "" {
+		logInfo("Transcript written to " + result.TranscriptPath)
+	}
 
 	exitCode := result.ExitCode
 	if exitCode == 0 && strings.TrimSpace(result.Message) == "" {
@@ -735,17 +989,38 @@ func runSingleMode(cfg *Config, name string) int {
 		// Surface any parsed backend output even on non-zero exit to avoid "(no output)" in tool runners.
 		if strings.TrimSpace(result.Message) != "" {
 			fmt.Println(result.Message)
-			if result.SessionID != "" {
-				fmt.Printf("\n---\nSESSION_ID: %s\n", result.SessionID)
-			}
+			printSessionFooter(result)
 		}
-		return exitCode
+		return finishRun([]TaskResult{result}, exitCode)
 	}
 
 	fmt.Println(result.Message)
-	if result.SessionID != "" {
-		fmt.Printf("\n---\nSESSION_ID: %s\n", result.SessionID)
+	printSessionFooter(result)
+
+	return finishRun([]TaskResult{result}, 0)
+}
+
+// finishRun runs the post_run hooks with the same payload --output writes.
+// A failing hook with on_failure "fail" turns a successful run into exit
+// code 1.
+func finishRun(results []TaskResult, exitCode int) int {
+	if err := runPostRunHooks(outputPayload{Results: results, Summary: summarizeResults(results)}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		if exitCode == 0 {
+			return 1
+		}
 	}
+	return exitCode
+}
 
-	return 0
+// printSessionFooter prints the session ID to resume with and, for a
+// handoff, the session it continues.
+func printSessionFooter(result TaskResult) {
+	if result.SessionID == "" {
+		return
+	}
+	fmt.Printf("\n---\nSESSION_ID: %s\n", result.SessionID)
+	if result.HandoffFrom != "" {
+		fmt.Printf("HANDOFF_FROM: %s (%s)\n", result.HandoffFrom, result.HandoffBackend)
+	}
 }
@@ -0,0 +1,155 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+type configValidateReport struct {
+	Path   string                   `json:"path"`
+	Valid  bool                     `json:"valid"`
+	Issues []config.ValidationIssue `json:"issues"`
+}
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "config",
+		Short:         "Validate models.json or print its JSON Schema",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newConfigValidateCommand(), newConfigSchemaCommand())
+	return cmd
+}
+
+func newConfigValidateCommand() *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:           "validate [path]",
+		Short:         "Check models.json against the schema (default: ~/.codeagent/models.json and the project's)",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := activateProject(defaultWorkdir, false); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+
+			var paths []string
+			if len(args) == 1 {
+				paths = []string{args[0]}
+			} else {
+				resolved, err := config.ModelsConfigPath()
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+					return exitError{code: 1}
+				}
+				project := config.ActiveProject()
+				if _, err := os.Stat(resolved); err == nil || project == nil {
+					paths = append(paths, resolved)
+				}
+				if project != nil {
+					if _, err := os.Stat(project.ModelsPath()); err == nil {
+						paths = append(paths, project.ModelsPath())
+					}
+				}
+			}
+
+			reports := make([]configValidateReport, 0, len(paths))
+			valid := true
+			for _, path := range paths {
+				report, err := validateModelsFile(path)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+					return exitError{code: 1}
+				}
+				valid = valid && report.Valid
+				reports = append(reports, report)
+			}
+
+			if jsonOutput {
+				if err := printJSON(cmd, reports); err != nil {
+					return err
+				}
+			} else {
+				problems := 0
+				for _, report := range reports {
+					if report.Valid {
+						fmt.Fprintf(cmd.OutOrStdout(), "%s: OK\n", report.Path)
+						continue
+					}
+					for _, issue := range report.Issues {
+						fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", report.Path, issue)
+					}
+					problems += len(report.Issues)
+				}
+				if problems > 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "\n%d problem(s) found\n", problems)
+				}
+			}
+			if !valid {
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print issues as JSON")
+	return cmd
+}
+
+func validateModelsFile(path string) (configValidateReport, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- user-supplied path to a config file they want checked
+	if err != nil {
+		return configValidateReport{}, err
+	}
+
+	// Project models.json resolves relative prompt files against its own
+	// .codeagent directory, as loading does.
+	baseDir := ""
+	if project := config.ActiveProject(); project != nil && sameFile(path, project.ModelsPath()) {
+		baseDir = project.Dir
+	}
+	issues := config.ValidateModelsConfig(data, config.ValidateOptions{
+		CheckPromptFile: func(promptFile string) error {
+			if baseDir != "" && !filepath.IsAbs(promptFile) && !strings.HasPrefix(promptFile, "~") {
+				promptFile = filepath.Join(baseDir, promptFile)
+			}
+			content, err := readAgentPromptFile(promptFile, false)
+			if err != nil {
+				return err
+			}
+			return checkPromptTemplate(promptFile, content)
+		},
+	})
+	if issues == nil {
+		issues = []config.ValidationIssue{}
+	}
+	return configValidateReport{Path: path, Valid: len(issues) == 0, Issues: issues}, nil
+}
+
+func sameFile(a, b string) bool {
+	ia, errA := os.Stat(a)
+	ib, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ia, ib)
+}
+
+func newConfigSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "schema",
+		Short:         "Print the JSON Schema for models.json",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := cmd.OutOrStdout().Write(config.ModelsConfigSchema())
+			return err
+		},
+	}
+}
@@ -0,0 +1,351 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	backend "codeagent-wrapper/internal/backend"
+	config "codeagent-wrapper/internal/config"
+	executor "codeagent-wrapper/internal/executor"
+	ilogger "codeagent-wrapper/internal/logger"
+	"codeagent-wrapper/internal/worktree"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+)
+
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+
+	doctorVersionTimeout = 5 * time.Second
+)
+
+// Hook points for doctor checks (tests can override).
+var (
+	doctorLookPathFn      = exec.LookPath
+	doctorVersionFn       = backendVersion
+	doctorBackendsFn      = backend.Registry
+	doctorListWorktreesFn = worktree.ListTaskWorktrees
+	doctorOrphanedLogsFn  = ilogger.OrphanedLogs
+)
+
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type doctorSummary struct {
+	Pass int `json:"pass"`
+	Warn int `json:"warn"`
+	Fail int `json:"fail"`
+}
+
+type doctorReport struct {
+	Checks  []doctorCheck `json:"checks"`
+	Summary doctorSummary `json:"summary"`
+}
+
+func (r *doctorReport) add(name, status, message, hint string) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Message: message, Hint: hint})
+	switch status {
+	case doctorPass:
+		r.Summary.Pass++
+	case doctorWarn:
+		r.Summary.Warn++
+	default:
+		r.Summary.Fail++
+	}
+}
+
+func newDoctorCommand(name string) *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:           "doctor [workdir]",
+		Short:         "Diagnose backends, models.json, prompt files, temp dir, skills, worktrees and logs",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workDir := defaultWorkdir
+			if len(args) == 1 {
+				workDir = args[0]
+			}
+			report := runDoctor(workDir, name)
+			if jsonOutput {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			} else {
+				fmt.Print(renderDoctorReport(report))
+			}
+			if report.Summary.Fail > 0 {
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the report as JSON")
+	return cmd
+}
+
+func runDoctor(workDir, name string) *doctorReport {
+	report := &doctorReport{}
+	checkBackends(report)
+	checkProject(report, workDir)
+	checkModelsConfig(report)
+	checkTempDir(report)
+	checkSkills(report, workDir)
+	checkWorktrees(report, workDir)
+	checkLogs(report, name)
+	return report
+}
+
+func checkBackends(report *doctorReport) {
+	registry := doctorBackendsFn()
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		if n == (backend.MockBackend{}).Name() {
+			continue // runs the wrapper itself; nothing to install
+		}
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	found := 0
+	for _, n := range names {
+		command := registry[n].Command()
+		path, err := doctorLookPathFn(command)
+		if err != nil {
+			report.add("backend "+n, doctorWarn, fmt.Sprintf("%s not found on PATH", command),
+				fmt.Sprintf("install %s or add it to PATH; tasks using this backend exit with 127 (command not found)", command))
+			continue
+		}
+		found++
+		ver, err := doctorVersionFn(path)
+		if err != nil {
+			report.add("backend "+n, doctorWarn, fmt.Sprintf("%s found but `--version` failed: %v", path, err),
+				fmt.Sprintf("run `%s --version` manually to check the installation", command))
+			continue
+		}
+		report.add("backend "+n, doctorPass, fmt.Sprintf("%s (%s)", path, ver), "")
+	}
+	if found == 0 {
+		report.add("backends", doctorFail, "no backend command found on PATH",
+			"install at least one of: "+strings.Join(names, ", "))
+	}
+}
+
+func backendVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0])
+	if line == "" {
+		line = "unknown version"
+	}
+	return line, nil
+}
+
+func checkProject(report *doctorReport, workDir string) {
+	project, err := config.DiscoverProject(workDir)
+	if err != nil {
+		report.add("project", doctorWarn, err.Error(), "")
+		return
+	}
+	config.SetActiveProject(project)
+	switch {
+	case project == nil:
+		report.add("project", doctorPass, "no .codeagent directory above "+workDir, "")
+	case project.Restricted():
+		report.add("project", doctorWarn, fmt.Sprintf("%s is %s; ignoring %s", project.Dir, projectTrustStatus(project), strings.Join(project.Sensitive, ", ")),
+			"review it, then run `"+currentWrapperName()+" project trust "+project.Root+"`")
+	default:
+		report.add("project", doctorPass, fmt.Sprintf("%s (%s)", project.Dir, projectTrustStatus(project)), "")
+	}
+}
+
+func checkModelsConfig(report *doctorReport) {
+	path, err := config.ModelsConfigPath()
+	if err != nil {
+		report.add("models.json", doctorFail, err.Error(), "make sure $HOME is set")
+		return
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		report.add("models.json", doctorWarn, path+" not found; agent presets are unavailable",
+			"create it to use --agent presets (see README: Agent Presets)")
+		checkDynamicAgents(report)
+		return
+	}
+
+	cfg, err := config.LoadModelsConfig()
+	if err != nil {
+		msg := strings.SplitN(err.Error(), "\n\n", 2)[0]
+		report.add("models.json", doctorFail, msg, "fix the JSON syntax or the reported field")
+		return
+	}
+	report.add("models.json", doctorPass, fmt.Sprintf("%s (%d agents)", path, len(cfg.Agents)), "")
+
+	agents := make([]string, 0, len(cfg.Agents))
+	for n := range cfg.Agents {
+		agents = append(agents, n)
+	}
+	sort.Strings(agents)
+	for _, n := range agents {
+		checkAgent(report, n)
+	}
+	checkDynamicAgents(report)
+}
+
+func checkDynamicAgents(report *doctorReport) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(home, ".codeagent", "agents", "*.md"))
+	for _, f := range files {
+		checkAgent(report, strings.TrimSuffix(filepath.Base(f), ".md"))
+	}
+}
+
+func checkAgent(report *doctorReport, name string) {
+	label := "agent " + name
+	if err := config.ValidateAgentName(name); err != nil {
+		report.add(label, doctorFail, err.Error(), "agent names may only contain letters, digits, '-' and '_'")
+		return
+	}
+	backendName, model, promptFile, _, _, _, _, _, _, err := config.ResolveAgentConfig(name)
+	if err != nil {
+		report.add(label, doctorFail, strings.SplitN(err.Error(), "\n\n", 2)[0], "check the agent entry in models.json")
+		return
+	}
+	if _, err := selectBackendFn(backendName); err != nil {
+		report.add(label, doctorFail, err.Error(), "set backend to one of codex, claude, gemini, opencode, mock")
+		return
+	}
+	if strings.TrimSpace(promptFile) != "" {
+		content, err := readAgentPromptFile(promptFile, false)
+		if err != nil {
+			report.add(label, doctorFail, fmt.Sprintf("prompt file %s: %v", promptFile, err),
+				"prompt files must be readable and live under ~/.claude or ~/.codeagent/agents")
+			return
+		}
+		if err := checkPromptTemplate(promptFile, content); err != nil {
+			report.add(label, doctorFail, err.Error(), "fix the template syntax (see README: Prompt Templates)")
+			return
+		}
+	}
+	msg := backendName
+	if model != "" {
+		msg += "/" + model
+	}
+	if promptFile != "" {
+		msg += ", prompt " + promptFile
+	}
+	report.add(label, doctorPass, msg, "")
+}
+
+func checkTempDir(report *doctorReport) {
+	ensureExecutableTempDir()
+	dir := currentTempDirFromEnv()
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	ok, err := tmpDirExecutableCheckFn(dir)
+	switch {
+	case ok:
+		report.add("temp dir", doctorPass, dir+" allows executing scripts", "")
+	case err != nil:
+		report.add("temp dir", doctorFail, fmt.Sprintf("%s: %v", dir, err), "set "+tmpDirEnvOverrideKey+" to a writable directory without noexec")
+	default:
+		report.add("temp dir", doctorFail, dir+" is mounted noexec", "set "+tmpDirEnvOverrideKey+" to a directory that allows execution")
+	}
+}
+
+func checkSkills(report *doctorReport, workDir string) {
+	matched := executor.MatchProjectSkills(workDir)
+	if len(matched) == 0 {
+		report.add("skills", doctorPass, "no skill detection rule matches "+workDir, "")
+		return
+	}
+	for _, m := range matched {
+		rule := fmt.Sprintf("%s rule: %s", m.Source, m.Reason)
+		if m.Path == "" {
+			report.add("skill "+m.Skill, doctorWarn, "detected for "+workDir+" by "+rule+" but not installed in .claude/skills or ~/.claude/skills",
+				"install the skill or pass --skills explicitly")
+			continue
+		}
+		report.add("skill "+m.Skill, doctorPass, fmt.Sprintf("%s (%s)", m.Path, rule), "")
+	}
+}
+
+func checkWorktrees(report *doctorReport, workDir string) {
+	worktrees, err := doctorListWorktreesFn(workDir)
+	if err != nil {
+		report.add("worktrees", doctorWarn, err.Error(), "")
+		return
+	}
+	var stale, leftover []string
+	for _, wt := range worktrees {
+		if wt.Prunable {
+			stale = append(stale, wt.Dir)
+		} else {
+			leftover = append(leftover, fmt.Sprintf("%s (%s)", wt.Dir, wt.Branch))
+		}
+	}
+	if len(stale) > 0 {
+		report.add("worktrees", doctorWarn, fmt.Sprintf("%d stale worktree(s) with missing directories: %s", len(stale), strings.Join(stale, ", ")),
+			"run `git worktree prune` and delete the matching do/* branches")
+	}
+	if len(leftover) > 0 {
+		report.add("worktrees", doctorWarn, fmt.Sprintf("%d task worktree(s) left behind: %s", len(leftover), strings.Join(leftover, ", ")),
+			"merge what you need, then `git worktree remove <dir>` and `git branch -D <branch>`")
+	}
+	if len(stale) == 0 && len(leftover) == 0 {
+		report.add("worktrees", doctorPass, "no task worktrees left behind", "")
+	}
+}
+
+func checkLogs(report *doctorReport, name string) {
+	orphaned, err := doctorOrphanedLogsFn()
+	if err != nil {
+		report.add("logs", doctorWarn, "failed to scan logs: "+err.Error(), "")
+		return
+	}
+	if len(orphaned) == 0 {
+		msg := "no orphaned logs in " + os.TempDir()
+		if dir := ilogger.LogDir(); dir != "" {
+			msg += "; run logs kept in " + dir
+		}
+		report.add("logs", doctorPass, msg, "")
+		return
+	}
+	report.add("logs", doctorWarn, fmt.Sprintf("%d orphaned log file(s) in %s", len(orphaned), os.TempDir()),
+		fmt.Sprintf("run `%s cleanup`", name))
+}
+
+func renderDoctorReport(report *doctorReport) string {
+	var sb strings.Builder
+	for _, c := range report.Checks {
+		sb.WriteString(fmt.Sprintf("[%s] %s: %s\n", strings.ToUpper(c.Status), c.Name, c.Message))
+		if c.Hint != "" {
+			sb.WriteString(fmt.Sprintf("       hint: %s\n", c.Hint))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n%d passed, %d warnings, %d failed\n", report.Summary.Pass, report.Summary.Warn, report.Summary.Fail))
+	return sb.String()
+}
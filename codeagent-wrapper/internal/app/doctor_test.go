@@ -0,0 +1,131 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+	"codeagent-wrapper/internal/worktree"
+
+	"github.com/goccy/go-json"
+)
+
+func stubDoctorHooks(t *testing.T, worktrees []worktree.TaskWorktree, orphaned []string) {
+	t.Helper()
+	prevLook, prevVersion, prevWT, prevLogs := doctorLookPathFn, doctorVersionFn, doctorListWorktreesFn, doctorOrphanedLogsFn
+	t.Cleanup(func() {
+		doctorLookPathFn, doctorVersionFn, doctorListWorktreesFn, doctorOrphanedLogsFn = prevLook, prevVersion, prevWT, prevLogs
+		resetTestHooks()
+	})
+	doctorLookPathFn = func(name string) (string, error) {
+		if name == "codex" {
+			return "/usr/bin/codex", nil
+		}
+		return "", errors.New("not found")
+	}
+	doctorVersionFn = func(path string) (string, error) { return "codex 1.2.3", nil }
+	doctorListWorktreesFn = func(string) ([]worktree.TaskWorktree, error) { return worktrees, nil }
+	doctorOrphanedLogsFn = func() ([]string, error) { return orphaned, nil }
+}
+
+func writeDoctorHome(t *testing.T, modelsJSON string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	config.ResetModelsConfigCacheForTest()
+	if modelsJSON != "" {
+		if err := os.MkdirAll(filepath.Join(home, ".codeagent"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(home, ".codeagent", "models.json"), []byte(modelsJSON), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return home
+}
+
+func findDoctorCheck(report *doctorReport, name string) (doctorCheck, bool) {
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return doctorCheck{}, false
+}
+
+func TestDoctor_ReportsAgentsSkillsWorktreesAndLogs(t *testing.T) {
+	stubDoctorHooks(t,
+		[]worktree.TaskWorktree{{Dir: "/repo/.worktrees/do-a", Branch: "do/a", Prunable: true}},
+		[]string{"/tmp/codeagent-wrapper-1.log"},
+	)
+	home := writeDoctorHome(t, `{
+  "agents": {
+    "develop": { "backend": "codex", "model": "gpt-x", "prompt_file": "~/.claude/agents/develop.md" },
+    "broken": { "backend": "codex", "model": "gpt-x", "prompt_file": "/etc/passwd" }
+  }
+}`)
+	if err := os.MkdirAll(filepath.Join(home, ".claude", "agents"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".claude", "agents", "develop.md"), []byte("prompt"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "go.mod"), []byte("module x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := runDoctor(workDir, "codeagent-wrapper")
+
+	expect := map[string]string{
+		"backend codex":               doctorPass,
+		"backend claude":              doctorWarn,
+		"models.json":                 doctorPass,
+		"agent develop":               doctorPass,
+		"agent broken":                doctorFail,
+		"skill golang-base-practices": doctorWarn,
+		"worktrees":                   doctorWarn,
+		"logs":                        doctorWarn,
+	}
+	for name, status := range expect {
+		c, ok := findDoctorCheck(report, name)
+		if !ok {
+			t.Errorf("missing check %q", name)
+			continue
+		}
+		if c.Status != status {
+			t.Errorf("%s status = %s, want %s (%s)", name, c.Status, status, c.Message)
+		}
+		if c.Status != doctorPass && c.Hint == "" {
+			t.Errorf("%s should carry a fix hint", name)
+		}
+	}
+	if report.Summary.Fail != 1 {
+		t.Errorf("summary = %+v", report.Summary)
+	}
+}
+
+func TestDoctor_MalformedModelsJSONFailsWithJSONOutput(t *testing.T) {
+	stubDoctorHooks(t, nil, nil)
+	writeDoctorHome(t, `{"agents": {`)
+
+	os.Args = []string{"codeagent-wrapper", "doctor", "--json", t.TempDir()}
+	exitCode := 0
+	output := captureStdout(t, func() { exitCode = run() })
+	if exitCode != 1 {
+		t.Fatalf("exit code = %d, want 1", exitCode)
+	}
+
+	var report doctorReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	c, ok := findDoctorCheck(&report, "models.json")
+	if !ok || c.Status != doctorFail || !strings.Contains(c.Message, "failed to parse") {
+		t.Fatalf("unexpected models.json check: %+v", c)
+	}
+}
@@ -0,0 +1,106 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runDryRunSingle prints the resolved plan for a single-mode invocation
+// instead of starting the backend.
+func runDryRunSingle(cfg *Config) int {
+	backend, err := selectBackendFn(cfg.Backend)
+	if err != nil {
+		logError(err.Error())
+		return 1
+	}
+
+	taskText := cfg.Task
+	piped := false
+	if cfg.ExplicitStdin {
+		data, err := io.ReadAll(stdinReader)
+		if err != nil {
+			logError("Failed to read stdin: " + err.Error())
+			return 1
+		}
+		taskText = string(data)
+		piped = !isTerminal()
+	} else {
+		pipedTask, err := readPipedTask()
+		if err != nil {
+			logError("Failed to read piped stdin: " + err.Error())
+			return 1
+		}
+		if pipedTask != "" {
+			taskText = pipedTask
+			piped = true
+		}
+	}
+
+	plan, err := planTask(TaskSpec{
+		Task:            taskText,
+		WorkDir:         cfg.WorkDir,
+		Mode:            cfg.Mode,
+		SessionID:       cfg.SessionID,
+		Backend:         backend.Name(),
+		Model:           cfg.Model,
+		ReasoningEffort: cfg.ReasoningEffort,
+		Agent:           cfg.Agent,
+		PromptFile:      cfg.PromptFile,
+		SkipPermissions: cfg.SkipPermissions,
+		Worktree:        cfg.Worktree,
+		AllowedTools:    cfg.AllowedTools,
+		DisallowedTools: cfg.DisallowedTools,
+		Skills:          cfg.Skills,
+		UseStdin:        cfg.ExplicitStdin || shouldUseStdin(taskText, piped),
+		HandoffBackend:  cfg.HandoffBackend,
+		TranscriptDir:   cfg.TranscriptDir,
+		Sandbox:         cfg.Sandbox,
+	}, cfg.PromptFileExplicit)
+	if err != nil {
+		logError(err.Error())
+		return 1
+	}
+
+	fmt.Print(renderTaskPlans([]TaskPlan{plan}))
+	if plan.PromptError != "" {
+		return 1
+	}
+	return 0
+}
+
+// runDryRunParallel prints the resolved plan of every task in layer order,
+// followed by the dependency graph.
+func runDryRunParallel(layers [][]TaskSpec, graphFormat string) int {
+	graph, err := renderTaskGraph(layers, graphFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	var plans []TaskPlan
+	exitCode := 0
+	for _, layer := range layers {
+		for _, task := range layer {
+			plan, err := planTask(task, false)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: task %s: %v\n", task.ID, err)
+				exitCode = 1
+				continue
+			}
+			if plan.PromptError != "" {
+				exitCode = 1
+			}
+			plans = append(plans, plan)
+		}
+	}
+
+	fmt.Print(renderTaskPlans(plans))
+	format := strings.ToLower(strings.TrimSpace(graphFormat))
+	if format == "" {
+		format = "mermaid"
+	}
+	fmt.Printf("\n## Task Graph (%s)\n%s", format, graph)
+	return exitCode
+}
@@ -6,6 +6,7 @@ import (
 	backend "codeagent-wrapper/internal/backend"
 	config "codeagent-wrapper/internal/config"
 	executor "codeagent-wrapper/internal/executor"
+	session "codeagent-wrapper/internal/session"
 )
 
 // defaultRunCodexTaskFn is the default implementation of runCodexTaskFn (exposed for test reset).
@@ -28,6 +29,14 @@ func executeConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 	return executor.ExecuteConcurrentWithContext(parentCtx, layers, timeout, maxWorkers, runCodexTaskFn)
 }
 
+func runTaskHooks(task TaskSpec, run func(TaskSpec) TaskResult) TaskResult {
+	return executor.RunTaskHooks(task, run)
+}
+
+func runPostRunHooks(payload any) error {
+	return executor.RunPostRunHooks(payload)
+}
+
 func generateFinalOutput(results []TaskResult) string {
 	return executor.GenerateFinalOutput(results)
 }
@@ -57,6 +66,30 @@ func detectProjectSkills(workDir string) []string {
 	return executor.DetectProjectSkills(workDir)
 }
 
-func resolveSkillContent(skills []string, maxBudget int) string {
-	return executor.ResolveSkillContent(skills, maxBudget)
+func resolveSkillContent(workDir, task string, skills []string, maxTokens int) string {
+	return executor.ResolveSkillContent(workDir, task, skills, maxTokens)
+}
+
+func renderAgentPromptFile(path string, allowOutsideClaudeDir bool, vars PromptVars) (string, error) {
+	return executor.RenderAgentPromptFile(path, allowOutsideClaudeDir, vars)
+}
+
+func checkPromptTemplate(name, content string) error {
+	return executor.CheckPromptTemplate(name, content)
+}
+
+func handoffSource(sessionID, target string, rec *session.Entry) string {
+	return executor.HandoffSource(sessionID, target, rec)
+}
+
+func planTask(task TaskSpec, promptFileExplicit bool) (TaskPlan, error) {
+	return executor.PlanTask(task, promptFileExplicit)
+}
+
+func renderTaskPlans(plans []TaskPlan) string {
+	return executor.RenderTaskPlans(plans)
+}
+
+func renderTaskGraph(layers [][]TaskSpec, format string) (string, error) {
+	return executor.RenderTaskGraph(layers, format)
 }
@@ -0,0 +1,92 @@
+//go:build unix
+
+package wrapper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestParallelModeRunsHooks(t *testing.T) {
+	defer resetTestHooks()
+	dir := t.TempDir()
+	payloadPath := filepath.Join(dir, "run.json")
+	writeDoctorHome(t, `{
+  "default_backend": "codex",
+  "hooks": {
+    "pre_task": [{ "command": "if [ \"$CODEAGENT_TASK_ID\" = blocked ]; then echo '{\"veto\": true, \"reason\": \"protected\"}'; fi" }],
+    "post_run": [{ "command": "cat > '`+payloadPath+`'; exit 1", "on_failure": "fail" }]
+  }
+}`)
+
+	var ran []string
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		ran = append(ran, task.ID)
+		return TaskResult{TaskID: task.ID, Message: "done"}
+	}
+	stdinReader = bytes.NewReader([]byte("---TASK---\nid: ok\n---CONTENT---\nwork\n"))
+	code, output := runWithArgs(t, "--parallel")
+	if code != 1 {
+		t.Fatalf("a failing post_run hook with on_failure=fail should fail the run, exit = %d\n%s", code, output)
+	}
+	var payload outputPayload
+	data, err := os.ReadFile(payloadPath)
+	if err != nil || json.Unmarshal(data, &payload) != nil || payload.Summary.Success != 1 || len(payload.Results) != 1 {
+		t.Fatalf("post_run payload = %s (%v)", data, err)
+	}
+
+	ran = nil
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		ran = append(ran, task.ID)
+		return TaskResult{TaskID: task.ID, Message: "done"}
+	}
+	stdinReader = bytes.NewReader([]byte("---TASK---\nid: blocked\n---CONTENT---\nwork\n---TASK---\nid: free\n---CONTENT---\nwork\n"))
+	_, output = runWithArgs(t, "--parallel")
+	if len(ran) != 1 || ran[0] != "free" || !strings.Contains(output, "protected") {
+		t.Fatalf("ran = %v, output:\n%s", ran, output)
+	}
+}
+
+func TestSingleModeHooksSeeTaskBeforeInjection(t *testing.T) {
+	defer resetTestHooks()
+	dir := t.TempDir()
+	payloadPath := filepath.Join(dir, "pre.json")
+	home := writeDoctorHome(t, `{
+  "default_backend": "codex",
+  "hooks": {
+    "pre_task": [{ "command": "cat > '`+payloadPath+`'; echo '{\"prompt\": \"rewritten\"}'" }]
+  }
+}`)
+	promptPath := filepath.Join(home, ".claude", "role.md")
+	if err := os.MkdirAll(filepath.Dir(promptPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(promptPath, []byte("be careful"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ran TaskSpec
+	runTaskFn = func(task TaskSpec, silent bool, timeout int) TaskResult {
+		ran = task
+		return TaskResult{Message: "done"}
+	}
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	if code, output := runWithArgs(t, "--prompt-file", promptPath, "original"); code != 0 {
+		t.Fatalf("exit = %d\n%s", code, output)
+	}
+
+	var payload map[string]any
+	data, err := os.ReadFile(payloadPath)
+	if err != nil || json.Unmarshal(data, &payload) != nil || payload["task"] != "original" {
+		t.Fatalf("pre_task payload = %s (%v), want the task as given", data, err)
+	}
+	if want := wrapTaskWithAgentPrompt("be careful", "rewritten"); ran.Task != want {
+		t.Fatalf("task ran with %q, want %q", ran.Task, want)
+	}
+}
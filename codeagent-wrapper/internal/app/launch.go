@@ -0,0 +1,30 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+
+	launcher "codeagent-wrapper/internal/launcher"
+
+	"github.com/spf13/cobra"
+)
+
+// newLaunchCommand is the launcher a sandboxed or resource-limited backend is
+// started through; it is not meant to be run by hand.
+func newLaunchCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                launcher.Command + " -- <command> [args...]",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		SilenceErrors:      true,
+		SilenceUsage:       true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && args[0] == "--" {
+				args = args[1:]
+			}
+			err := launcher.Exec(args)
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return exitError{code: launcher.FailureExitCode}
+		},
+	}
+}
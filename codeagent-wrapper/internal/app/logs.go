@@ -0,0 +1,180 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	ilogger "codeagent-wrapper/internal/logger"
+	utils "codeagent-wrapper/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	logFollowInterval = 500 * time.Millisecond
+	logRunActiveFn    = ilogger.RunActive
+)
+
+func newLogsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "logs",
+		Short:         "List, show and follow run logs (CODEAGENT_LOG_DIR or the temp directory)",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newLogsListCommand(), newLogsShowCommand(), newLogsFollowCommand())
+	return cmd
+}
+
+func newLogsListCommand() *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:           "list",
+		Short:         "List runs with logs, newest first",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runs, err := ilogger.ListRuns()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if jsonOutput {
+				if runs == nil {
+					runs = []ilogger.LogRun{}
+				}
+				return printJSON(cmd, runs)
+			}
+			if len(runs) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No logs")
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "RUN\tSTARTED\tSTATUS\tFILES\tSIZE")
+			for _, run := range runs {
+				status := "finished"
+				if run.Active {
+					status = "running"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", run.ID, run.Started.Local().Format("2006-01-02 15:04:05"), status, len(run.Files), utils.FormatByteSize(run.Size()))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print runs and their files as JSON")
+	return cmd
+}
+
+func newLogsShowCommand() *cobra.Command {
+	var listFiles bool
+	cmd := &cobra.Command{
+		Use:           "show [run_id|task_id]",
+		Short:         "Print a run's main log or a task's log (default: the latest run)",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			run, file, err := ilogger.FindLog(firstArg(args))
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if listFiles {
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "TASK\tSIZE\tPATH")
+				for _, f := range run.Files {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", valueOr(f.TaskID, "(main)"), utils.FormatByteSize(f.Size), f.Path)
+				}
+				return w.Flush()
+			}
+			if err := copyLogFile(cmd.OutOrStdout(), file); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&listFiles, "files", false, "List the run's log files instead of printing one")
+	return cmd
+}
+
+func newLogsFollowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "follow [run_id|task_id]",
+		Short:         "Print a log and keep printing new lines until its run exits",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			run, file, err := ilogger.FindLog(firstArg(args))
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if err := followLogFile(cmd, run, file); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func copyLogFile(w io.Writer, file ilogger.LogFile) error {
+	r, err := ilogger.OpenLogFile(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// followLogFile copies file to the command's output, then polls for appended
+// data until the run's process exits or the command is interrupted.
+// Archived logs belong to finished runs and are printed once.
+func followLogFile(cmd *cobra.Command, run ilogger.LogRun, file ilogger.LogFile) error {
+	if file.Compressed {
+		return copyLogFile(cmd.OutOrStdout(), file)
+	}
+	f, err := os.Open(file.Path) // #nosec G304 -- path comes from ListRuns
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := cmd.Context()
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := io.Copy(cmd.OutOrStdout(), f); err != nil {
+			return err
+		}
+		if !logRunActiveFn(run) {
+			// Pick up anything written between the copy and the exit check.
+			_, err := io.Copy(cmd.OutOrStdout(), f)
+			return err
+		}
+		if ctx == nil {
+			<-ticker.C
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ilogger "codeagent-wrapper/internal/logger"
+)
+
+func TestLogsListShowAndFollow(t *testing.T) {
+	defer resetTestHooks()
+	dir := t.TempDir()
+	t.Setenv("CODEAGENT_LOG_DIR", dir)
+	t.Setenv("TMPDIR", t.TempDir())
+
+	runDir := filepath.Join(dir, "20260102-150405-4242")
+	if err := os.MkdirAll(runDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	mainLog := filepath.Join(runDir, "codeagent-wrapper-4242.log")
+	for path, content := range map[string]string{
+		mainLog: "main started\n",
+		filepath.Join(runDir, "codeagent-wrapper-4242-api.log"): "api task output\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out := runWithArgs(t, "logs", "list")
+	if code != 0 || !strings.Contains(out, "RUN") || !strings.Contains(out, "20260102-150405-4242") {
+		t.Fatalf("logs list = %d:\n%s", code, out)
+	}
+
+	code, out = runWithArgs(t, "logs", "show", "api")
+	if code != 0 || out != "api task output\n" {
+		t.Fatalf("logs show api = %d %q", code, out)
+	}
+
+	code, out = runWithArgs(t, "logs", "show", "20260102", "--files")
+	if code != 0 || !strings.Contains(out, "(main)") || !strings.Contains(out, "codeagent-wrapper-4242-api.log") {
+		t.Fatalf("logs show --files = %d:\n%s", code, out)
+	}
+
+	polls := 0
+	logFollowInterval = time.Millisecond
+	logRunActiveFn = func(ilogger.LogRun) bool {
+		polls++
+		if polls == 1 {
+			f, err := os.OpenFile(mainLog, os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				t.Error(err)
+				return false
+			}
+			fmt.Fprintln(f, "main finished")
+			f.Close()
+			return true
+		}
+		return false
+	}
+	code, out = runWithArgs(t, "logs", "follow")
+	if code != 0 || out != "main started\nmain finished\n" {
+		t.Fatalf("logs follow = %d %q", code, out)
+	}
+
+	code, _ = runWithArgs(t, "logs", "show", "nope")
+	if code != 1 {
+		t.Fatalf("logs show nope exit = %d, want 1", code)
+	}
+}
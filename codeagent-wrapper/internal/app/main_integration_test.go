@@ -948,3 +948,51 @@ func TestRunCleanupFlagEndToEnd_FailureDoesNotAffectStartup(t *testing.T) {
 		t.Fatalf("normal run output = %q, want codex output", normalOutput)
 	}
 }
+
+func TestRunParallelDryRunDoesNotExecute(t *testing.T) {
+	defer resetTestHooks()
+	origRun := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		t.Fatalf("task %s should not execute in dry-run", task.ID)
+		return TaskResult{}
+	}
+	t.Cleanup(func() {
+		runCodexTaskFn = origRun
+		resetTestHooks()
+	})
+
+	input := `---TASK---
+id: A
+backend: gemini
+---CONTENT---
+a
+---TASK---
+id: B
+dependencies: A
+---CONTENT---
+b`
+	stdinReader = bytes.NewReader([]byte(input))
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--dry-run", "--graph", "dot"}
+
+	exitCode := -1
+	output := captureStdout(t, func() {
+		exitCode = run()
+	})
+
+	if exitCode != 0 {
+		t.Fatalf("dry-run exit code = %d, output:\n%s", exitCode, output)
+	}
+	for _, want := range []string{"=== Dry Run: 2 task(s) ===", "### A", "Backend: gemini", "Command: gemini ", "### B", "Depends on: A", `"A" -> "B";`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("dry-run output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestRunGraphRequiresDryRun(t *testing.T) {
+	defer resetTestHooks()
+	os.Args = []string{"codeagent-wrapper", "--graph", "dot", "task"}
+	if code := run(); code == 0 {
+		t.Fatal("expected --graph without --dry-run to fail")
+	}
+}
@@ -21,10 +21,24 @@ import (
 
 	config "codeagent-wrapper/internal/config"
 	executor "codeagent-wrapper/internal/executor"
+	ilogger "codeagent-wrapper/internal/logger"
+	mock "codeagent-wrapper/internal/mock"
+	session "codeagent-wrapper/internal/session"
 
 	"github.com/goccy/go-json"
 )
 
+func TestMain(m *testing.M) {
+	// The mock backend re-runs the current executable, which here is the
+	// test binary.
+	if len(os.Args) > 1 && os.Args[1] == mock.Command {
+		os.Exit(mock.Main(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	// Keep fake backend sessions out of the real ~/.codeagent/sessions.
+	executor.SetRecordSessionFn(func(session.Entry) error { return nil })
+	os.Exit(m.Run())
+}
+
 // Helper to reset test hooks
 func resetTestHooks() {
 	stdinReader = os.Stdin
@@ -44,6 +58,9 @@ func resetTestHooks() {
 	runTaskFn = runCodexTask
 	runCodexTaskFn = defaultRunCodexTaskFn
 	exitFn = os.Exit
+	projectTrustPromptFn = promptProjectTrust
+	logRunActiveFn = ilogger.RunActive
+	logFollowInterval = 500 * time.Millisecond
 }
 
 type capturedStdout struct {
@@ -1415,6 +1432,46 @@ func TestBackendParseArgs_PromptFileFlag(t *testing.T) {
 	}
 }
 
+func TestBackendParseArgs_ConfigPromptFile(t *testing.T) {
+	defer resetTestHooks()
+	home := writeDoctorHome(t, `{"agents": {}}`)
+	if err := os.WriteFile(filepath.Join(home, ".codeagent", "config.yaml"), []byte("prompt-file: /srv/prompts/house.md\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user's own config may point anywhere.
+	os.Args = []string{"codeagent-wrapper", "task"}
+	cfg, err := parseArgs()
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if cfg.PromptFile != "/srv/prompts/house.md" || !cfg.PromptFileExplicit {
+		t.Fatalf("PromptFile = %q, explicit = %v", cfg.PromptFile, cfg.PromptFileExplicit)
+	}
+
+	// An untrusted project's prompt-file never reaches the config.
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".codeagent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".codeagent", "config.yaml"), []byte("prompt-file: /etc/passwd\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := activateProject(root, false); err != nil {
+		t.Fatalf("activateProject: %v", err)
+	}
+	defer config.SetActiveProject(nil)
+	os.Args = []string{"codeagent-wrapper", "task", root}
+	if cfg, err = parseArgs(); err != nil || cfg.PromptFile != "/srv/prompts/house.md" {
+		t.Fatalf("untrusted project: PromptFile = %q, err = %v", cfg.PromptFile, err)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--prompt-file", "/tmp/prompt.md", "task"}
+	if cfg, err = parseArgs(); err != nil || !cfg.PromptFileExplicit {
+		t.Fatalf("--prompt-file: explicit = %v, err = %v", cfg.PromptFileExplicit, err)
+	}
+}
+
 func TestBackendParseArgs_PromptFileOverridesAgent(t *testing.T) {
 	defer resetTestHooks()
 
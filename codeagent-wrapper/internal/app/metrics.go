@@ -0,0 +1,77 @@
+package wrapper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metrics "codeagent-wrapper/internal/metrics"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runMetrics serves and persists the metrics of one invocation.
+type runMetrics struct {
+	mode    string
+	file    string
+	started time.Time
+	stop    func()
+}
+
+// startMetrics resolves --metrics-file and --metrics-listen (or
+// CODEAGENT_METRICS_FILE / CODEAGENT_METRICS_LISTEN) and starts the
+// /metrics listener when one is requested.
+func startMetrics(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (*runMetrics, error) {
+	file, err := resolveFlagOrConfig(cmd, v, "metrics-file", opts.MetricsFile)
+	if err != nil {
+		return nil, err
+	}
+	listen, err := resolveFlagOrConfig(cmd, v, "metrics-listen", opts.MetricsListen)
+	if err != nil {
+		return nil, err
+	}
+
+	rm := &runMetrics{mode: "single", file: file, started: time.Now()}
+	if opts.Parallel {
+		rm.mode = "parallel"
+	}
+	if listen != "" {
+		addr, stop, err := metrics.Default.Serve(listen)
+		if err != nil {
+			return nil, fmt.Errorf("--metrics-listen: %w", err)
+		}
+		rm.stop = stop
+		logInfo(fmt.Sprintf("Serving metrics on http://%s/metrics", addr))
+	}
+	return rm, nil
+}
+
+// finish records the run and merges the registry into the textfile.
+func (rm *runMetrics) finish(exitCode *int) {
+	if rm == nil {
+		return
+	}
+	finished := time.Now()
+	metrics.RecordRun(rm.mode, *exitCode, finished.Sub(rm.started), finished)
+	if rm.stop != nil {
+		rm.stop()
+	}
+	if rm.file == "" {
+		return
+	}
+	if err := metrics.Default.WriteTextfile(rm.file); err != nil {
+		logWarn(fmt.Sprintf("Failed to write metrics file: %v", err))
+	}
+}
+
+func resolveFlagOrConfig(cmd *cobra.Command, v *viper.Viper, name, flagValue string) (string, error) {
+	if cmd.Flags().Changed(name) {
+		value := strings.TrimSpace(flagValue)
+		if value == "" {
+			return "", fmt.Errorf("--%s flag requires a value", name)
+		}
+		return value, nil
+	}
+	return strings.TrimSpace(v.GetString(name)), nil
+}
@@ -0,0 +1,52 @@
+package wrapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	executor "codeagent-wrapper/internal/executor"
+)
+
+func TestMetrics_TaskAndRunWrittenToTextfile(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, "")
+	path := filepath.Join(t.TempDir(), "codeagent.prom")
+
+	fake := newFakeCmd(fakeCmdConfig{
+		StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"thread.started","thread_id":"metrics-thread"}` + "\n"},
+			{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}` + "\n"},
+		},
+	})
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return fake })
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	codexCommand = "fake-cmd"
+
+	rm := &runMetrics{mode: "single", file: path, started: time.Now()}
+	res := runCodexTask(TaskSpec{ID: "m1", Task: "count me", Agent: "metrics-agent", WorkDir: t.TempDir()}, false, 2)
+	exitCode := res.ExitCode
+	rm.finish(&exitCode)
+	if res.ExitCode != 0 {
+		t.Fatalf("runCodexTask = %+v", res)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("metrics file: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`codeagent_tasks_total{agent="metrics-agent",backend=`,
+		`codeagent_task_duration_seconds_count{agent="metrics-agent",backend=`,
+		`codeagent_runs_total{mode="single",status="success"}`,
+		"codeagent_last_run_exit_code 0\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package wrapper
+
+import (
+	"os"
+
+	mock "codeagent-wrapper/internal/mock"
+
+	"github.com/spf13/cobra"
+)
+
+// newMockCommand is the process the mock backend runs; it is not meant to be
+// run by hand.
+func newMockCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                mock.Command + " [-m model] [-r session] <prompt|->",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		SilenceErrors:      true,
+		SilenceUsage:       true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if code := mock.Main(args, os.Stdin, os.Stdout, os.Stderr); code != 0 {
+				return exitError{code: code}
+			}
+			return nil
+		},
+	}
+}
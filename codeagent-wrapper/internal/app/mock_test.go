@@ -0,0 +1,49 @@
+package wrapper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	mock "codeagent-wrapper/internal/mock"
+)
+
+func TestMockBackendSingleTask(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, `{"default_backend": "codex"}`)
+	t.Setenv(mock.ScriptFileEnv, "")
+	t.Setenv(mock.ScriptEnv, `{"format":"claude","message":"all done","session_id":"mock-s1"}`)
+
+	code, output := runWithArgs(t, "--backend", "mock", "ship it")
+	if code != 0 || !strings.Contains(output, "all done") || !strings.Contains(output, "SESSION_ID: mock-s1") {
+		t.Fatalf("code=%d\n%s", code, output)
+	}
+
+	t.Setenv(mock.ScriptEnv, `{"message":"","stderr":["quota exceeded"],"exit_code":4}`)
+	if code, output := runWithArgs(t, "--backend", "mock", "ship it"); code != 4 {
+		t.Fatalf("failing mock: code=%d\n%s", code, output)
+	}
+}
+
+func TestMockBackendParallelPerTaskScripts(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, `{"default_backend": "codex"}`)
+	t.Setenv(mock.ScriptFileEnv, "")
+	t.Setenv(mock.ScriptEnv, `{"message":"default answer"}`)
+
+	stdinReader = bytes.NewReader([]byte(`---TASK---
+id: plan
+---CONTENT---
+plan it
+---TASK---
+id: build
+dependencies: plan
+env: CODEAGENT_MOCK={"format":"gemini","message":"built"}
+---CONTENT---
+build it
+`))
+	code, output := runWithArgs(t, "--parallel", "--full-output", "--backend", "mock")
+	if code != 0 || !strings.Contains(output, "default answer") || !strings.Contains(output, "built") {
+		t.Fatalf("code=%d\n%s", code, output)
+	}
+}
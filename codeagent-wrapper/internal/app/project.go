@@ -0,0 +1,247 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+	session "codeagent-wrapper/internal/session"
+
+	"github.com/spf13/cobra"
+)
+
+// projectTrustPromptFn asks whether to trust a project with sensitive
+// settings (tests can override).
+var projectTrustPromptFn = promptProjectTrust
+
+// projectWorkDir returns the directory project discovery starts from: the
+// task workdir in single mode (for a recorded resume without one, the
+// session's directory), the current directory otherwise.
+func projectWorkDir(args []string, parallel bool) string {
+	if parallel || len(args) == 0 {
+		return defaultWorkdir
+	}
+	if args[0] == "resume" {
+		if len(args) > 3 && args[3] != "-" {
+			return args[3]
+		}
+		if len(args) > 1 {
+			if rec, _ := session.Lookup(args[1]); rec != nil {
+				if dir := rec.ResumeDir(); dir != "" {
+					return dir
+				}
+			}
+		}
+		return defaultWorkdir
+	}
+	if len(args) > 1 && args[1] != "-" {
+		return args[1]
+	}
+	return defaultWorkdir
+}
+
+// activateProject discovers the project config for workDir and makes it
+// active. When it carries untrusted sensitive settings, the user is asked
+// (interactive runs only); otherwise those settings are dropped with a
+// warning. interactive is false for subcommands.
+func activateProject(workDir string, interactive bool) error {
+	project, err := config.DiscoverProject(workDir)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		config.SetActiveProject(nil)
+		return nil
+	}
+
+	if project.Restricted() && interactive && projectTrustPromptFn(project) {
+		if err := config.TrustProject(project); err != nil {
+			return fmt.Errorf("failed to trust project %s: %w", project.Root, err)
+		}
+	}
+	config.SetActiveProject(project)
+
+	if project.Restricted() {
+		msg := projectRestrictedMessage(project)
+		logWarn(msg)
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", msg)
+	} else {
+		logInfo(fmt.Sprintf("Project config: %s (%s)", project.Dir, projectTrustStatus(project)))
+	}
+	return nil
+}
+
+func projectRestrictedMessage(p *config.Project) string {
+	reason := "is not trusted"
+	if p.TrustStale {
+		reason = "changed since it was trusted"
+	}
+	return fmt.Sprintf("project config %s %s; ignoring %s. Run `%s project trust %s` to allow them.",
+		p.Dir, reason, strings.Join(p.Sensitive, ", "), currentWrapperName(), p.Root)
+}
+
+func projectTrustStatus(p *config.Project) string {
+	switch {
+	case len(p.Sensitive) == 0:
+		return "no sensitive settings"
+	case p.Trusted:
+		return "trusted"
+	case p.TrustStale:
+		return "changed since trusted"
+	default:
+		return "not trusted"
+	}
+}
+
+func promptProjectTrust(p *config.Project) bool {
+	if !isTerminal() {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Project config %s wants to enable:\n", p.Dir)
+	for _, setting := range p.Sensitive {
+		fmt.Fprintf(os.Stderr, "  - %s\n", setting)
+	}
+	fmt.Fprint(os.Stderr, "Trust this project? [y/N] ")
+	return readYes(stdinReader)
+}
+
+func readYes(r io.Reader) bool {
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func newProjectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "project",
+		Short:         "Inspect and trust project-local .codeagent configuration",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newProjectStatusCommand(), newProjectTrustCommand(), newProjectUntrustCommand())
+	return cmd
+}
+
+func discoverProjectArg(cmd *cobra.Command, args []string) (*config.Project, string, error) {
+	dir := defaultWorkdir
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	project, err := config.DiscoverProject(dir)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+		return nil, dir, exitError{code: 1}
+	}
+	return project, dir, nil
+}
+
+func newProjectStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "status [dir]",
+		Short:         "Show the project config that applies to dir and whether it is trusted",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, dir, err := discoverProjectArg(cmd, args)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if project == nil {
+				if !config.EnvFlagDefaultTrue(config.ProjectConfigEnvKey) {
+					fmt.Fprintf(out, "Project config disabled by %s\n", config.ProjectConfigEnvKey)
+				} else {
+					fmt.Fprintf(out, "No .codeagent directory found from %s\n", dir)
+				}
+				return nil
+			}
+			fmt.Fprintf(out, "Project: %s\n", project.Root)
+			fmt.Fprintf(out, "Config dir: %s\n", project.Dir)
+			fmt.Fprintf(out, "Config file: %s\n", valueOr(project.ConfigFile(), "(none)"))
+			models := "(none)"
+			if _, err := os.Stat(project.ModelsPath()); err == nil {
+				models = project.ModelsPath()
+			}
+			fmt.Fprintf(out, "models.json: %s\n", models)
+			agents, _ := filepath.Glob(filepath.Join(project.Dir, "agents", "*.md"))
+			fmt.Fprintf(out, "Dynamic agents: %d\n", len(agents))
+			fmt.Fprintf(out, "Trust: %s\n", projectTrustStatus(project))
+			if len(project.Sensitive) > 0 {
+				fmt.Fprintf(out, "Sensitive settings: %s\n", strings.Join(project.Sensitive, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func newProjectTrustCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "trust [dir]",
+		Short:         "Allow the project's sensitive settings (yolo, allowed_tools, base_url, api_key, prompt_env, hooks, skip-permissions)",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, dir, err := discoverProjectArg(cmd, args)
+			if err != nil {
+				return err
+			}
+			if project == nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: no .codeagent directory found from %s\n", dir)
+				return exitError{code: 1}
+			}
+			if err := config.TrustProject(project); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Trusted %s\n", project.Root)
+			if len(project.Sensitive) > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Enabled: %s\n", strings.Join(project.Sensitive, ", "))
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Trust is revoked automatically when its models.json or config file changes.")
+			return nil
+		},
+	}
+}
+
+func newProjectUntrustCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:           "untrust [dir]",
+		Short:         "Revoke trust for the project that applies to dir",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, dir, err := discoverProjectArg(cmd, args)
+			if err != nil {
+				return err
+			}
+			root := dir
+			if project != nil {
+				root = project.Root
+			} else if abs, err := filepath.Abs(dir); err == nil {
+				root = abs
+			}
+			removed, err := config.UntrustProject(root)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if !removed {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s was not trusted\n", root)
+				return nil
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Revoked trust for %s\n", root)
+			return nil
+		},
+	}
+}
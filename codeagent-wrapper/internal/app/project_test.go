@@ -0,0 +1,119 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+func writeTestProject(t *testing.T, modelsJSON string) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, ".codeagent")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "models.json"), []byte(modelsJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestProjectWorkDir(t *testing.T) {
+	tests := []struct {
+		args     []string
+		parallel bool
+		want     string
+	}{
+		{nil, false, defaultWorkdir},
+		{[]string{"task", "/repo"}, false, "/repo"},
+		{[]string{"task", "-"}, false, defaultWorkdir},
+		{[]string{"task", "/repo"}, true, defaultWorkdir},
+		{[]string{"resume", "sid", "task", "/repo"}, false, "/repo"},
+		{[]string{"resume", "sid", "task"}, false, defaultWorkdir},
+	}
+	for _, tt := range tests {
+		if got := projectWorkDir(tt.args, tt.parallel); got != tt.want {
+			t.Errorf("projectWorkDir(%v, %v) = %q, want %q", tt.args, tt.parallel, got, tt.want)
+		}
+	}
+}
+
+func TestActivateProject_PromptsForUntrustedSettings(t *testing.T) {
+	writeDoctorHome(t, `{"agents": {}}`)
+	defer resetTestHooks()
+	root := writeTestProject(t, `{"agents": {"develop": {"backend": "codex", "model": "gpt-x", "yolo": true}}}`)
+
+	prompted := 0
+	projectTrustPromptFn = func(p *config.Project) bool {
+		prompted++
+		return false
+	}
+	if err := activateProject(root, true); err != nil {
+		t.Fatalf("activateProject: %v", err)
+	}
+	if prompted != 1 || !config.ActiveProject().Restricted() {
+		t.Fatalf("prompted=%d restricted=%v", prompted, config.ActiveProject().Restricted())
+	}
+	if _, _, _, _, _, _, yolo, _, _, err := config.ResolveAgentConfig("develop"); err != nil || yolo {
+		t.Fatalf("declined project must not enable yolo")
+	}
+
+	projectTrustPromptFn = func(p *config.Project) bool { return true }
+	if err := activateProject(root, true); err != nil {
+		t.Fatalf("activateProject: %v", err)
+	}
+	if config.ActiveProject().Restricted() {
+		t.Fatalf("accepted project should be trusted")
+	}
+	if _, _, _, _, _, _, yolo, _, _, err := config.ResolveAgentConfig("develop"); err != nil || !yolo {
+		t.Fatalf("trusted project should enable yolo")
+	}
+
+	// Non-interactive activation never prompts.
+	prompted = 0
+	projectTrustPromptFn = func(p *config.Project) bool {
+		prompted++
+		return true
+	}
+	if _, err := config.UntrustProject(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := activateProject(root, false); err != nil || prompted != 0 {
+		t.Fatalf("activateProject(non-interactive) err=%v prompted=%d", err, prompted)
+	}
+}
+
+func TestProjectCommands(t *testing.T) {
+	writeDoctorHome(t, `{"agents": {}}`)
+	root := writeTestProject(t, `{"backends": {"claude": {"base_url": "https://proxy.example"}}}`)
+
+	code, out := runWithArgs(t, "project", "status", root)
+	if code != 0 || !strings.Contains(out, "Trust: not trusted") || !strings.Contains(out, "backends.claude.base_url") {
+		t.Fatalf("status exit=%d output=%q", code, out)
+	}
+
+	code, out = runWithArgs(t, "project", "trust", root)
+	if code != 0 || !strings.Contains(out, "Trusted "+root) {
+		t.Fatalf("trust exit=%d output=%q", code, out)
+	}
+	if _, out = runWithArgs(t, "project", "status", root); !strings.Contains(out, "Trust: trusted") {
+		t.Fatalf("status after trust = %q", out)
+	}
+
+	code, out = runWithArgs(t, "project", "untrust", root)
+	if code != 0 || !strings.Contains(out, "Revoked trust for "+root) {
+		t.Fatalf("untrust exit=%d output=%q", code, out)
+	}
+	if _, out = runWithArgs(t, "project", "untrust", root); !strings.Contains(out, "was not trusted") {
+		t.Fatalf("second untrust = %q", out)
+	}
+
+	empty := t.TempDir()
+	if code, _ := runWithArgs(t, "project", "trust", empty); code != 1 {
+		t.Fatalf("trust without .codeagent exit=%d, want 1", code)
+	}
+}
@@ -0,0 +1,63 @@
+package wrapper
+
+import (
+	"fmt"
+	"strings"
+
+	sandbox "codeagent-wrapper/internal/sandbox"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// resolveSandbox returns the sandbox policy requested with --sandbox (or the
+// sandbox config key / CODEAGENT_SANDBOX), or nil when sandboxing is off.
+// Extra writable paths come from --sandbox-write and sandbox-write; read
+// access from sandbox-read (default /) minus sandbox-deny (default
+// sandbox.DefaultDeny).
+func resolveSandbox(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (*sandbox.Policy, error) {
+	enabled := v.GetBool("sandbox")
+	if cmd.Flags().Changed("sandbox") {
+		enabled = opts.Sandbox
+	}
+	write := append(configList(v, "sandbox-write"), opts.SandboxWrite...)
+	if !enabled {
+		if cmd.Flags().Changed("sandbox-write") {
+			return nil, fmt.Errorf("--sandbox-write requires --sandbox")
+		}
+		return nil, nil
+	}
+	if err := sandbox.Available(); err != nil {
+		return nil, err
+	}
+	policy := &sandbox.Policy{
+		Write: write,
+		Read:  configList(v, "sandbox-read"),
+		Deny:  sandbox.DefaultDeny,
+	}
+	if v.IsSet("sandbox-deny") {
+		policy.Deny = configList(v, "sandbox-deny")
+	}
+	return policy, nil
+}
+
+// configList reads a list from the config file or a comma-separated value
+// from the environment.
+func configList(v *viper.Viper, key string) []string {
+	var raw []string
+	switch val := v.Get(key).(type) {
+	case nil:
+		return nil
+	case string:
+		raw = strings.Split(val, ",")
+	default:
+		raw = v.GetStringSlice(key)
+	}
+	var out []string
+	for _, s := range raw {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package wrapper
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+	executor "codeagent-wrapper/internal/executor"
+	launcher "codeagent-wrapper/internal/launcher"
+	sandbox "codeagent-wrapper/internal/sandbox"
+
+	"github.com/goccy/go-json"
+)
+
+func TestSandbox_TaskRunsThroughLauncher(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skip(err)
+	}
+	defer resetTestHooks()
+	home := writeDoctorHome(t, "")
+	workDir := t.TempDir()
+
+	fake := newFakeCmd(fakeCmdConfig{
+		StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"thread.started","thread_id":"sandbox-thread"}` + "\n"},
+			{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}` + "\n"},
+		},
+	})
+	var gotName string
+	var gotArgs []string
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		gotName, gotArgs = name, args
+		return fake
+	})
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	codexCommand = "fake-cmd"
+
+	policy := &sandbox.Policy{Write: []string{"~/extra"}, Deny: sandbox.DefaultDeny}
+	res := runCodexTask(TaskSpec{ID: "s1", Task: "stay inside", WorkDir: workDir, Sandbox: policy}, false, 2)
+	if res.ExitCode != 0 {
+		t.Fatalf("runCodexTask = %+v", res)
+	}
+
+	self, _ := os.Executable()
+	if gotName != self || len(gotArgs) < 3 || gotArgs[0] != launcher.Command || gotArgs[1] != "--" || gotArgs[2] != "fake-cmd" {
+		t.Fatalf("command = %s %v, want the launcher running fake-cmd", gotName, gotArgs)
+	}
+	var spec launcher.Spec
+	if err := json.Unmarshal([]byte(fake.env[launcher.SpecEnv]), &spec); err != nil || spec.Sandbox == nil {
+		t.Fatalf("launch spec %+v: %v", spec, err)
+	}
+	got := *spec.Sandbox
+	write := strings.Join(got.Write, "\n")
+	resolvedWork, _ := filepath.EvalSymlinks(workDir)
+	for _, want := range []string{resolvedWork, filepath.Join(home, "extra"), "/dev/null"} {
+		if !strings.Contains(write, want) {
+			t.Fatalf("writable paths %v missing %s", got.Write, want)
+		}
+	}
+	if !strings.Contains(strings.Join(got.Deny, "\n"), filepath.Join(home, ".ssh")) {
+		t.Fatalf("deny = %v, want ~/.ssh", got.Deny)
+	}
+
+	tmp := fake.env["TMPDIR"]
+	if !strings.Contains(filepath.Base(tmp), "codeagent-sandbox-") || fake.env["TMP"] != tmp || fake.env["TEMP"] != tmp {
+		t.Fatalf("temp env = %q/%q/%q, want a private temp dir", tmp, fake.env["TMP"], fake.env["TEMP"])
+	}
+	shared, _ := filepath.EvalSymlinks(os.TempDir())
+	for _, w := range got.Write {
+		if w == shared {
+			t.Fatalf("writable paths %v include the shared temp dir", got.Write)
+		}
+	}
+	if !strings.Contains(write, filepath.Base(tmp)) {
+		t.Fatalf("writable paths %v missing %s", got.Write, tmp)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Fatalf("sandbox temp dir left behind: %v", err)
+	}
+}
+
+func TestSandbox_WriteRequiresSandbox(t *testing.T) {
+	writeDoctorHome(t, "")
+	if code, _ := runWithArgs(t, "--sandbox-write", "/tmp", "task"); code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+}
+
+func TestResourceLimits_BreachReported(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits are Linux-only")
+	}
+	defer resetTestHooks()
+	writeDoctorHome(t, "")
+	_ = executor.SetCommandContextFn(func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		if len(args) < 3 || args[0] != launcher.Command {
+			t.Errorf("command = %s %v, want the launcher", name, args)
+			return exec.CommandContext(ctx, "false")
+		}
+		return exec.CommandContext(ctx, args[2], args[3:]...)
+	})
+	_ = executor.SetNewCommandRunner(nil)
+	codexCommand = "sh"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{"-c", "kill -XCPU $$"} }
+
+	res := runCodexTask(TaskSpec{ID: "cpu", Task: "spin", Limits: config.ResourceLimits{CPUSeconds: 30}}, false, 5)
+	if res.ExitCode == 0 || !strings.HasPrefix(res.Error, "resource limit exceeded: CPU time limit 30s exceeded (SIGXCPU)") {
+		t.Fatalf("result = %+v", res)
+	}
+}
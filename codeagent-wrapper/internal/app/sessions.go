@@ -0,0 +1,212 @@
+package wrapper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	session "codeagent-wrapper/internal/session"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultSessionPruneAge = "30d"
+
+// lookupRecordedSession returns the registry entry for a resumed session, or
+// nil when it is unknown or the registry cannot be read.
+func lookupRecordedSession(id string) *session.Entry {
+	rec, err := session.Lookup(id)
+	if err != nil {
+		logWarn(fmt.Sprintf("Failed to read session registry for %s: %v", strings.TrimSpace(id), err))
+		return nil
+	}
+	return rec
+}
+
+func newSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "sessions",
+		Short:         "List, inspect and prune recorded sessions (~/.codeagent/sessions)",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newSessionsListCommand(), newSessionsShowCommand(), newSessionsPruneCommand())
+	return cmd
+}
+
+func newSessionsListCommand() *cobra.Command {
+	var jsonOutput bool
+	var workDir string
+	cmd := &cobra.Command{
+		Use:           "list",
+		Short:         "List recorded sessions, most recently used first",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := session.List()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if cmd.Flags().Changed("workdir") {
+				entries = filterSessionsByDir(entries, workDir)
+			}
+			if jsonOutput {
+				return printJSON(cmd, entries)
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No recorded sessions")
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SESSION\tBACKEND\tMODEL\tAGENT\tWORKDIR\tLAST USED")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.SessionID, valueOr(e.Backend, "-"), valueOr(e.Model, "-"), valueOr(e.Agent, "-"), valueOr(e.Worktree, e.WorkDir), e.UpdatedAt.Local().Format("2006-01-02 15:04"))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print sessions as JSON")
+	cmd.Flags().StringVar(&workDir, "workdir", ".", "Only list sessions whose workdir or worktree is inside this directory")
+	return cmd
+}
+
+// filterSessionsByDir keeps entries whose workdir or worktree is dir or
+// below it.
+func filterSessionsByDir(entries []session.Entry, dir string) []session.Entry {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	within := func(path string) bool {
+		if path == "" {
+			return false
+		}
+		rel, err := filepath.Rel(root, path)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	var kept []session.Entry
+	for _, e := range entries {
+		if within(e.WorkDir) || within(e.Worktree) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func newSessionsShowCommand() *cobra.Command {
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:           "show <session_id>",
+		Short:         "Show what was recorded for a session",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rec, err := session.Lookup(args[0])
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if rec == nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: session %q is not recorded\n", args[0])
+				return exitError{code: 1}
+			}
+			if jsonOutput {
+				return printJSON(cmd, rec)
+			}
+			fmt.Fprint(cmd.OutOrStdout(), renderSessionEntry(*rec))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the session as JSON")
+	return cmd
+}
+
+func renderSessionEntry(e session.Entry) string {
+	var sb strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			sb.WriteString(fmt.Sprintf("%-12s %s\n", label+":", value))
+		}
+	}
+	line("Session", e.SessionID)
+	line("Backend", e.Backend)
+	line("Model", e.Model)
+	line("Agent", e.Agent)
+	line("Workdir", e.WorkDir)
+	line("Worktree", e.Worktree)
+	line("Task", e.TaskID)
+	if e.HandoffFrom != "" {
+		line("Handoff", fmt.Sprintf("from %s (%s)", e.HandoffFrom, valueOr(e.HandoffBackend, "-")))
+	}
+	line("Created", e.CreatedAt.Local().Format(time.RFC3339))
+	line("Last used", e.UpdatedAt.Local().Format(time.RFC3339))
+	line("Runs", strconv.Itoa(e.Runs))
+	if e.ResumeDir() == "" {
+		line("Note", "workdir no longer exists")
+	}
+	return sb.String()
+}
+
+func newSessionsPruneCommand() *cobra.Command {
+	var olderThan string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:           "prune",
+		Short:         "Remove sessions not used recently or whose workdir is gone",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: --older-than: %v\n", err)
+				return exitError{code: 1}
+			}
+			pruned, err := session.Prune(age, dryRun)
+			verb := "Removed"
+			if dryRun {
+				verb = "Would remove"
+			}
+			for _, e := range pruned {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s (%s, last used %s)\n", verb, e.SessionID, valueOr(e.Backend, "-"), e.UpdatedAt.Local().Format("2006-01-02"))
+			}
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			if len(pruned) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing to prune")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&olderThan, "older-than", defaultSessionPruneAge, "Remove sessions last used longer ago than this (e.g. 30d, 12h; 0 only prunes sessions whose workdir is gone)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would be removed without removing it")
+	return cmd
+}
+
+// parseAge parses a time.Duration, also accepting whole days ("30d").
+func parseAge(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "0" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", raw)
+	}
+	return d, nil
+}
@@ -0,0 +1,137 @@
+package wrapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	executor "codeagent-wrapper/internal/executor"
+	session "codeagent-wrapper/internal/session"
+)
+
+func recordSessionForTest(t *testing.T, e session.Entry) {
+	t.Helper()
+	if err := session.Record(e); err != nil {
+		t.Fatalf("session.Record: %v", err)
+	}
+}
+
+func TestParseArgs_ResumeUsesRecordedSession(t *testing.T) {
+	defer resetTestHooks()
+	home := writeDoctorHome(t, `{"agents": {"develop": {"backend": "codex", "model": "gpt-x"}}}`)
+	workDir := filepath.Join(home, "repo")
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	recordSessionForTest(t, session.Entry{SessionID: "sess-1", Backend: "claude", Model: "opus", Agent: "develop", WorkDir: workDir})
+	recordSessionForTest(t, session.Entry{SessionID: "sess-2", Backend: "gemini", Agent: "ghost", WorkDir: filepath.Join(home, "gone")})
+
+	os.Args = []string{"codeagent-wrapper", "resume", "sess-1", "next step"}
+	cfg, err := parseArgs()
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if cfg.Backend != "claude" || cfg.Model != "opus" || cfg.Agent != "develop" || cfg.WorkDir != workDir {
+		t.Fatalf("cfg = backend %q model %q agent %q workdir %q", cfg.Backend, cfg.Model, cfg.Agent, cfg.WorkDir)
+	}
+	if cfg.HandoffBackend != "" {
+		t.Fatalf("HandoffBackend = %q, want native resume", cfg.HandoffBackend)
+	}
+
+	// Explicit flags and workdir still win; the recorded model belongs to
+	// another backend and is not reused.
+	os.Args = []string{"codeagent-wrapper", "--backend", "gemini", "resume", "sess-1", "next step", "/tmp"}
+	cfg, err = parseArgs()
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if cfg.Backend != "gemini" || cfg.Model != "gpt-x" || cfg.WorkDir != "/tmp" {
+		t.Fatalf("cfg = backend %q model %q workdir %q", cfg.Backend, cfg.Model, cfg.WorkDir)
+	}
+	if cfg.HandoffBackend != "claude" {
+		t.Fatalf("HandoffBackend = %q, want claude", cfg.HandoffBackend)
+	}
+
+	// A recorded agent that no longer resolves is ignored, and a missing
+	// workdir falls back to the current directory.
+	os.Args = []string{"codeagent-wrapper", "resume", "sess-2", "next step"}
+	cfg, err = parseArgs()
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if cfg.Backend != "gemini" || cfg.Agent != "" || cfg.WorkDir != defaultWorkdir {
+		t.Fatalf("cfg = backend %q agent %q workdir %q", cfg.Backend, cfg.Agent, cfg.WorkDir)
+	}
+}
+
+func TestRunCodexTask_RecordsSession(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, "")
+	restore := executor.SetRecordSessionFn(nil)
+	defer restore()
+
+	fake := newFakeCmd(fakeCmdConfig{
+		StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"thread.started","thread_id":"recorded-thread"}` + "\n"},
+			{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}` + "\n"},
+		},
+	})
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return fake })
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	codexCommand = "fake-cmd"
+
+	workDir := t.TempDir()
+	res := runCodexTask(TaskSpec{ID: "t1", Task: "ignored", WorkDir: workDir, Model: "gpt-x"}, false, 2)
+	if res.ExitCode != 0 || res.SessionID != "recorded-thread" {
+		t.Fatalf("runCodexTask = %+v", res)
+	}
+
+	rec, err := session.Lookup("recorded-thread")
+	if err != nil || rec == nil {
+		t.Fatalf("Lookup = %+v, %v", rec, err)
+	}
+	if rec.WorkDir != workDir || rec.Model != "gpt-x" || rec.TaskID != "t1" || rec.Runs != 1 || rec.Backend == "" {
+		t.Fatalf("recorded entry = %+v", rec)
+	}
+}
+
+func TestSessionsCommands(t *testing.T) {
+	home := writeDoctorHome(t, "")
+	repoA, repoB := filepath.Join(home, "a"), filepath.Join(home, "b")
+	for _, dir := range []string{repoA, repoB} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recordSessionForTest(t, session.Entry{SessionID: "sess-a", Backend: "claude", Model: "opus", WorkDir: repoA})
+	recordSessionForTest(t, session.Entry{SessionID: "sess-b", Backend: "codex", WorkDir: repoB})
+	recordSessionForTest(t, session.Entry{SessionID: "sess-gone", Backend: "gemini", WorkDir: filepath.Join(home, "gone")})
+
+	code, out := runWithArgs(t, "sessions", "list")
+	if code != 0 || !strings.Contains(out, "sess-a") || !strings.Contains(out, "sess-b") || !strings.Contains(out, "LAST USED") {
+		t.Fatalf("sessions list: code=%d out=%q", code, out)
+	}
+	code, out = runWithArgs(t, "sessions", "list", "--workdir", repoA)
+	if code != 0 || !strings.Contains(out, "sess-a") || strings.Contains(out, "sess-b") {
+		t.Fatalf("sessions list --workdir: code=%d out=%q", code, out)
+	}
+
+	code, out = runWithArgs(t, "sessions", "show", "sess-a")
+	if code != 0 || !strings.Contains(out, "Backend:     claude") || !strings.Contains(out, "Workdir:     "+repoA) {
+		t.Fatalf("sessions show: code=%d out=%q", code, out)
+	}
+	if code, _ := runWithArgs(t, "sessions", "show", "nope"); code != 1 {
+		t.Fatalf("sessions show unknown: code=%d", code)
+	}
+
+	code, out = runWithArgs(t, "sessions", "prune", "--older-than", "0")
+	if code != 0 || strings.TrimSpace(out) != "Removed sess-gone (gemini, last used "+time.Now().Format("2006-01-02")+")" {
+		t.Fatalf("sessions prune: code=%d out=%q", code, out)
+	}
+	if code, _ := runWithArgs(t, "sessions", "prune", "--older-than", "soon"); code != 1 {
+		t.Fatalf("invalid --older-than: code=%d", code)
+	}
+}
@@ -6,3 +6,5 @@ import executor "codeagent-wrapper/internal/executor"
 type ParallelConfig = executor.ParallelConfig
 type TaskSpec = executor.TaskSpec
 type TaskResult = executor.TaskResult
+type TaskPlan = executor.TaskPlan
+type PromptVars = executor.PromptVars
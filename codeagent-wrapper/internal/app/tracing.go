@@ -0,0 +1,45 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	tracing "codeagent-wrapper/internal/tracing"
+)
+
+// traceShutdownTimeout bounds how long exit waits for the last spans to be
+// exported.
+const traceShutdownTimeout = 10 * time.Second
+
+type runTrace struct {
+	span     *tracing.Span
+	shutdown func(context.Context) error
+}
+
+// startTracing sets up the exporters configured in the environment and
+// starts the span covering this invocation.
+func startTracing() runTrace {
+	shutdown, warnings := tracing.Init(version)
+	for _, w := range warnings {
+		logWarn("Tracing: " + w)
+	}
+	span := tracing.StartRun("codeagent.run", tracing.String("codeagent.version", version), tracing.Int("process.pid", os.Getpid()))
+	return runTrace{span: span, shutdown: shutdown}
+}
+
+// finishTracing ends the run span with the exit code and flushes the
+// exporters. It runs before the logger closes so export failures are logged.
+func finishTracing(rt runTrace, exitCode *int) {
+	rt.span.SetAttributes(tracing.Int("codeagent.exit_code", *exitCode))
+	if *exitCode != 0 {
+		rt.span.SetError(fmt.Sprintf("exit code %d", *exitCode))
+	}
+	rt.span.End()
+	ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+	defer cancel()
+	if err := rt.shutdown(ctx); err != nil {
+		logWarn(fmt.Sprintf("Failed to export traces: %v", err))
+	}
+}
@@ -0,0 +1,118 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	executor "codeagent-wrapper/internal/executor"
+
+	"github.com/goccy/go-json"
+)
+
+type exportedSpan struct {
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	} `json:"attributes"`
+}
+
+func (s exportedSpan) attr(key string) string {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			for _, v := range a.Value {
+				return fmt.Sprint(v)
+			}
+		}
+	}
+	return ""
+}
+
+func readTraceFile(t *testing.T, path string) map[string]exportedSpan {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("trace file: %v", err)
+	}
+	spans := make(map[string]exportedSpan)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			t.Fatalf("invalid trace line %q: %v", line, err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s
+				}
+			}
+		}
+	}
+	return spans
+}
+
+func TestTracing_TaskSpansAndTraceParent(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, "")
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	t.Setenv("CODEAGENT_TRACE_FILE", path)
+	t.Setenv("TRACEPARENT", "")
+
+	fake := newFakeCmd(fakeCmdConfig{
+		StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"thread.started","thread_id":"trace-thread"}` + "\n"},
+			{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}` + "\n"},
+		},
+	})
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return fake })
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	codexCommand = "fake-cmd"
+
+	rt := startTracing()
+	res := runCodexTask(TaskSpec{ID: "t1", Task: "trace me", Agent: "develop", Model: "m1", WorkDir: t.TempDir()}, false, 2)
+	exitCode := res.ExitCode
+	finishTracing(rt, &exitCode)
+	if res.ExitCode != 0 {
+		t.Fatalf("runCodexTask = %+v", res)
+	}
+
+	spans := readTraceFile(t, path)
+	run, task := spans["codeagent.run"], spans["codeagent.task"]
+	if run.SpanID == "" || task.ParentSpanID != run.SpanID {
+		t.Fatalf("task span should be a child of the run span: %+v", spans)
+	}
+	for _, name := range []string{"backend.start", "backend.first_event", "backend.completion", "backend.drain"} {
+		if spans[name].ParentSpanID != task.SpanID {
+			t.Fatalf("%s should be a child of the task span: %+v", name, spans[name])
+		}
+	}
+	for key, want := range map[string]string{
+		"codeagent.task.id":    "t1",
+		"codeagent.agent":      "develop",
+		"codeagent.model":      "m1",
+		"codeagent.exit_code":  "0",
+		"codeagent.session_id": "trace-thread",
+	} {
+		if got := task.attr(key); got != want {
+			t.Fatalf("task attribute %s = %q, want %q", key, got, want)
+		}
+	}
+	if got := run.attr("codeagent.exit_code"); got != "0" {
+		t.Fatalf("run exit code = %q", got)
+	}
+	if got := fake.env["TRACEPARENT"]; !strings.Contains(got, "-"+task.SpanID+"-") {
+		t.Fatalf("TRACEPARENT = %q, want the task span %s", got, task.SpanID)
+	}
+}
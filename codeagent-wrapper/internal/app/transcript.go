@@ -0,0 +1,171 @@
+package wrapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	parser "codeagent-wrapper/internal/parser"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+)
+
+// transcriptPreviewLines is how many lines of each text block `transcript
+// show` prints without --full.
+const transcriptPreviewLines = 20
+
+func newTranscriptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "transcript",
+		Short:         "Review transcripts written with --transcript-dir",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	cmd.AddCommand(newTranscriptShowCommand())
+	return cmd
+}
+
+func newTranscriptShowCommand() *cobra.Command {
+	var full bool
+	cmd := &cobra.Command{
+		Use:           "show <file>",
+		Short:         "Pretty-print a task transcript",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			defer f.Close()
+			skipped, err := renderTranscript(cmd.OutOrStdout(), f, full)
+			if skipped > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "WARN: skipped %d unreadable line(s)\n", skipped)
+			}
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "ERROR: %v\n", err)
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&full, "full", false, fmt.Sprintf("Print text and output in full instead of the first %d lines", transcriptPreviewLines))
+	return cmd
+}
+
+// renderTranscript prints each entry of a JSONL transcript as a timestamped
+// heading followed by its indented text. It returns the number of lines
+// that were not transcript entries.
+func renderTranscript(w io.Writer, r io.Reader, full bool) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	skipped := 0
+	lastAssistant := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e parser.TranscriptEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.Type == "" {
+			skipped++
+			continue
+		}
+		// The final message usually repeats the last assistant message.
+		switch e.Type {
+		case parser.EntryAssistant:
+			lastAssistant = strings.TrimSpace(e.Text)
+		case parser.EntryResult:
+			if strings.TrimSpace(e.Text) == lastAssistant {
+				e.Text = ""
+			}
+		}
+		heading, body := describeTranscriptEntry(e)
+		fmt.Fprintf(w, "[%s] %s\n", e.Time.Local().Format("15:04:05"), heading)
+		writeIndented(w, body, full)
+	}
+	return skipped, scanner.Err()
+}
+
+func describeTranscriptEntry(e parser.TranscriptEntry) (heading, body string) {
+	var attrs []string
+	attr := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, key+"="+value)
+		}
+	}
+	exit := ""
+	if e.ExitCode != nil {
+		exit = fmt.Sprintf("%d", *e.ExitCode)
+	}
+
+	switch e.Type {
+	case parser.EntryPrompt:
+		attr("task", e.TaskID)
+		attr("backend", e.Backend)
+		attr("model", e.Model)
+		attr("resume", e.SessionID)
+		attr("workdir", e.WorkDir)
+		return strings.TrimSpace("PROMPT " + strings.Join(attrs, " ")), e.Text
+	case parser.EntryAssistant:
+		return "ASSISTANT", e.Text
+	case parser.EntryReasoning:
+		return "REASONING", e.Text
+	case parser.EntryToolCall:
+		if e.Command != "" {
+			return fmt.Sprintf("TOOL %s $ %s", e.Tool, e.Command), ""
+		}
+		return strings.TrimSpace("TOOL " + e.Tool), string(e.Input)
+	case parser.EntryToolResult:
+		heading = "RESULT OF " + valueOr(e.Tool, valueOr(e.CallID, "tool"))
+		if e.IsError {
+			heading += " (error)"
+		}
+		return heading, e.Output
+	case parser.EntryCommand:
+		heading = "$ " + e.Command
+		if exit != "" {
+			heading += " (exit " + exit + ")"
+		}
+		return heading, e.Output
+	case parser.EntryError:
+		return "ERROR", e.Text
+	case parser.EntryResult:
+		attr("exit", exit)
+		attr("session", e.SessionID)
+		if e.DurationMs > 0 {
+			attr("duration", (time.Duration(e.DurationMs) * time.Millisecond).String())
+		}
+		body = e.Text
+		if e.Error != "" {
+			body = strings.TrimSpace(body + "\nerror: " + e.Error)
+		}
+		return strings.TrimSpace("FINAL " + strings.Join(attrs, " ")), body
+	}
+	return strings.ToUpper(e.Type), e.Text
+}
+
+func writeIndented(w io.Writer, text string, full bool) {
+	text = strings.TrimRight(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	more := 0
+	if !full && len(lines) > transcriptPreviewLines {
+		more = len(lines) - transcriptPreviewLines
+		lines = lines[:transcriptPreviewLines]
+	}
+	for _, line := range lines {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+	if more > 0 {
+		fmt.Fprintf(w, "    ... %d more lines (--full)\n", more)
+	}
+}
@@ -0,0 +1,91 @@
+package wrapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	executor "codeagent-wrapper/internal/executor"
+)
+
+func TestTranscriptDir_RecordsAndShows(t *testing.T) {
+	defer resetTestHooks()
+	writeDoctorHome(t, "")
+
+	fake := newFakeCmd(fakeCmdConfig{
+		StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"thread.started","thread_id":"tr-thread"}` + "\n"},
+			{Data: `{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"bash -lc 'go test ./...'","aggregated_output":"ok\n","exit_code":0,"status":"completed"}}` + "\n"},
+			{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"tests pass"}}` + "\n"},
+		},
+	})
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return fake })
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	codexCommand = "fake-cmd"
+
+	dir := filepath.Join(t.TempDir(), "transcripts")
+	res := runCodexTask(TaskSpec{ID: "t/1", Task: "run the tests", WorkDir: t.TempDir(), TranscriptDir: dir}, false, 2)
+	if res.ExitCode != 0 || res.TranscriptPath == "" {
+		t.Fatalf("runCodexTask = %+v", res)
+	}
+	if filepath.Dir(res.TranscriptPath) != dir || !strings.Contains(filepath.Base(res.TranscriptPath), "-t_1-") {
+		t.Fatalf("transcript path = %s", res.TranscriptPath)
+	}
+	data, err := os.ReadFile(res.TranscriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("transcript has %d lines:\n%s", len(lines), data)
+	}
+	for i, want := range []string{`"type":"prompt"`, `"type":"command"`, `"type":"assistant"`, `"type":"result"`} {
+		if !strings.Contains(lines[i], want) {
+			t.Fatalf("line %d = %s, want %s", i, lines[i], want)
+		}
+	}
+	if !strings.Contains(lines[3], `"session_id":"tr-thread"`) || !strings.Contains(lines[3], `"exit_code":0`) {
+		t.Fatalf("result line = %s", lines[3])
+	}
+
+	code, out := runWithArgs(t, "transcript", "show", res.TranscriptPath)
+	if code != 0 {
+		t.Fatalf("transcript show exit = %d, output:\n%s", code, out)
+	}
+	for _, want := range []string{
+		"PROMPT task=t/1 backend=",
+		"    run the tests",
+		"$ bash -lc 'go test ./...' (exit 0)",
+		"    ok",
+		"ASSISTANT\n    tests pass",
+		"FINAL exit=0 session=tr-thread",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("transcript show missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "tests pass") != 1 {
+		t.Fatalf("final message repeated:\n%s", out)
+	}
+}
+
+func TestTranscriptShow_TruncatesLongText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.jsonl")
+	long := strings.Repeat("line\n", transcriptPreviewLines+5)
+	content := `{"time":"2026-01-02T10:00:00Z","type":"tool_result","tool":"Bash","output":` + strconv.Quote(long) + "}\nnot json\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runWithArgs(t, "transcript", "show", path)
+	if code != 0 || !strings.Contains(out, "RESULT OF Bash") || !strings.Contains(out, "... 5 more lines (--full)") {
+		t.Fatalf("exit %d, output:\n%s", code, out)
+	}
+	code, out = runWithArgs(t, "transcript", "show", "--full", path)
+	if code != 0 || strings.Contains(out, "more lines") || strings.Count(out, "    line") != transcriptPreviewLines+5 {
+		t.Fatalf("--full exit %d, output:\n%s", code, out)
+	}
+}
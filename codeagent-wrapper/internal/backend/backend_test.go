@@ -320,3 +320,58 @@ func TestOpencodeBackend_Interface(t *testing.T) {
 		t.Errorf("Command() = %q, want %q", backend.Command(), "opencode")
 	}
 }
+
+func TestMockBackend_BuildArgs(t *testing.T) {
+	backend := MockBackend{}
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		want []string
+	}{
+		{"new", &config.Config{Mode: "new"}, []string{"__mock", "--", "hello"}},
+		{"model", &config.Config{Mode: "new", Model: "fast"}, []string{"__mock", "-m", "fast", "--", "hello"}},
+		{"resume", &config.Config{Mode: "resume", SessionID: "mock-1"}, []string{"__mock", "-r", "mock-1", "--", "hello"}},
+		{"nil config", nil, []string{"__mock", "--", "hello"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backend.BuildArgs(tt.cfg, "hello"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockBackend_CommandIsSelf(t *testing.T) {
+	orig := executableFn
+	t.Cleanup(func() { executableFn = orig })
+
+	executableFn = func() (string, error) { return "/opt/bin/codeagent-wrapper", nil }
+	if got := (MockBackend{}).Command(); got != "/opt/bin/codeagent-wrapper" {
+		t.Errorf("Command() = %q", got)
+	}
+	executableFn = func() (string, error) { return "", os.ErrNotExist }
+	if got := (MockBackend{}).Command(); got != "codeagent-wrapper" {
+		t.Errorf("Command() fallback = %q", got)
+	}
+}
+
+func TestStatePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CODEX_HOME", "")
+	if got := StatePaths("codex"); len(got) != 1 || got[0] != filepath.Join(home, ".codex") {
+		t.Fatalf("codex = %v", got)
+	}
+	t.Setenv("CODEX_HOME", "/srv/codex")
+	if got := StatePaths("codex"); len(got) != 1 || got[0] != "/srv/codex" {
+		t.Fatalf("codex with CODEX_HOME = %v", got)
+	}
+	if got := StatePaths("opencode"); len(got) == 0 {
+		t.Fatal("opencode should declare state paths")
+	}
+	if got := StatePaths("nope"); got != nil {
+		t.Fatalf("unknown backend = %v", got)
+	}
+}
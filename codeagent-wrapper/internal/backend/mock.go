@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"os"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+	mock "codeagent-wrapper/internal/mock"
+)
+
+// MockBackend is a scriptable fake backend for integration tests. It runs
+// the wrapper's own hidden `__mock` subcommand, configured through
+// CODEAGENT_MOCK_SCRIPT or CODEAGENT_MOCK.
+type MockBackend struct{}
+
+var executableFn = os.Executable
+
+func (MockBackend) Name() string                                 { return "mock" }
+func (MockBackend) Env(baseURL, apiKey string) map[string]string { return nil }
+func (MockBackend) Command() string {
+	self, err := executableFn()
+	if err != nil {
+		return "codeagent-wrapper"
+	}
+	return self
+}
+func (MockBackend) BuildArgs(cfg *config.Config, targetArg string) []string {
+	args := []string{mock.Command}
+	if cfg != nil {
+		if model := strings.TrimSpace(cfg.Model); model != "" {
+			args = append(args, "-m", model)
+		}
+		if cfg.Mode == "resume" && cfg.SessionID != "" {
+			args = append(args, "-r", cfg.SessionID)
+		}
+	}
+	return append(args, "--", targetArg)
+}
@@ -10,6 +10,7 @@ var registry = map[string]Backend{
 	"claude":   ClaudeBackend{},
 	"gemini":   GeminiBackend{},
 	"opencode": OpencodeBackend{},
+	"mock":     MockBackend{},
 }
 
 // Registry exposes the available backends. Intended for internal inspection/tests.
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StatePather is implemented by backends that keep sessions, caches or
+// credentials under the user's home directory. A sandboxed backend must still
+// be able to write there.
+type StatePather interface {
+	StatePaths() []string
+}
+
+// StatePaths returns the writable state locations of the named backend, or
+// nil when it declares none.
+func StatePaths(name string) []string {
+	b, ok := registry[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil
+	}
+	if p, ok := b.(StatePather); ok {
+		return p.StatePaths()
+	}
+	return nil
+}
+
+func homePaths(rel ...string) []string {
+	home, err := userHome()
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(rel))
+	for _, r := range rel {
+		paths = append(paths, filepath.Join(home, r))
+	}
+	return paths
+}
+
+func (CodexBackend) StatePaths() []string {
+	if root := strings.TrimSpace(os.Getenv("CODEX_HOME")); root != "" {
+		return []string{root}
+	}
+	return homePaths(".codex")
+}
+
+func (ClaudeBackend) StatePaths() []string {
+	paths := homePaths(".claude", ".claude.json", ".claude.json.backup")
+	if dir := strings.TrimSpace(os.Getenv("CLAUDE_CONFIG_DIR")); dir != "" {
+		paths = append(paths, dir)
+	}
+	return paths
+}
+
+func (GeminiBackend) StatePaths() []string {
+	return homePaths(".gemini")
+}
+
+func (OpencodeBackend) StatePaths() []string {
+	return homePaths(".config/opencode", ".local/share/opencode", ".local/state/opencode", ".cache/opencode")
+}
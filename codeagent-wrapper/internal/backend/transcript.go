@@ -0,0 +1,392 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Turn is one user or assistant message of a backend session.
+type Turn struct {
+	Role string // "user" or "assistant"
+	Text string
+}
+
+// TranscriptLoader is implemented by backends whose session history can be
+// read back from their local session files.
+type TranscriptLoader interface {
+	// HasSession reports whether local files exist for sessionID without
+	// reading the transcript.
+	HasSession(sessionID string) bool
+	LoadTranscript(sessionID string) ([]Turn, error)
+}
+
+// ErrSessionNotFound is returned when a backend has no local files for a
+// session.
+var ErrSessionNotFound = errors.New("session not found in local session files")
+
+const maxTranscriptLineBytes = 16 << 20
+
+// LocateSession returns the names of the backends that have local files for
+// sessionID, in sorted order.
+func LocateSession(sessionID string) []string {
+	if validTranscriptSessionID(sessionID) != nil {
+		return nil
+	}
+	var owners []string
+	for name, b := range registry {
+		if loader, ok := b.(TranscriptLoader); ok && loader.HasSession(sessionID) {
+			owners = append(owners, name)
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+func userHome() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return "", fmt.Errorf("failed to resolve home directory: %v", err)
+	}
+	return home, nil
+}
+
+// validTranscriptSessionID keeps session IDs from reaching into other paths
+// when they are joined into file names or globs.
+func validTranscriptSessionID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\*?[]`) || strings.Contains(id, "..") {
+		return fmt.Errorf("invalid session id %q", id)
+	}
+	return nil
+}
+
+// scanJSONLines calls fn for each line of a JSONL file.
+func scanJSONLines(path string, fn func([]byte)) error {
+	f, err := os.Open(path) // #nosec G304 -- backend session file located under its data dir
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTranscriptLineBytes)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
+
+func appendTurn(turns []Turn, role, text string) []Turn {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return turns
+	}
+	return append(turns, Turn{Role: role, Text: text})
+}
+
+// --- codex: $CODEX_HOME/sessions/YYYY/MM/DD/rollout-<time>-<id>.jsonl ---
+
+func codexSessionFile(sessionID string) (string, error) {
+	if err := validTranscriptSessionID(sessionID); err != nil {
+		return "", err
+	}
+	root := strings.TrimSpace(os.Getenv("CODEX_HOME"))
+	if root == "" {
+		home, err := userHome()
+		if err != nil {
+			return "", err
+		}
+		root = filepath.Join(home, ".codex")
+	}
+	matches, _ := filepath.Glob(filepath.Join(root, "sessions", "*", "*", "*", "*-"+sessionID+".jsonl"))
+	if len(matches) == 0 {
+		return "", ErrSessionNotFound
+	}
+	return matches[0], nil
+}
+
+func (CodexBackend) HasSession(sessionID string) bool {
+	_, err := codexSessionFile(sessionID)
+	return err == nil
+}
+
+func (CodexBackend) LoadTranscript(sessionID string) ([]Turn, error) {
+	path, err := codexSessionFile(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	type codexMessage struct {
+		Type    string `json:"type"`
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	var turns []Turn
+	err = scanJSONLines(path, func(line []byte) {
+		var rec struct {
+			Type    string       `json:"type"`
+			Payload codexMessage `json:"payload"`
+			codexMessage
+		}
+		if json.Unmarshal(line, &rec) != nil {
+			return
+		}
+		// Current rollouts wrap items in {"type":"response_item","payload":...};
+		// older ones store the message itself on the line.
+		msg := rec.Payload
+		if rec.Type == "message" {
+			msg = rec.codexMessage
+			msg.Type = rec.Type
+		} else if rec.Type != "response_item" {
+			return
+		}
+		if msg.Type != "message" || (msg.Role != "user" && msg.Role != "assistant") {
+			return
+		}
+		var parts []string
+		for _, c := range msg.Content {
+			if c.Type == "input_text" || c.Type == "output_text" || c.Type == "text" {
+				parts = append(parts, c.Text)
+			}
+		}
+		text := strings.Join(parts, "\n")
+		// Codex injects its own context as user messages.
+		if msg.Role == "user" && (strings.HasPrefix(strings.TrimSpace(text), "<environment_context>") || strings.HasPrefix(strings.TrimSpace(text), "<user_instructions>")) {
+			return
+		}
+		turns = appendTurn(turns, msg.Role, text)
+	})
+	return turns, err
+}
+
+// --- claude: ~/.claude/projects/<encoded workdir>/<id>.jsonl ---
+
+func claudeSessionFile(sessionID string) (string, error) {
+	if err := validTranscriptSessionID(sessionID); err != nil {
+		return "", err
+	}
+	home, err := userHome()
+	if err != nil {
+		return "", err
+	}
+	matches, _ := filepath.Glob(filepath.Join(home, ".claude", "projects", "*", sessionID+".jsonl"))
+	if len(matches) == 0 {
+		return "", ErrSessionNotFound
+	}
+	return matches[0], nil
+}
+
+func (ClaudeBackend) HasSession(sessionID string) bool {
+	_, err := claudeSessionFile(sessionID)
+	return err == nil
+}
+
+func (ClaudeBackend) LoadTranscript(sessionID string) ([]Turn, error) {
+	path, err := claudeSessionFile(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var turns []Turn
+	err = scanJSONLines(path, func(line []byte) {
+		var rec struct {
+			Type        string `json:"type"`
+			IsMeta      bool   `json:"isMeta"`
+			IsSidechain bool   `json:"isSidechain"`
+			Message     struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(line, &rec) != nil || rec.IsMeta || rec.IsSidechain {
+			return
+		}
+		if rec.Type != "user" && rec.Type != "assistant" {
+			return
+		}
+		turns = appendTurn(turns, rec.Type, textFromContent(rec.Message.Content))
+	})
+	return turns, err
+}
+
+// textFromContent extracts text from a message content that is either a
+// string or a list of blocks; tool calls and results are skipped.
+func textFromContent(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &blocks) != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if (b.Type == "text" || b.Type == "") && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// --- gemini: ~/.gemini/tmp/<project hash>/chats/session-*.json ---
+
+// geminiSessionFile returns the chat file of sessionID and its contents.
+// Gemini names chat files by start time, so each file mentioning the ID is
+// decoded to check its sessionId.
+func geminiSessionFile(sessionID string) (string, []byte, error) {
+	if err := validTranscriptSessionID(sessionID); err != nil {
+		return "", nil, err
+	}
+	home, err := userHome()
+	if err != nil {
+		return "", nil, err
+	}
+	files, _ := filepath.Glob(filepath.Join(home, ".gemini", "tmp", "*", "chats", "*.json"))
+	for _, path := range files {
+		data, err := os.ReadFile(path) // #nosec G304 -- gemini chat file under ~/.gemini
+		if err != nil || !bytes.Contains(data, []byte(sessionID)) {
+			continue
+		}
+		var chat struct {
+			SessionID string `json:"sessionId"`
+		}
+		if json.Unmarshal(data, &chat) == nil && chat.SessionID == sessionID {
+			return path, data, nil
+		}
+	}
+	return "", nil, ErrSessionNotFound
+}
+
+func (GeminiBackend) HasSession(sessionID string) bool {
+	_, _, err := geminiSessionFile(sessionID)
+	return err == nil
+}
+
+func (GeminiBackend) LoadTranscript(sessionID string) ([]Turn, error) {
+	_, data, err := geminiSessionFile(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var chat struct {
+		Messages []struct {
+			Type    string          `json:"type"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return nil, err
+	}
+	var turns []Turn
+	for _, m := range chat.Messages {
+		switch m.Type {
+		case "user":
+			turns = appendTurn(turns, "user", textFromContent(m.Content))
+		case "gemini", "model", "assistant":
+			turns = appendTurn(turns, "assistant", textFromContent(m.Content))
+		}
+	}
+	return turns, nil
+}
+
+// --- opencode: $XDG_DATA_HOME/opencode/storage/{message/<id>,part/<msg>}/*.json ---
+
+func opencodeStorage() (string, error) {
+	dataHome := strings.TrimSpace(os.Getenv("XDG_DATA_HOME"))
+	if dataHome == "" {
+		home, err := userHome()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "opencode", "storage"), nil
+}
+
+func (OpencodeBackend) HasSession(sessionID string) bool {
+	storage, err := opencodeStorage()
+	if err != nil || validTranscriptSessionID(sessionID) != nil {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(storage, "message", sessionID))
+	return err == nil && info.IsDir()
+}
+
+func (OpencodeBackend) LoadTranscript(sessionID string) ([]Turn, error) {
+	if err := validTranscriptSessionID(sessionID); err != nil {
+		return nil, err
+	}
+	storage, err := opencodeStorage()
+	if err != nil {
+		return nil, err
+	}
+	files, _ := filepath.Glob(filepath.Join(storage, "message", sessionID, "*.json"))
+	if len(files) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	type opencodeMessage struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+		Time struct {
+			Created int64 `json:"created"`
+		} `json:"time"`
+	}
+	var messages []opencodeMessage
+	for _, path := range files {
+		data, err := os.ReadFile(path) // #nosec G304 -- opencode message file under its storage dir
+		if err != nil {
+			continue
+		}
+		var m opencodeMessage
+		if json.Unmarshal(data, &m) == nil && m.ID != "" && validTranscriptSessionID(m.ID) == nil {
+			messages = append(messages, m)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Time.Created != messages[j].Time.Created {
+			return messages[i].Time.Created < messages[j].Time.Created
+		}
+		return messages[i].ID < messages[j].ID
+	})
+
+	var turns []Turn
+	for _, m := range messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			continue
+		}
+		parts, _ := filepath.Glob(filepath.Join(storage, "part", m.ID, "*.json"))
+		sort.Strings(parts)
+		var texts []string
+		for _, path := range parts {
+			data, err := os.ReadFile(path) // #nosec G304 -- opencode part file under its storage dir
+			if err != nil {
+				continue
+			}
+			var p struct {
+				Type      string `json:"type"`
+				Text      string `json:"text"`
+				Synthetic bool   `json:"synthetic"`
+			}
+			if json.Unmarshal(data, &p) == nil && p.Type == "text" && !p.Synthetic {
+				texts = append(texts, p.Text)
+			}
+		}
+		turns = appendTurn(turns, m.Role, strings.Join(texts, "\n"))
+	}
+	return turns, nil
+}
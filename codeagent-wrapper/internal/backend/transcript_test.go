@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTranscriptFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func setTranscriptHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("CODEX_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	return home
+}
+
+func TestLoadTranscript_PerBackend(t *testing.T) {
+	home := setTranscriptHome(t)
+	writeTranscriptFiles(t, home, map[string]string{
+		".codex/sessions/2026/01/02/rollout-2026-01-02T10-00-00-cdx-1.jsonl": `{"type":"session_meta","payload":{"id":"cdx-1"}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>cwd</environment_context>"}]}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"fix the bug"}]}}
+{"type":"response_item","payload":{"type":"function_call","name":"shell"}}
+{"type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"fixed it"}]}}
+{"type":"message","role":"user","content":[{"type":"input_text","text":"legacy line"}]}
+not json`,
+		".claude/projects/-repo/cl-1.jsonl": `{"type":"summary","summary":"x"}
+{"type":"user","message":{"role":"user","content":"add tests"}}
+{"type":"user","isMeta":true,"message":{"role":"user","content":"meta"}}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"added"},{"type":"tool_use","name":"Bash"}]}}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","content":"ok"}]}}`,
+		".gemini/tmp/abc/chats/session-1.json":                  `{"sessionId":"gm-1","messages":[{"type":"user","content":"explain"},{"type":"gemini","content":"it works"},{"type":"info","content":"x"}]}`,
+		".local/share/opencode/storage/message/oc-1/msg_b.json": `{"id":"msg_b","role":"assistant","time":{"created":2}}`,
+		".local/share/opencode/storage/message/oc-1/msg_a.json": `{"id":"msg_a","role":"user","time":{"created":1}}`,
+		".local/share/opencode/storage/part/msg_a/prt_1.json":   `{"type":"text","text":"rename it"}`,
+		".local/share/opencode/storage/part/msg_b/prt_1.json":   `{"type":"tool","tool":"edit"}`,
+		".local/share/opencode/storage/part/msg_b/prt_2.json":   `{"type":"text","text":"renamed"}`,
+	})
+
+	tests := []struct {
+		backend TranscriptLoader
+		id      string
+		want    []Turn
+	}{
+		{CodexBackend{}, "cdx-1", []Turn{{"user", "fix the bug"}, {"assistant", "fixed it"}, {"user", "legacy line"}}},
+		{ClaudeBackend{}, "cl-1", []Turn{{"user", "add tests"}, {"assistant", "added"}}},
+		{GeminiBackend{}, "gm-1", []Turn{{"user", "explain"}, {"assistant", "it works"}}},
+		{OpencodeBackend{}, "oc-1", []Turn{{"user", "rename it"}, {"assistant", "renamed"}}},
+	}
+	for _, tt := range tests {
+		got, err := tt.backend.LoadTranscript(tt.id)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%T.LoadTranscript(%s) = %+v, %v; want %+v", tt.backend, tt.id, got, err, tt.want)
+		}
+		if !tt.backend.HasSession(tt.id) || tt.backend.HasSession("missing") {
+			t.Errorf("%T.HasSession(%s) = %v, HasSession(missing) = %v", tt.backend, tt.id, tt.backend.HasSession(tt.id), tt.backend.HasSession("missing"))
+		}
+		if _, err := tt.backend.LoadTranscript("missing"); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("%T.LoadTranscript(missing) err = %v", tt.backend, err)
+		}
+		if _, err := tt.backend.LoadTranscript("../cl-1"); err == nil {
+			t.Errorf("%T accepted a path-like session id", tt.backend)
+		}
+	}
+
+	if got := LocateSession("cl-1"); !reflect.DeepEqual(got, []string{"claude"}) {
+		t.Fatalf("LocateSession = %v", got)
+	}
+}
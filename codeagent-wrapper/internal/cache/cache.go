@@ -0,0 +1,226 @@
+// Package cache stores task results in ~/.codeagent/cache, keyed on
+// everything that determines a backend's answer, so an identical task
+// against an unchanged tree can be answered without running it again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// keyVersion changes whenever what a key covers changes, so old entries stop
+// matching.
+const keyVersion = 1
+
+// Key is what a cached result is looked up by.
+type Key struct {
+	Prompt          string `json:"prompt"` // after prompt-file and skill injection
+	Backend         string `json:"backend"`
+	Model           string `json:"model,omitempty"`
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	Tree            string `json:"tree"`             // git tree of the workdir's repository, dirty changes included
+	Prefix          string `json:"prefix,omitempty"` // workdir relative to the repository root
+}
+
+// Hash returns the hex SHA-256 the entry for k is stored under.
+func (k Key) Hash() string {
+	data, _ := json.Marshal(struct {
+		Version int `json:"v"`
+		Key
+	}{keyVersion, k})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Limits bounds the cache. Zero values mean "no limit".
+type Limits struct {
+	TTL     time.Duration // entries older than this are misses and get removed
+	MaxSize int64         // total bytes; the least recently used entries go first
+}
+
+type entry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Result    json.RawMessage `json:"result"`
+}
+
+var nowFn = time.Now
+
+// Dir returns ~/.codeagent/cache.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".codeagent", "cache"), nil
+}
+
+func entryPath(hash string) (string, error) {
+	if len(hash) != sha256.Size*2 || strings.Trim(hash, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid cache key %q", hash)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hash+".json"), nil
+}
+
+// Get returns the result stored under hash and when it was stored. Entries
+// older than ttl are removed and reported as misses.
+func Get(hash string, ttl time.Duration) (result []byte, createdAt time.Time, ok bool) {
+	path, err := entryPath(hash)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- file under ~/.codeagent/cache named by a hex hash
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || len(e.Result) == 0 {
+		_ = os.Remove(path)
+		return nil, time.Time{}, false
+	}
+	now := nowFn()
+	if ttl > 0 && now.Sub(e.CreatedAt) > ttl {
+		_ = os.Remove(path)
+		return nil, time.Time{}, false
+	}
+	// The modification time tracks use, so pruning drops the least recently
+	// used entries first.
+	_ = os.Chtimes(path, now, now)
+	return e.Result, e.CreatedAt, true
+}
+
+// Put stores result under hash and prunes the cache to limits. A result
+// larger than limits.MaxSize is not stored.
+func Put(hash string, result []byte, limits Limits) error {
+	path, err := entryPath(hash)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{CreatedAt: nowFn().UTC(), Result: result})
+	if err != nil {
+		return err
+	}
+	if limits.MaxSize > 0 && int64(len(data)) > limits.MaxSize {
+		return fmt.Errorf("result of %d bytes exceeds the cache size limit of %d bytes", len(data), limits.MaxSize)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	_, err = Prune(limits)
+	return err
+}
+
+type fileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func listEntries() ([]fileInfo, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]fileInfo, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, fileInfo{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Prune removes entries not used within limits.TTL, then the least recently
+// used ones until the cache fits limits.MaxSize. It returns how many entries
+// it removed.
+func Prune(limits Limits) (int, error) {
+	files, err := listEntries()
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	now := nowFn()
+	var total int64
+	removed := 0
+	var errs []error
+	for _, f := range files {
+		expired := limits.TTL > 0 && now.Sub(f.modTime) > limits.TTL
+		if !expired && (limits.MaxSize <= 0 || total+f.size <= limits.MaxSize) {
+			total += f.size
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// Stats reports the number of entries and their total size.
+func Stats() (entries int, size int64, err error) {
+	files, err := listEntries()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, f := range files {
+		size += f.size
+	}
+	return len(files), size, nil
+}
+
+// Clear removes every entry and returns how many entries and bytes it
+// removed.
+func Clear() (removed int, freed int64, err error) {
+	files, err := listEntries()
+	if err != nil {
+		return 0, 0, err
+	}
+	var errs []error
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+		freed += f.size
+	}
+	return removed, freed, errors.Join(errs...)
+}
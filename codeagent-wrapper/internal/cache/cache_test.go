@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setTestHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	return home
+}
+
+func setNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := nowFn
+	nowFn = func() time.Time { return now }
+	t.Cleanup(func() { nowFn = prev })
+}
+
+func TestKeyHash(t *testing.T) {
+	k := Key{Prompt: "review", Backend: "codex", Tree: "abc"}
+	if k.Hash() != k.Hash() || len(k.Hash()) != 64 {
+		t.Fatalf("hash = %q", k.Hash())
+	}
+	for _, other := range []Key{
+		{Prompt: "review!", Backend: "codex", Tree: "abc"},
+		{Prompt: "review", Backend: "claude", Tree: "abc"},
+		{Prompt: "review", Backend: "codex", Tree: "abd"},
+		{Prompt: "review", Backend: "codex", Tree: "abc", Prefix: "sub/"},
+		{Prompt: "review", Backend: "codex", Tree: "abc", ReasoningEffort: "high"},
+	} {
+		if other.Hash() == k.Hash() {
+			t.Errorf("%+v hashes like %+v", other, k)
+		}
+	}
+}
+
+func TestPutGetTTL(t *testing.T) {
+	setTestHome(t)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, start)
+	hash := Key{Prompt: "p", Backend: "codex", Tree: "t"}.Hash()
+
+	if _, _, ok := Get(hash, time.Hour); ok {
+		t.Fatal("hit on an empty cache")
+	}
+	if err := Put(hash, []byte(`{"message":"done"}`), Limits{}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	result, createdAt, ok := Get(hash, time.Hour)
+	if !ok || string(result) != `{"message":"done"}` || !createdAt.Equal(start) {
+		t.Fatalf("Get = %s, %s, %v", result, createdAt, ok)
+	}
+
+	setNow(t, start.Add(2*time.Hour))
+	if _, _, ok := Get(hash, time.Hour); ok {
+		t.Fatal("expired entry was a hit")
+	}
+	if n, _, _ := Stats(); n != 0 {
+		t.Fatalf("expired entry was not removed, %d entries left", n)
+	}
+
+	if _, _, ok := Get("../../etc/passwd", 0); ok {
+		t.Fatal("invalid key was a hit")
+	}
+}
+
+func TestPruneAndClear(t *testing.T) {
+	home := setTestHome(t)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	setNow(t, start)
+
+	var hashes []string
+	for i, prompt := range []string{"a", "b", "c"} {
+		hash := Key{Prompt: prompt, Backend: "codex", Tree: "t"}.Hash()
+		hashes = append(hashes, hash)
+		if err := Put(hash, []byte(`"`+strings.Repeat(prompt, 100)+`"`), Limits{}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		used := start.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(home, ".codeagent", "cache", hash+".json"), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, size, _ := Stats()
+	entrySize := size / 3
+
+	// Using "a" makes "b" the least recently used entry.
+	setNow(t, start.Add(10*time.Minute))
+	if _, _, ok := Get(hashes[0], 0); !ok {
+		t.Fatal("miss on a")
+	}
+	if removed, err := Prune(Limits{MaxSize: 2 * entrySize}); err != nil || removed != 1 {
+		t.Fatalf("Prune = %d, %v", removed, err)
+	}
+	if _, _, ok := Get(hashes[1], 0); ok {
+		t.Fatal("least recently used entry survived pruning")
+	}
+
+	if err := Put(hashes[1], []byte(`"`+strings.Repeat("x", 1000)+`"`), Limits{MaxSize: 100}); err == nil {
+		t.Fatal("oversized result was stored")
+	}
+
+	removed, freed, err := Clear()
+	if err != nil || removed != 2 || freed != 2*entrySize {
+		t.Fatalf("Clear = %d, %d, %v", removed, freed, err)
+	}
+	if n, _, _ := Stats(); n != 0 {
+		t.Fatalf("%d entries left after Clear", n)
+	}
+}
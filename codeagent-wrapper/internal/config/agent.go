@@ -4,28 +4,42 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/goccy/go-json"
 )
 
 type BackendConfig struct {
-	BaseURL string `json:"base_url,omitempty"`
-	APIKey  string `json:"api_key,omitempty"`
+	BaseURL          string `json:"base_url,omitempty"`
+	APIKey           string `json:"api_key,omitempty"`
+	MaxConcurrency   int    `json:"max_concurrency,omitempty"`
+	MinStartInterval string `json:"min_start_interval,omitempty"`
 }
 
 type AgentModelConfig struct {
+	Extends         string   `json:"extends,omitempty"`
 	Backend         string   `json:"backend"`
 	Model           string   `json:"model"`
 	PromptFile      string   `json:"prompt_file,omitempty"`
 	Description     string   `json:"description,omitempty"`
-	Yolo            bool     `json:"yolo,omitempty"`
+	Yolo            *bool    `json:"yolo,omitempty"`
 	Reasoning       string   `json:"reasoning,omitempty"`
 	BaseURL         string   `json:"base_url,omitempty"`
 	APIKey          string   `json:"api_key,omitempty"`
 	AllowedTools    []string `json:"allowed_tools,omitempty"`
 	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+
+	MaxConcurrency   int    `json:"max_concurrency,omitempty"`
+	MinStartInterval string `json:"min_start_interval,omitempty"`
+
+	Limits *ResourceLimitsConfig `json:"limits,omitempty"`
+
+	Env            map[string]string `json:"env,omitempty"`
+	EnvPassthrough []string          `json:"env_passthrough,omitempty"`
+	EnvUnset       []string          `json:"env_unset,omitempty"`
 }
 
 type ModelsConfig struct {
@@ -33,6 +47,16 @@ type ModelsConfig struct {
 	DefaultModel   string                      `json:"default_model"`
 	Agents         map[string]AgentModelConfig `json:"agents"`
 	Backends       map[string]BackendConfig    `json:"backends,omitempty"`
+	Profiles       map[string]ProfileConfig    `json:"profiles,omitempty"`
+	// PromptEnv lists the environment variables prompt templates may read
+	// with {{env "NAME"}}.
+	PromptEnv []string `json:"prompt_env,omitempty"`
+	// Hooks are commands run before and after each task and after the run.
+	Hooks HooksConfig `json:"hooks,omitempty"`
+
+	// agentSources records which file each agent came from after a project
+	// models.json was merged in; nil means all came from the home config.
+	agentSources map[string]string
 }
 
 var defaultModelsConfig = ModelsConfig{}
@@ -99,22 +123,59 @@ func loadModelsConfig() (*ModelsConfig, error) {
 		return nil, fmt.Errorf("%w\n\n%s", err, modelsConfigHint(""))
 	}
 
-	data, err := os.ReadFile(configPath) // #nosec G304 -- path is fixed under user home and validated to stay within configDir
+	projectCfg, err := loadProjectModelsConfig(ActiveProject())
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("models config not found: %s\n\n%s", configPath, modelsConfigHint(configPath))
-		}
-		return nil, fmt.Errorf("failed to read models config %s: %w\n\n%s", configPath, err, modelsConfigHint(configPath))
+		return nil, err
 	}
 
 	var cfg ModelsConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse models config %s: %w\n\n%s", configPath, err, modelsConfigHint(configPath))
+	data, err := os.ReadFile(configPath) // #nosec G304 -- path is fixed under user home and validated to stay within configDir
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse models config %s: %w\n\n%s", configPath, err, modelsConfigHint(configPath))
+		}
+	case os.IsNotExist(err) && projectCfg != nil:
+		// A project models.json is enough on its own.
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("models config not found: %s\n\n%s", configPath, modelsConfigHint(configPath))
+	default:
+		return nil, fmt.Errorf("failed to read models config %s: %w\n\n%s", configPath, err, modelsConfigHint(configPath))
+	}
+	if projectCfg != nil {
+		cfg = *mergeModelsConfig(&cfg, projectCfg)
 	}
 
 	cfg.DefaultBackend = strings.TrimSpace(cfg.DefaultBackend)
 	cfg.DefaultModel = strings.TrimSpace(cfg.DefaultModel)
 
+	if err := resolveAgentInheritance(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid agents in %s: %w", configPath, err)
+	}
+
+	if err := cfg.Hooks.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+
+	for name, backend := range cfg.Backends {
+		if err := validateConcurrencyFields(backend.MaxConcurrency, backend.MinStartInterval); err != nil {
+			return nil, fmt.Errorf("invalid backends.%s in %s: %w", name, configPath, err)
+		}
+	}
+	for name, agent := range cfg.Agents {
+		if err := validateConcurrencyFields(agent.MaxConcurrency, agent.MinStartInterval); err != nil {
+			return nil, fmt.Errorf("invalid agents.%s in %s: %w", name, configPath, err)
+		}
+		if agent.Limits != nil {
+			if _, err := agent.Limits.resolve(); err != nil {
+				return nil, fmt.Errorf("invalid agents.%s in %s: %w", name, configPath, err)
+			}
+		}
+		if err := validateEnvFields(agent.Env, agent.EnvPassthrough, agent.EnvUnset); err != nil {
+			return nil, fmt.Errorf("invalid agents.%s in %s: %w", name, configPath, err)
+		}
+	}
+
 	// Normalize backend keys so lookups can be case-insensitive.
 	if len(cfg.Backends) > 0 {
 		normalized := make(map[string]BackendConfig, len(cfg.Backends))
@@ -135,25 +196,96 @@ func loadModelsConfig() (*ModelsConfig, error) {
 	return &cfg, nil
 }
 
+// LoadModelsConfig reads and validates models.json without the cache, so
+// callers such as doctor see the current file and its error.
+func LoadModelsConfig() (*ModelsConfig, error) {
+	return loadModelsConfig()
+}
+
+// ModelsConfigPath returns the resolved path of ~/.codeagent/models.json.
+func ModelsConfigPath() (string, error) {
+	return modelsConfigPath()
+}
+
+// loadProjectModelsConfig reads the active project's models.json, resolving
+// relative prompt_file paths against its .codeagent directory and dropping
+// sensitive settings when the project is not trusted. It returns nil when
+// there is no project file.
+func loadProjectModelsConfig(p *Project) (*ModelsConfig, error) {
+	if p == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(p.ModelsPath()) // #nosec G304 -- fixed name inside the discovered project dir
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read project models config %s: %w", p.ModelsPath(), err)
+	}
+	var cfg ModelsConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse project models config %s: %w", p.ModelsPath(), err)
+	}
+	for name, agent := range cfg.Agents {
+		agent.PromptFile = p.resolvePath(agent.PromptFile)
+		cfg.Agents[name] = agent
+	}
+	for name, profile := range cfg.Profiles {
+		profile.PromptFile = p.resolvePath(profile.PromptFile)
+		cfg.Profiles[name] = profile
+	}
+	if p.Restricted() {
+		restrictModelsConfig(&cfg)
+	}
+	return &cfg, nil
+}
+
+// LoadDynamicAgent finds {name}.md in the active project's .codeagent/agents
+// first, then in ~/.codeagent/agents. An untrusted project cannot replace a
+// home agent.
 func LoadDynamicAgent(name string) (AgentModelConfig, bool) {
 	if err := ValidateAgentName(name); err != nil {
 		return AgentModelConfig{}, false
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil || strings.TrimSpace(home) == "" {
-		return AgentModelConfig{}, false
+	homePath := homeDynamicAgentPath(name)
+	if p := ActiveProject(); p != nil && !(homePath != "" && p.Restricted()) {
+		projectPath := filepath.Join(p.Dir, "agents", name+".md")
+		if info, err := os.Stat(projectPath); err == nil && !info.IsDir() {
+			return AgentModelConfig{PromptFile: projectPath}, true
+		}
 	}
 
-	absPath := filepath.Join(home, ".codeagent", "agents", name+".md")
-	info, err := os.Stat(absPath)
-	if err != nil || info.IsDir() {
+	if homePath == "" {
 		return AgentModelConfig{}, false
 	}
-
 	return AgentModelConfig{PromptFile: "~/.codeagent/agents/" + name + ".md"}, true
 }
 
+// homeDynamicAgentPath returns ~/.codeagent/agents/{name}.md, or "" when it
+// does not exist.
+func homeDynamicAgentPath(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return ""
+	}
+	path := filepath.Join(home, ".codeagent", "agents", name+".md")
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+	return path
+}
+
+// PromptEnvAllowlist returns the variables listed in models.json
+// "prompt_env". A missing or invalid models.json allows none.
+func PromptEnvAllowlist() []string {
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return nil
+	}
+	return cfg.PromptEnv
+}
+
 func ResolveBackendConfig(backendName string) (baseURL, apiKey string) {
 	cfg, err := modelsConfig()
 	if err != nil || cfg == nil {
@@ -194,6 +326,7 @@ func resolveAgentConfig(agentName string) (backend, model, promptFile, reasoning
 	}
 
 	if agent, ok := cfg.Agents[agentName]; ok {
+		agent = applyActiveProfile(cfg, agent)
 		backend = strings.TrimSpace(agent.Backend)
 		if backend == "" {
 			backend = strings.TrimSpace(cfg.DefaultBackend)
@@ -224,12 +357,13 @@ func resolveAgentConfig(agentName string) (backend, model, promptFile, reasoning
 			}
 			return "", "", "", "", "", "", false, nil, nil, fmt.Errorf("agent %q has empty model; set agents.%s.model in %s\n\n%s", agentName, agentName, modelsConfigTildePath, modelsConfigHint(configPath))
 		}
-		return backend, model, agent.PromptFile, agent.Reasoning, baseURL, apiKey, agent.Yolo, agent.AllowedTools, agent.DisallowedTools, nil
+		return backend, model, agent.PromptFile, agent.Reasoning, baseURL, apiKey, agent.yoloEnabled(), agent.AllowedTools, agent.DisallowedTools, nil
 	}
 
 	if dynamic, ok := LoadDynamicAgent(agentName); ok {
-		backend = strings.TrimSpace(cfg.DefaultBackend)
-		model = strings.TrimSpace(cfg.DefaultModel)
+		agent := applyActiveProfile(cfg, AgentModelConfig{Backend: cfg.DefaultBackend, Model: cfg.DefaultModel, PromptFile: dynamic.PromptFile})
+		backend = strings.TrimSpace(agent.Backend)
+		model = strings.TrimSpace(agent.Model)
 		configPath, pathErr := modelsConfigPath()
 		if backend == "" || model == "" {
 			if pathErr != nil {
@@ -238,9 +372,15 @@ func resolveAgentConfig(agentName string) (backend, model, promptFile, reasoning
 			return "", "", "", "", "", "", false, nil, nil, fmt.Errorf("dynamic agent %q requires default_backend and default_model to be set in %s\n\n%s", agentName, modelsConfigTildePath, modelsConfigHint(configPath))
 		}
 		backendCfg := resolveBackendConfig(cfg, backend)
-		baseURL = strings.TrimSpace(backendCfg.BaseURL)
-		apiKey = strings.TrimSpace(backendCfg.APIKey)
-		return backend, model, dynamic.PromptFile, "", baseURL, apiKey, false, nil, nil, nil
+		baseURL = strings.TrimSpace(agent.BaseURL)
+		if baseURL == "" {
+			baseURL = strings.TrimSpace(backendCfg.BaseURL)
+		}
+		apiKey = strings.TrimSpace(agent.APIKey)
+		if apiKey == "" {
+			apiKey = strings.TrimSpace(backendCfg.APIKey)
+		}
+		return backend, model, agent.PromptFile, agent.Reasoning, baseURL, apiKey, agent.yoloEnabled(), agent.AllowedTools, agent.DisallowedTools, nil
 	}
 
 	configPath, pathErr := modelsConfigPath()
@@ -258,4 +398,121 @@ func ResetModelsConfigCacheForTest() {
 	modelsConfigCached = nil
 	modelsConfigErr = nil
 	modelsConfigOnce = sync.Once{}
+	activeProfileMu.Lock()
+	activeProfile = ""
+	activeProfileMu.Unlock()
+	activeProjectMu.Lock()
+	activeProject = nil
+	activeProjectMu.Unlock()
+}
+
+// Agent sources reported by ListAgents.
+const (
+	AgentSourceModels         = "models.json"
+	AgentSourceDynamic        = "dynamic"
+	AgentSourceProject        = "project models.json"
+	AgentSourceProjectDynamic = "project dynamic"
+)
+
+// AgentInfo summarizes one agent for `agents list`.
+type AgentInfo struct {
+	Name        string `json:"name"`
+	Backend     string `json:"backend"`
+	Model       string `json:"model"`
+	Description string `json:"description,omitempty"`
+	Source      string `json:"source"`
+	Extends     string `json:"extends,omitempty"`
+	PromptFile  string `json:"prompt_file,omitempty"`
+}
+
+// ListAgents returns the agents defined in models.json (merged with the
+// active project's) followed by dynamic agents from the project's and then
+// ~/.codeagent/agents/*.md, sorted by name. Earlier sources shadow later ones
+// of the same name, matching ResolveAgentConfig. A missing models.json is not
+// an error; dynamic agents are still listed.
+func ListAgents() ([]AgentInfo, error) {
+	cfg, err := loadModelsConfig()
+	if err != nil {
+		path, pathErr := modelsConfigPath()
+		if pathErr != nil {
+			return nil, err
+		}
+		if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+			return nil, err
+		}
+		cfg = &ModelsConfig{}
+	}
+
+	agents := make([]AgentInfo, 0, len(cfg.Agents))
+	seen := make(map[string]bool, len(cfg.Agents))
+	for name, agent := range cfg.Agents {
+		backend := strings.TrimSpace(agent.Backend)
+		if backend == "" {
+			backend = cfg.DefaultBackend
+		}
+		source := AgentSourceModels
+		if s, ok := cfg.agentSources[name]; ok {
+			source = s
+		}
+		agents = append(agents, AgentInfo{
+			Name:        name,
+			Backend:     backend,
+			Model:       strings.TrimSpace(agent.Model),
+			Description: strings.TrimSpace(agent.Description),
+			Source:      source,
+			Extends:     agent.Extends,
+			PromptFile:  agent.PromptFile,
+		})
+		seen[name] = true
+	}
+
+	addDynamic := func(dir, source string, promptPath func(name, file string) string) {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".md")
+			if seen[name] || ValidateAgentName(name) != nil {
+				continue
+			}
+			seen[name] = true
+			agents = append(agents, AgentInfo{
+				Name:        name,
+				Backend:     cfg.DefaultBackend,
+				Model:       cfg.DefaultModel,
+				Description: promptFileSummary(file),
+				Source:      source,
+				PromptFile:  promptPath(name, file),
+			})
+		}
+	}
+	if p := ActiveProject(); p != nil {
+		addDynamic(filepath.Join(p.Dir, "agents"), AgentSourceProjectDynamic, func(_, file string) string { return file })
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.TrimSpace(home) != "" {
+		addDynamic(filepath.Join(home, ".codeagent", "agents"), AgentSourceDynamic, func(name, _ string) string {
+			return "~/.codeagent/agents/" + name + ".md"
+		})
+	}
+
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	return agents, nil
+}
+
+// promptFileSummary returns the first non-empty line of a dynamic agent's
+// prompt file, without Markdown heading markers, as its description.
+func promptFileSummary(path string) string {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from a glob under ~/.codeagent/agents
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if strings.Trim(line, "-") != "" {
+			const maxLen = 80
+			if utf8.RuneCountInString(line) > maxLen {
+				line = string([]rune(line)[:maxLen-3]) + "..."
+			}
+			return line
+		}
+	}
+	return ""
 }
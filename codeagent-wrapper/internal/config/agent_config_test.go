@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestResolveAgentConfig_NoConfig_ReturnsHelpfulError(t *testing.T) {
@@ -260,3 +261,63 @@ func TestResolveAgentConfig_EmptyModel_ReturnsError(t *testing.T) {
 		t.Fatalf("error should mention empty model, got: %s", err.Error())
 	}
 }
+
+func TestResolveConcurrencyLimits(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(ResetModelsConfigCacheForTest)
+	ResetModelsConfigCacheForTest()
+
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "backends": {
+    "Claude": { "max_concurrency": 2, "min_start_interval": "1500ms" }
+  },
+  "agents": {
+    "develop": { "backend": "codex", "model": "gpt-test", "max_concurrency": 3 }
+  }
+}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	got := ResolveBackendConcurrency("claude")
+	if got.MaxConcurrency != 2 || got.MinStartInterval != 1500*time.Millisecond {
+		t.Errorf("ResolveBackendConcurrency(claude) = %+v", got)
+	}
+	if got := ResolveBackendConcurrency("codex"); !got.IsZero() {
+		t.Errorf("ResolveBackendConcurrency(codex) = %+v, want zero", got)
+	}
+	if got := ResolveAgentConcurrency("develop"); got.MaxConcurrency != 3 || got.MinStartInterval != 0 {
+		t.Errorf("ResolveAgentConcurrency(develop) = %+v", got)
+	}
+}
+
+func TestLoadModelsConfig_InvalidConcurrency(t *testing.T) {
+	for name, body := range map[string]string{
+		"negative": `{"backends": {"codex": {"max_concurrency": -1}}}`,
+		"interval": `{"agents": {"a": {"backend": "codex", "model": "m", "min_start_interval": "soon"}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("USERPROFILE", home)
+			t.Cleanup(ResetModelsConfigCacheForTest)
+			ResetModelsConfigCacheForTest()
+
+			configDir := filepath.Join(home, ".codeagent")
+			if err := os.MkdirAll(configDir, 0o755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(body), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if _, err := loadModelsConfig(); err == nil {
+				t.Fatalf("expected error for %s", body)
+			}
+		})
+	}
+}
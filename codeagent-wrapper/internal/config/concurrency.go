@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConcurrencyLimit caps how many tasks of one backend or agent run at once
+// and how closely their starts may follow each other. Zero values mean
+// "unlimited".
+type ConcurrencyLimit struct {
+	MaxConcurrency   int
+	MinStartInterval time.Duration
+}
+
+// IsZero reports whether the limit imposes no restriction.
+func (l ConcurrencyLimit) IsZero() bool {
+	return l.MaxConcurrency <= 0 && l.MinStartInterval <= 0
+}
+
+func validateConcurrencyFields(maxConcurrency int, minStartInterval string) error {
+	if maxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency must be >= 0, got %d", maxConcurrency)
+	}
+	if maxConcurrency > maxParallelWorkersLimit {
+		return fmt.Errorf("max_concurrency must be <= %d, got %d", maxParallelWorkersLimit, maxConcurrency)
+	}
+	if _, err := parseStartInterval(minStartInterval); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseStartInterval(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("min_start_interval %q: %w", raw, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("min_start_interval must be >= 0, got %s", raw)
+	}
+	return d, nil
+}
+
+func newConcurrencyLimit(maxConcurrency int, minStartInterval string) ConcurrencyLimit {
+	interval, _ := parseStartInterval(minStartInterval)
+	if maxConcurrency < 0 {
+		maxConcurrency = 0
+	}
+	return ConcurrencyLimit{MaxConcurrency: maxConcurrency, MinStartInterval: interval}
+}
+
+// ResolveBackendConcurrency returns the concurrency limit configured under
+// backends.<name> in models.json. A missing config yields no limit.
+func ResolveBackendConcurrency(backendName string) ConcurrencyLimit {
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return ConcurrencyLimit{}
+	}
+	key := strings.ToLower(strings.TrimSpace(backendName))
+	if key == "" {
+		return ConcurrencyLimit{}
+	}
+	backend, ok := cfg.Backends[key]
+	if !ok {
+		return ConcurrencyLimit{}
+	}
+	return newConcurrencyLimit(backend.MaxConcurrency, backend.MinStartInterval)
+}
+
+// ResolveAgentConcurrency returns the concurrency limit configured under
+// agents.<name> in models.json. Dynamic agents have no limit.
+func ResolveAgentConcurrency(agentName string) ConcurrencyLimit {
+	agentName = strings.TrimSpace(agentName)
+	if agentName == "" {
+		return ConcurrencyLimit{}
+	}
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return ConcurrencyLimit{}
+	}
+	agent, ok := cfg.Agents[agentName]
+	if !ok {
+		return ConcurrencyLimit{}
+	}
+	return newConcurrencyLimit(agent.MaxConcurrency, agent.MinStartInterval)
+}
@@ -5,6 +5,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	sandbox "codeagent-wrapper/internal/sandbox"
+	utils "codeagent-wrapper/internal/utils"
 )
 
 // Config holds CLI configuration.
@@ -28,7 +32,13 @@ type Config struct {
 	AllowedTools       []string
 	DisallowedTools    []string
 	Skills             []string
-	Worktree           bool // Execute in a new git worktree
+	Worktree           bool            // Execute in a new git worktree
+	HandoffBackend     string          // resume: replay SessionID from this backend instead of resuming it natively
+	TranscriptDir      string          // write normalized JSONL transcripts here
+	Sandbox            *sandbox.Policy // confine the backend with Landlock; nil runs it unconfined
+	Cache              bool            // reuse cached results of identical tasks against an unchanged tree
+	RecordDir          string          // save each backend run as a cassette here
+	ReplayDir          string          // serve backend runs from cassettes here instead of running backends
 }
 
 // EnvFlagEnabled returns true when the environment variable exists and is not
@@ -105,3 +115,85 @@ func ResolveMaxParallelWorkers() int {
 	}
 	return value
 }
+
+// ResolveSkillTokenBudget reads CODEAGENT_SKILL_TOKENS, the estimated token
+// budget for injected skill content. It returns 0 for "use the default".
+func ResolveSkillTokenBudget() int {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_SKILL_TOKENS"))
+	if raw == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+// ResolveHandoffTokenBudget reads CODEAGENT_HANDOFF_TOKENS, the estimated
+// token budget for a transcript replayed into another backend. It returns 0
+// for "use the default".
+func ResolveHandoffTokenBudget() int {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_HANDOFF_TOKENS"))
+	if raw == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+const (
+	ScheduleInputOrder   = "input"
+	ScheduleCriticalPath = "critical-path"
+)
+
+// ResolveScheduleMode reads CODEAGENT_SCHEDULE. Unknown values fall back to
+// input order.
+func ResolveScheduleMode() string {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("CODEAGENT_SCHEDULE"))) {
+	case ScheduleCriticalPath, "critical_path", "cp":
+		return ScheduleCriticalPath
+	default:
+		return ScheduleInputOrder
+	}
+}
+
+const (
+	defaultCacheTTL     = 24 * time.Hour
+	defaultCacheMaxSize = 256 << 20
+)
+
+// ResolveCacheTTL reads CODEAGENT_CACHE_TTL, how long a cached result stays
+// valid (default 24h). 0 keeps results until they are evicted by size.
+func ResolveCacheTTL() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_CACHE_TTL"))
+	if raw == "" {
+		return defaultCacheTTL
+	}
+	if raw == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return defaultCacheTTL
+	}
+	return d
+}
+
+// ResolveCacheMaxSize reads CODEAGENT_CACHE_MAX_SIZE, the total size of the
+// result cache in bytes or with a K/M/G suffix (default 256M). 0 means
+// unlimited.
+func ResolveCacheMaxSize() int64 {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_CACHE_MAX_SIZE"))
+	if raw == "" {
+		return defaultCacheMaxSize
+	}
+	n, err := utils.ParseByteSize(raw)
+	if err != nil {
+		return defaultCacheMaxSize
+	}
+	return n
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// EnvPolicy controls the environment a backend starts with. The zero value
+// inherits the wrapper's full environment.
+type EnvPolicy struct {
+	// Env is set on top of the inherited and injected variables.
+	Env map[string]string
+	// Passthrough lists glob patterns of inherited variables to keep; when
+	// empty every inherited variable is kept. Variables the wrapper injects
+	// itself (API keys, TMPDIR, ...) are not affected.
+	Passthrough []string
+	// Unset lists glob patterns of variables to remove, inherited or not.
+	Unset []string
+}
+
+// IsZero reports whether the policy leaves the environment alone.
+func (p EnvPolicy) IsZero() bool {
+	return len(p.Env) == 0 && len(p.Passthrough) == 0 && len(p.Unset) == 0
+}
+
+// Inherits reports whether the inherited variable name is kept.
+func (p EnvPolicy) Inherits(name string) bool {
+	return len(p.Passthrough) == 0 || matchEnvGlob(p.Passthrough, name)
+}
+
+// Removes reports whether name is unset.
+func (p EnvPolicy) Removes(name string) bool {
+	return matchEnvGlob(p.Unset, name)
+}
+
+// Merge returns p with over applied: over's variables win per name and its
+// patterns are added to p's.
+func (p EnvPolicy) Merge(over EnvPolicy) EnvPolicy {
+	out := EnvPolicy{
+		Passthrough: appendUnique(append([]string(nil), p.Passthrough...), over.Passthrough...),
+		Unset:       appendUnique(append([]string(nil), p.Unset...), over.Unset...),
+	}
+	if len(p.Env)+len(over.Env) > 0 {
+		out.Env = make(map[string]string, len(p.Env)+len(over.Env))
+		for k, v := range p.Env {
+			out.Env[k] = v
+		}
+		for k, v := range over.Env {
+			out.Env[k] = v
+		}
+	}
+	return out
+}
+
+func matchEnvGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, have := range list {
+			if have == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// validateEnvFields checks variable names and glob patterns of an agent.
+func validateEnvFields(env map[string]string, passthrough, unset []string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ValidateEnvName(name); err != nil {
+			return fmt.Errorf("env: %w", err)
+		}
+	}
+	for field, patterns := range map[string][]string{"env_passthrough": passthrough, "env_unset": unset} {
+		for _, pattern := range patterns {
+			if pattern == inheritListMarker {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("%s: invalid pattern %q", field, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateEnvName rejects names that cannot be environment variables.
+func ValidateEnvName(name string) error {
+	if name == "" || strings.ContainsAny(name, "= \t\n\x00") {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	return nil
+}
+
+// ParseEnvAssignment splits a KEY=VALUE task header value.
+func ParseEnvAssignment(raw string) (string, string, error) {
+	name, value, ok := strings.Cut(strings.TrimSpace(raw), "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected KEY=VALUE, got %q", raw)
+	}
+	name = strings.TrimSpace(name)
+	if err := ValidateEnvName(name); err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+// ResolveAgentEnvPolicy returns the env, env_passthrough and env_unset of an
+// agent in models.json. Dynamic agents have none.
+func ResolveAgentEnvPolicy(agentName string) EnvPolicy {
+	agentName = strings.TrimSpace(agentName)
+	if agentName == "" {
+		return EnvPolicy{}
+	}
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return EnvPolicy{}
+	}
+	agent, ok := cfg.Agents[agentName]
+	if !ok {
+		return EnvPolicy{}
+	}
+	return EnvPolicy{
+		Env:         agent.Env,
+		Passthrough: withoutMarker(agent.EnvPassthrough),
+		Unset:       withoutMarker(agent.EnvUnset),
+	}
+}
+
+func withoutMarker(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if s != inheritListMarker {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveAgentEnvPolicy_Inheritance(t *testing.T) {
+	writeModelsConfigForTest(t, `{
+  "agents": {
+    "base": { "backend": "codex", "model": "m", "env": { "A": "1", "B": "2" }, "env_passthrough": ["PATH", "HOME"], "env_unset": ["AWS_*"] },
+    "child": { "extends": "base", "env": { "B": "3" }, "env_passthrough": ["...", "GO*"] },
+    "minimal": { "backend": "codex", "model": "m", "env_passthrough": ["PATH"] }
+  }
+}`)
+	got := ResolveAgentEnvPolicy("child")
+	want := EnvPolicy{
+		Env:         map[string]string{"A": "1", "B": "3"},
+		Passthrough: []string{"PATH", "HOME", "GO*"},
+		Unset:       []string{"AWS_*"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("child policy = %+v, want %+v", got, want)
+	}
+	if !got.Inherits("GOPATH") || got.Inherits("SECRET") || !got.Removes("AWS_PROFILE") {
+		t.Fatalf("unexpected matching for %+v", got)
+	}
+
+	merged := ResolveAgentEnvPolicy("minimal").Merge(EnvPolicy{Env: map[string]string{"X": "y"}, Passthrough: []string{"PATH", "LANG"}})
+	if !reflect.DeepEqual(merged.Passthrough, []string{"PATH", "LANG"}) || merged.Env["X"] != "y" {
+		t.Fatalf("merged = %+v", merged)
+	}
+	if p := ResolveAgentEnvPolicy("unknown"); !p.IsZero() || !p.Inherits("ANYTHING") {
+		t.Fatalf("unknown agent policy = %+v", p)
+	}
+}
+
+func TestLoadModelsConfig_InvalidEnv(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"agents": {"a": {"backend": "codex", "env": {"BAD=NAME": "x"}}}}`: "invalid variable name",
+		`{"agents": {"a": {"backend": "codex", "env_unset": ["[abc"]}}}`:    "env_unset: invalid pattern",
+	} {
+		writeModelsConfigForTest(t, body)
+		if _, err := modelsConfig(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want %q", body, err, want)
+		}
+	}
+}
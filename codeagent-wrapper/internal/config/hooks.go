@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hook failure policies.
+const (
+	HookOnFailureWarn = "warn" // log and carry on (default)
+	HookOnFailureFail = "fail" // fail the task, or the run for post_run hooks
+)
+
+// DefaultHookTimeout bounds a hook without its own "timeout".
+const DefaultHookTimeout = 30 * time.Second
+
+// HookConfig is one command under "hooks" in models.json.
+type HookConfig struct {
+	Command   string `json:"command"`              // run with sh -c (cmd /C on Windows)
+	Timeout   string `json:"timeout,omitempty"`    // Go duration; DefaultHookTimeout when empty
+	OnFailure string `json:"on_failure,omitempty"` // HookOnFailureWarn or HookOnFailureFail
+}
+
+// HooksConfig is the "hooks" object of models.json. Hooks of each kind run
+// in order.
+type HooksConfig struct {
+	PreTask  []HookConfig `json:"pre_task,omitempty"`
+	PostTask []HookConfig `json:"post_task,omitempty"`
+	PostRun  []HookConfig `json:"post_run,omitempty"`
+}
+
+// IsZero reports whether no hook is configured.
+func (h HooksConfig) IsZero() bool {
+	return len(h.PreTask) == 0 && len(h.PostTask) == 0 && len(h.PostRun) == 0
+}
+
+// TimeoutDuration returns the hook's timeout, falling back to
+// DefaultHookTimeout when it is unset or invalid.
+func (h HookConfig) TimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(strings.TrimSpace(h.Timeout))
+	if err != nil || d <= 0 {
+		return DefaultHookTimeout
+	}
+	return d
+}
+
+// FailsOnError reports whether a failing hook fails the task or run.
+func (h HookConfig) FailsOnError() bool {
+	return strings.TrimSpace(h.OnFailure) == HookOnFailureFail
+}
+
+func (h HookConfig) validate() error {
+	if strings.TrimSpace(h.Command) == "" {
+		return fmt.Errorf("command is required")
+	}
+	if raw := strings.TrimSpace(h.Timeout); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("timeout %q: %w", raw, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("timeout must be > 0, got %s", raw)
+		}
+	}
+	switch strings.TrimSpace(h.OnFailure) {
+	case "", HookOnFailureWarn, HookOnFailureFail:
+	default:
+		return fmt.Errorf("on_failure must be %q or %q, got %q", HookOnFailureWarn, HookOnFailureFail, h.OnFailure)
+	}
+	return nil
+}
+
+func (h HooksConfig) validate() error {
+	for kind, hooks := range map[string][]HookConfig{"pre_task": h.PreTask, "post_task": h.PostTask, "post_run": h.PostRun} {
+		for i, hook := range hooks {
+			if err := hook.validate(); err != nil {
+				return fmt.Errorf("hooks.%s[%d]: %w", kind, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeHooksConfig runs the project's hooks after the home ones.
+func mergeHooksConfig(home, project HooksConfig) HooksConfig {
+	return HooksConfig{
+		PreTask:  append(append([]HookConfig(nil), home.PreTask...), project.PreTask...),
+		PostTask: append(append([]HookConfig(nil), home.PostTask...), project.PostTask...),
+		PostRun:  append(append([]HookConfig(nil), home.PostRun...), project.PostRun...),
+	}
+}
+
+// ResolveHooks returns the hooks configured in models.json. A missing or
+// invalid models.json has none.
+func ResolveHooks() HooksConfig {
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return HooksConfig{}
+	}
+	return cfg.Hooks
+}
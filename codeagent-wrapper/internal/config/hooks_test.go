@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveHooks(t *testing.T) {
+	writeModelsConfigForTest(t, `{
+  "agents": { "a": { "backend": "codex" } },
+  "hooks": {
+    "pre_task": [{ "command": "./guard.sh", "on_failure": "fail" }],
+    "post_task": [{ "command": "make lint", "timeout": "2m" }],
+    "post_run": [{ "command": "./notify.sh" }]
+  }
+}`)
+	hooks := ResolveHooks()
+	if len(hooks.PreTask) != 1 || len(hooks.PostTask) != 1 || len(hooks.PostRun) != 1 {
+		t.Fatalf("hooks = %+v", hooks)
+	}
+	if !hooks.PreTask[0].FailsOnError() || hooks.PostTask[0].FailsOnError() {
+		t.Fatalf("on_failure not applied: %+v", hooks)
+	}
+	if got := hooks.PostTask[0].TimeoutDuration(); got != 2*time.Minute {
+		t.Fatalf("post_task timeout = %s", got)
+	}
+	if got := hooks.PostRun[0].TimeoutDuration(); got != DefaultHookTimeout {
+		t.Fatalf("default timeout = %s", got)
+	}
+}
+
+func TestLoadModelsConfig_InvalidHooks(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"hooks": {"pre_task": [{"command": " "}]}}`:                        "hooks.pre_task[0]: command is required",
+		`{"hooks": {"post_task": [{"command": "x", "timeout": "soon"}]}}`:    "hooks.post_task[0]: timeout",
+		`{"hooks": {"post_run": [{"command": "x", "on_failure": "abort"}]}}`: "on_failure must be",
+	} {
+		writeModelsConfigForTest(t, body)
+		if _, err := modelsConfig(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestValidateModelsConfig_Hooks(t *testing.T) {
+	issues := ValidateModelsConfig([]byte(`{
+  "hooks": {
+    "pre_task": [{ "timeout": "1s" }],
+    "post_task": [{ "command": "x", "on_failure": "abort" }],
+    "on_start": []
+  }
+}`), ValidateOptions{})
+	var paths []string
+	for _, issue := range issues {
+		paths = append(paths, issue.Path)
+	}
+	for _, want := range []string{"hooks.pre_task[0]", "hooks.post_task[0].on_failure", "hooks.on_start"} {
+		if _, ok := findIssue(issues, want); !ok {
+			t.Errorf("no issue at %s; got %v", want, paths)
+		}
+	}
+}
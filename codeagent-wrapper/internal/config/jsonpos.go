@@ -0,0 +1,280 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+// jsonKind is the type of a parsed JSON value.
+type jsonKind int
+
+const (
+	jsonNull jsonKind = iota
+	jsonBool
+	jsonNumber
+	jsonString
+	jsonArray
+	jsonObject
+)
+
+func (k jsonKind) String() string {
+	switch k {
+	case jsonBool:
+		return "boolean"
+	case jsonNumber:
+		return "number"
+	case jsonString:
+		return "string"
+	case jsonArray:
+		return "array"
+	case jsonObject:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// jsonNode is a JSON value annotated with the 1-based line and column where
+// it starts. encoding/json drops positions, which validation needs.
+type jsonNode struct {
+	kind    jsonKind
+	line    int
+	col     int
+	str     string // string value, or the raw number literal
+	boolean bool
+	members []jsonMember // object members in document order
+	items   []*jsonNode
+}
+
+type jsonMember struct {
+	key   string
+	line  int
+	col   int
+	value *jsonNode
+}
+
+// jsonSyntaxError reports malformed JSON at a line and column.
+type jsonSyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *jsonSyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+type jsonPosParser struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int
+}
+
+func parseJSONWithPositions(data []byte) (*jsonNode, error) {
+	p := &jsonPosParser{data: data, line: 1}
+	p.skipSpace()
+	if p.pos == 0 && len(data) >= 3 && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF {
+		p.pos = 3
+		p.lineStart = 3
+		p.skipSpace()
+	}
+	node, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected %q after top-level value", p.data[p.pos])
+	}
+	return node, nil
+}
+
+func (p *jsonPosParser) column() int {
+	return utf8.RuneCount(p.data[p.lineStart:p.pos]) + 1
+}
+
+func (p *jsonPosParser) errorf(format string, args ...any) error {
+	return &jsonSyntaxError{Line: p.line, Column: p.column(), Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *jsonPosParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\n':
+			p.pos++
+			p.line++
+			p.lineStart = p.pos
+		case ' ', '\t', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonPosParser) parseValue() (*jsonNode, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	node := &jsonNode{line: p.line, col: p.column()}
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return node, p.parseObject(node)
+	case c == '[':
+		return node, p.parseArray(node)
+	case c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		node.kind = jsonString
+		node.str = s
+		return node, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.data) && isJSONNumberByte(p.data[p.pos]) {
+			p.pos++
+		}
+		raw := string(p.data[start:p.pos])
+		if _, err := strconv.ParseFloat(raw, 64); err != nil || !json.Valid(p.data[start:p.pos]) {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", raw)
+		}
+		node.kind = jsonNumber
+		node.str = raw
+		return node, nil
+	default:
+		for _, lit := range []struct {
+			text string
+			kind jsonKind
+			val  bool
+		}{{"true", jsonBool, true}, {"false", jsonBool, false}, {"null", jsonNull, false}} {
+			if len(p.data)-p.pos >= len(lit.text) && string(p.data[p.pos:p.pos+len(lit.text)]) == lit.text {
+				p.pos += len(lit.text)
+				node.kind = lit.kind
+				node.boolean = lit.val
+				return node, nil
+			}
+		}
+		return nil, p.errorf("unexpected character %q", c)
+	}
+}
+
+func isJSONNumberByte(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+func (p *jsonPosParser) parseString() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal(p.data[start:p.pos], &s); err != nil {
+				p.pos = start
+				return "", p.errorf("invalid string literal")
+			}
+			return s, nil
+		case '\n':
+			p.pos = start
+			return "", p.errorf("unterminated string")
+		default:
+			p.pos++
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonPosParser) parseObject(node *jsonNode) error {
+	node.kind = jsonObject
+	p.pos++ // {
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return p.errorf("expected object key string")
+		}
+		member := jsonMember{line: p.line, col: p.column()}
+		key, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		member.key = key
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return p.errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		member.value = value
+		node.members = append(node.members, member)
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return p.errorf("unexpected end of input, expected ',' or '}'")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == '}' {
+				return p.errorf("trailing comma before '}'")
+			}
+		case '}':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("expected ',' or '}' after value of %q", key)
+		}
+	}
+}
+
+func (p *jsonPosParser) parseArray(node *jsonNode) error {
+	node.kind = jsonArray
+	p.pos++ // [
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == ']' {
+		p.pos++
+		return nil
+	}
+	for {
+		p.skipSpace()
+		item, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		node.items = append(node.items, item)
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return p.errorf("unexpected end of input, expected ',' or ']'")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				return p.errorf("trailing comma before ']'")
+			}
+		case ']':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
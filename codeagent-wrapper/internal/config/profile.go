@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// inheritListMarker inside allowed_tools/disallowed_tools expands to the
+// inherited list, so ["...", "Bash"] appends while ["Read"] replaces.
+const inheritListMarker = "..."
+
+// ProfileConfig is a named overlay from models.json "profiles" that --profile
+// applies on top of whichever agent is selected. Set fields override the
+// agent's; tool lists follow the same "..." rule as extends.
+type ProfileConfig struct {
+	Backend         string   `json:"backend,omitempty"`
+	Model           string   `json:"model,omitempty"`
+	PromptFile      string   `json:"prompt_file,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Yolo            *bool    `json:"yolo,omitempty"`
+	Reasoning       string   `json:"reasoning,omitempty"`
+	BaseURL         string   `json:"base_url,omitempty"`
+	APIKey          string   `json:"api_key,omitempty"`
+	AllowedTools    []string `json:"allowed_tools,omitempty"`
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+}
+
+func (a AgentModelConfig) yoloEnabled() bool {
+	return a.Yolo != nil && *a.Yolo
+}
+
+// overlayAgent returns base with every field set in over taking precedence.
+// Description is deliberately not inherited: it describes one agent.
+func overlayAgent(base, over AgentModelConfig) AgentModelConfig {
+	merged := base
+	merged.Extends = over.Extends
+	merged.Description = over.Description
+	setString := func(dst *string, v string) {
+		if strings.TrimSpace(v) != "" {
+			*dst = v
+		}
+	}
+	setString(&merged.Backend, over.Backend)
+	setString(&merged.Model, over.Model)
+	setString(&merged.PromptFile, over.PromptFile)
+	setString(&merged.Reasoning, over.Reasoning)
+	setString(&merged.BaseURL, over.BaseURL)
+	setString(&merged.APIKey, over.APIKey)
+	setString(&merged.MinStartInterval, over.MinStartInterval)
+	if over.Yolo != nil {
+		merged.Yolo = over.Yolo
+	}
+	if over.MaxConcurrency != 0 {
+		merged.MaxConcurrency = over.MaxConcurrency
+	}
+	merged.Limits = mergeResourceLimitsConfig(base.Limits, over.Limits)
+	merged.Env = EnvPolicy{Env: base.Env}.Merge(EnvPolicy{Env: over.Env}).Env
+	merged.EnvPassthrough = mergeToolList(base.EnvPassthrough, over.EnvPassthrough)
+	merged.EnvUnset = mergeToolList(base.EnvUnset, over.EnvUnset)
+	merged.AllowedTools = mergeToolList(base.AllowedTools, over.AllowedTools)
+	merged.DisallowedTools = mergeToolList(base.DisallowedTools, over.DisallowedTools)
+	return merged
+}
+
+// mergeToolList inherits parent when child is unset, replaces it otherwise,
+// and splices parent in wherever child contains "...". Duplicates are
+// dropped, keeping the first occurrence.
+func mergeToolList(parent, child []string) []string {
+	if child == nil {
+		return parent
+	}
+	merged := make([]string, 0, len(parent)+len(child))
+	seen := make(map[string]bool, len(parent)+len(child))
+	add := func(tool string) {
+		if !seen[tool] {
+			seen[tool] = true
+			merged = append(merged, tool)
+		}
+	}
+	for _, tool := range child {
+		if tool == inheritListMarker {
+			for _, inherited := range parent {
+				add(inherited)
+			}
+			continue
+		}
+		add(tool)
+	}
+	return merged
+}
+
+func (p ProfileConfig) asAgent() AgentModelConfig {
+	return AgentModelConfig{
+		Backend:         p.Backend,
+		Model:           p.Model,
+		PromptFile:      p.PromptFile,
+		Yolo:            p.Yolo,
+		Reasoning:       p.Reasoning,
+		BaseURL:         p.BaseURL,
+		APIKey:          p.APIKey,
+		AllowedTools:    p.AllowedTools,
+		DisallowedTools: p.DisallowedTools,
+	}
+}
+
+// resolveAgentInheritance flattens "extends" chains in place so every other
+// reader sees fully merged agents. Unknown parents and cycles are errors.
+func resolveAgentInheritance(cfg *ModelsConfig) error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(cfg.Agents))
+	resolved := make(map[string]AgentModelConfig, len(cfg.Agents))
+
+	var resolve func(name string, chain []string) (AgentModelConfig, error)
+	resolve = func(name string, chain []string) (AgentModelConfig, error) {
+		switch state[name] {
+		case done:
+			return resolved[name], nil
+		case visiting:
+			return AgentModelConfig{}, fmt.Errorf("inheritance cycle: %s", strings.Join(append(chain, name), " -> "))
+		}
+		agent := cfg.Agents[name]
+		parentName := strings.TrimSpace(agent.Extends)
+		if parentName == "" {
+			agent.AllowedTools = mergeToolList(nil, agent.AllowedTools)
+			agent.DisallowedTools = mergeToolList(nil, agent.DisallowedTools)
+			state[name] = done
+			resolved[name] = agent
+			return agent, nil
+		}
+		if _, ok := cfg.Agents[parentName]; !ok {
+			return AgentModelConfig{}, fmt.Errorf("agents.%s extends unknown agent %q", name, parentName)
+		}
+		state[name] = visiting
+		parent, err := resolve(parentName, append(chain, name))
+		if err != nil {
+			return AgentModelConfig{}, err
+		}
+		merged := overlayAgent(parent, agent)
+		merged.Extends = parentName
+		state[name] = done
+		resolved[name] = merged
+		return merged, nil
+	}
+
+	names := make([]string, 0, len(cfg.Agents))
+	for name := range cfg.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	for name, agent := range resolved {
+		cfg.Agents[name] = agent
+	}
+	return nil
+}
+
+var (
+	activeProfileMu sync.RWMutex
+	activeProfile   string
+)
+
+// SetAgentProfile selects the models.json profile applied on top of every
+// agent resolved afterwards (single, parallel, judge and dry-run alike). An
+// empty name clears it.
+func SetAgentProfile(name string) error {
+	name = strings.TrimSpace(name)
+	if name != "" {
+		cfg, err := modelsConfig()
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Profiles[name]; !ok {
+			available := make([]string, 0, len(cfg.Profiles))
+			for p := range cfg.Profiles {
+				available = append(available, p)
+			}
+			sort.Strings(available)
+			if len(available) == 0 {
+				return fmt.Errorf("profile %q not found: no profiles defined in %s", name, modelsConfigTildePath)
+			}
+			return fmt.Errorf("profile %q not found in %s (available: %s)", name, modelsConfigTildePath, strings.Join(available, ", "))
+		}
+	}
+	activeProfileMu.Lock()
+	activeProfile = name
+	activeProfileMu.Unlock()
+	return nil
+}
+
+// AgentProfile returns the profile set by SetAgentProfile.
+func AgentProfile() string {
+	activeProfileMu.RLock()
+	defer activeProfileMu.RUnlock()
+	return activeProfile
+}
+
+func applyActiveProfile(cfg *ModelsConfig, agent AgentModelConfig) AgentModelConfig {
+	name := AgentProfile()
+	if name == "" || cfg == nil {
+		return agent
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return agent
+	}
+	merged := overlayAgent(agent, profile.asAgent())
+	merged.Extends = agent.Extends
+	merged.Description = agent.Description
+	return merged
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeModelsConfigForTest(t *testing.T, body string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(ResetModelsConfigCacheForTest)
+	ResetModelsConfigCacheForTest()
+
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return home
+}
+
+const inheritanceModelsJSON = `{
+  "default_backend": "codex",
+  "default_model": "gpt-default",
+  "backends": { "claude": { "api_key": "backend-key" } },
+  "agents": {
+    "base": {
+      "backend": "claude",
+      "model": "sonnet",
+      "yolo": true,
+      "reasoning": "medium",
+      "description": "Base agent",
+      "allowed_tools": ["Read", "Grep"],
+      "disallowed_tools": ["WebFetch"]
+    },
+    "develop": {
+      "extends": "base",
+      "prompt_file": "~/.claude/develop.md",
+      "allowed_tools": ["...", "Write", "Read"]
+    },
+    "review": {
+      "extends": "develop",
+      "reasoning": "high",
+      "yolo": false,
+      "allowed_tools": ["Read"],
+      "disallowed_tools": []
+    }
+  },
+  "profiles": {
+    "cheap": { "model": "haiku", "reasoning": "low", "allowed_tools": ["...", "Bash"] },
+    "codex": { "backend": "codex", "model": "gpt-5" }
+  }
+}`
+
+func TestResolveAgentConfig_Extends(t *testing.T) {
+	writeModelsConfigForTest(t, inheritanceModelsJSON)
+
+	backend, model, promptFile, reasoning, _, apiKey, yolo, allowed, disallowed, err := ResolveAgentConfig("develop")
+	if err != nil {
+		t.Fatalf("ResolveAgentConfig(develop): %v", err)
+	}
+	if backend != "claude" || model != "sonnet" || promptFile != "~/.claude/develop.md" || reasoning != "medium" || !yolo || apiKey != "backend-key" {
+		t.Errorf("develop = %s %s %s %s yolo=%v key=%s", backend, model, promptFile, reasoning, yolo, apiKey)
+	}
+	if want := []string{"Read", "Grep", "Write"}; !reflect.DeepEqual(allowed, want) {
+		t.Errorf("develop allowed_tools = %v, want %v", allowed, want)
+	}
+	if want := []string{"WebFetch"}; !reflect.DeepEqual(disallowed, want) {
+		t.Errorf("develop disallowed_tools = %v, want %v", disallowed, want)
+	}
+
+	_, _, promptFile, reasoning, _, _, yolo, allowed, disallowed, err = ResolveAgentConfig("review")
+	if err != nil {
+		t.Fatalf("ResolveAgentConfig(review): %v", err)
+	}
+	if promptFile != "~/.claude/develop.md" || reasoning != "high" || yolo {
+		t.Errorf("review = %s %s yolo=%v", promptFile, reasoning, yolo)
+	}
+	if !reflect.DeepEqual(allowed, []string{"Read"}) || len(disallowed) != 0 {
+		t.Errorf("review tools = %v / %v, want replaced lists", allowed, disallowed)
+	}
+
+	agents, err := ListAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range agents {
+		if a.Name == "develop" && (a.Extends != "base" || a.Description != "") {
+			t.Errorf("develop info = %+v: extends should be kept and description not inherited", a)
+		}
+	}
+}
+
+func TestLoadModelsConfig_ExtendsErrors(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want string
+	}{
+		"cycle": {
+			body: `{"agents": {"a": {"extends": "b", "model": "m"}, "b": {"extends": "c"}, "c": {"extends": "a"}}}`,
+			want: "inheritance cycle: a -> b -> c -> a",
+		},
+		"self": {
+			body: `{"agents": {"a": {"extends": "a", "model": "m"}}}`,
+			want: "inheritance cycle: a -> a",
+		},
+		"unknown": {
+			body: `{"agents": {"a": {"extends": "missing", "model": "m"}}}`,
+			want: `agents.a extends unknown agent "missing"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			writeModelsConfigForTest(t, tt.body)
+			_, err := loadModelsConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAgentProfile(t *testing.T) {
+	home := writeModelsConfigForTest(t, inheritanceModelsJSON)
+	if err := os.MkdirAll(filepath.Join(home, ".codeagent", "agents"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".codeagent", "agents", "docs.md"), []byte("docs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetAgentProfile("cheap"); err != nil {
+		t.Fatalf("SetAgentProfile: %v", err)
+	}
+	backend, model, _, reasoning, _, _, yolo, allowed, _, err := ResolveAgentConfig("develop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend != "claude" || model != "haiku" || reasoning != "low" || !yolo {
+		t.Errorf("develop+cheap = %s %s %s yolo=%v", backend, model, reasoning, yolo)
+	}
+	if want := []string{"Read", "Grep", "Write", "Bash"}; !reflect.DeepEqual(allowed, want) {
+		t.Errorf("develop+cheap allowed_tools = %v, want %v", allowed, want)
+	}
+
+	if err := SetAgentProfile("codex"); err != nil {
+		t.Fatal(err)
+	}
+	backend, model, promptFile, _, _, _, _, _, _, err := ResolveAgentConfig("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend != "codex" || model != "gpt-5" || promptFile != "~/.codeagent/agents/docs.md" {
+		t.Errorf("dynamic docs+codex = %s %s %s", backend, model, promptFile)
+	}
+
+	err = SetAgentProfile("thorough")
+	if err == nil || !strings.Contains(err.Error(), "available: cheap, codex") {
+		t.Fatalf("unknown profile err = %v", err)
+	}
+	if AgentProfile() != "codex" {
+		t.Errorf("a failed SetAgentProfile should keep the previous profile, got %q", AgentProfile())
+	}
+
+	ResetModelsConfigCacheForTest()
+	if AgentProfile() != "" {
+		t.Errorf("reset should clear the profile")
+	}
+}
@@ -0,0 +1,471 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/viper"
+)
+
+const (
+	projectConfigDirName = ".codeagent"
+	trustStoreFileName   = "trusted-projects.json"
+
+	// ProjectConfigEnvKey disables project discovery when set to a false value.
+	ProjectConfigEnvKey = "CODEAGENT_PROJECT_CONFIG"
+)
+
+// Project is a .codeagent directory discovered by walking up from a task
+// workdir. Its config, models.json and agents/*.md are layered over the
+// ones in $HOME/.codeagent.
+//
+// Settings that can widen what a backend may do or where credentials go
+// (yolo, allowed_tools, base_url, api_key, prompt_env, hooks, skip-permissions) are
+// Sensitive:
+// they only take effect once the user trusts the project, so cloning a
+// hostile repository cannot enable them silently.
+type Project struct {
+	Root        string   // directory containing .codeagent
+	Dir         string   // Root/.codeagent
+	Sensitive   []string // e.g. "agents.develop.yolo", "config.skip-permissions", "agents/review.md"
+	Fingerprint string   // hash of models.json, config.* and agents/*.md; trust is bound to it
+	Trusted     bool
+	// TrustStale is set when the project was trusted with a different
+	// fingerprint, i.e. its sensitive files changed since.
+	TrustStale bool
+}
+
+// Restricted reports whether sensitive settings are present but not trusted.
+func (p *Project) Restricted() bool {
+	return p != nil && !p.Trusted && len(p.Sensitive) > 0
+}
+
+// ModelsPath returns the project's models.json path.
+func (p *Project) ModelsPath() string {
+	return filepath.Join(p.Dir, "models.json")
+}
+
+// ConfigFile returns the project's config.<ext> file, or "" if none exists.
+func (p *Project) ConfigFile() string {
+	for _, ext := range viper.SupportedExts {
+		path := filepath.Join(p.Dir, "config."+ext)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// resolvePath makes a project-relative path absolute; "~" and absolute
+// paths are returned unchanged.
+func (p *Project) resolvePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" || path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~\\") || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.Dir, path)
+}
+
+// DiscoverProject walks up from workDir to the filesystem root and returns
+// the first .codeagent directory other than $HOME/.codeagent. It returns nil
+// when none is found or CODEAGENT_PROJECT_CONFIG is false.
+func DiscoverProject(workDir string) (*Project, error) {
+	if !EnvFlagDefaultTrue(ProjectConfigEnvKey) {
+		return nil, nil
+	}
+	if strings.TrimSpace(workDir) == "" {
+		workDir = "."
+	}
+	dir, err := filepath.Abs(workDir)
+	if err != nil {
+		return nil, err
+	}
+	homeDir := ""
+	if home, err := os.UserHomeDir(); err == nil && strings.TrimSpace(home) != "" {
+		homeDir = filepath.Clean(filepath.Join(home, projectConfigDirName))
+	}
+
+	for {
+		candidate := filepath.Join(dir, projectConfigDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() && !sameDir(candidate, homeDir) {
+			return loadProject(dir, candidate)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func sameDir(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && filepath.Clean(ra) == filepath.Clean(rb)
+}
+
+func loadProject(root, dir string) (*Project, error) {
+	p := &Project{Root: root, Dir: dir}
+
+	fingerprint := sha256.New()
+	if data, err := os.ReadFile(p.ModelsPath()); err == nil { // #nosec G304 -- fixed name inside the discovered project dir
+		fmt.Fprintf(fingerprint, "models.json\x00%d\x00", len(data))
+		fingerprint.Write(data)
+		var cfg ModelsConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse project models config %s: %w", p.ModelsPath(), err)
+		}
+		p.Sensitive = append(p.Sensitive, sensitiveModelsSettings(&cfg)...)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read project models config %s: %w", p.ModelsPath(), err)
+	}
+
+	if path := p.ConfigFile(); path != "" {
+		data, err := os.ReadFile(path) // #nosec G304 -- config.<ext> inside the discovered project dir
+		if err != nil {
+			return nil, fmt.Errorf("failed to read project config %s: %w", path, err)
+		}
+		fmt.Fprintf(fingerprint, "%s\x00%d\x00", filepath.Base(path), len(data))
+		fingerprint.Write(data)
+		pv := viper.New()
+		pv.SetConfigFile(path)
+		if err := pv.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to parse project config %s: %w", path, err)
+		}
+		for _, key := range sensitiveConfigKeys {
+			if sensitiveConfigSet(pv, key) {
+				p.Sensitive = append(p.Sensitive, "config."+key)
+			}
+		}
+	}
+
+	// A project agent may add new dynamic agents freely, but replacing one
+	// the user already has needs trust.
+	agentFiles, _ := filepath.Glob(filepath.Join(p.Dir, "agents", "*.md"))
+	sort.Strings(agentFiles)
+	for _, path := range agentFiles {
+		data, err := os.ReadFile(path) // #nosec G304 -- agents/*.md inside the discovered project dir
+		if err != nil {
+			return nil, fmt.Errorf("failed to read project agent %s: %w", path, err)
+		}
+		name := filepath.Base(path)
+		fmt.Fprintf(fingerprint, "agents/%s\x00%d\x00", name, len(data))
+		fingerprint.Write(data)
+		if homeDynamicAgentPath(strings.TrimSuffix(name, ".md")) != "" {
+			p.Sensitive = append(p.Sensitive, "agents/"+name)
+		}
+	}
+	p.Fingerprint = "sha256:" + hex.EncodeToString(fingerprint.Sum(nil))
+
+	store, err := loadTrustStore()
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := store.Projects[p.Root]; ok {
+		p.Trusted = entry.Fingerprint == p.Fingerprint
+		p.TrustStale = !p.Trusted
+	}
+	return p, nil
+}
+
+// sensitiveConfigKeys are config settings an untrusted project may not
+// make: they skip permission prompts, read or write files anywhere, open a
+// port, or turn off or widen the sandbox.
+var sensitiveConfigKeys = []string{
+	"skip-permissions", "dangerously-skip-permissions",
+	"prompt-file", "output", "transcript-dir", "record", "replay", "metrics-file", "metrics-listen",
+	"sandbox", "sandbox-write", "sandbox-read", "sandbox-deny",
+}
+
+// sensitiveConfigSet reports whether pv sets key to a value that needs
+// trust. Enabling the sandbox or leaving permission prompts on does not.
+func sensitiveConfigSet(pv *viper.Viper, key string) bool {
+	if !pv.IsSet(key) {
+		return false
+	}
+	switch key {
+	case "skip-permissions", "dangerously-skip-permissions":
+		return pv.GetBool(key)
+	case "sandbox":
+		return !pv.GetBool(key)
+	}
+	return true
+}
+
+func sensitiveModelsSettings(cfg *ModelsConfig) []string {
+	var found []string
+	addAgent := func(prefix string, yolo *bool, baseURL, apiKey string, allowedTools []string) {
+		if yolo != nil && *yolo {
+			found = append(found, prefix+".yolo")
+		}
+		if strings.TrimSpace(baseURL) != "" {
+			found = append(found, prefix+".base_url")
+		}
+		if strings.TrimSpace(apiKey) != "" {
+			found = append(found, prefix+".api_key")
+		}
+		if len(allowedTools) > 0 {
+			found = append(found, prefix+".allowed_tools")
+		}
+	}
+	for name, agent := range cfg.Agents {
+		addAgent("agents."+name, agent.Yolo, agent.BaseURL, agent.APIKey, agent.AllowedTools)
+		if len(agent.Env) > 0 {
+			found = append(found, "agents."+name+".env")
+		}
+		if len(agent.EnvPassthrough) > 0 {
+			found = append(found, "agents."+name+".env_passthrough")
+		}
+		if len(agent.EnvUnset) > 0 {
+			found = append(found, "agents."+name+".env_unset")
+		}
+	}
+	for name, profile := range cfg.Profiles {
+		addAgent("profiles."+name, profile.Yolo, profile.BaseURL, profile.APIKey, profile.AllowedTools)
+	}
+	for name, backend := range cfg.Backends {
+		addAgent("backends."+name, nil, backend.BaseURL, backend.APIKey, nil)
+	}
+	if len(cfg.PromptEnv) > 0 {
+		found = append(found, "prompt_env")
+	}
+	if !cfg.Hooks.IsZero() {
+		found = append(found, "hooks")
+	}
+	sort.Strings(found)
+	return found
+}
+
+// restrictModelsConfig drops the sensitive settings of an untrusted project.
+func restrictModelsConfig(cfg *ModelsConfig) {
+	for name, agent := range cfg.Agents {
+		agent.Yolo, agent.BaseURL, agent.APIKey, agent.AllowedTools = nil, "", "", nil
+		agent.Env, agent.EnvPassthrough, agent.EnvUnset = nil, nil, nil
+		cfg.Agents[name] = agent
+	}
+	for name, profile := range cfg.Profiles {
+		profile.Yolo, profile.BaseURL, profile.APIKey, profile.AllowedTools = nil, "", "", nil
+		cfg.Profiles[name] = profile
+	}
+	for name, backend := range cfg.Backends {
+		backend.BaseURL, backend.APIKey = "", ""
+		cfg.Backends[name] = backend
+	}
+	cfg.PromptEnv = nil
+	cfg.Hooks = HooksConfig{}
+}
+
+// mergeModelsConfig layers a project models.json over the home one: scalar
+// defaults override when set, backends merge field by field, and agents and
+// profiles replace same-named entries (a project agent may still extend a
+// home agent, since inheritance is resolved after merging).
+func mergeModelsConfig(home, project *ModelsConfig) *ModelsConfig {
+	merged := *home
+	if strings.TrimSpace(project.DefaultBackend) != "" {
+		merged.DefaultBackend = project.DefaultBackend
+	}
+	if strings.TrimSpace(project.DefaultModel) != "" {
+		merged.DefaultModel = project.DefaultModel
+	}
+
+	merged.Agents = make(map[string]AgentModelConfig, len(home.Agents)+len(project.Agents))
+	merged.agentSources = make(map[string]string, len(home.Agents)+len(project.Agents))
+	for name, agent := range home.Agents {
+		merged.Agents[name] = agent
+		merged.agentSources[name] = AgentSourceModels
+	}
+	for name, agent := range project.Agents {
+		merged.Agents[name] = agent
+		merged.agentSources[name] = AgentSourceProject
+	}
+
+	if len(project.PromptEnv) > 0 {
+		merged.PromptEnv = append(append([]string(nil), home.PromptEnv...), project.PromptEnv...)
+	}
+	if !project.Hooks.IsZero() {
+		merged.Hooks = mergeHooksConfig(home.Hooks, project.Hooks)
+	}
+
+	if len(home.Profiles)+len(project.Profiles) > 0 {
+		merged.Profiles = make(map[string]ProfileConfig, len(home.Profiles)+len(project.Profiles))
+		for name, profile := range home.Profiles {
+			merged.Profiles[name] = profile
+		}
+		for name, profile := range project.Profiles {
+			merged.Profiles[name] = profile
+		}
+	}
+
+	if len(home.Backends)+len(project.Backends) > 0 {
+		merged.Backends = make(map[string]BackendConfig, len(home.Backends)+len(project.Backends))
+		for name, backend := range home.Backends {
+			merged.Backends[strings.ToLower(strings.TrimSpace(name))] = backend
+		}
+		for name, over := range project.Backends {
+			key := strings.ToLower(strings.TrimSpace(name))
+			backend := merged.Backends[key]
+			if strings.TrimSpace(over.BaseURL) != "" {
+				backend.BaseURL = over.BaseURL
+			}
+			if strings.TrimSpace(over.APIKey) != "" {
+				backend.APIKey = over.APIKey
+			}
+			if over.MaxConcurrency != 0 {
+				backend.MaxConcurrency = over.MaxConcurrency
+			}
+			if strings.TrimSpace(over.MinStartInterval) != "" {
+				backend.MinStartInterval = over.MinStartInterval
+			}
+			merged.Backends[key] = backend
+		}
+	}
+	return &merged
+}
+
+var (
+	activeProjectMu sync.RWMutex
+	activeProject   *Project
+)
+
+// SetActiveProject layers p over the home config for the rest of the run
+// (nil disables project config) and drops the cached models.json.
+func SetActiveProject(p *Project) {
+	activeProjectMu.Lock()
+	activeProject = p
+	activeProjectMu.Unlock()
+	modelsConfigCached = nil
+	modelsConfigErr = nil
+	modelsConfigOnce = sync.Once{}
+}
+
+// ActiveProject returns the project set by SetActiveProject, or nil.
+func ActiveProject() *Project {
+	activeProjectMu.RLock()
+	defer activeProjectMu.RUnlock()
+	return activeProject
+}
+
+// ProjectPromptDirs returns extra directories prompt files may be read from:
+// the active project's .codeagent directory.
+func ProjectPromptDirs() []string {
+	if p := ActiveProject(); p != nil {
+		return []string{p.Dir}
+	}
+	return nil
+}
+
+type trustEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	TrustedAt   time.Time `json:"trusted_at"`
+}
+
+type trustStore struct {
+	Projects map[string]trustEntry `json:"projects"`
+}
+
+func trustStorePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return "", fmt.Errorf("failed to resolve user home directory: %w", err)
+	}
+	return filepath.Join(home, projectConfigDirName, trustStoreFileName), nil
+}
+
+func loadTrustStore() (*trustStore, error) {
+	store := &trustStore{Projects: map[string]trustEntry{}}
+	path, err := trustStorePath()
+	if err != nil {
+		return store, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- fixed path under user home
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if store.Projects == nil {
+		store.Projects = map[string]trustEntry{}
+	}
+	return store, nil
+}
+
+func saveTrustStore(store *trustStore) error {
+	path, err := trustStorePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".trusted-projects-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// TrustProject records the project's current fingerprint in
+// ~/.codeagent/trusted-projects.json. Any later change to its models.json,
+// config file or agents/*.md invalidates the trust.
+func TrustProject(p *Project) error {
+	store, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	store.Projects[p.Root] = trustEntry{Fingerprint: p.Fingerprint, TrustedAt: time.Now().UTC()}
+	if err := saveTrustStore(store); err != nil {
+		return err
+	}
+	p.Trusted = true
+	p.TrustStale = false
+	return nil
+}
+
+// UntrustProject removes root from the trust store. It reports whether an
+// entry existed.
+func UntrustProject(root string) (bool, error) {
+	store, err := loadTrustStore()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := store.Projects[root]; !ok {
+		return false, nil
+	}
+	delete(store.Projects, root)
+	return true, saveTrustStore(store)
+}
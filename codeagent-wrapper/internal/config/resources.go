@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	utils "codeagent-wrapper/internal/utils"
+)
+
+// ResourceLimitsConfig is the "limits" object of an agent in models.json.
+type ResourceLimitsConfig struct {
+	Memory     string `json:"memory,omitempty"` // e.g. "4GiB" or "512M"
+	CPUSeconds int    `json:"cpu_seconds,omitempty"`
+	MaxProcs   int    `json:"max_procs,omitempty"`
+}
+
+// ResourceLimits caps the memory, CPU time and number of processes of a
+// backend and everything it starts. Zero values mean "unlimited".
+type ResourceLimits struct {
+	Memory     int64 // bytes
+	CPUSeconds int
+	MaxProcs   int
+}
+
+// IsZero reports whether the limits impose no restriction.
+func (l ResourceLimits) IsZero() bool {
+	return l.Memory <= 0 && l.CPUSeconds <= 0 && l.MaxProcs <= 0
+}
+
+// Merge returns l with every limit set in over taking precedence.
+func (l ResourceLimits) Merge(over ResourceLimits) ResourceLimits {
+	if over.Memory > 0 {
+		l.Memory = over.Memory
+	}
+	if over.CPUSeconds > 0 {
+		l.CPUSeconds = over.CPUSeconds
+	}
+	if over.MaxProcs > 0 {
+		l.MaxProcs = over.MaxProcs
+	}
+	return l
+}
+
+// String renders the limits that are set, e.g. "memory=4.0G cpu_seconds=600".
+func (l ResourceLimits) String() string {
+	var parts []string
+	if l.Memory > 0 {
+		parts = append(parts, "memory="+utils.FormatByteSize(l.Memory))
+	}
+	if l.CPUSeconds > 0 {
+		parts = append(parts, fmt.Sprintf("cpu_seconds=%d", l.CPUSeconds))
+	}
+	if l.MaxProcs > 0 {
+		parts = append(parts, fmt.Sprintf("max_procs=%d", l.MaxProcs))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c ResourceLimitsConfig) resolve() (ResourceLimits, error) {
+	var l ResourceLimits
+	if strings.TrimSpace(c.Memory) != "" {
+		n, err := utils.ParseByteSize(c.Memory)
+		if err != nil {
+			return l, fmt.Errorf("limits.memory: %w", err)
+		}
+		l.Memory = n
+	}
+	if c.CPUSeconds < 0 {
+		return l, fmt.Errorf("limits.cpu_seconds must be >= 0, got %d", c.CPUSeconds)
+	}
+	if c.MaxProcs < 0 {
+		return l, fmt.Errorf("limits.max_procs must be >= 0, got %d", c.MaxProcs)
+	}
+	l.CPUSeconds, l.MaxProcs = c.CPUSeconds, c.MaxProcs
+	return l, nil
+}
+
+func mergeResourceLimitsConfig(base, over *ResourceLimitsConfig) *ResourceLimitsConfig {
+	if over == nil {
+		return base
+	}
+	if base == nil {
+		return over
+	}
+	merged := *base
+	if strings.TrimSpace(over.Memory) != "" {
+		merged.Memory = over.Memory
+	}
+	if over.CPUSeconds != 0 {
+		merged.CPUSeconds = over.CPUSeconds
+	}
+	if over.MaxProcs != 0 {
+		merged.MaxProcs = over.MaxProcs
+	}
+	return &merged
+}
+
+// ParseResourceLimits parses the limits: header of a parallel task, e.g.
+// "{memory: 4GiB, cpu_seconds: 600, max_procs: 256}". Braces are optional
+// and "=" may be used instead of ":".
+func ParseResourceLimits(raw string) (ResourceLimits, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSuffix(strings.TrimPrefix(raw, "{"), "}")
+	var c ResourceLimitsConfig
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			key, value, ok = strings.Cut(field, "=")
+		}
+		if !ok {
+			return ResourceLimits{}, fmt.Errorf("expected key: value, got %q", field)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "memory":
+			c.Memory = value
+		case "cpu_seconds", "max_procs":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return ResourceLimits{}, fmt.Errorf("%s: %q is not an integer", key, value)
+			}
+			if key == "cpu_seconds" {
+				c.CPUSeconds = n
+			} else {
+				c.MaxProcs = n
+			}
+		default:
+			return ResourceLimits{}, fmt.Errorf("unknown limit %q (want memory, cpu_seconds or max_procs)", key)
+		}
+	}
+	return c.resolve()
+}
+
+// ResolveAgentResourceLimits returns the limits configured under
+// agents.<name>.limits in models.json. Dynamic agents have none.
+func ResolveAgentResourceLimits(agentName string) ResourceLimits {
+	agentName = strings.TrimSpace(agentName)
+	if agentName == "" {
+		return ResourceLimits{}
+	}
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return ResourceLimits{}
+	}
+	agent, ok := cfg.Agents[agentName]
+	if !ok || agent.Limits == nil {
+		return ResourceLimits{}
+	}
+	limits, _ := agent.Limits.resolve()
+	return limits
+}
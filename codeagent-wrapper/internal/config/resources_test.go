@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseResourceLimits(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    ResourceLimits
+		wantErr string
+	}{
+		{raw: "{memory: 4GiB, cpu_seconds: 600, max_procs: 64}", want: ResourceLimits{Memory: 4 << 30, CPUSeconds: 600, MaxProcs: 64}},
+		{raw: "memory=512M", want: ResourceLimits{Memory: 512 << 20}},
+		{raw: "{}", want: ResourceLimits{}},
+		{raw: "{memory: lots}", wantErr: "limits.memory"},
+		{raw: "{cpu_seconds: 1.5}", wantErr: "not an integer"},
+		{raw: "{max_procs: -1}", wantErr: "must be >= 0"},
+		{raw: "{disk: 1G}", wantErr: "unknown limit"},
+		{raw: "{memory}", wantErr: "expected key: value"},
+	}
+	for _, tt := range tests {
+		got, err := ParseResourceLimits(tt.raw)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseResourceLimits(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseResourceLimits(%q) = %+v, %v; want %+v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveAgentResourceLimits(t *testing.T) {
+	writeModelsConfigForTest(t, `{
+  "agents": {
+    "base": { "backend": "codex", "limits": { "memory": "2GiB", "cpu_seconds": 300 } },
+    "heavy": { "extends": "base", "limits": { "memory": "8GiB", "max_procs": 128 } }
+  }
+}`)
+	got := ResolveAgentResourceLimits("heavy")
+	want := ResourceLimits{Memory: 8 << 30, CPUSeconds: 300, MaxProcs: 128}
+	if got != want {
+		t.Fatalf("heavy limits = %+v, want %+v", got, want)
+	}
+	if merged := got.Merge(ResourceLimits{CPUSeconds: 60}); merged.CPUSeconds != 60 || merged.Memory != 8<<30 {
+		t.Fatalf("Merge = %+v", merged)
+	}
+	if s := want.String(); s != "memory=8.0G cpu_seconds=300 max_procs=128" {
+		t.Fatalf("String = %q", s)
+	}
+	if got := ResolveAgentResourceLimits("missing"); !got.IsZero() {
+		t.Fatalf("missing agent limits = %+v", got)
+	}
+}
+
+func TestLoadModelsConfig_InvalidLimits(t *testing.T) {
+	writeModelsConfigForTest(t, `{"agents": {"a": {"backend": "codex", "limits": {"memory": "huge"}}}}`)
+	if _, err := modelsConfig(); err == nil || !strings.Contains(err.Error(), "agents.a") {
+		t.Fatalf("modelsConfig error = %v, want the invalid memory limit", err)
+	}
+}
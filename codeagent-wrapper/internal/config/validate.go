@@ -0,0 +1,425 @@
+package config
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+//go:embed models.schema.json
+var modelsConfigSchema []byte
+
+// ModelsConfigSchema returns the published JSON Schema for models.json.
+func ModelsConfigSchema() []byte {
+	return append([]byte(nil), modelsConfigSchema...)
+}
+
+// ValidationIssue is one problem found in models.json. Line and Column are
+// 1-based and point at the offending key or value.
+type ValidationIssue struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Path, i.Message)
+}
+
+// ValidateOptions controls checks that depend on the environment rather than
+// on the document itself.
+type ValidateOptions struct {
+	// CheckPromptFile, when set, is called for every agents.*.prompt_file
+	// value; a non-nil error is reported at the value's position.
+	CheckPromptFile func(path string) error
+}
+
+// ValidateModelsConfig checks a models.json document against the embedded
+// schema plus the rules loading and ResolveAgentConfig enforce at run time. Unlike
+// loading, it is strict: unknown fields are reported so typos surface.
+func ValidateModelsConfig(data []byte, opts ValidateOptions) []ValidationIssue {
+	root, err := parseJSONWithPositions(data)
+	if err != nil {
+		var syntaxErr *jsonSyntaxError
+		if errors.As(err, &syntaxErr) {
+			return []ValidationIssue{{Line: syntaxErr.Line, Column: syntaxErr.Column, Message: "invalid JSON: " + syntaxErr.Msg}}
+		}
+		return []ValidationIssue{{Line: 1, Column: 1, Message: "invalid JSON: " + err.Error()}}
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(modelsConfigSchema, &schema); err != nil {
+		return []ValidationIssue{{Line: 1, Column: 1, Message: "internal error: invalid embedded schema: " + err.Error()}}
+	}
+
+	v := &schemaValidator{root: schema}
+	v.checkDuplicateKeys(root, "")
+	v.check(root, schema, "")
+	v.checkSemantics(root, opts)
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		if v.issues[i].Line != v.issues[j].Line {
+			return v.issues[i].Line < v.issues[j].Line
+		}
+		return v.issues[i].Column < v.issues[j].Column
+	})
+	return v.issues
+}
+
+// schemaValidator evaluates the subset of JSON Schema used by
+// models.schema.json: type, enum, properties, additionalProperties,
+// propertyNames, required, items, minLength, pattern, minimum, maximum and
+// local $ref.
+type schemaValidator struct {
+	root   map[string]any
+	issues []ValidationIssue
+}
+
+func (v *schemaValidator) report(path string, line, col int, format string, args ...any) {
+	v.issues = append(v.issues, ValidationIssue{Path: path, Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) resolve(schema map[string]any) map[string]any {
+	for i := 0; i < 8; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		defs, _ := v.root["$defs"].(map[string]any)
+		next, ok := defs[name].(map[string]any)
+		if !ok {
+			return map[string]any{}
+		}
+		schema = next
+	}
+	return schema
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (v *schemaValidator) check(n *jsonNode, schema map[string]any, path string) {
+	schema = v.resolve(schema)
+
+	if want, ok := schema["type"].(string); ok && !kindMatches(n, want) {
+		v.report(path, n.line, n.col, "expected %s, got %s", want, describeKind(n))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		allowed := make([]string, 0, len(enum))
+		match := false
+		for _, e := range enum {
+			s := fmt.Sprint(e)
+			allowed = append(allowed, s)
+			if n.kind == jsonString && n.str == s {
+				match = true
+			}
+		}
+		if !match {
+			msg := fmt.Sprintf("%s is not one of %s", describeValue(n), strings.Join(allowed, ", "))
+			if n.kind == jsonString {
+				msg += didYouMean(n.str, allowed)
+			}
+			v.report(path, n.line, n.col, "%s", msg)
+		}
+	}
+
+	switch n.kind {
+	case jsonString:
+		if min, ok := schema["minLength"].(float64); ok && float64(utf8.RuneCountInString(strings.TrimSpace(n.str))) < min {
+			if min <= 1 {
+				v.report(path, n.line, n.col, "must not be empty")
+			} else {
+				v.report(path, n.line, n.col, "must be at least %d characters", int(min))
+			}
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(n.str) {
+				v.report(path, n.line, n.col, "%q does not match %s", n.str, pattern)
+			}
+		}
+	case jsonNumber:
+		value, _ := strconv.ParseFloat(n.str, 64)
+		if min, ok := schema["minimum"].(float64); ok && value < min {
+			v.report(path, n.line, n.col, "must be >= %v (got %s)", min, n.str)
+		}
+		if max, ok := schema["maximum"].(float64); ok && value > max {
+			v.report(path, n.line, n.col, "must be <= %v (got %s)", max, n.str)
+		}
+	case jsonArray:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range n.items {
+				v.check(item, items, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case jsonObject:
+		v.checkObject(n, schema, path)
+	}
+}
+
+func (v *schemaValidator) checkObject(n *jsonNode, schema map[string]any, path string) {
+	props, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(props))
+	for name := range props {
+		if !strings.HasPrefix(name, "$") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	present := make(map[string]bool, len(n.members))
+	for _, m := range n.members {
+		present[m.key] = true
+		memberPath := joinPath(path, m.key)
+
+		if keySchema, ok := schema["propertyNames"].(map[string]any); ok {
+			key := &jsonNode{kind: jsonString, str: m.key, line: m.line, col: m.col}
+			before := len(v.issues)
+			v.check(key, keySchema, memberPath)
+			for i := before; i < len(v.issues); i++ {
+				v.issues[i].Message = "invalid key: " + v.issues[i].Message
+			}
+		}
+
+		if propSchema, ok := props[m.key].(map[string]any); ok {
+			v.check(m.value, propSchema, memberPath)
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.report(memberPath, m.line, m.col, "unknown field %q%s", m.key, didYouMean(m.key, names))
+			}
+		case map[string]any:
+			v.check(m.value, extra, memberPath)
+		}
+	}
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name := fmt.Sprint(r)
+			if !present[name] {
+				v.report(path, n.line, n.col, "missing required field %q", name)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) checkDuplicateKeys(n *jsonNode, path string) {
+	switch n.kind {
+	case jsonObject:
+		seen := make(map[string]bool, len(n.members))
+		for _, m := range n.members {
+			memberPath := joinPath(path, m.key)
+			if seen[m.key] {
+				v.report(memberPath, m.line, m.col, "duplicate key %q; the last value wins", m.key)
+			}
+			seen[m.key] = true
+			v.checkDuplicateKeys(m.value, memberPath)
+		}
+	case jsonArray:
+		for i, item := range n.items {
+			v.checkDuplicateKeys(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// checkSemantics covers rules that span fields or touch the filesystem:
+// extends chains, the effective backend and model of each agent, and prompt
+// file access.
+func (v *schemaValidator) checkSemantics(root *jsonNode, opts ValidateOptions) {
+	if root.kind != jsonObject {
+		return
+	}
+	defaultBackend := ""
+	if n := root.member("default_backend"); n != nil && n.kind == jsonString {
+		defaultBackend = strings.TrimSpace(n.str)
+	}
+	if profiles := root.member("profiles"); profiles != nil && profiles.kind == jsonObject {
+		for _, m := range profiles.members {
+			if m.value.kind == jsonObject {
+				v.checkPromptFile(m.value, "profiles."+m.key, opts)
+			}
+		}
+	}
+	agents := root.member("agents")
+	if agents == nil || agents.kind != jsonObject {
+		return
+	}
+	byName := make(map[string]*jsonNode, len(agents.members))
+	for _, m := range agents.members {
+		if m.value.kind == jsonObject {
+			byName[m.key] = m.value
+		}
+	}
+
+	for _, m := range agents.members {
+		if m.value.kind != jsonObject {
+			continue
+		}
+		path := "agents." + m.key
+		v.checkPromptFile(m.value, path, opts)
+
+		chain, ok := v.extendsChain(m.key, m.value, byName, path)
+		if !ok {
+			continue
+		}
+		if defaultBackend == "" && !inheritedSet(chain, "backend") {
+			v.report(path, m.value.line, m.value.col, "backend is not set and default_backend is empty")
+		}
+		if !inheritedSet(chain, "model") {
+			msg := "model is not set"
+			if len(chain) > 1 {
+				msg += " (directly or via extends)"
+			}
+			v.report(path, m.value.line, m.value.col, "%s", msg)
+		}
+	}
+}
+
+// extendsChain returns the agent followed by its ancestors. Unknown parents
+// are reported on the agent that names them and cycles on each member of the
+// cycle; agents that merely inherit a broken chain are skipped silently.
+func (v *schemaValidator) extendsChain(name string, node *jsonNode, byName map[string]*jsonNode, path string) ([]*jsonNode, bool) {
+	chain := []*jsonNode{node}
+	names := []string{name}
+	seen := map[string]bool{name: true}
+	for current := node; ; {
+		extends := current.member("extends")
+		if extends == nil || extends.kind != jsonString || strings.TrimSpace(extends.str) == "" {
+			return chain, true
+		}
+		parentName := strings.TrimSpace(extends.str)
+		parent, ok := byName[parentName]
+		switch {
+		case !ok:
+			if len(chain) == 1 {
+				v.report(path+".extends", extends.line, extends.col, "extends unknown agent %q", parentName)
+			}
+			return nil, false
+		case parentName == name:
+			own := node.member("extends")
+			v.report(path+".extends", own.line, own.col, "inheritance cycle: %s", strings.Join(append(names, parentName), " -> "))
+			return nil, false
+		case seen[parentName]:
+			return nil, false
+		}
+		seen[parentName] = true
+		names = append(names, parentName)
+		chain = append(chain, parent)
+		current = parent
+	}
+}
+
+// inheritedSet reports whether key appears anywhere along the chain. Empty
+// or mistyped values are left to the schema checks.
+func inheritedSet(chain []*jsonNode, key string) bool {
+	for _, n := range chain {
+		if n.member(key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *schemaValidator) checkPromptFile(n *jsonNode, path string, opts ValidateOptions) {
+	prompt := n.member("prompt_file")
+	if prompt == nil || prompt.kind != jsonString || strings.TrimSpace(prompt.str) == "" || opts.CheckPromptFile == nil {
+		return
+	}
+	if err := opts.CheckPromptFile(strings.TrimSpace(prompt.str)); err != nil {
+		v.report(path+".prompt_file", prompt.line, prompt.col, "prompt file %s: %v", prompt.str, err)
+	}
+}
+
+func (n *jsonNode) member(key string) *jsonNode {
+	var found *jsonNode
+	for _, m := range n.members {
+		if m.key == key {
+			found = m.value
+		}
+	}
+	return found
+}
+
+func kindMatches(n *jsonNode, want string) bool {
+	switch want {
+	case "integer":
+		if n.kind != jsonNumber {
+			return false
+		}
+		f, err := strconv.ParseFloat(n.str, 64)
+		return err == nil && f == math.Trunc(f)
+	default:
+		return n.kind.String() == want
+	}
+}
+
+func describeKind(n *jsonNode) string {
+	if n.kind == jsonNumber {
+		return "number " + n.str
+	}
+	return n.kind.String()
+}
+
+func describeValue(n *jsonNode) string {
+	if n.kind == jsonString {
+		return strconv.Quote(n.str)
+	}
+	return describeKind(n)
+}
+
+// didYouMean suggests the closest candidate within edit distance 2, so
+// "modle" points at "model".
+func didYouMean(got string, candidates []string) string {
+	best, bestDist := "", 3
+	lower := strings.ToLower(got)
+	for _, c := range candidates {
+		if d := editDistance(lower, strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" || best == got {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
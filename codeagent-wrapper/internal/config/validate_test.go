@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func findIssue(issues []ValidationIssue, path string) (ValidationIssue, bool) {
+	for _, issue := range issues {
+		if issue.Path == path {
+			return issue, true
+		}
+	}
+	return ValidationIssue{}, false
+}
+
+func TestValidateModelsConfig_Valid(t *testing.T) {
+	data := []byte(`{
+  "$schema": "./models.schema.json",
+  "default_backend": "codex",
+  "default_model": "gpt-4.1",
+  "backends": { "claude": { "api_key": "k", "max_concurrency": 2, "min_start_interval": "1.5s" } },
+  "agents": {
+    "develop": { "model": "gpt-4.1", "prompt_file": "~/.claude/p.md", "yolo": true, "allowed_tools": ["Read"],
+      "limits": { "memory": "4GiB", "cpu_seconds": 600, "max_procs": 64 } }
+  },
+  "prompt_env": ["CI", "TEAM_NAME"],
+  "hooks": { "pre_task": [{ "command": "./guard.sh", "timeout": "10s", "on_failure": "fail" }], "post_run": [{ "command": "./notify.sh" }] }
+}`)
+	checked := ""
+	issues := ValidateModelsConfig(data, ValidateOptions{CheckPromptFile: func(path string) error {
+		checked = path
+		return nil
+	}})
+	if len(issues) != 0 {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+	if checked != "~/.claude/p.md" {
+		t.Fatalf("prompt file check got %q", checked)
+	}
+}
+
+func TestValidateModelsConfig_ReportsPositions(t *testing.T) {
+	data := []byte(`{
+  "default_backend": "codex",
+  "agents": {
+    "develop": {
+      "backend": "claud",
+      "modle": "x",
+      "model": "  ",
+      "prompt_file": "~/.claude/missing.md",
+      "max_concurrency": 1.5
+    },
+    "bad name": { "model": "m" }
+  },
+  "backend": {}
+}`)
+	issues := ValidateModelsConfig(data, ValidateOptions{CheckPromptFile: func(string) error {
+		return errors.New("no such file")
+	}})
+
+	tests := []struct {
+		path      string
+		line, col int
+		contains  string
+	}{
+		{"agents.develop.backend", 5, 18, `(did you mean "claude"?)`},
+		{"agents.develop.modle", 6, 7, `unknown field "modle" (did you mean "model"?)`},
+		{"agents.develop.model", 7, 16, "must not be empty"},
+		{"agents.develop.prompt_file", 8, 22, "no such file"},
+		{"agents.develop.max_concurrency", 9, 26, "expected integer"},
+		{"agents.bad name", 11, 5, "invalid key"},
+		{"backend", 13, 3, `did you mean "backends"?`},
+	}
+	for _, tt := range tests {
+		issue, ok := findIssue(issues, tt.path)
+		if !ok {
+			t.Errorf("missing issue for %s in %+v", tt.path, issues)
+			continue
+		}
+		if issue.Line != tt.line || issue.Column != tt.col || !strings.Contains(issue.Message, tt.contains) {
+			t.Errorf("%s: got %s, want %d:%d containing %q", tt.path, issue, tt.line, tt.col, tt.contains)
+		}
+	}
+	if len(issues) != len(tests) {
+		t.Errorf("got %d issues, want %d: %+v", len(issues), len(tests), issues)
+	}
+}
+
+func TestValidateModelsConfig_SemanticAndStructural(t *testing.T) {
+	data := []byte(`{
+  "agents": {
+    "a": { "backend": "codex" },
+    "b": { "model": "m" },
+    "a": { "backend": "codex", "model": "m" }
+  }
+}`)
+	issues := ValidateModelsConfig(data, ValidateOptions{})
+
+	if issue, ok := findIssue(issues, "agents.b"); !ok || !strings.Contains(issue.Message, "default_backend is empty") {
+		t.Errorf("expected missing backend issue, got %+v", issues)
+	}
+	var sawMissingModel, sawDuplicate bool
+	for _, issue := range issues {
+		if issue.Path == "agents.a" && strings.Contains(issue.Message, "model is not set") {
+			sawMissingModel = true
+		}
+		if issue.Path == "agents.a" && issue.Line == 5 && strings.Contains(issue.Message, "duplicate key") {
+			sawDuplicate = true
+		}
+	}
+	if !sawMissingModel || !sawDuplicate {
+		t.Errorf("missing model=%v duplicate=%v in %+v", sawMissingModel, sawDuplicate, issues)
+	}
+}
+
+func TestValidateModelsConfig_SyntaxError(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		line, col int
+	}{
+		{"missing comma", "{\n  \"a\": 1\n  \"b\": 2\n}", 3, 3},
+		{"trailing comma", "{\"agents\": {},}", 1, 15},
+		{"unterminated", "{\n  \"agents\": \"x", 2, 13},
+		{"empty", "", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := ValidateModelsConfig([]byte(tt.data), ValidateOptions{})
+			if len(issues) != 1 || !strings.HasPrefix(issues[0].Message, "invalid JSON") {
+				t.Fatalf("issues = %+v", issues)
+			}
+			if issues[0].Line != tt.line || issues[0].Column != tt.col {
+				t.Fatalf("position = %d:%d, want %d:%d", issues[0].Line, issues[0].Column, tt.line, tt.col)
+			}
+		})
+	}
+}
+
+func TestParseJSONWithPositions_MatchesEncodingJSON(t *testing.T) {
+	data := []byte(`{"s": "a\"bé", "n": -1.5e3, "t": true, "f": false, "z": null, "arr": [1, {"k": "v"}]}`)
+	root, err := parseJSONWithPositions(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]any
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if got := root.member("s").str; got != want["s"] {
+		t.Fatalf("string = %q, want %q", got, want["s"])
+	}
+	if got := root.member("n").str; got != "-1.5e3" {
+		t.Fatalf("number = %q", got)
+	}
+	if !root.member("t").boolean || root.member("f").boolean || root.member("z").kind != jsonNull {
+		t.Fatal("literal mismatch")
+	}
+	arr := root.member("arr")
+	if len(arr.items) != 2 || arr.items[1].member("k").str != "v" || arr.items[1].col != 74 {
+		t.Fatalf("array = %+v", arr)
+	}
+}
+
+func TestModelsConfigSchema_IsValidJSON(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(ModelsConfigSchema(), &schema); err != nil {
+		t.Fatalf("embedded schema is not valid JSON: %v", err)
+	}
+	if schema["$id"] == nil {
+		t.Fatal("schema should declare $id")
+	}
+}
+
+func TestValidateModelsConfig_ExtendsAndProfiles(t *testing.T) {
+	data := []byte(`{
+  "default_backend": "codex",
+  "agents": {
+    "base": { "model": "m", "allowed_tools": ["Read"] },
+    "child": { "extends": "base", "allowed_tools": ["...", "Write"] },
+    "orphan": { "extends": "nope" },
+    "x": { "extends": "y", "model": "m" },
+    "y": { "extends": "x" }
+  },
+  "profiles": {
+    "cheap": { "model": "mini", "max_concurrency": 1 }
+  }
+}`)
+	issues := ValidateModelsConfig(data, ValidateOptions{})
+
+	if _, ok := findIssue(issues, "agents.child"); ok {
+		t.Errorf("child inherits model from base, got %+v", issues)
+	}
+	if issue, ok := findIssue(issues, "agents.orphan.extends"); !ok || issue.Line != 6 || !strings.Contains(issue.Message, `unknown agent "nope"`) {
+		t.Errorf("orphan issue = %+v", issue)
+	}
+	if issue, ok := findIssue(issues, "agents.x.extends"); !ok || !strings.Contains(issue.Message, "x -> y -> x") {
+		t.Errorf("cycle issue for x = %+v", issue)
+	}
+	if issue, ok := findIssue(issues, "agents.y.extends"); !ok || !strings.Contains(issue.Message, "y -> x -> y") {
+		t.Errorf("cycle issue for y = %+v", issue)
+	}
+	if issue, ok := findIssue(issues, "profiles.cheap.max_concurrency"); !ok || !strings.Contains(issue.Message, "unknown field") {
+		t.Errorf("profile issue = %+v", issue)
+	}
+	if len(issues) != 4 {
+		t.Errorf("got %d issues: %+v", len(issues), issues)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +15,10 @@ import (
 //
 // Search order when configFile is empty:
 //   - $HOME/.codeagent/config.(yaml|yml|json|toml|...)
+//
+// The active project's .codeagent/config.* is then merged on top, so its
+// keys override the home file (environment variables and flags still win).
+// An explicit configFile replaces both.
 func NewViper(configFile string) (*viper.Viper, error) {
 	v := viper.New()
 	v.SetEnvPrefix("CODEAGENT")
@@ -29,19 +34,45 @@ func NewViper(configFile string) (*viper.Viper, error) {
 	}
 
 	home, err := os.UserHomeDir()
-	if err != nil || strings.TrimSpace(home) == "" {
-		return v, nil
+	if err == nil && strings.TrimSpace(home) != "" {
+		v.SetConfigName("config")
+		v.AddConfigPath(filepath.Join(home, ".codeagent"))
+		if err := v.ReadInConfig(); err != nil {
+			var notFound viper.ConfigFileNotFoundError
+			if !errors.As(err, &notFound) {
+				return nil, err
+			}
+		}
 	}
 
-	v.SetConfigName("config")
-	v.AddConfigPath(filepath.Join(home, ".codeagent"))
-	if err := v.ReadInConfig(); err != nil {
-		var notFound viper.ConfigFileNotFoundError
-		if errors.As(err, &notFound) {
-			return v, nil
-		}
+	if err := mergeProjectConfig(v, ActiveProject()); err != nil {
 		return nil, err
 	}
-
 	return v, nil
 }
+
+// mergeProjectConfig layers the project's config file over v, leaving out
+// sensitive keys while the project is untrusted.
+func mergeProjectConfig(v *viper.Viper, p *Project) error {
+	if p == nil {
+		return nil
+	}
+	path := p.ConfigFile()
+	if path == "" {
+		return nil
+	}
+	pv := viper.New()
+	pv.SetConfigFile(path)
+	if err := pv.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read project config %s: %w", path, err)
+	}
+	settings := pv.AllSettings()
+	if p.Restricted() {
+		for _, key := range sensitiveConfigKeys {
+			if sensitiveConfigSet(pv, key) {
+				delete(settings, key)
+			}
+		}
+	}
+	return v.MergeConfigMap(settings)
+}
@@ -0,0 +1,387 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	config "codeagent-wrapper/internal/config"
+	"codeagent-wrapper/internal/worktree"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	bestOfJudgeEnv       = "CODEAGENT_BEST_OF_JUDGE"
+	maxBestOfCandidates  = 8
+	bestOfDiffLimit      = 20000 // chars of diff per candidate shown to the judge
+	bestOfMessageLimit   = 8000  // chars of message per candidate shown to the judge
+	bestOfCommitTemplate = "best-of: %s candidate %s (%s)"
+)
+
+// Hook points for best_of worktree handling (tests can override).
+var (
+	worktreeDiffFn   = worktree.Diff
+	commitWorktreeFn = worktree.Commit
+	removeWorktreeFn = worktree.Remove
+)
+
+// BestOfCandidate summarizes one isolated candidate run.
+type BestOfCandidate struct {
+	ID        string `json:"id"`
+	Backend   string `json:"backend"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	Worktree  string `json:"worktree,omitempty"`
+	DiffBytes int    `json:"diff_bytes"`
+}
+
+// BestOfResult records the judge verdict for a best_of task.
+type BestOfResult struct {
+	Judge  string `json:"judge"`
+	Winner string `json:"winner"`
+	// SynthesizedMessage is set when the result's message was written by
+	// the judge from the candidates' messages. Only the winner's changes are
+	// committed; nothing from the other candidates' worktrees is merged.
+	SynthesizedMessage bool              `json:"synthesized_message,omitempty"`
+	WinnerMessage      string            `json:"winner_message,omitempty"` // the winner's own message, when replaced
+	Rationale          string            `json:"rationale,omitempty"`
+	Scores             map[string]int    `json:"scores,omitempty"`
+	Branch             string            `json:"branch,omitempty"`
+	Worktree           string            `json:"worktree,omitempty"`
+	Candidates         []BestOfCandidate `json:"candidates"`
+}
+
+// bestOfVerdict is the structured answer expected from the judge agent.
+type bestOfVerdict struct {
+	Winner             string         `json:"winner"`
+	Rationale          string         `json:"rationale"`
+	Scores             map[string]int `json:"scores"`
+	SynthesizedMessage string         `json:"synthesized_message"`
+}
+
+// taskGate takes a worker slot for a task, honouring its backend and agent
+// limits. ok is false when the run was cancelled while waiting.
+type taskGate func(TaskSpec) (release func(), ok bool)
+
+// run runs task through runTask once the gate grants it a slot. A nil gate
+// runs the task straight away.
+func (g taskGate) run(task TaskSpec, timeout int, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	if g == nil {
+		return runTask(task, timeout)
+	}
+	release, ok := g(task)
+	if !ok {
+		return cancelledTaskResult(task.ID, task.Context)
+	}
+	defer release()
+	return runTask(task, timeout)
+}
+
+type bestOfRun struct {
+	candidate BestOfCandidate
+	paths     *worktree.Paths
+	result    TaskResult
+	diff      string
+}
+
+// parseBestOf accepts either a candidate count ("3", reusing the task backend)
+// or a comma-separated backend list ("codex,claude,gemini").
+func parseBestOf(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("value is empty")
+	}
+
+	var candidates []string
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 2 || n > maxBestOfCandidates {
+			return nil, fmt.Errorf("candidate count must be between 2 and %d, got %d", maxBestOfCandidates, n)
+		}
+		return make([]string, n), nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, err := selectBackendFn(name); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, name)
+	}
+	if len(candidates) < 2 || len(candidates) > maxBestOfCandidates {
+		return nil, fmt.Errorf("need between 2 and %d candidates, got %d", maxBestOfCandidates, len(candidates))
+	}
+	return candidates, nil
+}
+
+// bestOfCandidateBackend returns the backend a best_of candidate runs on.
+func bestOfCandidateBackend(task TaskSpec, name string) string {
+	backendName := strings.TrimSpace(name)
+	if backendName == "" {
+		backendName = task.Backend
+	}
+	if backendName == "" {
+		backendName = defaultBackendName
+	}
+	return backendName
+}
+
+// bestOfLimitTasks returns stand-ins for the candidate and judge runs of a
+// best_of task, so their backends and agents get limiter scopes.
+func bestOfLimitTasks(task TaskSpec) []TaskSpec {
+	if len(task.BestOf) == 0 {
+		return nil
+	}
+	var out []TaskSpec
+	for _, name := range task.BestOf {
+		out = append(out, TaskSpec{Backend: bestOfCandidateBackend(task, name), Agent: task.Agent})
+	}
+	if backendName, _, _, _, _, _, _, _, _, err := config.ResolveAgentConfig(task.Judge); err == nil {
+		out = append(out, TaskSpec{Backend: backendName, Agent: task.Judge})
+	}
+	return out
+}
+
+// runBestOf runs task once per best_of candidate, each in its own git worktree,
+// asks the judge agent to pick a winner, keeps the winning branch and removes
+// the losing worktrees. Each candidate and the judge take their own slot
+// through gate.
+func runBestOf(task TaskSpec, timeout int, gate taskGate, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	result := TaskResult{TaskID: task.ID}
+	fail := func(msg string) TaskResult {
+		logError(fmt.Sprintf("[Task: %s] best_of: %s", task.ID, msg))
+		result.ExitCode = 1
+		result.Error = "best_of: " + msg
+		return result
+	}
+
+	if dir := strings.TrimSpace(os.Getenv("DO_WORKTREE_DIR")); dir != "" {
+		return fail("cannot create candidate worktrees while DO_WORKTREE_DIR is set")
+	}
+
+	workDir := task.WorkDir
+	if workDir == "" {
+		workDir = defaultWorkdir
+	}
+
+	// Worktrees are created sequentially: concurrent `git worktree add` calls
+	// in the same repository race on git's lock files.
+	runs := make([]*bestOfRun, 0, len(task.BestOf))
+	for i, name := range task.BestOf {
+		backendName := bestOfCandidateBackend(task, name)
+		paths, err := createWorktreeFn(workDir)
+		if err != nil {
+			for _, r := range runs {
+				cleanupBestOfCandidate(task.ID, r)
+			}
+			return fail(fmt.Sprintf("failed to create worktree for candidate %d: %v", i+1, err))
+		}
+		runs = append(runs, &bestOfRun{
+			candidate: BestOfCandidate{
+				ID:       fmt.Sprintf("c%d", i+1),
+				Backend:  backendName,
+				Branch:   paths.Branch,
+				Worktree: paths.Dir,
+			},
+			paths: paths,
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range runs {
+		wg.Add(1)
+		go func(r *bestOfRun) {
+			defer wg.Done()
+			defer func() {
+				if rec := recover(); rec != nil {
+					r.result = TaskResult{ExitCode: 1, Error: fmt.Sprintf("panic: %v", rec)}
+				}
+			}()
+			spec := task
+			spec.ID = task.ID + "." + r.candidate.ID
+			spec.Backend = r.candidate.Backend
+			spec.WorkDir = r.paths.Dir
+			spec.Worktree = false
+			spec.BestOf = nil
+			spec.Cache = false // a cached answer would leave the candidate worktree without changes
+			if r.candidate.Backend != task.Backend {
+				// The task model belongs to the task backend; let each
+				// candidate backend fall back to its own default.
+				spec.Model = ""
+				spec.ReasoningEffort = ""
+			}
+			r.result = gate.run(spec, timeout, runTask)
+		}(r)
+	}
+	wg.Wait()
+
+	var succeeded []*bestOfRun
+	for _, r := range runs {
+		r.candidate.ExitCode = r.result.ExitCode
+		r.candidate.Error = r.result.Error
+		r.candidate.SessionID = r.result.SessionID
+		if r.result.ExitCode != 0 || r.result.Error != "" {
+			continue
+		}
+		diff, err := worktreeDiffFn(r.paths.Dir)
+		if err != nil {
+			logWarn(fmt.Sprintf("[Task: %s] best_of: candidate %s diff failed: %v", task.ID, r.candidate.ID, err))
+		}
+		r.diff = diff
+		r.candidate.DiffBytes = len(diff)
+		succeeded = append(succeeded, r)
+	}
+
+	bestOf := &BestOfResult{Judge: task.Judge}
+	for _, r := range runs {
+		bestOf.Candidates = append(bestOf.Candidates, r.candidate)
+	}
+	result.BestOf = bestOf
+
+	if len(succeeded) == 0 {
+		for _, r := range runs {
+			cleanupBestOfCandidate(task.ID, r)
+		}
+		return fail("all candidates failed")
+	}
+
+	var winner *bestOfRun
+	var verdict bestOfVerdict
+	if len(succeeded) == 1 {
+		winner = succeeded[0]
+		verdict.Rationale = "only successful candidate"
+	} else {
+		judgeRes := runBestOfJudge(task, succeeded, timeout, gate, runTask)
+		if judgeRes.ExitCode != 0 || judgeRes.Error != "" {
+			// Keep every worktree so the candidates can be compared by hand.
+			return fail(fmt.Sprintf("judge %q failed (candidate worktrees kept): %s", task.Judge, judgeRes.Error))
+		}
+		v, err := parseBestOfVerdict(judgeRes.Message)
+		if err != nil {
+			return fail(fmt.Sprintf("judge %q returned an invalid verdict (candidate worktrees kept): %v", task.Judge, err))
+		}
+		for _, r := range succeeded {
+			if strings.EqualFold(r.candidate.ID, v.Winner) {
+				winner = r
+				break
+			}
+		}
+		if winner == nil {
+			return fail(fmt.Sprintf("judge %q picked unknown candidate %q (candidate worktrees kept)", task.Judge, v.Winner))
+		}
+		verdict = v
+	}
+
+	if err := commitWorktreeFn(winner.paths.Dir, fmt.Sprintf(bestOfCommitTemplate, task.ID, winner.candidate.ID, winner.candidate.Backend)); err != nil {
+		logWarn(fmt.Sprintf("[Task: %s] best_of: failed to commit winner: %v", task.ID, err))
+	}
+	for _, r := range runs {
+		if r != winner {
+			cleanupBestOfCandidate(task.ID, r)
+		}
+	}
+
+	bestOf.Winner = winner.candidate.ID
+	bestOf.Rationale = verdict.Rationale
+	bestOf.Scores = verdict.Scores
+	bestOf.Branch = winner.paths.Branch
+	bestOf.Worktree = winner.paths.Dir
+
+	result = winner.result
+	result.TaskID = task.ID
+	result.BestOf = bestOf
+	if synthesized := strings.TrimSpace(verdict.SynthesizedMessage); synthesized != "" {
+		bestOf.WinnerMessage = result.Message
+		bestOf.SynthesizedMessage = true
+		result.Message = synthesized
+	}
+	logInfo(fmt.Sprintf("[Task: %s] best_of: winner=%s backend=%s branch=%s", task.ID, winner.candidate.ID, winner.candidate.Backend, winner.paths.Branch))
+	return result
+}
+
+func runBestOfJudge(task TaskSpec, candidates []*bestOfRun, timeout int, gate taskGate, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	backendName, model, promptFile, reasoning, _, _, _, allowedTools, disallowedTools, err := config.ResolveAgentConfig(task.Judge)
+	if err != nil {
+		return TaskResult{ExitCode: 1, Error: err.Error()}
+	}
+	judge := TaskSpec{
+		ID:              task.ID + ".judge",
+		Task:            buildBestOfJudgePrompt(task, candidates),
+		WorkDir:         task.WorkDir,
+		Mode:            "new",
+		Backend:         backendName,
+		Model:           model,
+		ReasoningEffort: reasoning,
+		Agent:           task.Judge,
+		PromptFile:      promptFile,
+		SkipPermissions: task.SkipPermissions,
+		AllowedTools:    allowedTools,
+		DisallowedTools: disallowedTools,
+		TranscriptDir:   task.TranscriptDir,
+		Sandbox:         task.Sandbox,
+		RecordDir:       task.RecordDir,
+		ReplayDir:       task.ReplayDir,
+		Context:         task.Context,
+	}
+	return gate.run(judge, timeout, runTask)
+}
+
+func buildBestOfJudgePrompt(task TaskSpec, candidates []*bestOfRun) string {
+	var sb strings.Builder
+	sb.WriteString("You are judging competing solutions to the same task. Each candidate ran in its own git worktree.\n")
+	sb.WriteString("Pick the best candidate. Only the winner's changes are kept. If the candidates' final messages complement each other, you may also write one message combining them; it replaces the winner's message but does not change its code.\n\n")
+	sb.WriteString("<task>\n")
+	sb.WriteString(task.Task)
+	sb.WriteString("\n</task>\n")
+	for _, r := range candidates {
+		sb.WriteString(fmt.Sprintf("\n<candidate id=%q backend=%q>\n", r.candidate.ID, r.candidate.Backend))
+		sb.WriteString("<message>\n")
+		sb.WriteString(safeTruncate(r.result.Message, bestOfMessageLimit))
+		sb.WriteString("\n</message>\n<diff>\n")
+		if strings.TrimSpace(r.diff) == "" {
+			sb.WriteString("(no file changes)")
+		} else {
+			sb.WriteString(safeTruncate(r.diff, bestOfDiffLimit))
+		}
+		sb.WriteString("\n</diff>\n</candidate>\n")
+	}
+	sb.WriteString("\nReply with a single JSON object and nothing else:\n")
+	sb.WriteString(`{"winner": "<candidate id>", "rationale": "<one paragraph>", "scores": {"<candidate id>": <0-10>}, "synthesized_message": "<optional combined message>"}`)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// parseBestOfVerdict extracts the first JSON object from the judge message,
+// tolerating surrounding prose or Markdown code fences.
+func parseBestOfVerdict(message string) (bestOfVerdict, error) {
+	var v bestOfVerdict
+	start := strings.Index(message, "{")
+	end := strings.LastIndex(message, "}")
+	if start < 0 || end <= start {
+		return v, fmt.Errorf("no JSON object in judge output")
+	}
+	if err := json.Unmarshal([]byte(message[start:end+1]), &v); err != nil {
+		return v, err
+	}
+	v.Winner = strings.TrimSpace(v.Winner)
+	if v.Winner == "" {
+		return v, fmt.Errorf("verdict has empty winner")
+	}
+	return v, nil
+}
+
+func cleanupBestOfCandidate(taskID string, r *bestOfRun) {
+	if r == nil || r.paths == nil {
+		return
+	}
+	if err := removeWorktreeFn(r.paths); err != nil {
+		logWarn(fmt.Sprintf("[Task: %s] best_of: failed to remove candidate %s: %v", taskID, r.candidate.ID, err))
+	}
+}
@@ -0,0 +1,287 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+	"codeagent-wrapper/internal/worktree"
+)
+
+func writeJudgeModelsConfig(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	setTestHome(t, home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "agents": {
+    "reviewer": { "backend": "claude", "model": "judge-model" }
+  }
+}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config.ResetModelsConfigCacheForTest()
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+}
+
+type fakeBestOfWorktrees struct {
+	mu        sync.Mutex
+	created   int
+	removed   []string
+	committed []string
+}
+
+func (f *fakeBestOfWorktrees) install(t *testing.T) {
+	t.Helper()
+	prevCreate, prevDiff, prevCommit, prevRemove := createWorktreeFn, worktreeDiffFn, commitWorktreeFn, removeWorktreeFn
+	t.Cleanup(func() {
+		createWorktreeFn, worktreeDiffFn, commitWorktreeFn, removeWorktreeFn = prevCreate, prevDiff, prevCommit, prevRemove
+	})
+	createWorktreeFn = func(string) (*worktree.Paths, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.created++
+		id := fmt.Sprintf("wt%d", f.created)
+		return &worktree.Paths{Dir: "/repo/.worktrees/do-" + id, Branch: "do/" + id, TaskID: id}, nil
+	}
+	worktreeDiffFn = func(dir string) (string, error) {
+		return "diff --git a/x b/x\n+" + filepath.Base(dir) + "\n", nil
+	}
+	commitWorktreeFn = func(dir, message string) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.committed = append(f.committed, dir)
+		return nil
+	}
+	removeWorktreeFn = func(p *worktree.Paths) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.removed = append(f.removed, p.Branch)
+		return nil
+	}
+}
+
+func TestParseBestOf(t *testing.T) {
+	got, err := parseBestOf("codex, claude ,gemini")
+	if err != nil {
+		t.Fatalf("parseBestOf: %v", err)
+	}
+	if strings.Join(got, ",") != "codex,claude,gemini" {
+		t.Fatalf("got %v", got)
+	}
+
+	got, err = parseBestOf("3")
+	if err != nil || len(got) != 3 || got[0] != "" {
+		t.Fatalf("count form: got %v, err %v", got, err)
+	}
+
+	for _, bad := range []string{"", "1", "99", "codex", "codex,nope"} {
+		if _, err := parseBestOf(bad); err == nil {
+			t.Errorf("parseBestOf(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseParallelConfig_BestOfRequiresJudge(t *testing.T) {
+	t.Setenv(bestOfJudgeEnv, "")
+	input := `---TASK---
+id: t1
+best_of: codex,claude
+---CONTENT---
+Do something.
+`
+	if _, err := ParseParallelConfig([]byte(input)); err == nil || !strings.Contains(err.Error(), "judge") {
+		t.Fatalf("expected missing judge error, got %v", err)
+	}
+
+	t.Setenv(bestOfJudgeEnv, "reviewer")
+	cfg, err := ParseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	if cfg.Tasks[0].Judge != "reviewer" || len(cfg.Tasks[0].BestOf) != 2 {
+		t.Fatalf("unexpected task: %+v", cfg.Tasks[0])
+	}
+}
+
+func TestRunBestOf_JudgePicksWinner(t *testing.T) {
+	writeJudgeModelsConfig(t)
+	t.Setenv("DO_WORKTREE_DIR", "")
+	fake := &fakeBestOfWorktrees{}
+	fake.install(t)
+
+	var mu sync.Mutex
+	var judgePrompt string
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		if strings.HasSuffix(ts.ID, ".judge") {
+			mu.Lock()
+			judgePrompt = ts.Task
+			mu.Unlock()
+			if ts.Backend != "claude" || ts.Model != "judge-model" {
+				t.Errorf("judge resolved to %s/%s", ts.Backend, ts.Model)
+			}
+			return TaskResult{TaskID: ts.ID, Message: "```json\n{\"winner\": \"c2\", \"rationale\": \"cleaner\", \"scores\": {\"c1\": 5, \"c2\": 8}}\n```"}
+		}
+		if ts.Worktree || len(ts.BestOf) != 0 {
+			t.Errorf("candidate %s should not re-enter worktree/best_of mode", ts.ID)
+		}
+		return TaskResult{TaskID: ts.ID, Message: "done by " + ts.Backend, SessionID: "s-" + ts.Backend}
+	}
+
+	task := TaskSpec{ID: "feat", Task: "implement it", Backend: "codex", Model: "gpt-x", BestOf: []string{"codex", "claude"}, Judge: "reviewer"}
+	res := runBestOf(task, 10, nil, runTask)
+
+	if res.ExitCode != 0 || res.Error != "" {
+		t.Fatalf("unexpected failure: %+v", res)
+	}
+	if res.TaskID != "feat" || res.Message != "done by claude" || res.SessionID != "s-claude" {
+		t.Fatalf("winner result not propagated: %+v", res)
+	}
+	if res.BestOf == nil || res.BestOf.Winner != "c2" || res.BestOf.Branch != "do/wt2" || res.BestOf.Rationale != "cleaner" {
+		t.Fatalf("unexpected verdict: %+v", res.BestOf)
+	}
+	if len(res.BestOf.Candidates) != 2 || res.BestOf.Candidates[0].DiffBytes == 0 {
+		t.Fatalf("unexpected candidates: %+v", res.BestOf.Candidates)
+	}
+	if len(fake.removed) != 1 || fake.removed[0] != "do/wt1" {
+		t.Fatalf("expected loser removed, got %v", fake.removed)
+	}
+	if len(fake.committed) != 1 || fake.committed[0] != "/repo/.worktrees/do-wt2" {
+		t.Fatalf("expected winner committed, got %v", fake.committed)
+	}
+	if !strings.Contains(judgePrompt, `<candidate id="c1" backend="codex">`) || !strings.Contains(judgePrompt, "do-wt2") {
+		t.Fatalf("judge prompt missing candidates: %s", judgePrompt)
+	}
+}
+
+func TestRunBestOf_SynthesizedMessage(t *testing.T) {
+	writeJudgeModelsConfig(t)
+	t.Setenv("DO_WORKTREE_DIR", "")
+	fake := &fakeBestOfWorktrees{}
+	fake.install(t)
+
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		if strings.HasSuffix(ts.ID, ".judge") {
+			return TaskResult{Message: `{"winner": "c1", "synthesized_message": "combined answer"}`}
+		}
+		return TaskResult{Message: "answer " + ts.ID}
+	}
+	res := runBestOf(TaskSpec{ID: "r", BestOf: make([]string, 2), Judge: "reviewer"}, 10, nil, runTask)
+	if res.Message != "combined answer" || !res.BestOf.SynthesizedMessage || res.BestOf.WinnerMessage != "answer r.c1" {
+		t.Fatalf("expected synthesized message, got %+v", res)
+	}
+	// Only the winner's worktree is committed; the message does not merge code.
+	if len(fake.committed) != 1 {
+		t.Fatalf("committed = %v, want only the winner", fake.committed)
+	}
+}
+
+func TestRunBestOf_SingleSurvivorSkipsJudge(t *testing.T) {
+	t.Setenv("DO_WORKTREE_DIR", "")
+	fake := &fakeBestOfWorktrees{}
+	fake.install(t)
+
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		if strings.HasSuffix(ts.ID, ".judge") {
+			t.Fatal("judge should not run with one successful candidate")
+		}
+		if ts.Backend == "codex" {
+			return TaskResult{ExitCode: 1, Error: "boom"}
+		}
+		return TaskResult{Message: "ok"}
+	}
+	res := runBestOf(TaskSpec{ID: "t", BestOf: []string{"codex", "claude"}, Judge: "reviewer"}, 10, nil, runTask)
+	if res.ExitCode != 0 || res.BestOf.Winner != "c2" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(fake.removed) != 1 || fake.removed[0] != "do/wt1" {
+		t.Fatalf("expected failed candidate removed, got %v", fake.removed)
+	}
+}
+
+func TestRunBestOf_InvalidVerdictKeepsWorktrees(t *testing.T) {
+	writeJudgeModelsConfig(t)
+	t.Setenv("DO_WORKTREE_DIR", "")
+	fake := &fakeBestOfWorktrees{}
+	fake.install(t)
+
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		if strings.HasSuffix(ts.ID, ".judge") {
+			return TaskResult{Message: "I like both"}
+		}
+		return TaskResult{Message: "ok"}
+	}
+	res := runBestOf(TaskSpec{ID: "t", BestOf: []string{"codex", "claude"}, Judge: "reviewer"}, 10, nil, runTask)
+	if res.ExitCode == 0 || !strings.Contains(res.Error, "invalid verdict") {
+		t.Fatalf("expected invalid verdict error, got %+v", res)
+	}
+	if len(fake.removed) != 0 {
+		t.Fatalf("worktrees should be kept for inspection, removed %v", fake.removed)
+	}
+}
+
+func TestRunBestOf_RefusesWithDoWorktreeDir(t *testing.T) {
+	t.Setenv("DO_WORKTREE_DIR", "/tmp/wt")
+	res := runBestOf(TaskSpec{ID: "t", BestOf: []string{"codex", "claude"}, Judge: "reviewer"}, 10, nil, func(TaskSpec, int) TaskResult {
+		t.Fatal("runTask should not be called")
+		return TaskResult{}
+	})
+	if res.ExitCode == 0 || !strings.Contains(res.Error, "DO_WORKTREE_DIR") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestExecuteConcurrent_BestOfHonoursLimits(t *testing.T) {
+	writeJudgeModelsConfig(t)
+	t.Setenv("DO_WORKTREE_DIR", "")
+	t.Setenv("TMPDIR", t.TempDir())
+	fake := &fakeBestOfWorktrees{}
+	fake.install(t)
+	setConcurrencyLimits(t, map[string]config.ConcurrencyLimit{"claude": {MaxConcurrency: 1}}, nil)
+
+	var mu sync.Mutex
+	active, peak := map[string]int{}, map[string]int{}
+	track := func(key string, delta int) {
+		mu.Lock()
+		defer mu.Unlock()
+		active[key] += delta
+		if active[key] > peak[key] {
+			peak[key] = active[key]
+		}
+	}
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		track("all", 1)
+		track(ts.Backend, 1)
+		time.Sleep(30 * time.Millisecond)
+		track(ts.Backend, -1)
+		track("all", -1)
+		if strings.HasSuffix(ts.ID, ".judge") {
+			return TaskResult{TaskID: ts.ID, Message: `{"winner": "c1"}`}
+		}
+		return TaskResult{TaskID: ts.ID, Message: "done"}
+	}
+
+	layer := []TaskSpec{
+		{ID: "best", Task: "x", Backend: "codex", BestOf: []string{"claude", "claude", "codex"}, Judge: "reviewer"},
+		{ID: "plain", Task: "y", Backend: "codex"},
+	}
+	results := ExecuteConcurrentWithContext(context.Background(), [][]TaskSpec{layer}, 10, 2, runTask)
+	for _, res := range results {
+		if res.ExitCode != 0 || res.Error != "" {
+			t.Fatalf("task failed: %+v", res)
+		}
+	}
+	if peak["all"] > 2 || peak["claude"] != 1 {
+		t.Fatalf("peak concurrency = %v, want all<=2 claude=1", peak)
+	}
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	cache "codeagent-wrapper/internal/cache"
+	config "codeagent-wrapper/internal/config"
+	worktree "codeagent-wrapper/internal/worktree"
+)
+
+var (
+	treeHashFn    = worktree.TreeHash
+	cacheLimitsFn = func() cache.Limits {
+		return cache.Limits{TTL: config.ResolveCacheTTL(), MaxSize: config.ResolveCacheMaxSize()}
+	}
+)
+
+// taskCacheKey returns the hash a task's result is cached under, or why the
+// task cannot be cached. cfg must hold the resolved backend, model and
+// workdir.
+func taskCacheKey(task TaskSpec, cfg *Config) (string, error) {
+	switch {
+	case task.Mode == "resume" || cfg.Mode == "resume":
+		return "", fmt.Errorf("resumed sessions are never cached")
+	case task.Worktree:
+		return "", fmt.Errorf("tasks in a new worktree are not cached")
+	}
+	dir := cfg.WorkDir
+	if worktreeDir := os.Getenv("DO_WORKTREE_DIR"); worktreeDir != "" {
+		dir = worktreeDir
+	}
+	tree, prefix, err := treeHashFn(dir)
+	if err != nil {
+		return "", err
+	}
+	return cache.Key{
+		Prompt:          task.Task,
+		Backend:         cfg.Backend,
+		Model:           cfg.Model,
+		ReasoningEffort: cfg.ReasoningEffort,
+		Tree:            tree,
+		Prefix:          prefix,
+	}.Hash(), nil
+}
+
+// lookupCachedResult returns the cached result for key, marked Cached.
+func lookupCachedResult(key, taskID string) (TaskResult, time.Time, bool) {
+	data, createdAt, ok := cache.Get(key, cacheLimitsFn().TTL)
+	if !ok {
+		return TaskResult{}, time.Time{}, false
+	}
+	var res TaskResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return TaskResult{}, time.Time{}, false
+	}
+	res.TaskID = taskID
+	res.Cached = true
+	return res, createdAt, true
+}
+
+// storeCachedResult caches a successful result under key, without the
+// fields that describe one particular run.
+func storeCachedResult(key string, res TaskResult) error {
+	if res.ExitCode != 0 || res.Error != "" || strings.TrimSpace(res.Message) == "" || res.Cached {
+		return nil
+	}
+	res.TaskID, res.LogPath, res.TranscriptPath = "", "", ""
+	res.QueueWaitMs, res.CriticalPathPos, res.CriticalPathLen = 0, 0, 0
+	res.Stragglers, res.Annotations = nil, nil
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return cache.Put(key, data, cacheLimitsFn())
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func setTreeHashForTest(t *testing.T, tree string) {
+	t.Helper()
+	prev := treeHashFn
+	treeHashFn = func(string) (string, string, error) { return tree, "", nil }
+	t.Cleanup(func() { treeHashFn = prev })
+}
+
+func TestRunCodexTaskWithContext_CacheHit(t *testing.T) {
+	setTestHome(t, t.TempDir())
+	setTreeHashForTest(t, "tree-1")
+
+	task := TaskSpec{ID: "t1", Task: "explain main.go", WorkDir: ".", Backend: "claude", Cache: true}
+	key, err := taskCacheKey(task, &Config{Backend: "claude", WorkDir: "."})
+	if err != nil {
+		t.Fatalf("taskCacheKey: %v", err)
+	}
+	if err := storeCachedResult(key, TaskResult{TaskID: "old", Message: "it prints hello", SessionID: "sess-1", LogPath: "/tmp/old.log"}); err != nil {
+		t.Fatalf("storeCachedResult: %v", err)
+	}
+
+	restore := SetNewCommandRunner(func(context.Context, string, ...string) CommandRunner {
+		t.Fatal("backend ran despite a cache hit")
+		return nil
+	})
+	defer restore()
+
+	res := RunCodexTaskWithContext(context.Background(), task, nil, "claude", nil, nil, false, true, 1)
+	if !res.Cached || res.TaskID != "t1" || res.Message != "it prints hello" || res.SessionID != "sess-1" || res.LogPath != "" {
+		t.Fatalf("result = %+v", res)
+	}
+	if out := GenerateFinalOutputWithMode([]TaskResult{res}, true); !strings.Contains(out, "(cached)") {
+		t.Fatalf("summary does not mark the cached result:\n%s", out)
+	}
+
+	setTreeHashForTest(t, "tree-2")
+	if other, err := taskCacheKey(task, &Config{Backend: "claude", WorkDir: "."}); err != nil || other == key {
+		t.Fatalf("a changed tree must change the key: %q (%v)", other, err)
+	}
+	if other, _ := taskCacheKey(task, &Config{Backend: "codex", WorkDir: "."}); other == key {
+		t.Fatal("a different backend must change the key")
+	}
+}
+
+func TestTaskCacheKey_NeverResume(t *testing.T) {
+	setTreeHashForTest(t, "tree-1")
+	if _, err := taskCacheKey(TaskSpec{Task: "go on", Mode: "resume", SessionID: "s"}, &Config{Backend: "claude"}); err == nil {
+		t.Fatal("resumed task got a cache key")
+	}
+	if _, err := taskCacheKey(TaskSpec{Task: "x", Worktree: true}, &Config{Backend: "claude"}); err == nil {
+		t.Fatal("worktree task got a cache key")
+	}
+}
+
+func TestStoreCachedResult_SkipsFailures(t *testing.T) {
+	setTestHome(t, t.TempDir())
+	setTreeHashForTest(t, "tree-1")
+	key, _ := taskCacheKey(TaskSpec{Task: "x"}, &Config{Backend: "claude"})
+	for _, res := range []TaskResult{{ExitCode: 1, Message: "partial"}, {Error: "boom"}, {Message: "  "}} {
+		if err := storeCachedResult(key, res); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := lookupCachedResult(key, "t1"); ok {
+			t.Fatalf("cached an unsuccessful result %+v", res)
+		}
+	}
+}
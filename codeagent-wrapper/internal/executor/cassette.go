@@ -0,0 +1,373 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const cassetteVersion = 1
+
+// cassette is one backend run saved by --record and served by --replay.
+type cassette struct {
+	Version    int       `json:"version"`
+	TaskID     string    `json:"task_id,omitempty"`
+	PromptHash string    `json:"prompt_hash"` // hex SHA-256 of the final prompt
+	Backend    string    `json:"backend"`
+	Command    string    `json:"command"`
+	Args       []string  `json:"args"`
+	Stdin      string    `json:"stdin,omitempty"`
+	Stdout     []string  `json:"stdout"` // one entry per line, usually a JSON event
+	Stderr     string    `json:"stderr,omitempty"`
+	ExitCode   int       `json:"exit_code"` // -1 when the backend did not exit on its own
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// cassetteName is the file a task's cassette is written to: the task ID, or
+// the prompt hash for tasks without one.
+func cassetteName(taskID, hash string) string {
+	if taskID = unsafeFileChars.ReplaceAllString(strings.TrimSpace(taskID), "_"); taskID != "" {
+		return taskID + ".json"
+	}
+	return "prompt-" + hash[:16] + ".json"
+}
+
+// cassetteRecorder captures a backend's stdout and stderr as they are read
+// and writes the cassette once the task is done.
+type cassetteRecorder struct {
+	mu      sync.Mutex
+	c       cassette
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	started bool
+}
+
+func newCassetteRecorder(taskSpec TaskSpec, backendName, command string, args []string, stdin string) *cassetteRecorder {
+	return &cassetteRecorder{c: cassette{
+		Version:    cassetteVersion,
+		TaskID:     taskSpec.ID,
+		PromptHash: promptHash(taskSpec.Task),
+		Backend:    backendName,
+		Command:    command,
+		Args:       append([]string(nil), args...),
+		Stdin:      stdin,
+	}}
+}
+
+type recorderStream struct {
+	r   *cassetteRecorder
+	buf *bytes.Buffer
+}
+
+func (s recorderStream) Write(p []byte) (int, error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (r *cassetteRecorder) Stdout() io.Writer { return recorderStream{r: r, buf: &r.stdout} }
+func (r *cassetteRecorder) Stderr() io.Writer { return recorderStream{r: r, buf: &r.stderr} }
+
+// Started marks the backend as running; a backend that never started is not
+// recorded.
+func (r *cassetteRecorder) Started() {
+	r.mu.Lock()
+	r.started = true
+	r.mu.Unlock()
+}
+
+// SetExit records how the backend exited from the error Wait returned.
+func (r *cassetteRecorder) SetExit(waitErr error) {
+	code := 0
+	if waitErr != nil {
+		code = -1
+		if c, _, ok := exitStatus(waitErr); ok {
+			code = c
+		}
+	}
+	r.mu.Lock()
+	r.c.ExitCode = code
+	r.mu.Unlock()
+}
+
+// Save writes the cassette to dir, replacing an earlier recording of the same
+// task.
+func (r *cassetteRecorder) Save(dir string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		return "", nil
+	}
+	c := r.c
+	c.RecordedAt = time.Now().UTC()
+	c.Stdout = strings.SplitAfter(r.stdout.String(), "\n")
+	for i := range c.Stdout {
+		c.Stdout[i] = strings.TrimSuffix(c.Stdout[i], "\n")
+	}
+	if n := len(c.Stdout); n > 0 && c.Stdout[n-1] == "" {
+		c.Stdout = c.Stdout[:n-1]
+	}
+	c.Stderr = r.stderr.String()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir = absPathOr(dir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, cassetteName(c.TaskID, c.PromptHash))
+	tmp, err := os.CreateTemp(dir, ".cassette-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// loadCassette finds the cassette in dir for a task: the one recorded under
+// the same task ID, else one recorded with the same prompt.
+func loadCassette(dir, taskID, prompt string) (*cassette, string, error) {
+	dir = absPathOr(dir)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(paths)
+	hash := promptHash(prompt)
+	var byPrompt *cassette
+	byPromptPath := ""
+	for _, path := range paths {
+		data, err := os.ReadFile(path) // #nosec G304 -- cassette directory chosen by the user
+		if err != nil {
+			return nil, "", err
+		}
+		var c cassette
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, "", fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+		if c.Version != cassetteVersion {
+			return nil, "", fmt.Errorf("cassette %s has unsupported version %d", path, c.Version)
+		}
+		if taskID != "" && c.TaskID == taskID {
+			return &c, path, nil
+		}
+		if byPrompt == nil && c.PromptHash == hash {
+			byPrompt, byPromptPath = &c, path
+		}
+	}
+	if byPrompt == nil {
+		if taskID != "" {
+			return nil, "", fmt.Errorf("no cassette in %s for task %q or its prompt", dir, taskID)
+		}
+		return nil, "", fmt.Errorf("no cassette in %s for this prompt", dir)
+	}
+	return byPrompt, byPromptPath, nil
+}
+
+// replayExitError is what a replayed backend that exited non-zero returns
+// from Wait.
+type replayExitError struct {
+	code int
+}
+
+func (e *replayExitError) Error() string {
+	if e.code < 0 {
+		return "signal: terminated"
+	}
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitStatus returns the exit code of a backend that ran and failed, and its
+// process state when it was a real process.
+func exitStatus(err error) (int, *os.ProcessState, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), exitErr.ProcessState, true
+	}
+	var replayErr *replayExitError
+	if errors.As(err, &replayErr) {
+		return replayErr.code, nil, true
+	}
+	return 0, nil, false
+}
+
+// replayCmd implements commandRunner by serving a cassette instead of
+// starting a process. A cassette whose backend did not exit on its own keeps
+// running until it is signalled or its context ends.
+type replayCmd struct {
+	ctx    context.Context
+	c      *cassette
+	stdout *io.PipeWriter
+	stderr *io.PipeWriter
+	errOut io.Writer
+	proc   *replayProcess
+}
+
+func newReplayCmd(ctx context.Context, c *cassette) *replayCmd {
+	return &replayCmd{ctx: ctx, c: c}
+}
+
+func (r *replayCmd) Start() error {
+	if r.proc != nil {
+		return errors.New("replay already started")
+	}
+	r.proc = &replayProcess{done: make(chan struct{}), stop: make(chan struct{})}
+	var wg sync.WaitGroup
+	if r.stdout != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, line := range r.c.Stdout {
+				if _, err := io.WriteString(r.stdout, line+"\n"); err != nil {
+					break
+				}
+			}
+			r.stdout.Close()
+		}()
+	}
+	switch {
+	case r.stderr != nil:
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = io.WriteString(r.stderr, r.c.Stderr)
+			r.stderr.Close()
+		}()
+	case r.errOut != nil:
+		_, _ = io.WriteString(r.errOut, r.c.Stderr)
+	}
+	go func() {
+		output := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(output)
+		}()
+		select {
+		case <-output:
+			if r.c.ExitCode >= 0 {
+				r.proc.exit(r.c.ExitCode)
+				return
+			}
+		case <-r.proc.stop:
+		case <-r.ctx.Done():
+		}
+		select {
+		case <-r.proc.stop:
+		case <-r.ctx.Done():
+		}
+		r.closePipes()
+		r.proc.exit(-1)
+	}()
+	return nil
+}
+
+func (r *replayCmd) closePipes() {
+	if r.stdout != nil {
+		r.stdout.Close()
+	}
+	if r.stderr != nil {
+		r.stderr.Close()
+	}
+}
+
+func (r *replayCmd) Wait() error {
+	if r.proc == nil {
+		return errors.New("replay not started")
+	}
+	<-r.proc.done
+	if r.proc.code != 0 {
+		return &replayExitError{code: r.proc.code}
+	}
+	return nil
+}
+
+func (r *replayCmd) StdoutPipe() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	r.stdout = pw
+	return pr, nil
+}
+
+func (r *replayCmd) StderrPipe() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	r.stderr = pw
+	return pr, nil
+}
+
+func (r *replayCmd) StdinPipe() (io.WriteCloser, error) {
+	return discardCloser{}, nil
+}
+
+func (r *replayCmd) SetStderr(w io.Writer)    { r.errOut = w }
+func (r *replayCmd) SetDir(string)            {}
+func (r *replayCmd) SetEnv(map[string]string) {}
+func (r *replayCmd) UnsetEnv(...string)       {}
+func (r *replayCmd) Process() processHandle {
+	if r.proc == nil {
+		return nil
+	}
+	return r.proc
+}
+
+type discardCloser struct{}
+
+func (discardCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (discardCloser) Close() error                { return nil }
+
+// replayProcess stands in for the backend process of a replay.
+type replayProcess struct {
+	once     sync.Once
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	code     int
+}
+
+func (p *replayProcess) exit(code int) {
+	p.once.Do(func() {
+		p.code = code
+		close(p.done)
+	})
+}
+
+func (p *replayProcess) Pid() int { return 0 }
+
+func (p *replayProcess) Kill() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	return nil
+}
+
+func (p *replayProcess) Signal(os.Signal) error {
+	return p.Kill()
+}
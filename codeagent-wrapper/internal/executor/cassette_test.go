@@ -0,0 +1,89 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+const cassetteTestStream = `{"type":"thread.started","thread_id":"thread-1"}
+{"type":"item.completed","item":{"type":"agent_message","text":"hello"}}`
+
+func writeCassetteForTest(t *testing.T, dir string, c cassette) {
+	t.Helper()
+	c.Version = cassetteVersion
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, cassetteName(c.TaskID, c.PromptHash)), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordAndReplayCassette(t *testing.T) {
+	dir := t.TempDir()
+	// stderr goes first: once the message is parsed the wrapper may close
+	// the stderr pipe before draining what was written after it.
+	script := "cat > /dev/null; echo 'rate limited once' >&2; printf '%s\\n' '" + strings.ReplaceAll(cassetteTestStream, "\n", "' '") + "'"
+	task := TaskSpec{ID: "t1", Task: "say hello", WorkDir: t.TempDir(), UseStdin: true, RecordDir: dir}
+	res := RunCodexTaskWithContext(context.Background(), task, nil, "sh", nil, []string{"-c", script}, true, true, 10)
+	if res.ExitCode != 0 || res.Message != "hello" || res.SessionID != "thread-1" {
+		t.Fatalf("recorded run = %+v", res)
+	}
+
+	var c cassette
+	data, err := os.ReadFile(filepath.Join(dir, "t1.json"))
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		t.Fatalf("cassette = %s (%v)", data, err)
+	}
+	if c.TaskID != "t1" || c.Stdin != "say hello" || c.ExitCode != 0 || len(c.Stdout) != 2 || c.Stderr != "rate limited once\n" || c.Command != "sh" || c.PromptHash != promptHash("say hello") {
+		t.Fatalf("cassette = %+v", c)
+	}
+
+	restore := SetNewCommandRunner(func(context.Context, string, ...string) CommandRunner {
+		t.Fatal("backend ran during a replay")
+		return nil
+	})
+	defer restore()
+
+	task.RecordDir, task.ReplayDir = "", dir
+	res = RunCodexTaskWithContext(context.Background(), task, nil, "sh", nil, []string{"-c", script}, true, true, 10)
+	if res.ExitCode != 0 || res.Message != "hello" || res.SessionID != "thread-1" {
+		t.Fatalf("replayed run = %+v", res)
+	}
+
+	// Unknown task IDs fall back to the prompt.
+	task.ID = "renamed"
+	if res := RunCodexTaskWithContext(context.Background(), task, nil, "sh", nil, nil, true, true, 10); res.Message != "hello" {
+		t.Fatalf("replay by prompt = %+v", res)
+	}
+	task.Task = "something else"
+	if res := RunCodexTaskWithContext(context.Background(), task, nil, "sh", nil, nil, true, true, 10); res.ExitCode != 1 || !strings.Contains(res.Error, "no cassette") {
+		t.Fatalf("replay without a cassette = %+v", res)
+	}
+}
+
+func TestReplayCassette_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeCassetteForTest(t, dir, cassette{TaskID: "failed", PromptHash: promptHash("x"), Stdout: []string{`{"type":"thread.started","thread_id":"thread-2"}`}, Stderr: "quota exceeded\n", ExitCode: 3})
+	writeCassetteForTest(t, dir, cassette{TaskID: "lingering", PromptHash: promptHash("y"), Stdout: strings.Split(cassetteTestStream, "\n"), ExitCode: -1})
+
+	res := RunCodexTaskWithContext(context.Background(), TaskSpec{ID: "failed", Task: "x", ReplayDir: dir}, nil, "codex", nil, nil, false, true, 10)
+	if res.ExitCode != 3 || !strings.Contains(res.Error, "exited with status 3") || !strings.Contains(res.Error, "quota exceeded") {
+		t.Fatalf("failed replay = %+v", res)
+	}
+
+	// A backend that had to be terminated after its output lingers until the
+	// executor terminates it again.
+	res = RunCodexTaskWithContext(context.Background(), TaskSpec{ID: "lingering", Task: "y", ReplayDir: dir}, nil, "codex", nil, nil, false, true, 10)
+	if res.ExitCode != 0 || res.Message != "hello" {
+		t.Fatalf("lingering replay = %+v", res)
+	}
+}
@@ -0,0 +1,404 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sandbox "codeagent-wrapper/internal/sandbox"
+)
+
+// TaskPlan is the fully resolved execution plan for one task, as printed by
+// --dry-run. Building a plan reads config and prompt files but never spawns
+// a backend.
+type TaskPlan struct {
+	TaskID          string
+	Backend         string
+	Model           string
+	ReasoningEffort string
+	Agent           string
+	Mode            string
+	SessionID       string
+	WorkDir         string
+	Dependencies    []string
+	PromptFile      string
+	PromptSource    string // "--prompt-file", "agent <name>" or "config"
+	PromptBytes     int
+	PromptError     string
+	SkillSource     string // "explicit" or "detected"
+	Skills          []SkillPlan
+	Env             []string // KEY=value with secrets masked, sorted by key
+	UnsetEnv        []string
+	Command         string
+	Args            []string
+	UseStdin        bool
+	StdinBytes      int
+	Notes           []string
+}
+
+// SkillPlan describes one skill block injected into the task prompt.
+type SkillPlan struct {
+	Name     string
+	Bytes    int
+	Tokens   int      // estimated
+	Sections []string // section titles injected
+	Dropped  []string // section titles left out to fit the budget
+	Rule     string   // detection rule that selected it; empty for explicit skills
+}
+
+// PlanTask resolves a task the same way DefaultRunCodexTaskFn and
+// RunCodexTaskWithContext would, without creating worktrees or starting the
+// backend. promptFileExplicit mirrors --prompt-file, which may point outside
+// ~/.claude.
+func PlanTask(task TaskSpec, promptFileExplicit bool) (TaskPlan, error) {
+	if task.WorkDir == "" {
+		task.WorkDir = defaultWorkdir
+	}
+	if task.Mode == "" {
+		task.Mode = "new"
+	}
+
+	plan := TaskPlan{
+		TaskID:       task.ID,
+		Agent:        task.Agent,
+		Mode:         task.Mode,
+		SessionID:    task.SessionID,
+		WorkDir:      task.WorkDir,
+		Dependencies: task.Dependencies,
+	}
+	taskText := task.Task
+
+	if path := strings.TrimSpace(task.PromptFile); path != "" {
+		plan.PromptFile = path
+		switch {
+		case promptFileExplicit:
+			plan.PromptSource = "--prompt-file"
+		case task.Agent != "":
+			plan.PromptSource = "agent " + task.Agent
+		default:
+			plan.PromptSource = "config"
+		}
+		prompt, err := RenderAgentPromptFile(path, promptFileExplicit, promptVarsForTask(task))
+		if err != nil {
+			plan.PromptError = err.Error()
+		} else {
+			plan.PromptBytes = len(prompt)
+			task.Task = WrapTaskWithAgentPrompt(prompt, task.Task)
+		}
+	}
+
+	skills := task.Skills
+	plan.SkillSource = "explicit"
+	rules := make(map[string]string)
+	if len(skills) == 0 {
+		plan.SkillSource = "detected"
+		for _, m := range MatchProjectSkills(task.WorkDir) {
+			if m.Path != "" {
+				skills = append(skills, m.Skill)
+				rules[m.Skill] = fmt.Sprintf("%s via %s", m.Reason, m.Source)
+			}
+		}
+	}
+	if sections := resolveSkillSections(task.WorkDir, taskText, skills, 0); len(sections) > 0 {
+		parts := make([]string, len(sections))
+		for i, section := range sections {
+			parts[i] = section.content
+			plan.Skills = append(plan.Skills, SkillPlan{
+				Name:     section.name,
+				Bytes:    len(section.content),
+				Tokens:   section.tokens,
+				Sections: section.included,
+				Dropped:  section.dropped,
+				Rule:     rules[section.name],
+			})
+		}
+		task.Task = task.Task + "\n\n# Domain Best Practices\n\n" + strings.Join(parts, "\n\n")
+	}
+
+	backendName := task.Backend
+	if backendName == "" {
+		backendName = defaultBackendName
+	}
+	backend, err := selectBackendFn(backendName)
+	if err != nil {
+		return plan, err
+	}
+
+	cfg := &Config{
+		Mode:            task.Mode,
+		Task:            task.Task,
+		SessionID:       task.SessionID,
+		WorkDir:         task.WorkDir,
+		Model:           task.Model,
+		ReasoningEffort: task.ReasoningEffort,
+		SkipPermissions: task.SkipPermissions,
+		Backend:         backend.Name(),
+		AllowedTools:    task.AllowedTools,
+		DisallowedTools: task.DisallowedTools,
+	}
+
+	if worktreeDir := os.Getenv("DO_WORKTREE_DIR"); worktreeDir != "" {
+		cfg.WorkDir = worktreeDir
+		plan.Notes = append(plan.Notes, "workdir taken from DO_WORKTREE_DIR")
+	} else if task.Worktree {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("a new git worktree would be created from %s; the command would run inside it", cfg.WorkDir))
+	}
+	if cfg.Mode == "resume" && task.HandoffBackend != "" && task.HandoffBackend != cfg.Backend {
+		handoffTask, err := buildHandoffTask(task.HandoffBackend, strings.TrimSpace(task.SessionID), task.Task, 0)
+		if err != nil {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("handoff from %s would fail: %v", task.HandoffBackend, err))
+		} else {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%s session %s would be replayed into a new %s session", task.HandoffBackend, task.SessionID, cfg.Backend))
+			cfg.Mode, cfg.SessionID = "new", ""
+			cfg.Task, task.Task = handoffTask, handoffTask
+			task.UseStdin = true
+			plan.Mode = cfg.Mode
+		}
+	}
+	if dir := strings.TrimSpace(task.TranscriptDir); dir != "" {
+		plan.Notes = append(plan.Notes, "a JSONL transcript would be written to "+absPathOr(dir))
+	}
+	if dir := strings.TrimSpace(task.RecordDir); dir != "" {
+		plan.Notes = append(plan.Notes, "the backend run would be recorded as a cassette in "+absPathOr(dir))
+	}
+	if dir := strings.TrimSpace(task.ReplayDir); dir != "" {
+		if _, path, err := loadCassette(dir, task.ID, task.Task); err != nil {
+			plan.Notes = append(plan.Notes, "the task would fail: "+err.Error())
+		} else {
+			plan.Notes = append(plan.Notes, "the backend would not run; its output would be replayed from "+path)
+		}
+	}
+	if len(task.BestOf) > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("best_of: %d candidates judged by agent %s", len(task.BestOf), task.Judge))
+	}
+	if task.Sandbox != nil {
+		if err := sandbox.Available(); err != nil {
+			plan.Notes = append(plan.Notes, "the task would fail: "+err.Error())
+		} else {
+			policy := sandboxPolicy(*task.Sandbox, cfg, "")
+			plan.Notes = append(plan.Notes, "the backend would run in a Landlock sandbox; writable: "+strings.Join(policy.Write, ", ")+" and a private temp directory")
+		}
+	}
+	if limits := taskResourceLimits(task); !limits.IsZero() {
+		plan.Notes = append(plan.Notes, "resource limits: "+limits.String())
+	}
+	if task.Cache && len(task.BestOf) == 0 {
+		if key, err := taskCacheKey(task, cfg); err != nil {
+			plan.Notes = append(plan.Notes, "result cache not used: "+err.Error())
+		} else if _, createdAt, ok := lookupCachedResult(key, task.ID); ok {
+			plan.Notes = append(plan.Notes, "the result cached at "+createdAt.Local().Format(time.RFC3339)+" would be returned; the command would not run")
+		} else {
+			plan.Notes = append(plan.Notes, "no cached result; a successful result would be cached")
+		}
+	}
+	if hooks := resolveHooksFn(); len(hooks.PreTask)+len(hooks.PostTask) > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%d pre_task and %d post_task hooks would run around the task", len(hooks.PreTask), len(hooks.PostTask)))
+	}
+
+	env := make(map[string]string)
+	for k, v := range resolveFileEnv(cfg) {
+		env[k] = v
+	}
+	for k, v := range resolveBackendEnv(backend, cfg.Backend, task.Agent) {
+		env[k] = v
+	}
+	for _, k := range []string{"TMPDIR", "TMP", "TEMP"} {
+		if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+			env[k] = v
+		}
+	}
+
+	plan.Backend = cfg.Backend
+	plan.Model = cfg.Model
+	plan.ReasoningEffort = cfg.ReasoningEffort
+	plan.WorkDir = cfg.WorkDir
+	plan.Command = backend.Command()
+
+	if plan.Command == "claude" {
+		env["CLAUDE_CODE_TMPDIR"] = "<isolated temp dir>"
+		plan.UnsetEnv = append(plan.UnsetEnv, "CLAUDECODE")
+	}
+	envPolicy := taskEnvPolicy(task)
+	for k, v := range envPolicy.Env {
+		env[k] = v
+	}
+	for k := range env {
+		if envPolicy.Removes(k) {
+			delete(env, k)
+		}
+	}
+	plan.UnsetEnv = append(plan.UnsetEnv, envPolicy.Unset...)
+	if len(envPolicy.Passthrough) > 0 {
+		plan.Notes = append(plan.Notes, "only inherited variables matching "+strings.Join(envPolicy.Passthrough, ", ")+" would be passed to the backend")
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		plan.Env = append(plan.Env, k+"="+maskSensitiveValue(k, env[k]))
+	}
+
+	plan.UseStdin = task.UseStdin || ShouldUseStdin(task.Task, false)
+	targetArg := task.Task
+	if plan.UseStdin {
+		targetArg = "-"
+		plan.StdinBytes = len(task.Task)
+	}
+	plan.Args = backend.BuildArgs(cfg, targetArg)
+	if cfg.Mode != "resume" && plan.Command != "codex" && cfg.WorkDir != "" {
+		plan.Notes = append(plan.Notes, "process working directory: "+cfg.WorkDir)
+	}
+	return plan, nil
+}
+
+// RenderTaskPlans formats plans for the --dry-run report.
+func RenderTaskPlans(plans []TaskPlan) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("=== Dry Run: %d task(s) ===\n", len(plans)))
+	for _, p := range plans {
+		title := p.TaskID
+		if title == "" {
+			title = "task"
+		}
+		sb.WriteString(fmt.Sprintf("\n### %s\n", sanitizeOutput(title)))
+		sb.WriteString(fmt.Sprintf("Backend: %s\n", p.Backend))
+		sb.WriteString(fmt.Sprintf("Model: %s\n", valueOrDefault(p.Model, "(backend default)")))
+		sb.WriteString(fmt.Sprintf("Reasoning: %s\n", valueOrDefault(p.ReasoningEffort, "(backend default)")))
+		if p.Agent != "" {
+			sb.WriteString(fmt.Sprintf("Agent: %s\n", p.Agent))
+		}
+		mode := p.Mode
+		if p.SessionID != "" {
+			mode += " (session " + p.SessionID + ")"
+		}
+		sb.WriteString(fmt.Sprintf("Mode: %s\n", mode))
+		sb.WriteString(fmt.Sprintf("Workdir: %s\n", p.WorkDir))
+		if len(p.Dependencies) > 0 {
+			sb.WriteString(fmt.Sprintf("Depends on: %s\n", strings.Join(p.Dependencies, ", ")))
+		}
+		if p.PromptFile != "" {
+			switch {
+			case p.PromptError != "":
+				sb.WriteString(fmt.Sprintf("Prompt file: %s (%s) ERROR: %s\n", p.PromptFile, p.PromptSource, p.PromptError))
+			default:
+				sb.WriteString(fmt.Sprintf("Prompt file: %s (%s, %d bytes)\n", p.PromptFile, p.PromptSource, p.PromptBytes))
+			}
+		}
+		if len(p.Skills) > 0 {
+			parts := make([]string, len(p.Skills))
+			for i, skill := range p.Skills {
+				parts[i] = fmt.Sprintf("%s (~%d tokens, %d/%d sections", skill.Name, skill.Tokens, len(skill.Sections), len(skill.Sections)+len(skill.Dropped))
+				if skill.Rule != "" {
+					parts[i] += ", " + skill.Rule
+				}
+				parts[i] += ")"
+			}
+			sb.WriteString(fmt.Sprintf("Skills (%s): %s\n", p.SkillSource, strings.Join(parts, ", ")))
+			for _, skill := range p.Skills {
+				if len(skill.Dropped) > 0 {
+					sb.WriteString(fmt.Sprintf("Skill %s dropped sections: %s\n", skill.Name, strings.Join(skill.Dropped, "; ")))
+				}
+			}
+		}
+		for _, kv := range p.Env {
+			sb.WriteString(fmt.Sprintf("Env: %s\n", kv))
+		}
+		if len(p.UnsetEnv) > 0 {
+			sb.WriteString(fmt.Sprintf("Unset env: %s\n", strings.Join(p.UnsetEnv, ", ")))
+		}
+		sb.WriteString(fmt.Sprintf("Command: %s\n", shellJoin(append([]string{p.Command}, p.Args...))))
+		if p.UseStdin {
+			sb.WriteString(fmt.Sprintf("Stdin: task text (%d bytes)\n", p.StdinBytes))
+		}
+		for _, note := range p.Notes {
+			sb.WriteString(fmt.Sprintf("Note: %s\n", note))
+		}
+	}
+	return sb.String()
+}
+
+func valueOrDefault(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
+
+var shellSafeArg = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+// shellJoin quotes args so the printed command can be pasted into a POSIX
+// shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafeArg.MatchString(arg) {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// RenderTaskGraph exports the dependency graph of the sorted layers as
+// Mermaid ("mermaid") or Graphviz DOT ("dot").
+func RenderTaskGraph(layers [][]TaskSpec, format string) (string, error) {
+	var tasks []TaskSpec
+	for _, layer := range layers {
+		tasks = append(tasks, layer...)
+	}
+	label := func(task TaskSpec) string {
+		detail := task.Backend
+		if task.Agent != "" {
+			detail = task.Agent + "@" + detail
+		}
+		if detail == "" {
+			return task.ID
+		}
+		return task.ID + "\n" + detail
+	}
+
+	var sb strings.Builder
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "mermaid":
+		nodeIDs := make(map[string]string, len(tasks))
+		for i, task := range tasks {
+			nodeIDs[task.ID] = fmt.Sprintf("t%d", i)
+		}
+		sb.WriteString("graph TD\n")
+		for _, task := range tasks {
+			text := strings.ReplaceAll(label(task), `"`, "#quot;")
+			text = strings.ReplaceAll(text, "\n", "<br/>")
+			sb.WriteString(fmt.Sprintf("  %s[\"%s\"]\n", nodeIDs[task.ID], text))
+		}
+		for _, task := range tasks {
+			for _, dep := range task.Dependencies {
+				sb.WriteString(fmt.Sprintf("  %s --> %s\n", nodeIDs[dep], nodeIDs[task.ID]))
+			}
+		}
+	case "dot":
+		quote := func(s string) string {
+			s = strings.ReplaceAll(s, `\`, `\\`)
+			s = strings.ReplaceAll(s, `"`, `\"`)
+			return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+		}
+		sb.WriteString("digraph tasks {\n  rankdir=LR;\n")
+		for _, task := range tasks {
+			sb.WriteString(fmt.Sprintf("  %s [label=%s];\n", quote(task.ID), quote(label(task))))
+		}
+		for _, task := range tasks {
+			for _, dep := range task.Dependencies {
+				sb.WriteString(fmt.Sprintf("  %s -> %s;\n", quote(dep), quote(task.ID)))
+			}
+		}
+		sb.WriteString("}\n")
+	default:
+		return "", fmt.Errorf("unsupported graph format %q (use mermaid or dot)", format)
+	}
+	return sb.String(), nil
+}
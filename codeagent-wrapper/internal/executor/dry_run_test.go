@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+func TestPlanTask_ResolvesAgentSkillsAndEnv(t *testing.T) {
+	home := createTempSkill(t, "golang-base-practices", "---\nname: go\n---\nUse gofmt.")
+	setTestHome(t, home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("DO_WORKTREE_DIR", "")
+	config.ResetModelsConfigCacheForTest()
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+
+	promptPath := filepath.Join(home, ".claude", "agents", "develop.md")
+	if err := os.MkdirAll(filepath.Dir(promptPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(promptPath, []byte("You are a developer."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".codeagent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".codeagent", "models.json"), []byte(`{
+  "backends": { "claude": { "base_url": "http://localhost:9999", "api_key": "sk-abcdefghijkl" } }
+}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "go.mod"), []byte("module x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanTask(TaskSpec{
+		ID:              "t1",
+		Task:            "fix it",
+		WorkDir:         workDir,
+		Backend:         "claude",
+		Model:           "opus",
+		ReasoningEffort: "high",
+		Agent:           "develop",
+		PromptFile:      "~/.claude/agents/develop.md",
+		Dependencies:    []string{"t0"},
+	}, false)
+	if err != nil {
+		t.Fatalf("PlanTask: %v", err)
+	}
+
+	if plan.Backend != "claude" || plan.Model != "opus" || plan.Command != "claude" {
+		t.Fatalf("unexpected backend resolution: %+v", plan)
+	}
+	if plan.PromptSource != "agent develop" || plan.PromptBytes != len("You are a developer.") || plan.PromptError != "" {
+		t.Fatalf("unexpected prompt resolution: %+v", plan)
+	}
+	if plan.SkillSource != "detected" || len(plan.Skills) != 1 || plan.Skills[0].Name != "golang-base-practices" || plan.Skills[0].Bytes == 0 {
+		t.Fatalf("unexpected skills: %+v", plan.Skills)
+	}
+	env := strings.Join(plan.Env, "\n")
+	if !strings.Contains(env, "ANTHROPIC_BASE_URL=http://localhost:9999") || strings.Contains(env, "sk-abcdefghijkl") {
+		t.Fatalf("env not resolved or not masked:\n%s", env)
+	}
+	if !plan.UseStdin || plan.Args[len(plan.Args)-1] != "-" {
+		t.Fatalf("prompt with agent wrapper should go through stdin: %+v", plan.Args)
+	}
+
+	out := RenderTaskPlans([]TaskPlan{plan})
+	for _, want := range []string{"### t1", "Model: opus", "Reasoning: high", "Depends on: t0", "Skills (detected): golang-base-practices", "Command: claude ", "Unset env: CLAUDECODE"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPlanTask_UnknownBackend(t *testing.T) {
+	if _, err := PlanTask(TaskSpec{ID: "x", Task: "t", Backend: "nope"}, false); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin([]string{"codex", "-C", "/tmp/x", "it's done", ""})
+	want := `codex -C /tmp/x 'it'\''s done' ''`
+	if got != want {
+		t.Fatalf("shellJoin = %s, want %s", got, want)
+	}
+}
+
+func TestRenderTaskGraph(t *testing.T) {
+	layers := [][]TaskSpec{
+		{{ID: "api", Backend: "codex"}},
+		{{ID: "ui \"v2\"", Backend: "claude", Agent: "frontend", Dependencies: []string{"api"}}},
+	}
+
+	mermaid, err := RenderTaskGraph(layers, "mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"graph TD", `t0["api<br/>codex"]`, `t1["ui #quot;v2#quot;<br/>frontend@claude"]`, "t0 --> t1"} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("mermaid missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	dot, err := RenderTaskGraph(layers, "dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot, `"api" -> "ui \"v2\"";`) || !strings.HasPrefix(dot, "digraph tasks {") {
+		t.Fatalf("unexpected dot output:\n%s", dot)
+	}
+
+	if _, err := RenderTaskGraph(layers, "svg"); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// envPolicySetter is implemented by command runners that can restrict the
+// inherited environment.
+type envPolicySetter interface {
+	SetEnvPolicy(config.EnvPolicy)
+}
+
+// taskEnvPolicy combines the agent's env, env_passthrough and env_unset with
+// the task's own; task variables win and patterns add up.
+func taskEnvPolicy(task TaskSpec) config.EnvPolicy {
+	return config.ResolveAgentEnvPolicy(task.Agent).Merge(config.EnvPolicy{
+		Env:         task.Env,
+		Passthrough: task.EnvPassthrough,
+		Unset:       task.EnvUnset,
+	})
+}
+
+// commandEnviron returns the environment cmd will start with, or nil when it
+// is not known.
+func commandEnviron(cmd commandRunner) []string {
+	rc, ok := cmd.(*realCmd)
+	if !ok || rc.cmd == nil {
+		return nil
+	}
+	if rc.cmd.Env == nil {
+		return os.Environ()
+	}
+	return rc.cmd.Env
+}
+
+// logFinalEnv writes the backend's environment to the task log. Only the
+// values in explicit, which the agent or task set, are shown, with sensitive
+// ones masked; inherited values are always masked.
+func logFinalEnv(cmd commandRunner, explicit map[string]string, logFn func(string)) {
+	env := commandEnviron(cmd)
+	if env == nil {
+		return
+	}
+	env = append([]string(nil), env...)
+	sort.Strings(env)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Backend environment (%d variables):", len(env)))
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if _, ok := explicit[name]; !ok && value != "" {
+			value = "****"
+		}
+		sb.WriteString("\n  " + name + "=" + maskEnvValue(name, value))
+	}
+	logFn(sb.String())
+}
+
+// maskEnvValue masks what maskSensitiveValue masks plus passwords and
+// credentials.
+func maskEnvValue(name, value string) string {
+	lower := strings.ToLower(name)
+	if value != "" && (strings.Contains(lower, "passw") || strings.Contains(lower, "credential")) {
+		return "****"
+	}
+	return maskSensitiveValue(name, value)
+}
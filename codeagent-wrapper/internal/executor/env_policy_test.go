@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+	launcher "codeagent-wrapper/internal/launcher"
+)
+
+func TestRealCmd_EnvPolicy(t *testing.T) {
+	t.Setenv("KEEP_A", "1")
+	t.Setenv("KEEP_B", "2")
+	t.Setenv("DROP_ME", "3")
+	t.Setenv("SECRET_TOKEN", "4")
+
+	cmd := &realCmd{cmd: commandContext(context.Background(), "true")}
+	policy := config.EnvPolicy{
+		Env:         map[string]string{"PROJECT": "demo", "KEEP_A": "override"},
+		Passthrough: []string{"KEEP_*"},
+		Unset:       []string{"SECRET_*", "CODEAGENT_*"},
+	}
+	cmd.SetEnvPolicy(policy)
+	cmd.SetEnv(map[string]string{"ANTHROPIC_API_KEY": "sk-injected", "SECRET_EXTRA": "x", launcher.SpecEnv: "{}"})
+	cmd.UnsetEnv("KEEP_B")
+	cmd.SetEnv(policy.Env)
+
+	got := strings.Join(cmd.cmd.Env, "\n")
+	want := strings.Join([]string{"ANTHROPIC_API_KEY=sk-injected", launcher.SpecEnv + "={}", "KEEP_A=override", "PROJECT=demo"}, "\n")
+	if got != want {
+		t.Fatalf("env =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRealCmd_EnvPolicyEmptyPassthroughMatch(t *testing.T) {
+	cmd := &realCmd{cmd: commandContext(context.Background(), "true")}
+	cmd.SetEnvPolicy(config.EnvPolicy{Passthrough: []string{"NO_SUCH_VARIABLE_*"}})
+	if cmd.cmd.Env == nil || len(cmd.cmd.Env) != 0 {
+		t.Fatalf("env = %v, want an empty (not inherited) environment", cmd.cmd.Env)
+	}
+}
+
+func TestParseParallelConfig_Env(t *testing.T) {
+	cfg, err := ParseParallelConfig([]byte("---TASK---\nid: a\nenv: GOFLAGS=-mod=mod -tags=x\nenv: URL=http://h:1/p?a=b\nenv_passthrough: PATH, HOME, GO*\nenv_unset: AWS_*\n---CONTENT---\ndo a"))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	task := cfg.Tasks[0]
+	if task.Env["GOFLAGS"] != "-mod=mod -tags=x" || task.Env["URL"] != "http://h:1/p?a=b" {
+		t.Fatalf("env = %v", task.Env)
+	}
+	if strings.Join(task.EnvPassthrough, ",") != "PATH,HOME,GO*" || strings.Join(task.EnvUnset, ",") != "AWS_*" {
+		t.Fatalf("passthrough = %v, unset = %v", task.EnvPassthrough, task.EnvUnset)
+	}
+	for _, bad := range []string{"env: NOVALUE", "env: =x", "env_unset: [", "env_passthrough: a[b"} {
+		if _, err := ParseParallelConfig([]byte("---TASK---\nid: a\n" + bad + "\n---CONTENT---\ndo a")); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestLogFinalEnv_MasksSecrets(t *testing.T) {
+	cmd := &realCmd{cmd: commandContext(context.Background(), "true")}
+	t.Setenv("INHERITED_DSN", "postgres://user:pw@db/app")
+	cmd.SetEnvPolicy(config.EnvPolicy{Passthrough: []string{"INHERITED_*"}})
+	explicit := map[string]string{"OPENAI_API_KEY": "sk-1234567890abcd", "DB_PASSWORD": "hunter2", "PLAIN": "visible"}
+	cmd.SetEnv(explicit)
+
+	var logged string
+	logFinalEnv(cmd, explicit, func(msg string) { logged = msg })
+	for _, want := range []string{"4 variables", "OPENAI_API_KEY=sk-1****abcd", "DB_PASSWORD=****", "PLAIN=visible", "INHERITED_DSN=****"} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("log missing %q:\n%s", want, logged)
+		}
+	}
+	for _, secret := range []string{"hunter2", "postgres://"} {
+		if strings.Contains(logged, secret) {
+			t.Fatalf("%q leaked:\n%s", secret, logged)
+		}
+	}
+}
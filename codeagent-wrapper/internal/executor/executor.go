@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -18,8 +19,13 @@ import (
 
 	backend "codeagent-wrapper/internal/backend"
 	config "codeagent-wrapper/internal/config"
+	launcher "codeagent-wrapper/internal/launcher"
 	ilogger "codeagent-wrapper/internal/logger"
+	metrics "codeagent-wrapper/internal/metrics"
 	parser "codeagent-wrapper/internal/parser"
+	resources "codeagent-wrapper/internal/resources"
+	session "codeagent-wrapper/internal/session"
+	tracing "codeagent-wrapper/internal/tracing"
 	utils "codeagent-wrapper/internal/utils"
 	"codeagent-wrapper/internal/worktree"
 )
@@ -51,6 +57,7 @@ var (
 	commandContext     = exec.CommandContext
 	terminateCommandFn = terminateCommand
 	createWorktreeFn   = worktree.CreateWorktree
+	recordSessionFn    = session.Record
 )
 
 var forceKillDelay atomic.Int32
@@ -89,8 +96,8 @@ func logError(msg string) { ilogger.LogError(msg) }
 
 func logConcurrencyPlanning(limit, total int) { ilogger.LogConcurrencyPlanning(limit, total) }
 
-func logConcurrencyState(event, taskID string, active, limit int) {
-	ilogger.LogConcurrencyState(event, taskID, active, limit)
+func logConcurrencyState(event, taskID string, active, limit int, details ...string) {
+	ilogger.LogConcurrencyState(event, taskID, active, limit, details...)
 }
 
 func parseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func()) (message, threadID string) {
@@ -126,14 +133,24 @@ type processHandle interface {
 
 // realCmd implements commandRunner using exec.Cmd
 type realCmd struct {
-	cmd *exec.Cmd
+	cmd       *exec.Cmd
+	group     bool // the process leads its own process group
+	envPolicy *config.EnvPolicy
+	unset     map[string]bool // removed with UnsetEnv; not re-inherited by SetEnv
+	childEnds []*os.File      // write ends of the output pipes; the parent closes them after Start
 }
 
 func (r *realCmd) Start() error {
 	if r.cmd == nil {
 		return errors.New("command is nil")
 	}
-	return r.cmd.Start()
+	r.group = startInOwnGroup(r.cmd)
+	err := r.cmd.Start()
+	for _, f := range r.childEnds {
+		_ = f.Close()
+	}
+	r.childEnds = nil
+	return err
 }
 
 func (r *realCmd) Wait() error {
@@ -143,18 +160,47 @@ func (r *realCmd) Wait() error {
 	return r.cmd.Wait()
 }
 
+// StdoutPipe returns the read end of an os.Pipe rather than exec's pipe:
+// exec closes its pipe as soon as Wait returns, which drops output not yet
+// read from a backend that exits right after printing it. The executor
+// drains and closes the pipe itself.
 func (r *realCmd) StdoutPipe() (io.ReadCloser, error) {
 	if r.cmd == nil {
 		return nil, errors.New("command is nil")
 	}
-	return r.cmd.StdoutPipe()
+	if r.cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if r.cmd.Process != nil {
+		return nil, errors.New("exec: StdoutPipe after process started")
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	r.cmd.Stdout = pw
+	r.childEnds = append(r.childEnds, pw)
+	return pr, nil
 }
 
+// StderrPipe is like StdoutPipe.
 func (r *realCmd) StderrPipe() (io.ReadCloser, error) {
 	if r.cmd == nil {
 		return nil, errors.New("command is nil")
 	}
-	return r.cmd.StderrPipe()
+	if r.cmd.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	if r.cmd.Process != nil {
+		return nil, errors.New("exec: StderrPipe after process started")
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	r.cmd.Stderr = pw
+	r.childEnds = append(r.childEnds, pw)
+	return pr, nil
 }
 
 func (r *realCmd) StdinPipe() (io.WriteCloser, error) {
@@ -176,11 +222,26 @@ func (r *realCmd) SetDir(dir string) {
 	}
 }
 
+// SetEnvPolicy limits the inherited environment to the policy's passthrough
+// patterns and removes its unset patterns, now and on every later SetEnv. The
+// policy's own variables are not set here; the executor sets them last so
+// they win over everything the wrapper injects. Call it before SetEnv.
+func (r *realCmd) SetEnvPolicy(p config.EnvPolicy) {
+	if r == nil || r.cmd == nil {
+		return
+	}
+	r.envPolicy = &p
+	r.mergeEnv(nil)
+}
+
 func (r *realCmd) SetEnv(env map[string]string) {
 	if r == nil || r.cmd == nil || len(env) == 0 {
 		return
 	}
+	r.mergeEnv(env)
+}
 
+func (r *realCmd) mergeEnv(env map[string]string) {
 	merged := make(map[string]string, len(env)+len(os.Environ()))
 	for _, kv := range os.Environ() {
 		if kv == "" {
@@ -190,6 +251,9 @@ func (r *realCmd) SetEnv(env map[string]string) {
 		if idx <= 0 {
 			continue
 		}
+		if r.unset[kv[:idx]] || (r.envPolicy != nil && !r.envPolicy.Inherits(kv[:idx])) {
+			continue
+		}
 		merged[kv[:idx]] = kv[idx+1:]
 	}
 	for _, kv := range r.cmd.Env {
@@ -208,6 +272,14 @@ func (r *realCmd) SetEnv(env map[string]string) {
 		}
 		merged[k] = v
 	}
+	if r.envPolicy != nil {
+		for k := range merged {
+			// The launcher cannot start the backend without its spec.
+			if k != launcher.SpecEnv && r.envPolicy.Removes(k) {
+				delete(merged, k)
+			}
+		}
+	}
 
 	keys := make([]string, 0, len(merged))
 	for k := range merged {
@@ -231,9 +303,13 @@ func (r *realCmd) UnsetEnv(keys ...string) {
 	if r.cmd.Env == nil {
 		r.cmd.Env = os.Environ()
 	}
+	if r.unset == nil {
+		r.unset = make(map[string]bool, len(keys))
+	}
 	drop := make(map[string]struct{}, len(keys))
 	for _, k := range keys {
 		drop[k] = struct{}{}
+		r.unset[k] = true
 	}
 	filtered := make([]string, 0, len(r.cmd.Env))
 	for _, kv := range r.cmd.Env {
@@ -253,12 +329,14 @@ func (r *realCmd) Process() processHandle {
 	if r == nil || r.cmd == nil || r.cmd.Process == nil {
 		return nil
 	}
-	return &realProcess{proc: r.cmd.Process}
+	return &realProcess{proc: r.cmd.Process, group: r.group}
 }
 
-// realProcess implements processHandle using os.Process
+// realProcess implements processHandle using os.Process. When the process
+// leads its own group, signals go to the whole group.
 type realProcess struct {
-	proc *os.Process
+	proc  *os.Process
+	group bool
 }
 
 func (p *realProcess) Pid() int {
@@ -272,6 +350,9 @@ func (p *realProcess) Kill() error {
 	if p == nil || p.proc == nil {
 		return nil
 	}
+	if p.group && signalGroup(p.proc.Pid, os.Kill) == nil {
+		return nil
+	}
 	return p.proc.Kill()
 }
 
@@ -279,6 +360,9 @@ func (p *realProcess) Signal(sig os.Signal) error {
 	if p == nil || p.proc == nil {
 		return nil
 	}
+	if p.group && signalGroup(p.proc.Pid, sig) == nil {
+		return nil
+	}
 	return p.proc.Signal(sig)
 }
 
@@ -358,10 +442,11 @@ func DefaultRunCodexTaskFn(task TaskSpec, timeout int) TaskResult {
 	if task.Mode == "" {
 		task.Mode = "new"
 	}
+	taskText := task.Task
 	if strings.TrimSpace(task.PromptFile) != "" {
-		prompt, err := ReadAgentPromptFile(task.PromptFile, false)
+		prompt, err := RenderAgentPromptFile(task.PromptFile, false, promptVarsForTask(task))
 		if err != nil {
-			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "failed to read prompt file: " + err.Error()}
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "failed to load prompt file: " + err.Error()}
 		}
 		task.Task = WrapTaskWithAgentPrompt(prompt, task.Task)
 	}
@@ -371,7 +456,7 @@ func DefaultRunCodexTaskFn(task TaskSpec, timeout int) TaskResult {
 		skills = DetectProjectSkills(task.WorkDir)
 	}
 	if len(skills) > 0 {
-		if content := ResolveSkillContent(skills, 0); content != "" {
+		if content := ResolveSkillContent(task.WorkDir, taskText, skills, 0); content != "" {
 			task.Task = task.Task + "\n\n# Domain Best Practices\n\n" + content
 		}
 	}
@@ -514,43 +599,27 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 		workerLimit = 0
 	}
 
-	var sem chan struct{}
-	if workerLimit > 0 {
-		sem = make(chan struct{}, workerLimit)
-	}
-
 	logConcurrencyPlanning(workerLimit, totalTasks)
-
-	acquireSlot := func() bool {
-		if sem == nil {
-			return true
-		}
-		select {
-		case sem <- struct{}{}:
-			return true
-		case <-ctx.Done():
-			return false
-		}
-	}
-
-	releaseSlot := func() {
-		if sem == nil {
-			return
-		}
-		select {
-		case <-sem:
-		default:
-		}
+	mode := scheduleModeFn()
+	var history durationHistory
+	if mode == config.ScheduleCriticalPath {
+		history = loadDurationHistory()
 	}
+	schedule := planSchedule(layers, mode, history)
+	limiter := newScopedLimiter(layers)
+	gate := newSlotGate(workerLimit, limiter)
 
 	var activeWorkers int64
 
-	for _, layer := range layers {
+	for layerIdx, layer := range layers {
 		var wg sync.WaitGroup
 		executed := 0
+		layerCtx, layerSpan := tracing.Start(ctx, "codeagent.layer", tracing.Int("codeagent.layer.index", layerIdx), tracing.Int("codeagent.layer.tasks", len(layer)))
 
-		for _, task := range layer {
+		for _, task := range schedule.sortLayer(layer) {
 			if skip, reason := shouldSkipTask(task, failed); skip {
+				layerSpan.AddEvent("skipped", tracing.String("codeagent.task.id", task.ID), tracing.String("reason", reason))
+				metrics.RecordTask(metrics.TaskOutcome{Backend: task.Backend, Agent: task.Agent, Skipped: true})
 				res := TaskResult{TaskID: task.ID, ExitCode: 1, Error: reason}
 				results = append(results, res)
 				failed[task.ID] = res
@@ -559,6 +628,7 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 
 			if ctx.Err() != nil {
 				res := cancelledTaskResult(task.ID, ctx)
+				metrics.RecordTask(metrics.TaskOutcome{Backend: task.Backend, Agent: task.Agent, ExitCode: res.ExitCode})
 				results = append(results, res)
 				failed[task.ID] = res
 				continue
@@ -566,6 +636,8 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 
 			executed++
 			wg.Add(1)
+			queuedAt := time.Now()
+			waiter := gate.enqueue(task, schedule.rankOf(task.ID))
 			go func(ts TaskSpec) {
 				defer wg.Done()
 				var taskLogPath string
@@ -576,14 +648,34 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 					}
 				}()
 
-				if !acquireSlot() {
-					resultsCh <- cancelledTaskResult(ts.ID, ctx)
+				_, queueSpan := tracing.Start(layerCtx, "codeagent.task.queue", tracing.String("codeagent.task.id", ts.ID))
+				releaseSlot, ok := gate.wait(ctx, waiter)
+				if !ok {
+					queueSpan.SetError("cancelled while waiting for a worker slot")
+					queueSpan.End()
+					res := cancelledTaskResult(ts.ID, ctx)
+					metrics.RecordTask(metrics.TaskOutcome{Backend: ts.Backend, Agent: ts.Agent, ExitCode: res.ExitCode})
+					resultsCh <- res
 					return
 				}
+				releaseSlot = sync.OnceFunc(releaseSlot)
 				defer releaseSlot()
 
+				if !limiter.pace(ctx, ts) {
+					queueSpan.SetError("cancelled while pacing")
+					queueSpan.End()
+					res := cancelledTaskResult(ts.ID, ctx)
+					metrics.RecordTask(metrics.TaskOutcome{Backend: ts.Backend, Agent: ts.Agent, ExitCode: res.ExitCode})
+					resultsCh <- res
+					return
+				}
+				queueWait := time.Since(queuedAt)
+				queueSpan.End()
+				metrics.RecordQueueWait(ts.Backend, ts.Agent, queueWait)
+
 				current := atomic.AddInt64(&activeWorkers, 1)
-				logConcurrencyState("start", ts.ID, int(current), workerLimit)
+				details := append([]string{"wait=" + queueWait.Round(time.Millisecond).String()}, schedule.criticalDetail(ts.ID)...)
+				logConcurrencyState("start", ts.ID, int(current), workerLimit, details...)
 				defer func() {
 					after := atomic.AddInt64(&activeWorkers, -1)
 					logConcurrencyState("done", ts.ID, int(after), workerLimit)
@@ -595,15 +687,37 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 					defer handle.closeFn()
 				}
 
-				taskCtx := ctx
+				taskCtx := layerCtx
 				if handle.logger != nil {
-					taskCtx = withTaskLogger(ctx, handle.logger)
+					taskCtx = withTaskLogger(layerCtx, handle.logger)
 				}
 				ts.Context = taskCtx
 
 				printTaskStart(ts.ID, taskLogPath, handle.shared)
 
-				res := runTask(ts, timeout)
+				startedAt := time.Now()
+				res := RunTaskHooks(ts, func(ts TaskSpec) TaskResult {
+					if len(ts.BestOf) > 0 {
+						// Candidates and the judge queue for their own slots;
+						// holding this one as well could starve them.
+						releaseSlot()
+						rank := schedule.rankOf(ts.ID)
+						return runBestOf(ts, timeout, func(spec TaskSpec) (func(), bool) {
+							release, ok := gate.wait(ctx, gate.enqueue(spec, rank))
+							if !ok {
+								return nil, false
+							}
+							if !limiter.pace(ctx, spec) {
+								release()
+								return nil, false
+							}
+							return release, true
+						}, runTask)
+					}
+					return runTask(ts, timeout)
+				})
+				res.elapsed = time.Since(startedAt)
+				res.QueueWaitMs = queueWait.Milliseconds()
 				if taskLogPath != "" {
 					if res.LogPath == "" || (handle.shared && handle.logger != nil && res.LogPath == handle.logger.Path()) {
 						res.LogPath = taskLogPath
@@ -619,18 +733,52 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 
 		wg.Wait()
 
+		layerFailed := 0
 		for i := 0; i < executed; i++ {
 			res := <-resultsCh
 			results = append(results, res)
 			if res.ExitCode != 0 || res.Error != "" {
 				failed[res.TaskID] = res
+				layerFailed++
 			}
 		}
+		layerSpan.SetAttributes(tracing.Int("codeagent.layer.executed", executed), tracing.Int("codeagent.layer.failed", layerFailed))
+		layerSpan.End()
 	}
 
+	schedule.annotate(results)
+	if mode == config.ScheduleCriticalPath {
+		recordDurations(layers, results, history)
+	}
 	return results
 }
 
+// recordDurations folds successful task durations into the history used to
+// estimate critical paths on later runs.
+func recordDurations(layers [][]TaskSpec, results []TaskResult, history durationHistory) {
+	specs := make(map[string]TaskSpec)
+	for _, layer := range layers {
+		for _, task := range layer {
+			specs[task.ID] = task
+		}
+	}
+	recorded := 0
+	for _, res := range results {
+		spec, ok := specs[res.TaskID]
+		if !ok || res.ExitCode != 0 || res.Error != "" || res.elapsed <= 0 {
+			continue
+		}
+		history.record(spec, res.elapsed)
+		recorded++
+	}
+	if recorded == 0 {
+		return
+	}
+	if err := saveDurationHistory(history); err != nil {
+		logWarn(fmt.Sprintf("parallel: failed to save duration history: %v", err))
+	}
+}
+
 func cancelledTaskResult(taskID string, ctx context.Context) TaskResult {
 	exitCode := 130
 	msg := "execution cancelled"
@@ -739,6 +887,9 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if coverage != "" {
 					sb.WriteString(fmt.Sprintf(" %s", coverage))
 				}
+				if res.Cached {
+					sb.WriteString(" (cached)")
+				}
 				sb.WriteString("\n")
 
 				if keyOutput != "" {
@@ -750,9 +901,15 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if res.TestsPassed > 0 {
 					sb.WriteString(fmt.Sprintf("Tests: %d passed\n", res.TestsPassed))
 				}
+				writeSchedulingInfo(&sb, res)
+				writeStragglers(&sb, res)
+				writeAnnotations(&sb, res)
 				if logPath != "" {
 					sb.WriteString(fmt.Sprintf("Log: %s\n", logPath))
 				}
+				if res.TranscriptPath != "" {
+					sb.WriteString(fmt.Sprintf("Transcript: %s\n", sanitizeOutput(res.TranscriptPath)))
+				}
 
 			} else if isSuccess && isBelowTarget {
 				// Below target: add Gap info
@@ -772,9 +929,15 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if gap != "" {
 					sb.WriteString(fmt.Sprintf("Gap: %s\n", gap))
 				}
+				writeSchedulingInfo(&sb, res)
+				writeStragglers(&sb, res)
+				writeAnnotations(&sb, res)
 				if logPath != "" {
 					sb.WriteString(fmt.Sprintf("Log: %s\n", logPath))
 				}
+				if res.TranscriptPath != "" {
+					sb.WriteString(fmt.Sprintf("Transcript: %s\n", sanitizeOutput(res.TranscriptPath)))
+				}
 
 			} else {
 				// Failed task: show error detail
@@ -788,9 +951,15 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if detail != "" {
 					sb.WriteString(fmt.Sprintf("Detail: %s\n", detail))
 				}
+				writeSchedulingInfo(&sb, res)
+				writeStragglers(&sb, res)
+				writeAnnotations(&sb, res)
 				if logPath != "" {
 					sb.WriteString(fmt.Sprintf("Log: %s\n", logPath))
 				}
+				if res.TranscriptPath != "" {
+					sb.WriteString(fmt.Sprintf("Transcript: %s\n", sanitizeOutput(res.TranscriptPath)))
+				}
 			}
 		}
 
@@ -841,6 +1010,8 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				sb.WriteString(fmt.Sprintf("Status: FAILED (exit code %d)\nError: %s\n", res.ExitCode, sanitizeOutput(res.Error)))
 			} else if res.ExitCode != 0 {
 				sb.WriteString(fmt.Sprintf("Status: FAILED (exit code %d)\n", res.ExitCode))
+			} else if res.Cached {
+				sb.WriteString("Status: SUCCESS (cached)\n")
 			} else {
 				sb.WriteString("Status: SUCCESS\n")
 			}
@@ -850,6 +1021,14 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 			if res.SessionID != "" {
 				sb.WriteString(fmt.Sprintf("Session: %s\n", sanitizeOutput(res.SessionID)))
 			}
+			if res.HandoffFrom != "" {
+				sb.WriteString(fmt.Sprintf("Handoff from: %s (%s)\n", sanitizeOutput(res.HandoffFrom), res.HandoffBackend))
+			}
+			if res.TranscriptPath != "" {
+				sb.WriteString(fmt.Sprintf("Transcript: %s\n", sanitizeOutput(res.TranscriptPath)))
+			}
+			writeStragglers(&sb, res)
+			writeAnnotations(&sb, res)
 			if res.LogPath != "" {
 				logPath := sanitizeOutput(res.LogPath)
 				if res.sharedLog {
@@ -871,6 +1050,18 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 	return sb.String()
 }
 
+// writeSchedulingInfo reports how long a task waited for worker, backend and
+// agent slots before it started, and where it sits on the critical path.
+func writeSchedulingInfo(sb *strings.Builder, res TaskResult) {
+	if res.QueueWaitMs > 0 {
+		wait := time.Duration(res.QueueWaitMs) * time.Millisecond
+		sb.WriteString(fmt.Sprintf("Queued: %s\n", wait))
+	}
+	if res.CriticalPathPos > 0 {
+		sb.WriteString(fmt.Sprintf("Critical path: %d/%d\n", res.CriticalPathPos, res.CriticalPathLen))
+	}
+}
+
 func buildCodexArgs(cfg *Config, targetArg string) []string {
 	if cfg == nil {
 		panic("buildCodexArgs: nil config")
@@ -979,11 +1170,39 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		cfg.WorkDir = defaultWorkdir
 	}
 
+	cacheKey := ""
+	if taskSpec.Cache && !useCustomArgs {
+		cacheInfo, cacheWarn := taskLogFns(taskSpec)
+		key, err := taskCacheKey(taskSpec, cfg)
+		if err != nil {
+			cacheInfo("Result cache not used: " + err.Error())
+		} else if cached, createdAt, ok := lookupCachedResult(key, taskSpec.ID); ok {
+			cacheInfo(fmt.Sprintf("Result cache hit (stored %s); %s was not run", createdAt.Local().Format(time.RFC3339), cfg.Backend))
+			return cached
+		} else {
+			cacheKey = key
+			defer func() {
+				if err := storeCachedResult(cacheKey, result); err != nil {
+					cacheWarn("Failed to cache the result: " + err.Error())
+				}
+			}()
+		}
+	}
+
+	taskStarted := time.Now()
+	parentCtx, taskSpan := tracing.Start(parentCtx, "codeagent.task", tracing.String("codeagent.task.id", taskSpec.ID))
+	defer func() {
+		endTaskSpan(taskSpan, taskSpec, cfg, result)
+		metrics.RecordTask(metrics.TaskOutcome{Backend: cfg.Backend, Agent: taskSpec.Agent, ExitCode: result.ExitCode, Duration: time.Since(taskStarted)})
+	}()
+
 	// Handle worktree mode: check DO_WORKTREE_DIR env var first, then create if needed
-	if worktreeDir := os.Getenv("DO_WORKTREE_DIR"); worktreeDir != "" {
+	baseWorkDir, worktreeDir := cfg.WorkDir, ""
+	if dir := os.Getenv("DO_WORKTREE_DIR"); dir != "" {
 		// Use existing worktree from /do setup
-		cfg.WorkDir = worktreeDir
-		logInfo(fmt.Sprintf("Using existing worktree from DO_WORKTREE_DIR: %s", worktreeDir))
+		cfg.WorkDir = dir
+		worktreeDir = dir
+		logInfo(fmt.Sprintf("Using existing worktree from DO_WORKTREE_DIR: %s", dir))
 	} else if taskSpec.Worktree {
 		// Create new worktree (backward compatibility for standalone --worktree usage)
 		paths, err := createWorktreeFn(cfg.WorkDir)
@@ -993,8 +1212,31 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 			return result
 		}
 		cfg.WorkDir = paths.Dir
+		worktreeDir = paths.Dir
 		logInfo(fmt.Sprintf("Using worktree: %s (task_id: %s, branch: %s)", paths.Dir, paths.TaskID, paths.Branch))
 	}
+	defer func() {
+		if result.SessionID != "" {
+			recordTaskSession(taskSpec, cfg, baseWorkDir, worktreeDir, result)
+		}
+	}()
+
+	// A session owned by another backend cannot be resumed natively: start a
+	// new session with its transcript prepended instead.
+	if cfg.Mode == "resume" && taskSpec.HandoffBackend != "" && taskSpec.HandoffBackend != cfg.Backend {
+		task, err := buildHandoffTask(taskSpec.HandoffBackend, strings.TrimSpace(cfg.SessionID), taskSpec.Task, 0)
+		if err != nil {
+			result.ExitCode = 1
+			result.Error = fmt.Sprintf("failed to hand off %s session %s: %v", taskSpec.HandoffBackend, cfg.SessionID, err)
+			return result
+		}
+		result.HandoffFrom = strings.TrimSpace(cfg.SessionID)
+		result.HandoffBackend = taskSpec.HandoffBackend
+		logInfo(fmt.Sprintf("Starting a new %s session from %s session %s", cfg.Backend, taskSpec.HandoffBackend, result.HandoffFrom))
+		cfg.Mode, cfg.SessionID = "new", ""
+		cfg.Task, taskSpec.Task = task, task
+		taskSpec.UseStdin = true
+	}
 
 	if cfg.Mode == "resume" && strings.TrimSpace(cfg.SessionID) == "" {
 		result.ExitCode = 1
@@ -1002,24 +1244,7 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		return result
 	}
 
-	var fileEnv map[string]string
-	if cfg.Backend == "claude" {
-		settings := loadMinimalClaudeSettings()
-		fileEnv = settings.Env
-		if cfg.Mode != "resume" && strings.TrimSpace(cfg.Model) == "" && settings.Model != "" {
-			cfg.Model = settings.Model
-		}
-	}
-
-	// Load gemini env from ~/.gemini/.env if exists
-	if cfg.Backend == "gemini" {
-		fileEnv = loadGeminiEnv()
-		if cfg.Mode != "resume" && strings.TrimSpace(cfg.Model) == "" {
-			if model := fileEnv["GEMINI_MODEL"]; model != "" {
-				cfg.Model = model
-			}
-		}
-	}
+	fileEnv := resolveFileEnv(cfg)
 
 	useStdin := taskSpec.UseStdin
 	targetArg := taskSpec.Task
@@ -1114,11 +1339,106 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	var launch launcher.Spec
 	attachStderr := func(msg string) string {
+		if violation := sandboxViolation(stderrBuf.String(), launch.Sandbox); violation != "" {
+			msg += "; " + violation
+		}
 		return fmt.Sprintf("%s; stderr: %s", msg, stderrBuf.String())
 	}
 
-	cmd := newCommandRunner(ctx, commandName, codexArgs...)
+	var replay *cassette
+	if dir := strings.TrimSpace(taskSpec.ReplayDir); dir != "" {
+		c, path, err := loadCassette(dir, taskSpec.ID, taskSpec.Task)
+		if err != nil {
+			logErrorFn(err.Error())
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result
+		}
+		if c.PromptHash != promptHash(taskSpec.Task) {
+			logWarnFn(fmt.Sprintf("Prompt differs from the one recorded in %s", path))
+		}
+		replay = c
+		logInfoFn("Replaying " + path)
+	}
+
+	runName, runArgs := commandName, codexArgs
+	var sandboxTmp string
+	if taskSpec.Sandbox != nil && replay == nil {
+		// A private temp directory, so the backend cannot write the files
+		// other processes keep in the shared one.
+		dir, err := os.MkdirTemp("", "codeagent-sandbox-")
+		if err != nil {
+			msg := "Failed to create the sandbox temp directory: " + err.Error()
+			logErrorFn(msg)
+			result.ExitCode = 1
+			result.Error = msg
+			return result
+		}
+		defer os.RemoveAll(dir) //nolint:errcheck
+		sandboxTmp = dir
+		policy := sandboxPolicy(*taskSpec.Sandbox, cfg, sandboxTmp)
+		launch.Sandbox = &policy
+		logInfoFn("Sandbox: writable " + strings.Join(policy.Write, ", "))
+	}
+	var guard *resources.Guard
+	if replay == nil {
+		guard = newResourceGuardFn(taskResourceLimits(taskSpec), taskSpec.ID)
+	}
+	if guard != nil {
+		defer func() {
+			if err := guard.Close(); err != nil {
+				logWarnFn(err.Error())
+			}
+		}()
+		launch.Cgroup, launch.Rlimits = guard.Cgroup, guard.Rlimits
+		logInfoFn("Resource limits: " + guard.Describe())
+		if guard.Fallback != "" {
+			logWarnFn("Resource limits: " + guard.Fallback)
+		}
+	}
+	var launchEnv map[string]string
+	if !launch.IsZero() {
+		name, args, env, err := launchCommandFn(launch, commandName, codexArgs)
+		if err != nil {
+			logErrorFn(err.Error())
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result
+		}
+		runName, runArgs, launchEnv = name, args, env
+	}
+
+	var cmd commandRunner
+	if replay != nil {
+		cmd = newReplayCmd(ctx, replay)
+	} else {
+		cmd = newCommandRunner(ctx, runName, runArgs...)
+	}
+	var recorder *cassetteRecorder
+	if dir := strings.TrimSpace(taskSpec.RecordDir); dir != "" {
+		stdinData := ""
+		if useStdin {
+			stdinData = taskSpec.Task
+		}
+		recorder = newCassetteRecorder(taskSpec, cfg.Backend, commandName, codexArgs, stdinData)
+		defer func() {
+			path, err := recorder.Save(dir)
+			if err != nil {
+				logWarnFn(fmt.Sprintf("Failed to write cassette to %s: %v", dir, err))
+			} else if path != "" {
+				logInfoFn("Cassette written to " + path)
+			}
+		}()
+	}
+	envPolicy := taskEnvPolicy(taskSpec)
+	if setter, ok := cmd.(envPolicySetter); ok && !envPolicy.IsZero() {
+		setter.SetEnvPolicy(envPolicy)
+	}
+	if len(launchEnv) > 0 {
+		cmd.SetEnv(launchEnv)
+	}
 
 	if len(fileEnv) > 0 {
 		cmd.SetEnv(fileEnv)
@@ -1132,16 +1452,7 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	}
 
 	if envBackend != nil {
-		baseURL, apiKey := config.ResolveBackendConfig(cfg.Backend)
-		if agentName := strings.TrimSpace(taskSpec.Agent); agentName != "" {
-			agentBackend, _, _, _, agentBaseURL, agentAPIKey, _, _, _, err := config.ResolveAgentConfig(agentName)
-			if err == nil {
-				if strings.EqualFold(strings.TrimSpace(agentBackend), strings.TrimSpace(cfg.Backend)) {
-					baseURL, apiKey = agentBaseURL, agentAPIKey
-				}
-			}
-		}
-		if injected := envBackend.Env(baseURL, apiKey); len(injected) > 0 {
+		if injected := resolveBackendEnv(envBackend, cfg.Backend, taskSpec.Agent); len(injected) > 0 {
 			cmd.SetEnv(injected)
 			// Log injected env vars with masked API keys (to file and stderr)
 			for k, v := range injected {
@@ -1153,12 +1464,19 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	}
 
 	injectTempEnv(cmd)
+	if sandboxTmp != "" {
+		cmd.SetEnv(map[string]string{"TMPDIR": sandboxTmp, "TMP": sandboxTmp, "TEMP": sandboxTmp})
+	}
+
+	if env := tracing.Environ(parentCtx); len(env) > 0 {
+		cmd.SetEnv(env)
+	}
 
 	if commandName == "claude" {
 		// Claude 2.1.45+ calls Nz7() on startup to clean its tasks directory,
 		// which deletes the parent session's *.output files and causes "(no output)".
 		// Assign each nested claude its own isolated tmpdir so it only cleans its own files.
-		nestedTmpDir, err := os.MkdirTemp("", fmt.Sprintf("cc-nested-%d-", os.Getpid()))
+		nestedTmpDir, err := os.MkdirTemp(sandboxTmp, fmt.Sprintf("cc-nested-%d-", os.Getpid()))
 		if err != nil {
 			logWarnFn("Failed to create isolated CLAUDE_CODE_TMPDIR: " + err.Error())
 		} else {
@@ -1174,6 +1492,12 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		cmd.UnsetEnv("CLAUDECODE")
 	}
 
+	// Agent and task variables go last so they override anything injected above.
+	cmd.SetEnv(envPolicy.Env)
+	if !envPolicy.IsZero() {
+		logFinalEnv(cmd, envPolicy.Env, logInfoFn)
+	}
+
 	// For backends that don't support -C flag (claude, gemini), set working directory via cmd.Dir
 	// Codex passes workdir via -C flag, so we skip setting Dir for it to avoid conflicts
 	if cfg.Mode != "resume" && commandName != "codex" && cfg.WorkDir != "" {
@@ -1184,6 +1508,9 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	if stderrLogger != nil {
 		stderrWriters = append(stderrWriters, stderrLogger)
 	}
+	if recorder != nil {
+		stderrWriters = append(stderrWriters, recorder.Stderr())
+	}
 
 	// For gemini backend, filter noisy stderr output
 	var stderrFilter *filteringWriter
@@ -1233,8 +1560,31 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	}
 
 	stdoutReader := io.Reader(stdout)
+	if recorder != nil {
+		stdoutReader = io.TeeReader(stdoutReader, recorder.Stdout())
+	}
 	if stdoutLogger != nil {
-		stdoutReader = io.TeeReader(stdout, stdoutLogger)
+		stdoutReader = io.TeeReader(stdoutReader, stdoutLogger)
+	}
+	if dir := strings.TrimSpace(taskSpec.TranscriptDir); dir != "" {
+		if transcript, err := openTranscript(dir, taskSpec, cfg); err != nil {
+			logWarnFn(fmt.Sprintf("Failed to create transcript in %s: %v", dir, err))
+		} else {
+			result.TranscriptPath = transcript.Path()
+			stdoutReader = io.TeeReader(stdoutReader, transcript)
+			defer func() {
+				if err := transcript.Close(result); err != nil {
+					logWarnFn(fmt.Sprintf("Failed to write transcript %s: %v", transcript.Path(), err))
+				}
+			}()
+		}
+	}
+
+	_, firstEventSpan := tracing.Start(parentCtx, "backend.first_event")
+	endFirstEvent := endSpanOnce(firstEventSpan)
+	defer endFirstEvent(tracing.Bool("codeagent.output_seen", false))
+	if firstEventSpan != nil {
+		stdoutReader = &firstReadReader{r: stdoutReader, onFirst: func() { endFirstEvent() }}
 	}
 
 	// Start parse goroutine BEFORE starting the command to avoid race condition
@@ -1263,7 +1613,15 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 
 	logInfoFn(fmt.Sprintf("Starting %s with args: %s %s...", commandName, commandName, strings.Join(codexArgs[:min(5, len(codexArgs))], " ")))
 
-	if err := cmd.Start(); err != nil {
+	_, startSpan := tracing.Start(parentCtx, "backend.start", tracing.String("process.command", commandName))
+	startErr := cmd.Start()
+	if startErr != nil {
+		startSpan.SetError(startErr.Error())
+	} else if proc := cmd.Process(); proc != nil {
+		startSpan.SetAttributes(tracing.Int("process.pid", proc.Pid()))
+	}
+	startSpan.End()
+	if err := startErr; err != nil {
 		closeWithReason(stdout, "start-failed")
 		closeWithReason(stderr, "start-failed")
 		if stdinPipe != nil {
@@ -1282,6 +1640,9 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		return result
 	}
 
+	if recorder != nil {
+		recorder.Started()
+	}
 	logInfoFn(fmt.Sprintf("Starting %s with PID: %d", commandName, cmd.Process().Pid()))
 	if logger != nil {
 		logInfoFn(fmt.Sprintf("Log capturing to: %s", logger.Path()))
@@ -1308,9 +1669,15 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	waitCh := make(chan error, 1)
 	go func() { waitCh <- cmd.Wait() }()
 
+	_, completionSpan := tracing.Start(parentCtx, "backend.completion")
+	endCompletion := endSpanOnce(completionSpan)
+	defer endCompletion(tracing.String("codeagent.completion", "none"))
+	var terminateSpan *tracing.Span
+
 	var (
 		waitErr              error
 		forceKillTimer       *forceKillTimer
+		killed               bool
 		ctxCancelled         bool
 		messageTimer         *time.Timer
 		messageTimerCh       <-chan time.Time
@@ -1325,9 +1692,13 @@ waitLoop:
 		select {
 		case err := <-waitCh:
 			waitErr = err
+			endCompletion(tracing.String("codeagent.completion", "exit"))
 			break waitLoop
 		case <-ctx.Done():
 			ctxCancelled = true
+			endCompletion(tracing.String("codeagent.completion", "cancelled"))
+			_, terminateSpan = tracing.Start(parentCtx, "backend.terminate", tracing.String("codeagent.termination", terminationReason(ctx)))
+			metrics.RecordTermination(cfg.Backend, terminationReason(ctx))
 			logErrorFn(cancelReason(commandName, ctx))
 			if !terminated {
 				if timer := terminateCommandFn(cmd); timer != nil {
@@ -1342,13 +1713,17 @@ waitLoop:
 					break waitLoop
 				case <-time.After(forceKillWaitTimeout):
 					if proc := cmd.Process(); proc != nil {
+						terminateSpan.AddEvent("kill")
 						_ = proc.Kill()
+						killed = true
 					}
 				}
 			}
 		case <-messageTimerCh:
 			forcedAfterComplete = true
 			messageTimerCh = nil
+			_, terminateSpan = tracing.Start(parentCtx, "backend.terminate", tracing.String("codeagent.termination", "lingering"))
+			metrics.RecordTermination(cfg.Backend, "lingering")
 			if !terminated {
 				logWarnFn(fmt.Sprintf("%s output parsed; terminating lingering backend", commandName))
 				if timer := terminateCommandFn(cmd); timer != nil {
@@ -1366,12 +1741,15 @@ waitLoop:
 					break waitLoop
 				case <-time.After(forceKillWaitTimeout):
 					if proc := cmd.Process(); proc != nil {
+						terminateSpan.AddEvent("kill")
 						_ = proc.Kill()
+						killed = true
 					}
 				}
 			}
 		case <-completeSeen:
 			completeSeenObserved = true
+			endCompletion(tracing.String("codeagent.completion", "signal"))
 			if messageTimer != nil {
 				continue
 			}
@@ -1382,6 +1760,10 @@ waitLoop:
 		}
 	}
 
+	if recorder != nil {
+		recorder.SetExit(waitErr)
+	}
+
 	if messageTimer != nil {
 		if !messageTimer.Stop() {
 			select {
@@ -1393,8 +1775,21 @@ waitLoop:
 
 	if forceKillTimer != nil {
 		forceKillTimer.Stop()
+		killed = killed || forceKillTimer.drained.Load()
+	}
+	if killed {
+		metrics.RecordKill(cfg.Backend)
+	}
+	if terminated || killed {
+		if stragglers := killStragglers(cmd); len(stragglers) > 0 {
+			result.Stragglers = stragglers
+			terminateSpan.AddEvent("stragglers")
+			logWarnFn(fmt.Sprintf("%s left %d process(es) behind; killed %s", commandName, len(stragglers), strings.Join(stragglers, ", ")))
+		}
 	}
+	terminateSpan.End()
 
+	_, drainSpan := tracing.Start(parentCtx, "backend.drain")
 	var parsed parseResult
 	switch {
 	case ctxCancelled:
@@ -1417,6 +1812,7 @@ waitLoop:
 			closeWithReason(stdout, stdoutCloseReasonWait)
 			parsed = <-parseCh
 		case <-drainTimer.C:
+			drainSpan.AddEvent(stdoutCloseReasonDrain)
 			closeWithReason(stdout, stdoutCloseReasonDrain)
 			parsed = <-parseCh
 		}
@@ -1427,6 +1823,7 @@ waitLoop:
 	// Important: cmd.Wait can block on internal stderr copying if cmd.Stderr is a non-file writer.
 	// We use StderrPipe and drain ourselves to avoid that deadlock class (common when children inherit pipes).
 	<-stderrDone
+	drainSpan.End()
 
 	if ctxErr := ctx.Err(); ctxErr != nil {
 		if errors.Is(ctxErr, context.DeadlineExceeded) {
@@ -1443,11 +1840,14 @@ waitLoop:
 		if forcedAfterComplete && parsed.message != "" {
 			logWarnFn(fmt.Sprintf("%s terminated after delivering output", commandName))
 		} else {
-			if exitErr, ok := waitErr.(*exec.ExitError); ok {
-				code := exitErr.ExitCode()
-				logErrorFn(fmt.Sprintf("%s exited with status %d", commandName, code))
+			if code, state, ok := exitStatus(waitErr); ok {
+				msg := fmt.Sprintf("%s exited with status %d", commandName, code)
+				if breach := guard.Breach(state, stderrBuf.String()); breach != "" {
+					msg = fmt.Sprintf("%s (%s)", breach, msg)
+				}
+				logErrorFn(msg)
 				result.ExitCode = code
-				result.Error = attachStderr(fmt.Sprintf("%s exited with status %d", commandName, code))
+				result.Error = attachStderr(msg)
 				// Preserve parsed output when the backend exits non-zero (e.g. API error with stream-json output).
 				result.Message = parsed.message
 				result.SessionID = parsed.threadID
@@ -1469,9 +1869,13 @@ waitLoop:
 	message := parsed.message
 	threadID := parsed.threadID
 	if message == "" {
-		logErrorFn(fmt.Sprintf("%s completed without agent_message output", commandName))
+		msg := fmt.Sprintf("%s completed without agent_message output", commandName)
+		if breach := guard.Breach(nil, stderrBuf.String()); breach != "" {
+			msg = fmt.Sprintf("%s (%s)", breach, msg)
+		}
+		logErrorFn(msg)
 		result.ExitCode = 1
-		result.Error = attachStderr(fmt.Sprintf("%s completed without agent_message output", commandName))
+		result.Error = attachStderr(msg)
 		return result
 	}
 
@@ -1481,6 +1885,9 @@ waitLoop:
 	if stderrLogger != nil {
 		stderrLogger.Flush()
 	}
+	if violation := sandboxViolation(stderrBuf.String(), launch.Sandbox); violation != "" {
+		logWarnFn(violation)
+	}
 
 	result.ExitCode = 0
 	result.Message = message
@@ -1492,6 +1899,76 @@ waitLoop:
 	return result
 }
 
+// recordTaskSession adds the session to the ~/.codeagent/sessions registry so
+// a later `resume` can reuse its backend, model, agent and workdir. Failures
+// only log a warning.
+func recordTaskSession(taskSpec TaskSpec, cfg *Config, workDir, worktreeDir string, result TaskResult) {
+	entry := session.Entry{
+		SessionID:      result.SessionID,
+		Backend:        cfg.Backend,
+		Model:          cfg.Model,
+		Agent:          taskSpec.Agent,
+		WorkDir:        absPathOr(workDir),
+		TaskID:         taskSpec.ID,
+		HandoffFrom:    result.HandoffFrom,
+		HandoffBackend: result.HandoffBackend,
+	}
+	if worktreeDir != "" {
+		entry.Worktree = absPathOr(worktreeDir)
+	}
+	if err := recordSessionFn(entry); err != nil {
+		logWarn(fmt.Sprintf("Failed to record session %s: %v", result.SessionID, err))
+	}
+}
+
+func absPathOr(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// resolveFileEnv loads env vars from the backend's own settings files
+// (~/.claude/settings.json, ~/.gemini/.env) and fills cfg.Model from them
+// when no model was chosen.
+func resolveFileEnv(cfg *Config) map[string]string {
+	var fileEnv map[string]string
+	if cfg.Backend == "claude" {
+		settings := loadMinimalClaudeSettings()
+		fileEnv = settings.Env
+		if cfg.Mode != "resume" && strings.TrimSpace(cfg.Model) == "" && settings.Model != "" {
+			cfg.Model = settings.Model
+		}
+	}
+
+	// Load gemini env from ~/.gemini/.env if exists
+	if cfg.Backend == "gemini" {
+		fileEnv = loadGeminiEnv()
+		if cfg.Mode != "resume" && strings.TrimSpace(cfg.Model) == "" {
+			if model := fileEnv["GEMINI_MODEL"]; model != "" {
+				cfg.Model = model
+			}
+		}
+	}
+	return fileEnv
+}
+
+// resolveBackendEnv returns the env vars a backend injects for its base URL
+// and API key. An agent's backend settings win when the agent targets the
+// same backend.
+func resolveBackendEnv(b Backend, backendName, agentName string) map[string]string {
+	baseURL, apiKey := config.ResolveBackendConfig(backendName)
+	if agentName = strings.TrimSpace(agentName); agentName != "" {
+		agentBackend, _, _, _, agentBaseURL, agentAPIKey, _, _, _, err := config.ResolveAgentConfig(agentName)
+		if err == nil {
+			if strings.EqualFold(strings.TrimSpace(agentBackend), strings.TrimSpace(backendName)) {
+				baseURL, apiKey = agentBaseURL, agentAPIKey
+			}
+		}
+	}
+	return b.Env(baseURL, apiKey)
+}
+
 func injectTempEnv(cmd commandRunner) {
 	if cmd == nil {
 		return
@@ -1594,3 +2071,9 @@ func maskSensitiveValue(key, value string) string {
 	}
 	return value
 }
+
+// MaskSensitiveValue masks key, token and secret values for display outside
+// the executor (e.g. `agents show`).
+func MaskSensitiveValue(key, value string) string {
+	return maskSensitiveValue(key, value)
+}
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	backend "codeagent-wrapper/internal/backend"
+	config "codeagent-wrapper/internal/config"
+	session "codeagent-wrapper/internal/session"
+)
+
+// defaultHandoffTokenBudget caps a replayed transcript, in estimated tokens,
+// unless CODEAGENT_HANDOFF_TOKENS overrides it.
+const defaultHandoffTokenBudget = 8000
+
+// handoffExcerptRunes is the length of a condensed middle turn.
+const handoffExcerptRunes = 160
+
+// Hook points (tests can override inside this package).
+var (
+	loadTranscriptFn = loadBackendTranscript
+	locateSessionFn  = backend.LocateSession
+)
+
+func loadBackendTranscript(backendName, sessionID string) ([]backend.Turn, error) {
+	b, err := selectBackendFn(backendName)
+	if err != nil {
+		return nil, err
+	}
+	loader, ok := b.(backend.TranscriptLoader)
+	if !ok {
+		return nil, fmt.Errorf("backend %s cannot read its session transcripts", b.Name())
+	}
+	return loader.LoadTranscript(sessionID)
+}
+
+// HandoffSource returns the backend whose transcript a resume of sessionID
+// on target has to replay, or "" when target can resume the session itself.
+// The registry entry decides when there is one; otherwise the backends'
+// local session files are searched.
+func HandoffSource(sessionID, target string, rec *session.Entry) string {
+	if rec != nil && rec.Backend != "" {
+		if rec.Backend != target {
+			return rec.Backend
+		}
+		return ""
+	}
+	owners := locateSessionFn(sessionID)
+	for _, owner := range owners {
+		if owner == target {
+			return ""
+		}
+	}
+	if len(owners) == 0 {
+		return ""
+	}
+	return owners[0]
+}
+
+// buildHandoffTask prepends the transcript of sessionID on source to task.
+// Transcripts over maxTokens (estimated) keep the first and the most recent
+// turns and condense the rest to one-line excerpts.
+func buildHandoffTask(source, sessionID, task string, maxTokens int) (string, error) {
+	turns, err := loadTranscriptFn(source, sessionID)
+	if err != nil {
+		return "", err
+	}
+	if len(turns) == 0 {
+		return "", fmt.Errorf("session %s has no messages to replay", sessionID)
+	}
+	if maxTokens <= 0 {
+		maxTokens = config.ResolveHandoffTokenBudget()
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultHandoffTokenBudget
+	}
+
+	body, condensed := condenseTranscript(turns, maxTokens)
+	note := "The transcript of that session follows."
+	attrs := fmt.Sprintf("backend=%q session_id=%q turns=\"%d\"", source, sessionID, len(turns))
+	if condensed {
+		note = "The transcript of that session follows; turns in the middle are condensed."
+		attrs += ` condensed="true"`
+	}
+	logInfo(fmt.Sprintf("Handing off %s session %s: %d turns, ~%d tokens (condensed=%v)", source, sessionID, len(turns), estimateTokens(body), condensed))
+	return fmt.Sprintf("<previous-session %s>\nThis task continues a conversation from a %s session. %s\n\n%s\n</previous-session>\n\n%s",
+		attrs, source, note, body, task), nil
+}
+
+func renderTurn(t backend.Turn) string {
+	role := "User"
+	if t.Role == "assistant" {
+		role = "Assistant"
+	}
+	return "### " + role + "\n" + t.Text
+}
+
+// condenseTranscript renders turns within maxTokens. When everything fits
+// it is returned as is; otherwise the first turn (the original request) and
+// as many recent turns as fit are kept, and the turns between them become
+// excerpts or an omission marker.
+func condenseTranscript(turns []backend.Turn, maxTokens int) (string, bool) {
+	rendered := make([]string, len(turns))
+	total := 0
+	for i, t := range turns {
+		rendered[i] = renderTurn(t)
+		total += estimateTokens(rendered[i]) + 1
+	}
+	if total <= maxTokens {
+		return strings.Join(rendered, "\n\n"), false
+	}
+
+	remaining := maxTokens
+	head := clipToTokens(rendered[0], maxTokens/4, false)
+	remaining -= estimateTokens(head) + 1
+
+	// Recent turns get two thirds of what is left; the newest one is cut
+	// from the front if it alone is too long.
+	recentBudget := remaining * 2 / 3
+	var tail []string
+	first := len(turns)
+	for i := len(turns) - 1; i > 0; i-- {
+		cost := estimateTokens(rendered[i]) + 1
+		if cost > recentBudget {
+			if len(tail) == 0 {
+				tail = append(tail, clipToTokens(rendered[i], recentBudget-1, true))
+				first = i
+			}
+			break
+		}
+		tail = append([]string{rendered[i]}, tail...)
+		recentBudget -= cost
+		first = i
+	}
+	for _, r := range tail {
+		remaining -= estimateTokens(r) + 1
+	}
+
+	var middle []string
+	omitted := 0
+	for i := 1; i < first; i++ {
+		line := "- " + strings.TrimPrefix(strings.SplitN(rendered[i], "\n", 2)[0], "### ") + ": " + excerpt(turns[i].Text, handoffExcerptRunes)
+		// Leave room for the omission marker.
+		if cost := estimateTokens(line) + 1; omitted == 0 && cost <= remaining-16 {
+			middle = append(middle, line)
+			remaining -= cost
+			continue
+		}
+		omitted++
+	}
+	if omitted > 0 {
+		middle = append(middle, fmt.Sprintf("- [%d more turns omitted]", omitted))
+	}
+
+	parts := []string{head}
+	if len(middle) > 0 {
+		parts = append(parts, "### Condensed earlier turns\n"+strings.Join(middle, "\n"))
+	}
+	parts = append(parts, tail...)
+	return strings.Join(parts, "\n\n"), true
+}
+
+// excerpt returns the first n runes of s on one line.
+func excerpt(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// clipToTokens shortens s to about maxTokens, keeping its start, or its end
+// when keepEnd is set.
+func clipToTokens(s string, maxTokens int, keepEnd bool) string {
+	if estimateTokens(s) <= maxTokens {
+		return s
+	}
+	runes := []rune(s)
+	n := len(runes)
+	for n > 0 {
+		var part string
+		if keepEnd {
+			part = "…" + string(runes[len(runes)-n:])
+		} else {
+			part = string(runes[:n]) + "…"
+		}
+		if estimateTokens(part) <= maxTokens {
+			return part
+		}
+		n -= n/8 + 1
+	}
+	return "…"
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	backend "codeagent-wrapper/internal/backend"
+	session "codeagent-wrapper/internal/session"
+)
+
+func setTranscript(t *testing.T, turns []backend.Turn, err error) {
+	t.Helper()
+	old := loadTranscriptFn
+	loadTranscriptFn = func(string, string) ([]backend.Turn, error) { return turns, err }
+	t.Cleanup(func() { loadTranscriptFn = old })
+}
+
+func TestHandoffSource(t *testing.T) {
+	old := locateSessionFn
+	defer func() { locateSessionFn = old }()
+	locateSessionFn = func(string) []string { return []string{"claude", "gemini"} }
+
+	tests := []struct {
+		name   string
+		target string
+		rec    *session.Entry
+		want   string
+	}{
+		{"recorded other backend", "claude", &session.Entry{Backend: "codex"}, "codex"},
+		{"recorded same backend", "codex", &session.Entry{Backend: "codex"}, ""},
+		{"located elsewhere", "codex", nil, "claude"},
+		{"located on target", "gemini", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HandoffSource("s1", tt.target, tt.rec); got != tt.want {
+				t.Fatalf("HandoffSource = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildHandoffTask_FullTranscript(t *testing.T) {
+	setTranscript(t, []backend.Turn{{Role: "user", Text: "fix the bug"}, {Role: "assistant", Text: "fixed it"}}, nil)
+
+	got, err := buildHandoffTask("codex", "s1", "now add tests", 0)
+	if err != nil {
+		t.Fatalf("buildHandoffTask: %v", err)
+	}
+	for _, want := range []string{
+		`<previous-session backend="codex" session_id="s1" turns="2">`,
+		"### User\nfix the bug\n\n### Assistant\nfixed it\n</previous-session>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("handoff task missing %q:\n%s", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "</previous-session>\n\nnow add tests") {
+		t.Fatalf("task not appended after transcript:\n%s", got)
+	}
+	if strings.Contains(got, "condensed") {
+		t.Fatalf("short transcript should not be condensed:\n%s", got)
+	}
+}
+
+func TestBuildHandoffTask_CondensesWithinBudget(t *testing.T) {
+	var turns []backend.Turn
+	turns = append(turns, backend.Turn{Role: "user", Text: "original request"})
+	for i := 0; i < 40; i++ {
+		turns = append(turns, backend.Turn{Role: "assistant", Text: fmt.Sprintf("step %d %s", i, strings.Repeat("detail ", 60))})
+	}
+	turns = append(turns, backend.Turn{Role: "user", Text: "latest question"})
+	setTranscript(t, turns, nil)
+
+	const budget = 600
+	got, err := buildHandoffTask("claude", "s1", "task", budget)
+	if err != nil {
+		t.Fatalf("buildHandoffTask: %v", err)
+	}
+	if !strings.Contains(got, `condensed="true"`) || !strings.Contains(got, "more turns omitted]") {
+		t.Fatalf("expected condensed transcript:\n%s", got)
+	}
+	if !strings.Contains(got, "original request") || !strings.Contains(got, "latest question") {
+		t.Fatalf("first and last turns must be kept:\n%s", got)
+	}
+	body := got[strings.Index(got, "### User"):strings.Index(got, "</previous-session>")]
+	if tokens := estimateTokens(body); tokens > budget {
+		t.Fatalf("condensed transcript is ~%d tokens, budget %d", tokens, budget)
+	}
+}
+
+func TestBuildHandoffTask_Errors(t *testing.T) {
+	setTranscript(t, nil, backend.ErrSessionNotFound)
+	if _, err := buildHandoffTask("codex", "s1", "task", 0); !errors.Is(err, backend.ErrSessionNotFound) {
+		t.Fatalf("err = %v, want ErrSessionNotFound", err)
+	}
+	setTranscript(t, nil, nil)
+	if _, err := buildHandoffTask("codex", "s1", "task", 0); err == nil {
+		t.Fatal("expected error for empty transcript")
+	}
+}
+
+func TestPlanTask_Handoff(t *testing.T) {
+	setTranscript(t, []backend.Turn{{Role: "user", Text: "hello"}}, nil)
+
+	plan, err := PlanTask(TaskSpec{Task: "next", Mode: "resume", SessionID: "s1", Backend: "claude", HandoffBackend: "codex"}, false)
+	if err != nil {
+		t.Fatalf("PlanTask: %v", err)
+	}
+	if plan.Mode != "new" || !plan.UseStdin {
+		t.Fatalf("plan mode %q stdin %v, want a new session fed on stdin", plan.Mode, plan.UseStdin)
+	}
+	for _, arg := range plan.Args {
+		if arg == "s1" || arg == "--resume" {
+			t.Fatalf("handoff plan still resumes natively: %v", plan.Args)
+		}
+	}
+	if !strings.Contains(strings.Join(plan.Notes, "\n"), "codex session s1 would be replayed into a new claude session") {
+		t.Fatalf("notes = %v", plan.Notes)
+	}
+}
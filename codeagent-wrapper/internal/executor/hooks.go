@@ -0,0 +1,296 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	config "codeagent-wrapper/internal/config"
+	tracing "codeagent-wrapper/internal/tracing"
+)
+
+// Hook kinds, as named under "hooks" in models.json and passed to hooks in
+// CODEAGENT_HOOK.
+const (
+	hookPreTask  = "pre_task"
+	hookPostTask = "post_task"
+	hookPostRun  = "post_run"
+)
+
+const (
+	// maxHookOutput caps the size of a hook's response.
+	maxHookOutput = 1 << 20
+	// hookStderrTailLen is how much of a failed hook's stderr goes into the
+	// error.
+	hookStderrTailLen = 500
+)
+
+var (
+	resolveHooksFn   = config.ResolveHooks
+	runHookCommandFn = runHookCommand
+)
+
+// hookResponse is what a hook may print on stdout. Empty output means "no
+// changes".
+type hookResponse struct {
+	Veto        bool              `json:"veto,omitempty"`        // pre_task: do not run the task
+	Reason      string            `json:"reason,omitempty"`      // why the task was vetoed
+	Prompt      *string           `json:"prompt,omitempty"`      // pre_task: replaces the task text
+	Annotations map[string]string `json:"annotations,omitempty"` // added to the task result
+}
+
+// hookTask is the pre_task payload: the task spec with only the names of
+// its Env, so the values, often credentials, do not reach the hooks.
+type hookTask struct {
+	TaskSpec
+	EnvKeys []string `json:"env_keys,omitempty"`
+}
+
+func newHookTask(task TaskSpec) hookTask {
+	payload := hookTask{TaskSpec: task}
+	payload.Env = nil
+	for k := range task.Env {
+		payload.EnvKeys = append(payload.EnvKeys, k)
+	}
+	sort.Strings(payload.EnvKeys)
+	return payload
+}
+
+// RunTaskHooks runs run between the configured pre_task and post_task hooks.
+// pre_task hooks get the task spec and may veto the task or rewrite its
+// prompt; post_task hooks get the result and may annotate it. A vetoed task
+// is not run and gets no post_task hooks.
+//
+// task.Task must be the task text as given, before any prompt file or skill
+// is injected: run injects them, so a rewritten prompt is wrapped the same
+// way as the original would have been.
+func RunTaskHooks(task TaskSpec, run func(TaskSpec) TaskResult) TaskResult {
+	hooks := resolveHooksFn()
+	if len(hooks.PreTask) == 0 && len(hooks.PostTask) == 0 {
+		return run(task)
+	}
+	logInfoFn, logWarnFn := taskLogFns(task)
+
+	var annotations map[string]string
+	for _, hook := range hooks.PreTask {
+		resp, err := runHook(task.Context, hookPreTask, hook, task.WorkDir, task.ID, newHookTask(task))
+		if err != nil {
+			msg := fmt.Sprintf("pre_task hook %q failed: %v", hook.Command, err)
+			logWarnFn(msg)
+			if hook.FailsOnError() {
+				return TaskResult{TaskID: task.ID, ExitCode: 1, Error: msg, Annotations: annotations}
+			}
+			continue
+		}
+		annotations = mergeAnnotations(annotations, resp.Annotations)
+		if resp.Veto {
+			reason := strings.TrimSpace(resp.Reason)
+			if reason == "" {
+				reason = "no reason given"
+			}
+			logWarnFn(fmt.Sprintf("Task vetoed by pre_task hook %q: %s", hook.Command, reason))
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: fmt.Sprintf("vetoed by pre_task hook %q: %s", hook.Command, reason), Annotations: annotations}
+		}
+		if resp.Prompt != nil {
+			task.Task = *resp.Prompt
+			task.UseStdin = task.UseStdin || ShouldUseStdin(task.Task, false)
+			logInfoFn(fmt.Sprintf("pre_task hook %q rewrote the prompt (%d bytes)", hook.Command, len(task.Task)))
+		}
+	}
+
+	res := run(task)
+	res.Annotations = mergeAnnotations(annotations, res.Annotations)
+
+	for _, hook := range hooks.PostTask {
+		resp, err := runHook(task.Context, hookPostTask, hook, task.WorkDir, task.ID, res)
+		if err != nil {
+			msg := fmt.Sprintf("post_task hook %q failed: %v", hook.Command, err)
+			logWarnFn(msg)
+			if !hook.FailsOnError() {
+				continue
+			}
+			if res.ExitCode == 0 {
+				res.ExitCode = 1
+			}
+			if res.Error == "" {
+				res.Error = msg
+			} else {
+				res.Error = msg + "; " + res.Error
+			}
+			continue
+		}
+		res.Annotations = mergeAnnotations(res.Annotations, resp.Annotations)
+	}
+	return res
+}
+
+// RunPostRunHooks passes payload, the run's results, to every post_run
+// hook. It returns the first error of a hook with on_failure "fail"; other
+// failures are logged.
+func RunPostRunHooks(payload any) error {
+	var failed error
+	for _, hook := range resolveHooksFn().PostRun {
+		if _, err := runHook(context.Background(), hookPostRun, hook, "", "", payload); err != nil {
+			err = fmt.Errorf("post_run hook %q failed: %w", hook.Command, err)
+			switch {
+			case !hook.FailsOnError():
+				logWarn(err.Error())
+			case failed == nil:
+				failed = err
+			}
+		}
+	}
+	return failed
+}
+
+// runHook runs one hook with payload as JSON on stdin and parses its
+// response.
+func runHook(ctx context.Context, kind string, hook config.HookConfig, dir, taskID string, payload any) (hookResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var resp hookResponse
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return resp, fmt.Errorf("encode payload: %w", err)
+	}
+
+	ctx, span := tracing.Start(ctx, "codeagent.hook", tracing.String("codeagent.hook.kind", kind), tracing.String("codeagent.hook.command", hook.Command))
+	defer span.End()
+
+	env := []string{"CODEAGENT_HOOK=" + kind}
+	if taskID != "" {
+		env = append(env, "CODEAGENT_TASK_ID="+taskID)
+	}
+	for k, v := range tracing.Environ(ctx) {
+		env = append(env, k+"="+v)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hook.TimeoutDuration())
+	defer cancel()
+	out, err := runHookCommandFn(ctx, hook.Command, dir, env, input)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", hook.TimeoutDuration())
+	}
+	if err == nil && len(out) > maxHookOutput {
+		err = fmt.Errorf("output exceeds %d bytes", maxHookOutput)
+	}
+	if err == nil {
+		if trimmed := bytes.TrimSpace(out); len(trimmed) > 0 {
+			if jerr := json.Unmarshal(trimmed, &resp); jerr != nil {
+				err = fmt.Errorf("invalid JSON output: %w", jerr)
+			}
+		}
+	}
+	if err != nil {
+		span.SetError(err.Error())
+		return hookResponse{}, err
+	}
+	return resp, nil
+}
+
+// runHookCommand runs command through the shell in its own process group,
+// so a timeout kills everything it started.
+func runHookCommand(ctx context.Context, command, dir string, env []string, stdin []byte) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	grouped := startInOwnGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if !grouped || signalGroup(cmd.Process.Pid, os.Kill) != nil {
+			_ = cmd.Process.Kill()
+		}
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			// Something still holds the output pipes open; give up on it.
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		if tail := stderrTail(stderr.String()); tail != "" {
+			return nil, fmt.Errorf("%w: %s", err, tail)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func stderrTail(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) > hookStderrTailLen {
+		stderr = "..." + stderr[len(stderr)-hookStderrTailLen:]
+	}
+	return strings.ReplaceAll(stderr, "\n", " | ")
+}
+
+// taskLogFns writes to the task's own log in parallel mode.
+func taskLogFns(task TaskSpec) (info, warn func(string)) {
+	prefix := ""
+	if task.ID != "" {
+		prefix = fmt.Sprintf("[Task: %s] ", task.ID)
+	}
+	if logger := taskLoggerFromContext(task.Context); logger != nil {
+		return func(msg string) { logger.Info(prefix + msg) }, func(msg string) { logger.Warn(prefix + msg) }
+	}
+	return func(msg string) { logInfo(prefix + msg) }, func(msg string) { logWarn(prefix + msg) }
+}
+
+func mergeAnnotations(base, over map[string]string) map[string]string {
+	if len(over) == 0 {
+		return base
+	}
+	out := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
+
+// writeAnnotations reports what hooks attached to the result.
+func writeAnnotations(sb *strings.Builder, res TaskResult) {
+	if len(res.Annotations) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(res.Annotations))
+	for k := range res.Annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+res.Annotations[k])
+	}
+	sb.WriteString(fmt.Sprintf("Notes: %s\n", sanitizeOutput(strings.Join(parts, ", "))))
+}
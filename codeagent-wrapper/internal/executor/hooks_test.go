@@ -0,0 +1,161 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+func setHooksForTest(t *testing.T, hooks config.HooksConfig) {
+	t.Helper()
+	prev := resolveHooksFn
+	resolveHooksFn = func() config.HooksConfig { return hooks }
+	t.Cleanup(func() { resolveHooksFn = prev })
+}
+
+func TestRunTaskHooks_RewriteAndAnnotate(t *testing.T) {
+	dir := t.TempDir()
+	setHooksForTest(t, config.HooksConfig{
+		PreTask: []config.HookConfig{
+			{Command: `cat > pre.json; printf '{"prompt":"rewritten","annotations":{"guard":"%s/%s"}}' "$CODEAGENT_HOOK" "$CODEAGENT_TASK_ID"`},
+		},
+		PostTask: []config.HookConfig{
+			{Command: `exit 3`},
+			{Command: `cat > post.json; echo '{"annotations":{"lint":"2 warnings"}}'`},
+		},
+	})
+
+	var ran TaskSpec
+	res := RunTaskHooks(TaskSpec{ID: "t1", Task: "original", WorkDir: dir}, func(task TaskSpec) TaskResult {
+		ran = task
+		return TaskResult{TaskID: task.ID, Message: "done"}
+	})
+	if ran.Task != "rewritten" {
+		t.Fatalf("task ran with %q, want the rewritten prompt", ran.Task)
+	}
+	if res.ExitCode != 0 || res.Error != "" {
+		t.Fatalf("a warn-only post_task failure must not fail the task: %+v", res)
+	}
+	want := map[string]string{"guard": "pre_task/t1", "lint": "2 warnings"}
+	if len(res.Annotations) != len(want) || res.Annotations["guard"] != want["guard"] || res.Annotations["lint"] != want["lint"] {
+		t.Fatalf("annotations = %v, want %v", res.Annotations, want)
+	}
+	if out := GenerateFinalOutputWithMode([]TaskResult{res}, true); !strings.Contains(out, "Notes: guard=pre_task/t1, lint=2 warnings") {
+		t.Fatalf("summary does not show annotations:\n%s", out)
+	}
+
+	var spec TaskSpec
+	if data, err := os.ReadFile(filepath.Join(dir, "pre.json")); err != nil || json.Unmarshal(data, &spec) != nil || spec.Task != "original" {
+		t.Fatalf("pre_task payload = %+v (%v)", spec, err)
+	}
+	var result TaskResult
+	if data, err := os.ReadFile(filepath.Join(dir, "post.json")); err != nil || json.Unmarshal(data, &result) != nil || result.Message != "done" || result.Annotations["guard"] == "" {
+		t.Fatalf("post_task payload = %+v (%v)", result, err)
+	}
+}
+
+func TestRunTaskHooks_Veto(t *testing.T) {
+	setHooksForTest(t, config.HooksConfig{
+		PreTask:  []config.HookConfig{{Command: `echo '{"veto":true,"reason":"touches vendor/"}'`}},
+		PostTask: []config.HookConfig{{Command: `echo '{"annotations":{"post":"ran"}}'`}},
+	})
+	res := RunTaskHooks(TaskSpec{ID: "t1", Task: "x"}, func(TaskSpec) TaskResult {
+		t.Fatal("vetoed task ran")
+		return TaskResult{}
+	})
+	if res.ExitCode != 1 || !strings.Contains(res.Error, "touches vendor/") || res.Annotations["post"] != "" {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestRunTaskHooks_FailurePolicy(t *testing.T) {
+	ran := false
+	run := func(task TaskSpec) TaskResult {
+		ran = true
+		return TaskResult{TaskID: task.ID, Message: "done"}
+	}
+
+	setHooksForTest(t, config.HooksConfig{PreTask: []config.HookConfig{{Command: `echo not json`}}})
+	if res := RunTaskHooks(TaskSpec{ID: "t1"}, run); !ran || res.ExitCode != 0 {
+		t.Fatalf("warn policy: ran=%v result=%+v", ran, res)
+	}
+
+	ran = false
+	setHooksForTest(t, config.HooksConfig{PreTask: []config.HookConfig{{Command: `sleep 5`, Timeout: "100ms", OnFailure: config.HookOnFailureFail}}})
+	started := time.Now()
+	res := RunTaskHooks(TaskSpec{ID: "t1"}, run)
+	if ran || res.ExitCode != 1 || !strings.Contains(res.Error, "timed out after 100ms") {
+		t.Fatalf("fail policy: ran=%v result=%+v", ran, res)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Fatalf("timed out hook took %s", elapsed)
+	}
+
+	setHooksForTest(t, config.HooksConfig{PostTask: []config.HookConfig{{Command: `echo lint failed >&2; exit 1`, OnFailure: config.HookOnFailureFail}}})
+	res = RunTaskHooks(TaskSpec{ID: "t1"}, run)
+	if res.ExitCode != 1 || !strings.Contains(res.Error, "lint failed") {
+		t.Fatalf("failing post_task hook: %+v", res)
+	}
+}
+
+func TestRunPostRunHooks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "run.json")
+	setHooksForTest(t, config.HooksConfig{PostRun: []config.HookConfig{
+		{Command: `cat > '` + out + `'`},
+		{Command: `exit 2`},
+	}})
+	payload := map[string]any{"results": []TaskResult{{TaskID: "a"}, {TaskID: "b", ExitCode: 1}}}
+	if err := RunPostRunHooks(payload); err != nil {
+		t.Fatalf("warn-only hooks returned %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || !strings.Contains(string(data), `"task_id":"b"`) {
+		t.Fatalf("post_run payload = %s (%v)", data, err)
+	}
+
+	setHooksForTest(t, config.HooksConfig{PostRun: []config.HookConfig{{Command: `exit 2`, OnFailure: config.HookOnFailureFail}}})
+	if err := RunPostRunHooks(payload); err == nil || !strings.Contains(err.Error(), "exit status 2") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRunTaskHooks_PayloadOmitsEnvValues(t *testing.T) {
+	dir := t.TempDir()
+	setHooksForTest(t, config.HooksConfig{
+		PreTask: []config.HookConfig{{Command: `cat > pre.json`}},
+	})
+
+	task := TaskSpec{ID: "t1", Task: "x", WorkDir: dir, Env: map[string]string{"API_TOKEN": "s3cret", "DEBUG": "1"}}
+	res := RunTaskHooks(task, func(task TaskSpec) TaskResult {
+		if task.Env["API_TOKEN"] != "s3cret" {
+			t.Errorf("task env = %v, want it unchanged", task.Env)
+		}
+		return TaskResult{TaskID: task.ID, Message: "done"}
+	})
+	if res.ExitCode != 0 {
+		t.Fatalf("res = %+v", res)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "pre.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || payload["env"] != nil {
+		t.Fatalf("pre_task payload leaks env values: %s", data)
+	}
+	if keys, _ := payload["env_keys"].([]any); len(keys) != 2 || keys[0] != "API_TOKEN" || keys[1] != "DEBUG" {
+		t.Fatalf("env_keys = %v, want [API_TOKEN DEBUG]", payload["env_keys"])
+	}
+}
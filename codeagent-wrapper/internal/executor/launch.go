@@ -0,0 +1,18 @@
+package executor
+
+import (
+	config "codeagent-wrapper/internal/config"
+	launcher "codeagent-wrapper/internal/launcher"
+	resources "codeagent-wrapper/internal/resources"
+)
+
+var (
+	launchCommandFn    = launcher.Wrap
+	newResourceGuardFn = resources.New
+)
+
+// taskResourceLimits combines the agent's limits from models.json with the
+// task's own, which win per limit.
+func taskResourceLimits(task TaskSpec) config.ResourceLimits {
+	return config.ResolveAgentResourceLimits(task.Agent).Merge(task.Limits)
+}
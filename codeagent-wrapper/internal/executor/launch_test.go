@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+func TestParseParallelConfig_Limits(t *testing.T) {
+	cfg, err := ParseParallelConfig([]byte("---TASK---\nid: a\nlimits: {memory: 4GiB, cpu_seconds: 600, max_procs: 64}\n---CONTENT---\ndo a"))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	want := config.ResourceLimits{Memory: 4 << 30, CPUSeconds: 600, MaxProcs: 64}
+	if cfg.Tasks[0].Limits != want {
+		t.Fatalf("limits = %+v, want %+v", cfg.Tasks[0].Limits, want)
+	}
+	_, err = ParseParallelConfig([]byte("---TASK---\nid: a\nlimits: {memory: lots}\n---CONTENT---\ndo a"))
+	if err == nil || !strings.Contains(err.Error(), "task block #1 invalid limits") {
+		t.Fatalf("invalid limits error = %v", err)
+	}
+}
+
+func TestPlanTask_NotesResourceLimits(t *testing.T) {
+	setTestHome(t, t.TempDir())
+	config.ResetModelsConfigCacheForTest()
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+
+	plan, err := PlanTask(TaskSpec{ID: "t1", Task: "x", WorkDir: t.TempDir(), Backend: "codex", Limits: config.ResourceLimits{CPUSeconds: 60}}, false)
+	if err != nil {
+		t.Fatalf("PlanTask: %v", err)
+	}
+	if !strings.Contains(strings.Join(plan.Notes, "\n"), "resource limits: cpu_seconds=60") {
+		t.Fatalf("notes = %v", plan.Notes)
+	}
+}
@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// Hook points for per-backend / per-agent limits (tests can override).
+var (
+	backendConcurrencyFn = config.ResolveBackendConcurrency
+	agentConcurrencyFn   = config.ResolveAgentConcurrency
+)
+
+// scopeLimiter enforces one backend's or agent's max_concurrency and
+// min_start_interval.
+type scopeLimiter struct {
+	limit     config.ConcurrencyLimit
+	active    int       // guarded by the owning slotGate
+	nextStart time.Time // guarded by scopedLimiter.mu
+}
+
+// scopedLimiter tracks per-backend and per-agent slots, which slotGate takes
+// together with a global worker slot.
+type scopedLimiter struct {
+	mu     sync.Mutex
+	scopes map[string]*scopeLimiter
+}
+
+func newScopedLimiter(layers [][]TaskSpec) *scopedLimiter {
+	l := &scopedLimiter{scopes: make(map[string]*scopeLimiter)}
+	for _, layer := range layers {
+		var tasks []TaskSpec
+		for _, task := range layer {
+			tasks = append(append(tasks, task), bestOfLimitTasks(task)...)
+		}
+		for _, task := range tasks {
+			for _, key := range taskLimitScopes(task) {
+				if _, ok := l.scopes[key]; ok {
+					continue
+				}
+				kind, name, _ := strings.Cut(key, ":")
+				var limit config.ConcurrencyLimit
+				if kind == "backend" {
+					limit = backendConcurrencyFn(name)
+				} else {
+					limit = agentConcurrencyFn(name)
+				}
+				if limit.IsZero() {
+					l.scopes[key] = nil
+					continue
+				}
+				l.scopes[key] = &scopeLimiter{limit: limit}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(l.scopes))
+	for key, scope := range l.scopes {
+		if scope != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		scope := l.scopes[key]
+		logInfo(fmt.Sprintf("parallel: scope=%s max_concurrency=%s min_start_interval=%s", key, renderScopeLimit(scope.limit.MaxConcurrency), scope.limit.MinStartInterval))
+	}
+	return l
+}
+
+// taskLimitScopes returns the limiter keys for a task: its backend scope
+// followed by its agent scope, if any.
+func taskLimitScopes(task TaskSpec) []string {
+	backendName := strings.ToLower(strings.TrimSpace(task.Backend))
+	if backendName == "" {
+		backendName = defaultBackendName
+	}
+	keys := []string{"backend:" + backendName}
+	if agent := strings.TrimSpace(task.Agent); agent != "" {
+		keys = append(keys, "agent:"+agent)
+	}
+	return keys
+}
+
+func (l *scopedLimiter) scopesFor(task TaskSpec) []*scopeLimiter {
+	if l == nil {
+		return nil
+	}
+	var out []*scopeLimiter
+	for _, key := range taskLimitScopes(task) {
+		if scope := l.scopes[key]; scope != nil {
+			out = append(out, scope)
+		}
+	}
+	return out
+}
+
+// tryAcquire takes a max_concurrency slot in every scope of the task, or
+// none if any scope is full. The caller (slotGate) serializes all calls and
+// the returned release func.
+func (l *scopedLimiter) tryAcquire(task TaskSpec) (release func(), ok bool) {
+	scopes := l.scopesFor(task)
+	for _, scope := range scopes {
+		if scope.limit.MaxConcurrency > 0 && scope.active >= scope.limit.MaxConcurrency {
+			return nil, false
+		}
+	}
+	for _, scope := range scopes {
+		scope.active++
+	}
+	return func() {
+		for _, scope := range scopes {
+			scope.active--
+		}
+	}, true
+}
+
+// pace waits until the task's scopes allow another start according to
+// min_start_interval, reserving the next start time for each scope.
+func (l *scopedLimiter) pace(ctx context.Context, task TaskSpec) bool {
+	scopes := l.scopesFor(task)
+	if len(scopes) == 0 {
+		return true
+	}
+
+	// Reserve under one lock so concurrent tasks sharing a scope never pick
+	// the same start time.
+	l.mu.Lock()
+	startAt := time.Now()
+	for _, scope := range scopes {
+		if scope.limit.MinStartInterval > 0 && scope.nextStart.After(startAt) {
+			startAt = scope.nextStart
+		}
+	}
+	for _, scope := range scopes {
+		if scope.limit.MinStartInterval > 0 {
+			scope.nextStart = startAt.Add(scope.limit.MinStartInterval)
+		}
+	}
+	l.mu.Unlock()
+
+	delay := time.Until(startAt)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func renderScopeLimit(limit int) string {
+	if limit <= 0 {
+		return "unbounded"
+	}
+	return fmt.Sprintf("%d", limit)
+}
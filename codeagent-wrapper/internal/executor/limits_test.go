@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+func setConcurrencyLimits(t *testing.T, backends, agents map[string]config.ConcurrencyLimit) {
+	t.Helper()
+	prevBackend, prevAgent := backendConcurrencyFn, agentConcurrencyFn
+	t.Cleanup(func() { backendConcurrencyFn, agentConcurrencyFn = prevBackend, prevAgent })
+	backendConcurrencyFn = func(name string) config.ConcurrencyLimit { return backends[name] }
+	agentConcurrencyFn = func(name string) config.ConcurrencyLimit { return agents[name] }
+}
+
+func TestExecuteConcurrent_BackendAndAgentLimits(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	setConcurrencyLimits(t,
+		map[string]config.ConcurrencyLimit{"claude": {MaxConcurrency: 1}},
+		map[string]config.ConcurrencyLimit{"reviewer": {MaxConcurrency: 2}},
+	)
+
+	var mu sync.Mutex
+	active := map[string]int{}
+	peak := map[string]int{}
+	track := func(key string, delta int) {
+		mu.Lock()
+		defer mu.Unlock()
+		active[key] += delta
+		if active[key] > peak[key] {
+			peak[key] = active[key]
+		}
+	}
+
+	var layer []TaskSpec
+	for i := 0; i < 3; i++ {
+		layer = append(layer, TaskSpec{ID: fmt.Sprintf("claude-%d", i), Backend: "claude"})
+		layer = append(layer, TaskSpec{ID: fmt.Sprintf("review-%d", i), Backend: "codex", Agent: "reviewer"})
+	}
+
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		key := ts.Backend
+		if ts.Agent != "" {
+			key = "agent:" + ts.Agent
+		}
+		track(key, 1)
+		time.Sleep(30 * time.Millisecond)
+		track(key, -1)
+		return TaskResult{TaskID: ts.ID, Message: "ok"}
+	}
+
+	results := ExecuteConcurrentWithContext(context.Background(), [][]TaskSpec{layer}, 10, 0, runTask)
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(results))
+	}
+	if peak["claude"] != 1 {
+		t.Errorf("claude peak concurrency = %d, want 1", peak["claude"])
+	}
+	if peak["agent:reviewer"] > 2 {
+		t.Errorf("reviewer peak concurrency = %d, want <= 2", peak["agent:reviewer"])
+	}
+
+	var waited int
+	for _, res := range results {
+		if res.QueueWaitMs > 0 {
+			waited++
+		}
+	}
+	if waited == 0 {
+		t.Errorf("expected queued tasks to report queue wait")
+	}
+}
+
+func TestExecuteConcurrent_MinStartInterval(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	interval := 40 * time.Millisecond
+	setConcurrencyLimits(t, map[string]config.ConcurrencyLimit{"gemini": {MinStartInterval: interval}}, nil)
+
+	var mu sync.Mutex
+	var starts []time.Time
+	var calls atomic.Int32
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		calls.Add(1)
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return TaskResult{TaskID: ts.ID, Message: "ok"}
+	}
+
+	layer := []TaskSpec{{ID: "a", Backend: "gemini"}, {ID: "b", Backend: "gemini"}, {ID: "c", Backend: "gemini"}}
+	ExecuteConcurrentWithContext(context.Background(), [][]TaskSpec{layer}, 10, 0, runTask)
+
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls.Load())
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for i := 1; i < len(starts); i++ {
+		// Allow a little scheduler slack below the configured interval.
+		if gap := starts[i].Sub(starts[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("start gap %d = %s, want >= %s", i, gap, interval)
+		}
+	}
+}
+
+func TestSlotGate_ScopeFullCancelWhileQueued(t *testing.T) {
+	setConcurrencyLimits(t, map[string]config.ConcurrencyLimit{"codex": {MaxConcurrency: 1}}, nil)
+	gate := newSlotGate(0, newScopedLimiter([][]TaskSpec{{{ID: "a"}, {ID: "b"}}}))
+
+	first := gate.enqueue(TaskSpec{ID: "a"}, 0)
+	release, ok := gate.wait(context.Background(), first)
+	if !ok {
+		t.Fatal("first task should get a slot")
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	second := gate.enqueue(TaskSpec{ID: "b"}, 1)
+	if _, ok := gate.wait(ctx, second); ok {
+		t.Fatal("second task should give up once the context is done")
+	}
+	if len(gate.waiters) != 0 {
+		t.Fatalf("cancelled waiter left in queue: %d", len(gate.waiters))
+	}
+}
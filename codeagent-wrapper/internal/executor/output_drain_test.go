@@ -0,0 +1,37 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// The executor waits for the backend while the parser is still reading, so
+// output printed just before the backend exits must stay readable after Wait
+// has returned.
+func TestRealCmd_OutputReadableAfterWait(t *testing.T) {
+	cmd := &realCmd{cmd: commandContext(context.Background(), "sh", "-c", "echo out; echo err >&2")}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	for name, r := range map[string]io.ReadCloser{"out": stdout, "err": stderr} {
+		data, err := io.ReadAll(r)
+		if err != nil || string(data) != name+"\n" {
+			t.Errorf("std%s after Wait = %q, %v", name, data, err)
+		}
+		r.Close()
+	}
+}
@@ -3,9 +3,13 @@ package executor
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path"
+	"strconv"
 	"strings"
 
 	config "codeagent-wrapper/internal/config"
+	session "codeagent-wrapper/internal/session"
 )
 
 func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
@@ -36,6 +40,7 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 
 		task := TaskSpec{WorkDir: defaultWorkdir}
 		agentSpecified := false
+		workdirSpecified := false
 		for _, line := range strings.Split(meta, "\n") {
 			line = strings.TrimSpace(line)
 			if line == "" {
@@ -57,6 +62,7 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 					return nil, fmt.Errorf("task block #%d has invalid workdir: '-' is not a valid directory path", taskIndex)
 				}
 				task.WorkDir = value
+				workdirSpecified = true
 			case "session_id":
 				task.SessionID = value
 				task.Mode = "resume"
@@ -95,6 +101,50 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 						task.Skills = append(task.Skills, s)
 					}
 				}
+			case "best_of", "best-of":
+				candidates, err := parseBestOf(value)
+				if err != nil {
+					return nil, fmt.Errorf("task block #%d invalid best_of: %w", taskIndex, err)
+				}
+				task.BestOf = candidates
+			case "judge":
+				task.Judge = value
+			case "priority":
+				priority, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("task block #%d invalid priority %q: must be an integer", taskIndex, value)
+				}
+				task.Priority = priority
+			case "env":
+				name, val, err := config.ParseEnvAssignment(value)
+				if err != nil {
+					return nil, fmt.Errorf("task block #%d invalid env: %w", taskIndex, err)
+				}
+				if task.Env == nil {
+					task.Env = make(map[string]string)
+				}
+				task.Env[name] = val
+			case "env_passthrough", "env_unset":
+				for _, pattern := range strings.Split(value, ",") {
+					pattern = strings.TrimSpace(pattern)
+					if pattern == "" {
+						continue
+					}
+					if _, err := path.Match(pattern, ""); err != nil {
+						return nil, fmt.Errorf("task block #%d invalid %s pattern %q", taskIndex, key, pattern)
+					}
+					if key == "env_passthrough" {
+						task.EnvPassthrough = append(task.EnvPassthrough, pattern)
+					} else {
+						task.EnvUnset = append(task.EnvUnset, pattern)
+					}
+				}
+			case "limits":
+				limits, err := config.ParseResourceLimits(value)
+				if err != nil {
+					return nil, fmt.Errorf("task block #%d invalid limits: %w", taskIndex, err)
+				}
+				task.Limits = limits
 			}
 		}
 
@@ -102,6 +152,11 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 			task.Mode = "new"
 		}
 
+		if task.Mode == "resume" {
+			applyRecordedSession(&task, workdirSpecified)
+		}
+		agentFromSession := !agentSpecified && task.Agent != ""
+
 		if agentSpecified {
 			if strings.TrimSpace(task.Agent) == "" {
 				return nil, fmt.Errorf("task block #%d has empty agent field", taskIndex)
@@ -109,27 +164,48 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 			if err := config.ValidateAgentName(task.Agent); err != nil {
 				return nil, fmt.Errorf("task block #%d invalid agent name: %w", taskIndex, err)
 			}
+		}
+		if agentSpecified || agentFromSession {
 			backend, model, promptFile, reasoning, _, _, _, allowedTools, disallowedTools, err := config.ResolveAgentConfig(task.Agent)
-			if err != nil {
+			switch {
+			case err != nil && agentFromSession:
+				logWarn(fmt.Sprintf("Ignoring agent %q recorded for session %s: %s", task.Agent, task.SessionID, strings.SplitN(err.Error(), "\n\n", 2)[0]))
+				task.Agent = ""
+			case err != nil:
 				return nil, fmt.Errorf("task block #%d failed to resolve agent %q: %w", taskIndex, task.Agent, err)
+			default:
+				if task.Backend == "" {
+					task.Backend = backend
+				}
+				if task.Model == "" {
+					task.Model = model
+				}
+				if task.ReasoningEffort == "" {
+					task.ReasoningEffort = reasoning
+				}
+				task.PromptFile = promptFile
+				task.AllowedTools = allowedTools
+				task.DisallowedTools = disallowedTools
 			}
-			if task.Backend == "" {
-				task.Backend = backend
-			}
-			if task.Model == "" {
-				task.Model = model
-			}
-			if task.ReasoningEffort == "" {
-				task.ReasoningEffort = reasoning
-			}
-			task.PromptFile = promptFile
-			task.AllowedTools = allowedTools
-			task.DisallowedTools = disallowedTools
 		}
 
 		if task.ID == "" {
 			return nil, fmt.Errorf("task block #%d missing id field", taskIndex)
 		}
+		if len(task.BestOf) > 0 {
+			if task.Mode == "resume" {
+				return nil, fmt.Errorf("task block #%d (%q) cannot combine best_of with session_id", taskIndex, task.ID)
+			}
+			if task.Judge == "" {
+				task.Judge = strings.TrimSpace(os.Getenv(bestOfJudgeEnv))
+			}
+			if task.Judge == "" {
+				return nil, fmt.Errorf("task block #%d (%q) uses best_of but no judge agent is configured (set judge: or %s)", taskIndex, task.ID, bestOfJudgeEnv)
+			}
+			if err := config.ValidateAgentName(task.Judge); err != nil {
+				return nil, fmt.Errorf("task block #%d invalid judge: %w", taskIndex, err)
+			}
+		}
 		if content == "" {
 			return nil, fmt.Errorf("task block #%d (%q) missing content", taskIndex, task.ID)
 		}
@@ -151,3 +227,36 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 
 	return &cfg, nil
 }
+
+// applyRecordedSession fills the backend, model, agent and workdir of a
+// resume task from the session registry when the task block leaves them out.
+// A task that names a backend which does not own the session hands it off to
+// that backend; without a registry entry the owner is looked up in the
+// backends' local session files.
+func applyRecordedSession(task *TaskSpec, workdirSpecified bool) {
+	rec, err := session.Lookup(task.SessionID)
+	if err != nil {
+		logWarn(fmt.Sprintf("Failed to read session registry for %s: %v", task.SessionID, err))
+		rec = nil
+	}
+	if task.Backend != "" && (rec == nil || rec.Backend != task.Backend) {
+		task.HandoffBackend = HandoffSource(task.SessionID, task.Backend, rec)
+	}
+	if rec == nil {
+		return
+	}
+	if task.Backend == "" {
+		task.Backend = rec.Backend
+	}
+	if task.Model == "" && task.Backend == rec.Backend {
+		task.Model = rec.Model
+	}
+	if task.Agent == "" && rec.Agent != "" && config.ValidateAgentName(rec.Agent) == nil {
+		task.Agent = rec.Agent
+	}
+	if !workdirSpecified {
+		if dir := rec.ResumeDir(); dir != "" {
+			task.WorkDir = dir
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// stragglerGrace is how long processes left in a terminated backend's group
+// get to exit on their own before they are killed and reported.
+const stragglerGrace = 500 * time.Millisecond
+
+// killStragglers finds processes still running in the backend's process
+// group after the backend itself exited, kills them and returns them as
+// "pid (command)".
+func killStragglers(cmd commandRunner) []string {
+	rc, ok := cmd.(*realCmd)
+	if !ok || !rc.group || rc.cmd == nil || rc.cmd.Process == nil {
+		return nil
+	}
+	pgid := rc.cmd.Process.Pid
+	deadline := time.Now().Add(stragglerGrace)
+	members := groupMembers(pgid)
+	for len(members) > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		members = groupMembers(pgid)
+	}
+	if len(members) > 0 {
+		_ = signalGroup(pgid, os.Kill)
+	}
+	return members
+}
+
+// writeStragglers reports processes that outlived a terminated backend.
+func writeStragglers(sb *strings.Builder, res TaskResult) {
+	if len(res.Stragglers) > 0 {
+		sb.WriteString(fmt.Sprintf("Stragglers killed: %s\n", sanitizeOutput(strings.Join(res.Stragglers, ", "))))
+	}
+}
@@ -0,0 +1,73 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startShell runs script in sh the way a backend is started.
+func startShell(t *testing.T, script string) *realCmd {
+	t.Helper()
+	cmd := &realCmd{cmd: commandContext(context.Background(), "sh", "-c", script)}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if !cmd.group || !cmd.cmd.SysProcAttr.Setpgid {
+		t.Fatalf("backend was not started in its own process group")
+	}
+	return cmd
+}
+
+// waitGroupEmpty waits until no live process is left in group pgid.
+func waitGroupEmpty(t *testing.T, pgid int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if len(groupMembers(pgid)) == 0 {
+			return
+		}
+	}
+	t.Fatalf("process group %d still has %v", pgid, groupMembers(pgid))
+}
+
+func TestTerminateCommand_SignalsProcessGroup(t *testing.T) {
+	cmd := startShell(t, "sleep 60 & sleep 60 & wait")
+	pgid := cmd.Process().Pid()
+	for deadline := time.Now().Add(5 * time.Second); len(groupMembers(pgid)) < 3 && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	timer := terminateCommand(cmd)
+	_ = cmd.Wait()
+	timer.Stop()
+	waitGroupEmpty(t, pgid)
+}
+
+func TestKillStragglers(t *testing.T) {
+	cmd := startShell(t, `sh -c 'trap "" TERM; sleep 60' & sleep 0.2; exit 0`)
+	pgid := cmd.Process().Pid()
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	stragglers := killStragglers(cmd)
+	if !strings.Contains(strings.Join(stragglers, " "), "(sleep)") {
+		t.Fatalf("stragglers = %v, want the orphaned sleep", stragglers)
+	}
+	waitGroupEmpty(t, pgid)
+
+	if got := killStragglers(cmd); len(got) != 0 {
+		t.Fatalf("second sweep = %v, want none", got)
+	}
+}
+
+func TestWriteStragglers(t *testing.T) {
+	var sb strings.Builder
+	writeStragglers(&sb, TaskResult{Stragglers: []string{"41 (node)", "42 (sh)"}})
+	if got := sb.String(); got != "Stragglers killed: 41 (node), 42 (sh)\n" {
+		t.Fatalf("writeStragglers = %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix && !linux
+
+package executor
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// startInOwnGroup makes the backend lead a process group of its own, so it
+// and everything it starts can be signalled together.
+func startInOwnGroup(cmd *exec.Cmd) bool {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	return true
+}
+
+// groupMembers lists the live processes in process group pgid as
+// "pid (command)".
+func groupMembers(pgid int) []string {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,pgid=,stat=,comm=").Output()
+	if err != nil {
+		return nil
+	}
+	var members []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || strings.HasPrefix(fields[2], "Z") {
+			continue
+		}
+		if pgrp, err := strconv.Atoi(fields[1]); err == nil && pgrp == pgid {
+			members = append(members, fields[0]+" ("+strings.Join(fields[3:], " ")+")")
+		}
+	}
+	return members
+}
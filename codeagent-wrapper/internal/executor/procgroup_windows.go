@@ -0,0 +1,19 @@
+//go:build windows
+
+package executor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// startInOwnGroup is a no-op on Windows; sendTermSignal kills the process
+// tree with taskkill instead.
+func startInOwnGroup(*exec.Cmd) bool { return false }
+
+func signalGroup(int, os.Signal) error {
+	return errors.New("process groups are not supported on Windows")
+}
+
+func groupMembers(int) []string { return nil }
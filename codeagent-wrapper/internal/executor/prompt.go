@@ -6,14 +6,14 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	config "codeagent-wrapper/internal/config"
 )
 
-func ReadAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error) {
+// expandPromptPath expands a leading "~" and returns the cleaned absolute
+// path of a prompt file.
+func expandPromptPath(path string) (string, error) {
 	raw := strings.TrimSpace(path)
-	if raw == "" {
-		return "", nil
-	}
-
 	expanded := raw
 	if raw == "~" || strings.HasPrefix(raw, "~/") || strings.HasPrefix(raw, "~\\") {
 		home, err := os.UserHomeDir()
@@ -31,7 +31,18 @@ func ReadAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 	if err != nil {
 		return "", err
 	}
-	absPath = filepath.Clean(absPath)
+	return filepath.Clean(absPath), nil
+}
+
+func ReadAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", nil
+	}
+
+	absPath, err := expandPromptPath(path)
+	if err != nil {
+		return "", err
+	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -44,6 +55,11 @@ func ReadAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 			filepath.Clean(filepath.Join(home, ".claude")),
 			filepath.Clean(filepath.Join(home, ".codeagent", "agents")),
 		}
+		errOutside := fmt.Errorf("prompt file must be under ~/.claude or ~/.codeagent/agents")
+		if projectDirs := config.ProjectPromptDirs(); len(projectDirs) > 0 {
+			allowedDirs = append(allowedDirs, projectDirs...)
+			errOutside = fmt.Errorf("prompt file must be under ~/.claude, ~/.codeagent/agents or %s", projectDirs[0])
+		}
 		for i := range allowedDirs {
 			allowedAbs, err := filepath.Abs(allowedDirs[i])
 			if err == nil {
@@ -77,7 +93,7 @@ func ReadAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 			}
 			if !withinAllowed {
 				logWarn(fmt.Sprintf("Refusing to read prompt file outside allowed dirs (%s): %s", strings.Join(allowedDirs, ", "), absPath))
-				return "", fmt.Errorf("prompt file must be under ~/.claude or ~/.codeagent/agents")
+				return "", errOutside
 			}
 
 			resolvedPath, errPath := filepath.EvalSymlinks(absPath)
@@ -101,7 +117,7 @@ func ReadAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error
 					}
 					if !withinResolved {
 						logWarn(fmt.Sprintf("Refusing to read prompt file outside allowed dirs (%s) (resolved): %s", strings.Join(resolvedAllowed, ", "), resolvedPath))
-						return "", fmt.Errorf("prompt file must be under ~/.claude or ~/.codeagent/agents")
+						return "", errOutside
 					}
 				}
 			}
@@ -130,65 +146,57 @@ func WrapTaskWithAgentPrompt(prompt string, task string) string {
 	return "<agent-prompt>\n" + prompt + "\n</agent-prompt>\n\n" + task
 }
 
-// techSkillMap maps file-existence fingerprints to skill names.
-var techSkillMap = []struct {
-	Files  []string // any of these files → this tech
-	Skills []string
-}{
-	{Files: []string{"go.mod", "go.sum"}, Skills: []string{"golang-base-practices"}},
-	{Files: []string{"Cargo.toml"}, Skills: []string{"rust-best-practices"}},
-	{Files: []string{"pyproject.toml", "setup.py", "requirements.txt", "Pipfile"}, Skills: []string{"python-best-practices"}},
-	{Files: []string{"package.json"}, Skills: []string{"vercel-react-best-practices", "frontend-design"}},
-	{Files: []string{"vue.config.js", "vite.config.ts", "nuxt.config.ts"}, Skills: []string{"vue-web-app"}},
-}
+// defaultSkillTokenBudget caps injected skill content, in estimated tokens,
+// unless CODEAGENT_SKILL_TOKENS overrides it.
+const defaultSkillTokenBudget = 4000
 
-// DetectProjectSkills scans workDir for tech-stack fingerprints and returns
-// skill names that are both detected and installed at ~/.claude/skills/{name}/SKILL.md.
-func DetectProjectSkills(workDir string) []string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil
+// validSkillName ensures skill names contain only safe characters to prevent path traversal
+var validSkillName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ResolveSkillContent reads SKILL.md files for the given skill names from
+// workDir's .claude/skills or ~/.claude/skills, strips YAML frontmatter,
+// and injects the sections most relevant to task that fit in maxTokens
+// (estimated), each skill wrapped in <skill> tags.
+func ResolveSkillContent(workDir, task string, skills []string, maxTokens int) string {
+	sections := resolveSkillSections(workDir, task, skills, maxTokens)
+	if len(sections) == 0 {
+		return ""
 	}
-	var detected []string
-	seen := make(map[string]bool)
-	for _, entry := range techSkillMap {
-		for _, f := range entry.Files {
-			if _, err := os.Stat(filepath.Join(workDir, f)); err == nil {
-				for _, skill := range entry.Skills {
-					if seen[skill] {
-						continue
-					}
-					skillPath := filepath.Join(home, ".claude", "skills", skill, "SKILL.md")
-					if _, err := os.Stat(skillPath); err == nil {
-						detected = append(detected, skill)
-						seen[skill] = true
-					}
-				}
-				break // one matching file is enough for this entry
-			}
-		}
+	parts := make([]string, len(sections))
+	for i, section := range sections {
+		parts[i] = section.content
 	}
-	return detected
+	return strings.Join(parts, "\n\n")
 }
 
-const defaultSkillBudget = 16000 // chars, ~4K tokens
-
-// validSkillName ensures skill names contain only safe characters to prevent path traversal
-var validSkillName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+// skillSection is one <skill> block as injected into the task prompt.
+type skillSection struct {
+	name     string
+	content  string
+	tokens   int
+	included []string // section titles kept, in document order
+	dropped  []string // section titles left out for budget
+}
 
-// ResolveSkillContent reads SKILL.md files for the given skill names,
-// strips YAML frontmatter, wraps each in <skill> tags, and enforces a
-// character budget to prevent context bloat.
-func ResolveSkillContent(skills []string, maxBudget int) string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
+// resolveSkillSections splits each skill into Markdown sections, ranks them
+// against task with BM25 and packs the best ones into the budget. Skills
+// keep their list order and sections their document order; nothing is cut
+// mid-section. Skills with no section that fits are omitted.
+func resolveSkillSections(workDir, task string, skills []string, maxTokens int) []skillSection {
+	roots := skillRoots(workDir)
+	if len(roots) == 0 {
+		return nil
 	}
-	if maxBudget <= 0 {
-		maxBudget = defaultSkillBudget
+	if maxTokens <= 0 {
+		maxTokens = config.ResolveSkillTokenBudget()
 	}
-	var sections []string
-	remaining := maxBudget
+	if maxTokens <= 0 {
+		maxTokens = defaultSkillTokenBudget
+	}
+
+	var names []string
+	var tagTokens []int
+	var all []skillDocSection
 	for _, name := range skills {
 		name = strings.TrimSpace(name)
 		if name == "" {
@@ -198,33 +206,73 @@ func ResolveSkillContent(skills []string, maxBudget int) string {
 			logWarn(fmt.Sprintf("skill %q: invalid name (must contain only [a-zA-Z0-9_-]), skipping", name))
 			continue
 		}
-		path := filepath.Join(home, ".claude", "skills", name, "SKILL.md")
-		data, err := os.ReadFile(path)
-		if err != nil || len(data) == 0 {
+		var data []byte
+		path := findSkillFile(roots, name)
+		if path != "" {
+			data, _ = os.ReadFile(path) // #nosec G304 -- name is validated above
+		}
+		if len(data) == 0 {
 			logWarn(fmt.Sprintf("skill %q: SKILL.md not found or empty, skipping", name))
 			continue
 		}
 		body := stripYAMLFrontmatter(strings.TrimSpace(string(data)))
-		tagOverhead := len("<skill name=\"\">") + len(name) + len("\n") + len("\n</skill>")
-		bodyBudget := remaining - tagOverhead
-		if bodyBudget <= 0 {
-			logWarn(fmt.Sprintf("skill %q: skipped, insufficient budget for tags", name))
-			break
-		}
-		if len(body) > bodyBudget {
-			logWarn(fmt.Sprintf("skill %q: truncated from %d to %d chars (budget)", name, len(body), bodyBudget))
-			body = body[:bodyBudget]
+
+		skill := len(names)
+		names = append(names, name)
+		tags := estimateTokens(skillOpenTag(name) + "\n" + "\n</skill>")
+		tagTokens = append(tagTokens, tags)
+		index := 0
+		for _, s := range splitSkillSections(body) {
+			s.skill = skill
+			s.tokens = estimateTokens(s.text)
+			for _, part := range splitOversizedSection(s, maxTokens-tags-1) {
+				part.index = index
+				index++
+				all = append(all, part)
+			}
 		}
-		remaining -= len(body) + tagOverhead
-		sections = append(sections, "<skill name=\""+name+"\">\n"+body+"\n</skill>")
-		if remaining <= 0 {
-			break
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	scoreSectionsBM25(all, task)
+	chosen := packSkillSections(all, tagTokens, maxTokens)
+
+	result := make([]skillSection, len(names))
+	bodies := make([][]string, len(names))
+	for i, name := range names {
+		result[i].name = name
+	}
+	for i, s := range all {
+		if chosen[i] {
+			bodies[s.skill] = append(bodies[s.skill], s.text)
+			result[s.skill].included = append(result[s.skill].included, s.title)
+		} else {
+			result[s.skill].dropped = append(result[s.skill].dropped, s.title)
 		}
 	}
-	if len(sections) == 0 {
-		return ""
+
+	var sections []skillSection
+	for i, section := range result {
+		total := len(section.included) + len(section.dropped)
+		if len(section.included) == 0 {
+			logWarn(fmt.Sprintf("skill %q: dropped, no section fits the %d-token budget", section.name, maxTokens))
+			continue
+		}
+		if len(section.dropped) > 0 {
+			logInfo(fmt.Sprintf("skill %q: included %d/%d sections [%s]; dropped [%s] (budget %d tokens)",
+				section.name, len(section.included), total, strings.Join(section.included, "; "), strings.Join(section.dropped, "; "), maxTokens))
+		}
+		section.content = skillOpenTag(section.name) + "\n" + strings.Join(bodies[i], "\n\n") + "\n</skill>"
+		section.tokens = estimateTokens(section.content)
+		sections = append(sections, section)
 	}
-	return strings.Join(sections, "\n\n")
+	return sections
+}
+
+func skillOpenTag(name string) string {
+	return "<skill name=\"" + name + "\">"
 }
 
 func stripYAMLFrontmatter(s string) string {
@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+	"codeagent-wrapper/internal/worktree"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxPromptIncludeBytes      = 64 * 1024  // per included file
+	maxPromptIncludeTotalBytes = 256 * 1024 // all includes of one prompt file
+)
+
+// Hook points for testing
+var (
+	promptNowFn       = time.Now
+	promptBranchFn    = worktree.CurrentBranch
+	promptGitStatusFn = worktree.ShortStatus
+)
+
+// PromptVars are the per-task values a prompt template can reference.
+type PromptVars struct {
+	WorkDir string
+	TaskID  string
+	Backend string
+	Model   string
+}
+
+func promptVarsForTask(task TaskSpec) PromptVars {
+	return PromptVars{WorkDir: task.WorkDir, TaskID: task.ID, Backend: task.Backend, Model: task.Model}
+}
+
+// promptTemplateData is the dot value of a prompt template. Git values are
+// methods so git only runs when a template uses them.
+type promptTemplateData struct {
+	WorkDir string
+	TaskID  string
+	Backend string
+	Model   string
+	Date    string
+
+	branch, status *string
+}
+
+func (d *promptTemplateData) Branch() string {
+	if d.branch == nil {
+		branch, _ := promptBranchFn(d.WorkDir)
+		d.branch = &branch
+	}
+	return *d.branch
+}
+
+func (d *promptTemplateData) GitStatus() string {
+	if d.status == nil {
+		status, _ := promptGitStatusFn(d.WorkDir)
+		d.status = &status
+	}
+	return *d.status
+}
+
+// RenderAgentPromptFile reads a prompt file like ReadAgentPromptFile. When
+// its YAML frontmatter sets "template: true", the rest is rendered as a Go
+// text/template with vars, the git branch and status of vars.WorkDir, the
+// date, and two functions:
+//
+//	{{include "relative/path.md"}}  file contents from the prompt's directory (verbatim, size-capped)
+//	{{env "NAME"}}                  an environment variable listed in models.json "prompt_env"
+//
+// Other files are returned unchanged. Errors name the file and line.
+func RenderAgentPromptFile(path string, allowOutsideClaudeDir bool, vars PromptVars) (string, error) {
+	content, err := ReadAgentPromptFile(path, allowOutsideClaudeDir)
+	if err != nil {
+		return "", err
+	}
+	body, ok := promptTemplateBody(content)
+	if !ok {
+		return content, nil
+	}
+	absPath, err := expandPromptPath(path)
+	if err != nil {
+		return "", err
+	}
+	return renderPromptTemplate(strings.TrimSpace(path), filepath.Dir(absPath), body, allowOutsideClaudeDir, vars)
+}
+
+// promptTemplateBody returns the part of a prompt file after its frontmatter
+// when the frontmatter opts into templating with "template: true". The
+// frontmatter is replaced by blank lines so template errors keep the file's
+// line numbers.
+func promptTemplateBody(content string) (string, bool) {
+	frontmatter, ok := extractYAMLFrontmatter(content)
+	if !ok || !strings.Contains(frontmatter, "template") {
+		return "", false
+	}
+	var meta struct {
+		Template bool `yaml:"template"`
+	}
+	if yaml.Unmarshal([]byte(frontmatter), &meta) != nil || !meta.Template {
+		return "", false
+	}
+	s := strings.ReplaceAll(strings.TrimSpace(content), "\r\n", "\n")
+	end := len("---\n") + len(frontmatter) + len("\n---")
+	return strings.Repeat("\n", strings.Count(s[:end], "\n")) + s[end:], true
+}
+
+func renderPromptTemplate(name, baseDir, content string, allowOutsideClaudeDir bool, vars PromptVars) (string, error) {
+	workDir := vars.WorkDir
+	if strings.TrimSpace(workDir) == "" {
+		workDir = defaultWorkdir
+	}
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	data := &promptTemplateData{
+		WorkDir: workDir,
+		TaskID:  vars.TaskID,
+		Backend: vars.Backend,
+		Model:   vars.Model,
+		Date:    promptNowFn().Format("2006-01-02"),
+	}
+
+	included := 0
+	funcs := template.FuncMap{
+		"include": func(rel string) (string, error) {
+			return includePromptFile(baseDir, rel, allowOutsideClaudeDir, &included)
+		},
+		"env": promptEnv,
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return strings.Trim(out.String(), "\r\n"), nil
+}
+
+// includePromptFile reads rel from under baseDir, applying the prompt file
+// directory rules and the per-file and total size caps.
+func includePromptFile(baseDir, rel string, allowOutsideClaudeDir bool, included *int) (string, error) {
+	rel = strings.TrimSpace(rel)
+	if rel == "" || filepath.IsAbs(rel) || strings.HasPrefix(rel, "~") {
+		return "", fmt.Errorf("include %q: path must be relative to the prompt file", rel)
+	}
+	cleaned := filepath.Clean(rel)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("include %q: path must stay inside %s", rel, baseDir)
+	}
+	path := filepath.Join(baseDir, cleaned)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("include %q: %w", rel, err)
+	}
+	if info.Size() > maxPromptIncludeBytes {
+		return "", fmt.Errorf("include %q: file is %d bytes, limit is %d", rel, info.Size(), maxPromptIncludeBytes)
+	}
+	content, err := ReadAgentPromptFile(path, allowOutsideClaudeDir)
+	if err != nil {
+		return "", fmt.Errorf("include %q: %w", rel, err)
+	}
+	*included += len(content)
+	if *included > maxPromptIncludeTotalBytes {
+		return "", fmt.Errorf("include %q: includes exceed %d bytes in total", rel, maxPromptIncludeTotalBytes)
+	}
+	return content, nil
+}
+
+func promptEnv(name string) (string, error) {
+	for _, allowed := range config.PromptEnvAllowlist() {
+		if allowed == name {
+			return os.Getenv(name), nil
+		}
+	}
+	return "", fmt.Errorf("env %q is not listed in models.json \"prompt_env\"", name)
+}
+
+// CheckPromptTemplate reports template syntax errors in a prompt file's
+// content without executing it. Files that do not opt into templating are
+// never templates.
+func CheckPromptTemplate(name, content string) error {
+	body, ok := promptTemplateBody(content)
+	if !ok {
+		return nil
+	}
+	noop := func(string) (string, error) { return "", nil }
+	_, err := template.New(name).Funcs(template.FuncMap{"include": noop, "env": noop}).Parse(body)
+	return err
+}
@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// setupPromptTemplateHome creates ~/.codeagent/agents with the given files
+// and a models.json allowing prompt_env, stubbing git and the clock.
+func setupPromptTemplateHome(t *testing.T, files map[string]string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	config.ResetModelsConfigCacheForTest()
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+
+	agentsDir := filepath.Join(home, ".codeagent", "agents")
+	for name, content := range files {
+		path := filepath.Join(agentsDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	models := `{"agents": {}, "prompt_env": ["TEAM_NAME"]}`
+	if err := os.WriteFile(filepath.Join(home, ".codeagent", "models.json"), []byte(models), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	oldNow, oldBranch, oldStatus := promptNowFn, promptBranchFn, promptGitStatusFn
+	t.Cleanup(func() { promptNowFn, promptBranchFn, promptGitStatusFn = oldNow, oldBranch, oldStatus })
+	promptNowFn = func() time.Time { return time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC) }
+	promptBranchFn = func(string) (string, error) { return "feature/x", nil }
+	promptGitStatusFn = func(string) (string, error) { return " M main.go", nil }
+	return home
+}
+
+func TestRenderAgentPromptFile_Variables(t *testing.T) {
+	setupPromptTemplateHome(t, map[string]string{
+		"develop.md":        "---\ntemplate: true\n---\nTask {{.TaskID}} on {{.Backend}}/{{.Model}} in {{.WorkDir}}\nBranch {{.Branch}} ({{.Date}})\n{{.GitStatus}}\nTeam {{env \"TEAM_NAME\"}}\n{{include \"shared/rules.md\"}}\n",
+		"shared/rules.md":   "Always run tests.\n",
+		"plain.md":          "No templating here\n",
+		"bad-syntax.md":     "---\ntemplate: true\n---\nline one\n{{if .Backend}}unclosed\n",
+		"bad-env.md":        "---\ntemplate: true\n---\nline one\n\n{{env \"HOME\"}}\n",
+		"escape.md":         "---\ntemplate: true\n---\n{{include \"../../models.json\"}}",
+		"missing-field.md":  "---\ntemplate: true\n---\n{{.Nope}}",
+		"literal-braces.md": "---\ntemplate: true\n---\nVue uses {{\"{{\"}} msg }}",
+		"not-opted-in.md":   "Go templates look like {{.Name}} and {{ end }}\n",
+		"other-meta.md":     "---\ntitle: x\n---\n{{.TaskID}}\n",
+	})
+	t.Setenv("TEAM_NAME", "platform")
+	workDir := t.TempDir()
+
+	got, err := RenderAgentPromptFile("~/.codeagent/agents/develop.md", false, PromptVars{
+		WorkDir: workDir, TaskID: "t1", Backend: "claude", Model: "opus",
+	})
+	if err != nil {
+		t.Fatalf("RenderAgentPromptFile: %v", err)
+	}
+	want := "Task t1 on claude/opus in " + workDir + "\nBranch feature/x (2026-03-14)\n M main.go\nTeam platform\nAlways run tests."
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got, err := RenderAgentPromptFile("~/.codeagent/agents/plain.md", false, PromptVars{}); err != nil || got != "No templating here" {
+		t.Fatalf("plain = %q, %v", got, err)
+	}
+	if got, err := RenderAgentPromptFile("~/.codeagent/agents/literal-braces.md", false, PromptVars{}); err != nil || got != "Vue uses {{ msg }}" {
+		t.Fatalf("literal braces = %q, %v", got, err)
+	}
+	// Without the opt-in, braces are prompt text.
+	if got, err := RenderAgentPromptFile("~/.codeagent/agents/not-opted-in.md", false, PromptVars{}); err != nil || got != "Go templates look like {{.Name}} and {{ end }}" {
+		t.Fatalf("not opted in = %q, %v", got, err)
+	}
+	if got, err := RenderAgentPromptFile("~/.codeagent/agents/other-meta.md", false, PromptVars{TaskID: "t1"}); err != nil || got != "---\ntitle: x\n---\n{{.TaskID}}" {
+		t.Fatalf("frontmatter without template = %q, %v", got, err)
+	}
+
+	errCases := map[string][]string{
+		"bad-syntax.md":    {"bad-syntax.md:5", "unexpected EOF"},
+		"bad-env.md":       {"bad-env.md:6", `env "HOME" is not listed`},
+		"escape.md":        {"must stay inside"},
+		"missing-field.md": {"missing-field.md:4", "Nope"},
+	}
+	for name, wants := range errCases {
+		_, err := RenderAgentPromptFile("~/.codeagent/agents/"+name, false, PromptVars{})
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		for _, w := range wants {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("%s: error %q does not contain %q", name, err, w)
+			}
+		}
+	}
+}
+
+func TestRenderAgentPromptFile_IncludeSizeCap(t *testing.T) {
+	setupPromptTemplateHome(t, map[string]string{
+		"big.md":     "---\ntemplate: true\n---\n{{include \"huge.md\"}}",
+		"huge.md":    strings.Repeat("x", maxPromptIncludeBytes+1),
+		"many.md":    "---\ntemplate: true\n---\n" + strings.Repeat("{{include \"part.md\"}}", 5),
+		"part.md":    strings.Repeat("y", maxPromptIncludeBytes-10),
+		"missing.md": "---\ntemplate: true\n---\n{{include \"nope.md\"}}",
+	})
+
+	for name, want := range map[string]string{
+		"big.md":     "limit is",
+		"many.md":    "in total",
+		"missing.md": "no such file",
+	} {
+		_, err := RenderAgentPromptFile("~/.codeagent/agents/"+name, false, PromptVars{})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", name, err, want)
+		}
+	}
+}
+
+func TestCheckPromptTemplate(t *testing.T) {
+	if err := CheckPromptTemplate("p.md", "---\ntemplate: true\n---\nHello {{include \"x.md\"}} {{env \"A\"}} {{.Branch}}"); err != nil {
+		t.Fatalf("valid template: %v", err)
+	}
+	if err := CheckPromptTemplate("p.md", "a\n{{end}}"); err != nil {
+		t.Fatalf("a file without the opt-in is not a template: %v", err)
+	}
+	if err := CheckPromptTemplate("p.md", "---\ntemplate: true\n---\na\n{{end}}"); err == nil || !strings.Contains(err.Error(), "p.md:5") {
+		t.Fatalf("expected syntax error at line 5, got %v", err)
+	}
+}
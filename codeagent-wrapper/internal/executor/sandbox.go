@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"strings"
+
+	backend "codeagent-wrapper/internal/backend"
+	sandbox "codeagent-wrapper/internal/sandbox"
+)
+
+// sandboxPolicy adds what every sandboxed task needs to write to the
+// configured policy: its workdir (the worktree when one is used), tmpDir,
+// which the task gets as TMPDIR, the backend's own state directories and the
+// usual device files. The shared temp directory is not writable.
+func sandboxPolicy(base sandbox.Policy, cfg *Config, tmpDir string) sandbox.Policy {
+	policy := sandbox.Policy{
+		Read: append([]string(nil), base.Read...),
+		Deny: append([]string(nil), base.Deny...),
+	}
+	policy.Write = append(policy.Write, base.Write...)
+	if cfg.WorkDir != "" {
+		policy.Write = append(policy.Write, cfg.WorkDir)
+	}
+	if tmpDir != "" {
+		policy.Write = append(policy.Write, tmpDir)
+	}
+	policy.Write = append(policy.Write, backend.StatePaths(cfg.Backend)...)
+	policy.Write = append(policy.Write, sandbox.DefaultWrite...)
+	return policy.Normalize()
+}
+
+// sandboxViolation summarizes the stderr lines showing access policy
+// refused, or those that may come from it when none clearly does. It returns
+// "" for an unsandboxed task or when stderr reports no refused access.
+func sandboxViolation(stderr string, policy *sandbox.Policy) string {
+	if policy == nil {
+		return ""
+	}
+	denied, possible := sandbox.Violations(stderr, *policy, 3)
+	switch {
+	case len(denied) > 0:
+		return "sandbox denied access: " + strings.Join(denied, " | ")
+	case len(possible) > 0:
+		return "possible sandbox denial: " + strings.Join(possible, " | ")
+	}
+	return ""
+}
@@ -0,0 +1,395 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	// defaultTaskEstimate is used for tasks without duration history when no
+	// other task in the run has history either. Only relative values matter.
+	defaultTaskEstimate = time.Minute
+	// durationHistoryWeight is the weight of the newest sample in the moving
+	// average kept per agent/backend.
+	durationHistoryWeight = 0.3
+)
+
+// Hook points for scheduling (tests can override).
+var (
+	scheduleModeFn        = config.ResolveScheduleMode
+	durationHistoryPathFn = defaultDurationHistoryPath
+)
+
+// taskSchedule ranks the tasks of one parallel run. Lower ranks acquire
+// worker slots first.
+type taskSchedule struct {
+	mode        string
+	rank        map[string]int
+	criticalPos map[string]int // 1-based position on the critical path
+	criticalLen int
+}
+
+// planSchedule orders tasks by priority (higher first) and, in critical-path
+// mode, by the estimated length of their longest downstream path. Ties keep
+// input order.
+func planSchedule(layers [][]TaskSpec, mode string, history durationHistory) *taskSchedule {
+	s := &taskSchedule{mode: mode, rank: make(map[string]int), criticalPos: make(map[string]int)}
+
+	var all []TaskSpec
+	index := make(map[string]int)
+	for _, layer := range layers {
+		for _, task := range layer {
+			index[task.ID] = len(all)
+			all = append(all, task)
+		}
+	}
+
+	var longest map[string]time.Duration
+	if mode == config.ScheduleCriticalPath {
+		longest = longestPaths(layers, history)
+	}
+
+	ordered := append([]TaskSpec(nil), all...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if longest != nil && longest[a.ID] != longest[b.ID] {
+			return longest[a.ID] > longest[b.ID]
+		}
+		return index[a.ID] < index[b.ID]
+	})
+	for i, task := range ordered {
+		s.rank[task.ID] = i
+	}
+
+	if longest != nil {
+		s.markCriticalPath(all, longest, index)
+	}
+	return s
+}
+
+// longestPaths returns, for every task, its own estimate plus the longest
+// chain of dependents that cannot start before it finishes.
+func longestPaths(layers [][]TaskSpec, history durationHistory) map[string]time.Duration {
+	dependents := make(map[string][]string)
+	fallback := defaultTaskEstimate
+	var known []time.Duration
+	for _, layer := range layers {
+		for _, task := range layer {
+			for _, dep := range task.Dependencies {
+				dependents[dep] = append(dependents[dep], task.ID)
+			}
+			if d, ok := history.estimate(task); ok {
+				known = append(known, d)
+			}
+		}
+	}
+	if len(known) > 0 {
+		var sum time.Duration
+		for _, d := range known {
+			sum += d
+		}
+		fallback = sum / time.Duration(len(known))
+	}
+
+	longest := make(map[string]time.Duration)
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, task := range layers[i] {
+			est, ok := history.estimate(task)
+			if !ok {
+				est = fallback
+			}
+			var tail time.Duration
+			for _, child := range dependents[task.ID] {
+				if longest[child] > tail {
+					tail = longest[child]
+				}
+			}
+			longest[task.ID] = est + tail
+		}
+	}
+	return longest
+}
+
+func (s *taskSchedule) markCriticalPath(all []TaskSpec, longest map[string]time.Duration, index map[string]int) {
+	dependents := make(map[string][]string)
+	var current string
+	for _, task := range all {
+		for _, dep := range task.Dependencies {
+			dependents[dep] = append(dependents[dep], task.ID)
+		}
+		if len(task.Dependencies) == 0 && (current == "" || longest[task.ID] > longest[current]) {
+			current = task.ID
+		}
+	}
+
+	var path []string
+	for current != "" {
+		path = append(path, current)
+		next := ""
+		for _, child := range dependents[current] {
+			if next == "" || longest[child] > longest[next] || (longest[child] == longest[next] && index[child] < index[next]) {
+				next = child
+			}
+		}
+		current = next
+	}
+
+	s.criticalLen = len(path)
+	for i, id := range path {
+		s.criticalPos[id] = i + 1
+	}
+}
+
+func (s *taskSchedule) rankOf(taskID string) int {
+	if s == nil {
+		return 0
+	}
+	return s.rank[taskID]
+}
+
+// sortLayer returns the layer in rank order so tasks are queued for slots in
+// the order they should start.
+func (s *taskSchedule) sortLayer(layer []TaskSpec) []TaskSpec {
+	out := append([]TaskSpec(nil), layer...)
+	sort.SliceStable(out, func(i, j int) bool { return s.rankOf(out[i].ID) < s.rankOf(out[j].ID) })
+	return out
+}
+
+// annotate copies critical path positions into the results.
+func (s *taskSchedule) annotate(results []TaskResult) {
+	if s == nil || s.criticalLen == 0 {
+		return
+	}
+	for i := range results {
+		if pos, ok := s.criticalPos[results[i].TaskID]; ok {
+			results[i].CriticalPathPos = pos
+			results[i].CriticalPathLen = s.criticalLen
+		}
+	}
+}
+
+func (s *taskSchedule) criticalDetail(taskID string) []string {
+	if s == nil || s.criticalLen == 0 {
+		return nil
+	}
+	if pos, ok := s.criticalPos[taskID]; ok {
+		return []string{fmt.Sprintf("critical=%d/%d", pos, s.criticalLen)}
+	}
+	return nil
+}
+
+// slotGate hands out worker slots, together with the task's backend/agent
+// scope slots, to queued tasks in rank order. A task whose scopes are full
+// is passed over so lower ranked tasks can still use free workers.
+type slotGate struct {
+	mu      sync.Mutex
+	limit   int // 0 means unlimited
+	active  int
+	scopes  *scopedLimiter
+	waiters []*slotWaiter // sorted by rank
+}
+
+type slotWaiter struct {
+	task          TaskSpec
+	rank          int
+	ready         chan struct{}
+	granted       bool
+	releaseScopes func()
+}
+
+func newSlotGate(limit int, scopes *scopedLimiter) *slotGate {
+	return &slotGate{limit: limit, scopes: scopes}
+}
+
+// enqueue registers a task for a slot. It must be called from the launching
+// goroutine in rank order so the queue reflects the schedule rather than
+// goroutine start order.
+func (g *slotGate) enqueue(task TaskSpec, rank int) *slotWaiter {
+	w := &slotWaiter{task: task, rank: rank, ready: make(chan struct{})}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pos := sort.Search(len(g.waiters), func(i int) bool { return g.waiters[i].rank > rank })
+	g.waiters = append(g.waiters, nil)
+	copy(g.waiters[pos+1:], g.waiters[pos:])
+	g.waiters[pos] = w
+	g.dispatchLocked()
+	return w
+}
+
+// wait blocks until the waiter is granted a slot. The returned release func
+// must be called once the task finishes.
+func (g *slotGate) wait(ctx context.Context, w *slotWaiter) (release func(), ok bool) {
+	select {
+	case <-w.ready:
+		return func() { g.release(w) }, true
+	case <-ctx.Done():
+		g.mu.Lock()
+		if w.granted {
+			g.mu.Unlock()
+			g.release(w)
+			return func() {}, false
+		}
+		for i, queued := range g.waiters {
+			if queued == w {
+				g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+				break
+			}
+		}
+		g.mu.Unlock()
+		return func() {}, false
+	}
+}
+
+func (g *slotGate) release(w *slotWaiter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if w.releaseScopes != nil {
+		w.releaseScopes()
+		w.releaseScopes = nil
+	}
+	g.active--
+	g.dispatchLocked()
+}
+
+func (g *slotGate) dispatchLocked() {
+	for i := 0; i < len(g.waiters); {
+		if g.limit > 0 && g.active >= g.limit {
+			return
+		}
+		w := g.waiters[i]
+		releaseScopes, ok := g.scopes.tryAcquire(w.task)
+		if !ok {
+			i++
+			continue
+		}
+		g.active++
+		w.granted = true
+		w.releaseScopes = releaseScopes
+		close(w.ready)
+		g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+	}
+}
+
+// durationStat is a moving average of successful task durations.
+type durationStat struct {
+	AvgMs   int64 `json:"avg_ms"`
+	Samples int   `json:"samples"`
+}
+
+// durationHistory maps "agent:<name>" or "backend:<name>" to past durations.
+type durationHistory map[string]durationStat
+
+func defaultDurationHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return ""
+	}
+	return filepath.Join(home, ".codeagent", "durations.json")
+}
+
+// durationKey groups history by agent when one is set, else by backend.
+func durationKey(task TaskSpec) string {
+	if agent := strings.TrimSpace(task.Agent); agent != "" {
+		return "agent:" + agent
+	}
+	backendName := strings.ToLower(strings.TrimSpace(task.Backend))
+	if backendName == "" {
+		backendName = defaultBackendName
+	}
+	return "backend:" + backendName
+}
+
+func (h durationHistory) estimate(task TaskSpec) (time.Duration, bool) {
+	stat, ok := h[durationKey(task)]
+	if !ok || stat.AvgMs <= 0 {
+		return 0, false
+	}
+	return time.Duration(stat.AvgMs) * time.Millisecond, true
+}
+
+func (h durationHistory) record(task TaskSpec, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	key := durationKey(task)
+	stat := h[key]
+	ms := elapsed.Milliseconds()
+	if stat.Samples == 0 || stat.AvgMs <= 0 {
+		stat.AvgMs = ms
+	} else {
+		stat.AvgMs = int64(float64(stat.AvgMs)*(1-durationHistoryWeight) + float64(ms)*durationHistoryWeight)
+	}
+	stat.Samples++
+	h[key] = stat
+}
+
+func loadDurationHistory() durationHistory {
+	history := durationHistory{}
+	path := durationHistoryPathFn()
+	if path == "" {
+		return history
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn(fmt.Sprintf("parallel: failed to read duration history %s: %v", path, err))
+		}
+		return history
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		logWarn(fmt.Sprintf("parallel: ignoring invalid duration history %s: %v", path, err))
+		return durationHistory{}
+	}
+	return history
+}
+
+func saveDurationHistory(history durationHistory) error {
+	path := durationHistoryPathFn()
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// A fixed temp name would let concurrent runs clobber each other's file
+	// before the rename.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".durations-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
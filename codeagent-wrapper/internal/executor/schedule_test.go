@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+func setScheduleMode(t *testing.T, mode string) string {
+	t.Helper()
+	historyPath := filepath.Join(t.TempDir(), "durations.json")
+	prevMode, prevPath := scheduleModeFn, durationHistoryPathFn
+	t.Cleanup(func() { scheduleModeFn, durationHistoryPathFn = prevMode, prevPath })
+	scheduleModeFn = func() string { return mode }
+	durationHistoryPathFn = func() string { return historyPath }
+	return historyPath
+}
+
+func TestParseParallelConfig_Priority(t *testing.T) {
+	cfg, err := ParseParallelConfig([]byte("---TASK---\nid: a\npriority: 5\n---CONTENT---\ndo a"))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	if cfg.Tasks[0].Priority != 5 {
+		t.Fatalf("priority = %d, want 5", cfg.Tasks[0].Priority)
+	}
+	if _, err := ParseParallelConfig([]byte("---TASK---\nid: a\npriority: high\n---CONTENT---\ndo a")); err == nil {
+		t.Fatal("expected error for non-integer priority")
+	}
+}
+
+func TestPlanSchedule_PriorityThenInputOrder(t *testing.T) {
+	layers := [][]TaskSpec{{{ID: "a"}, {ID: "b", Priority: 2}, {ID: "c"}, {ID: "d", Priority: 2}}}
+	s := planSchedule(layers, config.ScheduleInputOrder, nil)
+
+	var got []string
+	for _, task := range s.sortLayer(layers[0]) {
+		got = append(got, task.ID)
+	}
+	if strings.Join(got, ",") != "b,d,a,c" {
+		t.Fatalf("order = %v", got)
+	}
+	if s.criticalLen != 0 {
+		t.Fatalf("input order schedule should not mark a critical path")
+	}
+}
+
+func TestPlanSchedule_CriticalPathUsesHistory(t *testing.T) {
+	// short -> tail is two hops but "slow" alone takes longer per history.
+	layers := [][]TaskSpec{
+		{{ID: "short", Agent: "quick"}, {ID: "slow", Agent: "heavy"}},
+		{{ID: "tail", Agent: "quick", Dependencies: []string{"short"}}},
+	}
+	history := durationHistory{
+		"agent:quick": {AvgMs: 1000, Samples: 3},
+		"agent:heavy": {AvgMs: 10000, Samples: 3},
+	}
+	s := planSchedule(layers, config.ScheduleCriticalPath, history)
+	if s.rankOf("slow") != 0 {
+		t.Fatalf("slow task should rank first, ranks=%v", s.rank)
+	}
+	if s.criticalLen != 1 || s.criticalPos["slow"] != 1 {
+		t.Fatalf("critical path = %v (len %d)", s.criticalPos, s.criticalLen)
+	}
+
+	// Without history every task costs the same, so the longer chain wins.
+	s = planSchedule(layers, config.ScheduleCriticalPath, nil)
+	if s.rankOf("short") != 0 || s.criticalLen != 2 || s.criticalPos["tail"] != 2 {
+		t.Fatalf("unexpected schedule: ranks=%v path=%v", s.rank, s.criticalPos)
+	}
+}
+
+func TestExecuteConcurrent_CriticalPathFirst(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	historyPath := setScheduleMode(t, config.ScheduleCriticalPath)
+
+	layers := [][]TaskSpec{
+		{{ID: "leaf1"}, {ID: "leaf2"}, {ID: "root"}},
+		{{ID: "mid", Dependencies: []string{"root"}}},
+		{{ID: "end", Dependencies: []string{"mid"}}},
+	}
+
+	var mu sync.Mutex
+	var started []string
+	runTask := func(ts TaskSpec, timeout int) TaskResult {
+		mu.Lock()
+		started = append(started, ts.ID)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		return TaskResult{TaskID: ts.ID}
+	}
+
+	results := ExecuteConcurrentWithContext(context.Background(), layers, 10, 1, runTask)
+	if len(started) != 5 || started[0] != "root" {
+		t.Fatalf("critical path task should start first, got %v", started)
+	}
+	for _, res := range results {
+		if res.TaskID == "end" && (res.CriticalPathPos != 3 || res.CriticalPathLen != 3) {
+			t.Fatalf("end critical path = %d/%d", res.CriticalPathPos, res.CriticalPathLen)
+		}
+		if res.TaskID == "leaf1" && res.CriticalPathPos != 0 {
+			t.Fatalf("leaf1 should not be on the critical path")
+		}
+	}
+
+	if _, err := os.Stat(historyPath); err != nil {
+		t.Fatalf("expected duration history to be saved: %v", err)
+	}
+	history := loadDurationHistory()
+	if stat := history["backend:codex"]; stat.Samples != 5 || stat.AvgMs <= 0 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	out := GenerateFinalOutput(results)
+	if !strings.Contains(out, "Critical path: 3/3") {
+		t.Fatalf("report missing critical path position:\n%s", out)
+	}
+}
+
+func TestDurationHistoryRecord(t *testing.T) {
+	h := durationHistory{}
+	task := TaskSpec{Backend: "Claude"}
+	h.record(task, 10*time.Second)
+	h.record(task, 20*time.Second)
+	stat := h["backend:claude"]
+	if stat.Samples != 2 || stat.AvgMs != 13000 {
+		t.Fatalf("unexpected stat: %+v", stat)
+	}
+	if d, ok := h.estimate(task); !ok || d != 13*time.Second {
+		t.Fatalf("estimate = %v, %v", d, ok)
+	}
+}
+
+func TestSaveDurationHistory_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "durations.json")
+	orig := durationHistoryPathFn
+	durationHistoryPathFn = func() string { return path }
+	t.Cleanup(func() { durationHistoryPathFn = orig })
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h := durationHistory{}
+			h.record(TaskSpec{Backend: "codex"}, time.Duration(i+1)*time.Second)
+			errs <- saveDurationHistory(h)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("saveDurationHistory: %v", err)
+		}
+	}
+	if h := loadDurationHistory(); h["backend:codex"].Samples != 1 {
+		t.Fatalf("history = %+v", h)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("leftover temp files: %v", entries)
+	}
+}
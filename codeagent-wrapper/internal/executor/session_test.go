@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+	session "codeagent-wrapper/internal/session"
+)
+
+func TestMain(m *testing.M) {
+	// Keep fake backend sessions out of the real ~/.codeagent/sessions.
+	recordSessionFn = func(session.Entry) error { return nil }
+	os.Exit(m.Run())
+}
+
+func TestRecordTaskSession(t *testing.T) {
+	var got session.Entry
+	restore := SetRecordSessionFn(func(e session.Entry) error {
+		got = e
+		return nil
+	})
+	defer restore()
+
+	worktree := t.TempDir()
+	recordTaskSession(TaskSpec{ID: "t1", Agent: "develop"}, &Config{Backend: "claude", Model: "opus"}, ".", worktree, TaskResult{SessionID: "sess-1"})
+
+	wd, _ := os.Getwd()
+	want := session.Entry{SessionID: "sess-1", Backend: "claude", Model: "opus", Agent: "develop", WorkDir: wd, Worktree: worktree, TaskID: "t1"}
+	if got != want {
+		t.Fatalf("recorded %+v, want %+v", got, want)
+	}
+}
+
+func TestParseParallelConfig_ResumeUsesRecordedSession(t *testing.T) {
+	home := t.TempDir()
+	setTestHome(t, home)
+	config.ResetModelsConfigCacheForTest()
+	defer config.ResetModelsConfigCacheForTest()
+
+	workDir := filepath.Join(home, "repo")
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Record(session.Entry{SessionID: "sess-1", Backend: "claude", Model: "opus", WorkDir: workDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	input := `---TASK---
+id: recorded
+session_id: sess-1
+---CONTENT---
+continue
+---TASK---
+id: explicit
+session_id: sess-1
+backend: gemini
+workdir: /tmp
+---CONTENT---
+continue
+---TASK---
+id: unknown
+session_id: sess-2
+---CONTENT---
+continue`
+	cfg, err := ParseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	recorded, explicit, unknown := cfg.Tasks[0], cfg.Tasks[1], cfg.Tasks[2]
+	if recorded.Backend != "claude" || recorded.Model != "opus" || recorded.WorkDir != workDir || recorded.HandoffBackend != "" {
+		t.Fatalf("recorded task = %+v", recorded)
+	}
+	if explicit.Backend != "gemini" || explicit.Model != "" || explicit.WorkDir != "/tmp" || explicit.HandoffBackend != "claude" {
+		t.Fatalf("explicit task = %+v", explicit)
+	}
+	if unknown.Backend != "" || unknown.WorkDir != defaultWorkdir {
+		t.Fatalf("unknown task = %+v", unknown)
+	}
+}
+
+func TestParseParallelConfig_ResumeUnrecordedSessionHandsOff(t *testing.T) {
+	setTestHome(t, t.TempDir())
+	old := locateSessionFn
+	t.Cleanup(func() { locateSessionFn = old })
+	locateSessionFn = func(id string) []string {
+		if id == "recorded" {
+			t.Errorf("located a session the registry already knows")
+		}
+		if id == "codex-sess" {
+			return []string{"codex"}
+		}
+		return nil
+	}
+
+	input := `---TASK---
+id: handoff
+session_id: codex-sess
+backend: claude
+---CONTENT---
+continue
+---TASK---
+id: owner
+session_id: codex-sess
+backend: codex
+---CONTENT---
+continue
+---TASK---
+id: nowhere
+session_id: lost-sess
+backend: claude
+---CONTENT---
+continue
+---TASK---
+id: known
+session_id: recorded
+backend: claude
+---CONTENT---
+continue`
+	if err := session.Record(session.Entry{SessionID: "recorded", Backend: "claude"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ParseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	for i, want := range []string{"codex", "", "", ""} {
+		if got := cfg.Tasks[i].HandoffBackend; got != want {
+			t.Errorf("%s: HandoffBackend = %q, want %q", cfg.Tasks[i].ID, got, want)
+		}
+	}
+}
+
+func TestParseParallelConfig_ResumeRestoresRecordedAgent(t *testing.T) {
+	home := t.TempDir()
+	setTestHome(t, home)
+	config.ResetModelsConfigCacheForTest()
+	defer config.ResetModelsConfigCacheForTest()
+
+	if err := os.MkdirAll(filepath.Join(home, ".codeagent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	models := `{"agents": {"develop": {"backend": "claude", "model": "sonnet", "allowed_tools": ["Read"]}, "other": {"backend": "claude", "model": "haiku"}}}`
+	if err := os.WriteFile(filepath.Join(home, ".codeagent", "models.json"), []byte(models), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []session.Entry{
+		{SessionID: "sess-1", Backend: "claude", Model: "opus", Agent: "develop"},
+		{SessionID: "sess-2", Backend: "codex", Agent: "removed"},
+	} {
+		if err := session.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input := `---TASK---
+id: recorded
+session_id: sess-1
+---CONTENT---
+continue
+---TASK---
+id: override
+session_id: sess-1
+agent: other
+---CONTENT---
+continue
+---TASK---
+id: stale
+session_id: sess-2
+---CONTENT---
+continue`
+	cfg, err := ParseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseParallelConfig: %v", err)
+	}
+	recorded, override, stale := cfg.Tasks[0], cfg.Tasks[1], cfg.Tasks[2]
+	if recorded.Agent != "develop" || recorded.Model != "opus" || len(recorded.AllowedTools) != 1 {
+		t.Fatalf("recorded task = %+v", recorded)
+	}
+	if override.Agent != "other" || len(override.AllowedTools) != 0 {
+		t.Fatalf("override task = %+v", override)
+	}
+	if stale.Agent != "" || stale.Backend != "codex" {
+		t.Fatalf("stale task = %+v", stale)
+	}
+}
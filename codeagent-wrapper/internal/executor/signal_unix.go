@@ -4,6 +4,7 @@
 package executor
 
 import (
+	"os"
 	"syscall"
 )
 
@@ -14,3 +15,12 @@ func sendTermSignal(proc processHandle) error {
 	}
 	return proc.Signal(syscall.SIGTERM)
 }
+
+// signalGroup sends sig to every process in process group pgid.
+func signalGroup(pgid int, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return syscall.EINVAL
+	}
+	return syscall.Kill(-pgid, s)
+}
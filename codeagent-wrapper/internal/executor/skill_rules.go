@@ -0,0 +1,383 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	skillRulesFileName     = "skill-rules.json"
+	maxSkillDetectReadSize = 1 << 20 // bytes of a file read for content_regex
+
+	SkillRuleSourceFrontmatter = "SKILL.md frontmatter"
+	SkillRuleSourceRulesFile   = skillRulesFileName
+	SkillRuleSourceBuiltin     = "built-in"
+)
+
+// SkillDetectRule decides whether a skill applies to a workdir. It matches
+// when any of Files exists in the workdir or any of Globs matches there;
+// with ContentRegex set, one of those files must also contain a match.
+type SkillDetectRule struct {
+	Files        []string `json:"files,omitempty" yaml:"files"`
+	Globs        []string `json:"globs,omitempty" yaml:"globs"`
+	ContentRegex string   `json:"content_regex,omitempty" yaml:"content_regex"`
+}
+
+// builtinSkillRules apply to skills that declare no rule of their own.
+// Their order is the order detected skills are injected in.
+var builtinSkillRules = []struct {
+	Skill string
+	Rule  SkillDetectRule
+}{
+	{"golang-base-practices", SkillDetectRule{Files: []string{"go.mod", "go.sum"}}},
+	{"rust-best-practices", SkillDetectRule{Files: []string{"Cargo.toml"}}},
+	{"python-best-practices", SkillDetectRule{Files: []string{"pyproject.toml", "setup.py", "requirements.txt", "Pipfile"}}},
+	{"vercel-react-best-practices", SkillDetectRule{Files: []string{"package.json"}, ContentRegex: `"(react|next)"\s*:`}},
+	{"frontend-design", SkillDetectRule{Files: []string{"package.json"}, ContentRegex: `"(react|next|vue|nuxt|svelte|@angular/core)"\s*:`}},
+	{"vue-web-app", SkillDetectRule{Files: []string{"package.json"}, ContentRegex: `"(vue|nuxt)"\s*:`}},
+}
+
+// SkillMatch is a skill whose detection rule matched a workdir.
+type SkillMatch struct {
+	Skill  string
+	Path   string // SKILL.md to inject; empty when the skill is not installed
+	Source string // SkillRuleSource* naming where the rule came from
+	Reason string // what matched, e.g. `package.json =~ "(react|next)"\s*:`
+}
+
+type skillRule struct {
+	rule   SkillDetectRule
+	source string
+	origin string // file the rule was read from
+}
+
+// skillRoots returns the skill directories to search, most specific first:
+// the nearest .claude/skills above workDir, then ~/.claude/skills.
+func skillRoots(workDir string) []string {
+	var roots []string
+	homeRoot := ""
+	if home, err := os.UserHomeDir(); err == nil && strings.TrimSpace(home) != "" {
+		homeRoot = filepath.Join(home, ".claude", "skills")
+	}
+
+	if strings.TrimSpace(workDir) != "" {
+		if dir, err := filepath.Abs(workDir); err == nil {
+			for {
+				candidate := filepath.Join(dir, ".claude", "skills")
+				if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+					if !sameSkillRoot(candidate, homeRoot) {
+						roots = append(roots, candidate)
+					}
+					break
+				}
+				parent := filepath.Dir(dir)
+				if parent == dir {
+					break
+				}
+				dir = parent
+			}
+		}
+	}
+	if homeRoot != "" {
+		roots = append(roots, homeRoot)
+	}
+	return roots
+}
+
+func sameSkillRoot(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ia, errA := os.Stat(a)
+	ib, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ia, ib)
+}
+
+// findSkillFile returns the first SKILL.md for name across roots.
+func findSkillFile(roots []string, name string) string {
+	for _, root := range roots {
+		path := filepath.Join(root, name, "SKILL.md")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadSkillRules collects detection rules from every root. A root's
+// skill-rules.json overrides its SKILL.md frontmatter, and earlier roots
+// override later ones. names lists the skills in the order their rules are
+// first declared: root by root, skill-rules.json in file order, then the
+// SKILL.md files in directory order.
+func loadSkillRules(roots []string) (names []string, rules map[string]skillRule) {
+	rules = make(map[string]skillRule)
+	for _, root := range roots {
+		var rootNames []string
+		rootRules := make(map[string]skillRule)
+
+		entries, _ := os.ReadDir(root)
+		for _, entry := range entries {
+			if !entry.IsDir() || !validSkillName.MatchString(entry.Name()) {
+				continue
+			}
+			path := filepath.Join(root, entry.Name(), "SKILL.md")
+			rule, err := readFrontmatterDetectRule(path)
+			if err != nil {
+				logWarn(fmt.Sprintf("skill %q: ignoring detect rule in %s: %v", entry.Name(), path, err))
+				continue
+			}
+			if rule != nil {
+				rootRules[entry.Name()] = skillRule{rule: *rule, source: SkillRuleSourceFrontmatter, origin: path}
+			}
+		}
+
+		rulesPath := filepath.Join(root, skillRulesFileName)
+		fileNames, fileRules, err := readSkillRulesFile(rulesPath)
+		if err != nil {
+			logWarn(fmt.Sprintf("ignoring %s: %v", rulesPath, err))
+		}
+		for _, name := range fileNames {
+			rootRules[name] = skillRule{rule: fileRules[name], source: SkillRuleSourceRulesFile, origin: rulesPath}
+		}
+		rootNames = append(rootNames, fileNames...)
+		for _, entry := range entries {
+			name := entry.Name()
+			if _, inFile := fileRules[name]; !inFile && rootRules[name].origin != "" {
+				rootNames = append(rootNames, name)
+			}
+		}
+
+		for _, name := range rootNames {
+			if _, ok := rules[name]; !ok {
+				rules[name] = rootRules[name]
+				names = append(names, name)
+			}
+		}
+	}
+	return names, rules
+}
+
+// readFrontmatterDetectRule returns the "detect" block of a SKILL.md YAML
+// frontmatter, or nil when there is none.
+func readFrontmatterDetectRule(path string) (*SkillDetectRule, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- SKILL.md under a skills root
+	if err != nil {
+		return nil, nil
+	}
+	frontmatter, ok := extractYAMLFrontmatter(string(data))
+	if !ok || !strings.Contains(frontmatter, "detect") {
+		return nil, nil
+	}
+	var meta struct {
+		Detect *SkillDetectRule `yaml:"detect"`
+	}
+	if err := yaml.Unmarshal([]byte(frontmatter), &meta); err != nil {
+		return nil, err
+	}
+	return meta.Detect, nil
+}
+
+func extractYAMLFrontmatter(s string) (string, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n")
+	if !strings.HasPrefix(s, "---\n") {
+		return "", false
+	}
+	end := strings.Index(s[4:], "\n---")
+	if end < 0 {
+		return "", false
+	}
+	return s[4 : 4+end], true
+}
+
+// readSkillRulesFile reads skills.<name>.detect entries from a
+// skill-rules.json, with the skill names in file order; skills without
+// "detect" are ignored.
+func readSkillRulesFile(path string) ([]string, map[string]SkillDetectRule, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- fixed name under a skills root
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var file struct {
+		Skills json.RawMessage `json:"skills"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, err
+	}
+	if len(file.Skills) == 0 || string(file.Skills) == "null" {
+		return nil, nil, nil
+	}
+
+	// A map would lose the order the skills are declared in.
+	dec := json.NewDecoder(bytes.NewReader(file.Skills))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("skills must be an object")
+	}
+	var names []string
+	rules := make(map[string]SkillDetectRule)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		name, _ := tok.(string)
+		var skill struct {
+			Detect *SkillDetectRule `json:"detect"`
+		}
+		if err := dec.Decode(&skill); err != nil {
+			return nil, nil, err
+		}
+		if skill.Detect == nil || !validSkillName.MatchString(name) {
+			continue
+		}
+		if _, ok := rules[name]; !ok {
+			names = append(names, name)
+		}
+		rules[name] = *skill.Detect
+	}
+	return names, rules, nil
+}
+
+// evaluate reports whether the rule matches workDir and, if so, why.
+func (r SkillDetectRule) evaluate(workDir string) (string, bool, error) {
+	var candidates []string
+	for _, f := range r.Files {
+		if !safeRelativePattern(f) {
+			return "", false, fmt.Errorf("files entry %q must be a relative path inside the workdir", f)
+		}
+		if info, err := os.Stat(filepath.Join(workDir, f)); err == nil && !info.IsDir() {
+			candidates = append(candidates, filepath.Clean(f))
+		}
+	}
+	for _, g := range r.Globs {
+		if !safeRelativePattern(g) {
+			return "", false, fmt.Errorf("globs entry %q must be a relative pattern inside the workdir", g)
+		}
+		matches, err := filepath.Glob(filepath.Join(workDir, g))
+		if err != nil {
+			return "", false, fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+		for _, m := range matches {
+			if rel, err := filepath.Rel(workDir, m); err == nil {
+				candidates = append(candidates, rel)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		if len(r.Files) == 0 && len(r.Globs) == 0 {
+			return "", false, fmt.Errorf("rule needs files or globs")
+		}
+		return "", false, nil
+	}
+	if strings.TrimSpace(r.ContentRegex) == "" {
+		return candidates[0], true, nil
+	}
+
+	re, err := regexp.Compile(r.ContentRegex)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid content_regex: %w", err)
+	}
+	for _, rel := range candidates {
+		if fileMatchesRegex(filepath.Join(workDir, rel), re) {
+			return fmt.Sprintf("%s =~ %s", rel, r.ContentRegex), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func safeRelativePattern(p string) bool {
+	p = strings.TrimSpace(p)
+	if p == "" || filepath.IsAbs(p) {
+		return false
+	}
+	cleaned := filepath.Clean(p)
+	return cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(os.PathSeparator))
+}
+
+func fileMatchesRegex(path string, re *regexp.Regexp) bool {
+	f, err := os.Open(path) // #nosec G304 -- file inside the task workdir named by a detect rule
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxSkillDetectReadSize))
+	return err == nil && re.Match(data)
+}
+
+// MatchProjectSkills evaluates every known detection rule against workDir,
+// whether or not the skill is installed. Skills that declare a rule in
+// SKILL.md frontmatter or skill-rules.json use it; the rest fall back to
+// the built-in rules. Results keep the built-in skills' order, followed by
+// the other skills in the order their rules are declared (see
+// loadSkillRules).
+func MatchProjectSkills(workDir string) []SkillMatch {
+	roots := skillRoots(workDir)
+	declared, rules := loadSkillRules(roots)
+	names := make([]string, 0, len(builtinSkillRules)+len(declared))
+	for _, b := range builtinSkillRules {
+		if _, ok := rules[b.Skill]; !ok {
+			rules[b.Skill] = skillRule{rule: b.Rule, source: SkillRuleSourceBuiltin}
+		}
+		names = append(names, b.Skill)
+	}
+	for _, name := range declared {
+		if !isBuiltinSkill(name) {
+			names = append(names, name)
+		}
+	}
+
+	var matched []SkillMatch
+	for _, name := range names {
+		rule := rules[name]
+		reason, ok, err := rule.rule.evaluate(workDir)
+		if err != nil {
+			logWarn(fmt.Sprintf("skill %q: invalid detect rule in %s: %v", name, valueOrBuiltin(rule.origin), err))
+			continue
+		}
+		if ok {
+			matched = append(matched, SkillMatch{Skill: name, Path: findSkillFile(roots, name), Source: rule.source, Reason: reason})
+		}
+	}
+	return matched
+}
+
+func isBuiltinSkill(name string) bool {
+	for _, b := range builtinSkillRules {
+		if b.Skill == name {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOrBuiltin(origin string) string {
+	if origin == "" {
+		return SkillRuleSourceBuiltin + " rules"
+	}
+	return origin
+}
+
+// DetectProjectSkills returns the skills whose detection rules match workDir
+// and that are installed in .claude/skills or ~/.claude/skills, logging the
+// rule behind each one.
+func DetectProjectSkills(workDir string) []string {
+	var detected []string
+	for _, m := range MatchProjectSkills(workDir) {
+		if m.Path == "" {
+			continue
+		}
+		logInfo(fmt.Sprintf("Skill %s detected by %s rule: %s", m.Skill, m.Source, m.Reason))
+		detected = append(detected, m.Skill)
+	}
+	return detected
+}
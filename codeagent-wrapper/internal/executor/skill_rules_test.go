@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeSkillFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func matchesByName(matches []SkillMatch) map[string]SkillMatch {
+	byName := make(map[string]SkillMatch, len(matches))
+	for _, m := range matches {
+		byName[m.Skill] = m
+	}
+	return byName
+}
+
+func TestMatchProjectSkills_BuiltinNodeRequiresFramework(t *testing.T) {
+	setTestHome(t, t.TempDir())
+
+	plain := t.TempDir()
+	writeSkillFiles(t, plain, map[string]string{"package.json": `{"dependencies": {"express": "^4"}}`})
+	if got := MatchProjectSkills(plain); len(got) != 0 {
+		t.Fatalf("plain Node project should not match frontend skills, got %+v", got)
+	}
+
+	react := t.TempDir()
+	writeSkillFiles(t, react, map[string]string{"package.json": `{"dependencies": {"react": "^18"}}`, "go.mod": "module x"})
+	matches := MatchProjectSkills(react)
+	var order []string
+	for _, m := range matches {
+		order = append(order, m.Skill)
+	}
+	// Built-in skills keep their declared order, not alphabetical order.
+	if want := []string{"golang-base-practices", "vercel-react-best-practices", "frontend-design"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	byName := matchesByName(matches)
+	for _, skill := range []string{"vercel-react-best-practices", "frontend-design", "golang-base-practices"} {
+		m, ok := byName[skill]
+		if !ok || m.Source != SkillRuleSourceBuiltin || m.Path != "" {
+			t.Fatalf("%s: got %+v (ok=%v)", skill, m, ok)
+		}
+	}
+	if _, ok := byName["vue-web-app"]; ok {
+		t.Fatalf("React project should not match vue-web-app")
+	}
+	if r := byName["vercel-react-best-practices"].Reason; !strings.HasPrefix(r, "package.json =~ ") {
+		t.Fatalf("reason = %q", r)
+	}
+}
+
+func TestMatchProjectSkills_FrontmatterAndRulesFile(t *testing.T) {
+	home := t.TempDir()
+	setTestHome(t, home)
+	homeSkills := filepath.Join(home, ".claude", "skills")
+	writeSkillFiles(t, homeSkills, map[string]string{
+		"terraform/SKILL.md":       "---\nname: terraform\ndetect:\n  globs: [\"*.tf\"]\n---\n# Terraform",
+		"django/SKILL.md":          "---\ndetect:\n  files: [requirements.txt]\n  content_regex: '(?i)^django'\n---\n# Django",
+		"no-rule/SKILL.md":         "# No frontmatter",
+		"broken/SKILL.md":          "---\ndetect: [unclosed\n---\n",
+		"frontend-design/SKILL.md": "---\ndetect:\n  files: [index.html]\n---\n# Frontend",
+		"overridden/SKILL.md":      "---\ndetect:\n  files: [never-present]\n---\n",
+		skillRulesFileName: `{"skills": {
+  "overridden": {"detect": {"files": ["Makefile"]}},
+  "codex": {"promptTriggers": {"keywords": ["refactor"]}},
+  "escape": {"detect": {"files": ["../outside"]}}
+}}`,
+	})
+
+	workDir := t.TempDir()
+	writeSkillFiles(t, workDir, map[string]string{
+		"main.tf":          "",
+		"requirements.txt": "Django==5.0\n",
+		"Makefile":         "all:",
+		"package.json":     `{"dependencies": {"react": "18"}}`,
+	})
+
+	byName := matchesByName(MatchProjectSkills(workDir))
+	want := map[string]string{
+		"terraform":  SkillRuleSourceFrontmatter,
+		"django":     SkillRuleSourceFrontmatter,
+		"overridden": SkillRuleSourceRulesFile,
+		// Built-in rule still applies to a skill without its own rule.
+		"vercel-react-best-practices": SkillRuleSourceBuiltin,
+	}
+	for skill, source := range want {
+		if m, ok := byName[skill]; !ok || m.Source != source {
+			t.Errorf("%s: got %+v, want source %q", skill, m, source)
+		}
+	}
+	if m := byName["terraform"]; m.Reason != "main.tf" || m.Path != filepath.Join(homeSkills, "terraform", "SKILL.md") {
+		t.Errorf("terraform match = %+v", m)
+	}
+	// frontend-design declares its own rule, which replaces the built-in one.
+	for _, skill := range []string{"frontend-design", "no-rule", "broken", "codex", "escape"} {
+		if _, ok := byName[skill]; ok {
+			t.Errorf("%s should not match", skill)
+		}
+	}
+
+	// skill-rules.json entries come first, in file order, then SKILL.md
+	// rules in directory order.
+	detected := DetectProjectSkills(workDir)
+	if !reflect.DeepEqual(detected, []string{"overridden", "django", "terraform"}) {
+		t.Fatalf("DetectProjectSkills = %v", detected)
+	}
+}
+
+func TestSkillRoots_ProjectLocalTakesPrecedence(t *testing.T) {
+	home := t.TempDir()
+	setTestHome(t, home)
+	writeSkillFiles(t, filepath.Join(home, ".claude", "skills"), map[string]string{
+		"golang-base-practices/SKILL.md": "# home go",
+		"shared/SKILL.md":                "# home shared",
+	})
+
+	project := t.TempDir()
+	projectSkills := filepath.Join(project, ".claude", "skills")
+	writeSkillFiles(t, projectSkills, map[string]string{
+		"golang-base-practices/SKILL.md": "---\ndetect:\n  files: [go.work]\n---\n# project go",
+	})
+	writeSkillFiles(t, project, map[string]string{"go.work": "go 1.21", "svc/go.mod": "module svc"})
+	workDir := filepath.Join(project, "svc")
+
+	roots := skillRoots(workDir)
+	if len(roots) != 2 || roots[0] != projectSkills {
+		t.Fatalf("skillRoots = %v", roots)
+	}
+
+	// The project rule (go.work) replaces the built-in one (go.mod), and is
+	// evaluated against workDir, where go.work does not exist.
+	if got := DetectProjectSkills(workDir); len(got) != 0 {
+		t.Fatalf("DetectProjectSkills(svc) = %v", got)
+	}
+	byName := matchesByName(MatchProjectSkills(project))
+	if m := byName["golang-base-practices"]; m.Source != SkillRuleSourceFrontmatter || m.Path != filepath.Join(projectSkills, "golang-base-practices", "SKILL.md") {
+		t.Fatalf("project match = %+v", m)
+	}
+
+	content := ResolveSkillContent(workDir, "", []string{"golang-base-practices", "shared"}, 0)
+	if !strings.Contains(content, "# project go") || !strings.Contains(content, "# home shared") {
+		t.Fatalf("ResolveSkillContent = %q", content)
+	}
+}
@@ -0,0 +1,258 @@
+package executor
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 parameters (the usual defaults).
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// skillDocSection is one heading-delimited part of a SKILL.md body.
+type skillDocSection struct {
+	skill  int // index into the requested skills
+	index  int // position within the skill
+	title  string
+	text   string
+	tokens int
+	terms  []string
+	score  float64
+}
+
+// estimateTokens approximates a tokenizer: about four ASCII characters per
+// token, and one token per non-ASCII rune (CJK text is roughly that dense).
+func estimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// splitSkillSections splits a SKILL.md body at Markdown headings, ignoring
+// "#" lines inside fenced code blocks. Text before the first heading is the
+// "(intro)" section.
+func splitSkillSections(body string) []skillDocSection {
+	var sections []skillDocSection
+	var cur []string
+	title := "(intro)"
+	inFence := false
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(cur, "\n"))
+		if text != "" {
+			sections = append(sections, skillDocSection{title: title, text: text})
+		}
+		cur = nil
+	}
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence && isMarkdownHeading(trimmed) {
+			flush()
+			title = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		}
+		cur = append(cur, line)
+	}
+	flush()
+	return sections
+}
+
+func isMarkdownHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	return level >= 1 && level <= 6 && (level == len(line) || line[level] == ' ')
+}
+
+// splitOversizedSection breaks a section that alone exceeds maxTokens at
+// blank lines, so it can still be included in part without cutting text
+// mid-paragraph.
+func splitOversizedSection(s skillDocSection, maxTokens int) []skillDocSection {
+	if s.tokens <= maxTokens {
+		return []skillDocSection{s}
+	}
+	var parts []skillDocSection
+	var cur []string
+	curTokens := 0
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+		text := strings.Join(cur, "\n\n")
+		part := skillDocSection{skill: s.skill, title: s.title, text: text, tokens: estimateTokens(text)}
+		if len(parts) > 0 {
+			part.title = s.title + " (cont.)"
+		}
+		parts = append(parts, part)
+		cur, curTokens = nil, 0
+	}
+	for _, para := range strings.Split(s.text, "\n\n") {
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		t := estimateTokens(para)
+		if curTokens > 0 && curTokens+t > maxTokens {
+			flush()
+		}
+		cur = append(cur, para)
+		curTokens += t
+	}
+	flush()
+	return parts
+}
+
+var bm25StopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"are": true, "was": true, "you": true, "your": true, "from": true, "into": true,
+	"use": true, "all": true, "any": true, "not": true, "but": true, "can": true,
+	"should": true, "must": true, "will": true, "when": true, "then": true, "than": true,
+	"has": true, "have": true, "its": true, "our": true, "out": true, "make": true,
+	"to": true, "of": true, "in": true, "on": true, "is": true, "it": true, "be": true,
+	"as": true, "at": true, "by": true, "or": true, "an": true, "if": true, "do": true,
+}
+
+// tokenizeForBM25 lowercases text, splits it into letter/digit runs, drops
+// stop words and a plural "s", and turns Han runs into bigrams.
+func tokenizeForBM25(text string) []string {
+	var terms []string
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) >= 2 {
+			w := string(word)
+			if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+				w = w[:len(w)-1]
+			}
+			if !bm25StopWords[w] {
+				terms = append(terms, w)
+			}
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			terms = append(terms, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			terms = append(terms, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return terms
+}
+
+// scoreSectionsBM25 scores every section against query with Okapi BM25,
+// treating the sections of all requested skills as one corpus.
+func scoreSectionsBM25(sections []skillDocSection, query string) {
+	queryTerms := make(map[string]bool)
+	for _, term := range tokenizeForBM25(query) {
+		queryTerms[term] = true
+	}
+	if len(queryTerms) == 0 || len(sections) == 0 {
+		return
+	}
+
+	docFreq := make(map[string]int)
+	totalLen := 0
+	for i := range sections {
+		sections[i].terms = tokenizeForBM25(sections[i].text)
+		totalLen += len(sections[i].terms)
+		seen := make(map[string]bool)
+		for _, term := range sections[i].terms {
+			if queryTerms[term] && !seen[term] {
+				seen[term] = true
+				docFreq[term]++
+			}
+		}
+	}
+	avgLen := float64(totalLen) / float64(len(sections))
+	if avgLen == 0 {
+		return
+	}
+
+	n := float64(len(sections))
+	for i := range sections {
+		tf := make(map[string]int)
+		for _, term := range sections[i].terms {
+			if queryTerms[term] {
+				tf[term]++
+			}
+		}
+		dl := float64(len(sections[i].terms))
+		score := 0.0
+		for term, f := range tf {
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			freq := float64(f)
+			score += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*dl/avgLen))
+		}
+		sections[i].score = score
+	}
+}
+
+// packSkillSections picks sections in descending score (document order on
+// ties, so an unrelated task keeps the skills' own order) while they fit in
+// budget tokens, charging each skill's <skill> tags once. It returns the
+// chosen flags parallel to sections.
+func packSkillSections(sections []skillDocSection, tagTokens []int, budget int) []bool {
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := sections[order[a]], sections[order[b]]
+		if sa.score != sb.score {
+			return sa.score > sb.score
+		}
+		if sa.skill != sb.skill {
+			return sa.skill < sb.skill
+		}
+		return sa.index < sb.index
+	})
+
+	chosen := make([]bool, len(sections))
+	opened := make(map[int]bool)
+	remaining := budget
+	for _, i := range order {
+		s := sections[i]
+		cost := s.tokens + 1 // separator
+		if !opened[s.skill] {
+			cost += tagTokens[s.skill]
+		}
+		if cost > remaining {
+			continue
+		}
+		remaining -= cost
+		opened[s.skill] = true
+		chosen[i] = true
+	}
+	return chosen
+}
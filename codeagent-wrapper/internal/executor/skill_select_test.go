@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sectionTitles(sections []skillDocSection) []string {
+	titles := make([]string, len(sections))
+	for i, s := range sections {
+		titles[i] = s.title
+	}
+	return titles
+}
+
+func TestSplitSkillSections_IgnoresFencedHeadings(t *testing.T) {
+	body := "Intro line.\n\n# Setup\nRun it.\n```sh\n# not a heading\n```\n## Testing\nUse go test.\n#hashtag is text"
+	sections := splitSkillSections(body)
+	if got, want := sectionTitles(sections), []string{"(intro)", "Setup", "Testing"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("titles = %v, want %v", got, want)
+	}
+	if !strings.Contains(sections[1].text, "# not a heading") || !strings.HasSuffix(sections[2].text, "#hashtag is text") {
+		t.Fatalf("unexpected section text: %q / %q", sections[1].text, sections[2].text)
+	}
+}
+
+func TestSplitOversizedSection_BreaksAtParagraphs(t *testing.T) {
+	para := strings.Repeat("y", 80) // 20 tokens
+	s := skillDocSection{title: "Long", text: para + "\n\n" + para + "\n\n" + para}
+	s.tokens = estimateTokens(s.text)
+	parts := splitOversizedSection(s, 45)
+	if got, want := sectionTitles(parts), []string{"Long", "Long (cont.)"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("titles = %v, want %v", got, want)
+	}
+	if parts[0].text != para+"\n\n"+para || parts[1].text != para {
+		t.Fatalf("unexpected split: %+v", parts)
+	}
+}
+
+func TestTokenizeForBM25(t *testing.T) {
+	got := tokenizeForBM25("Write the Tests for a database migration 数据库迁移")
+	want := []string{"write", "test", "database", "migration", "数据", "据库", "库迁", "迁移"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tokenizeForBM25 = %v, want %v", got, want)
+	}
+	if est := estimateTokens("abcdefgh数据"); est != 4 {
+		t.Fatalf("estimateTokens = %d, want 4", est)
+	}
+}
+
+func TestResolveSkillSections_PrefersRelevantSections(t *testing.T) {
+	filler := strings.Repeat("General guidance about style and naming. ", 10)
+	body := "# Go\n\n" + filler +
+		"\n\n## Error handling\n\nWrap errors with context. " + filler +
+		"\n\n## Database migrations\n\nRun migrations in a transaction and keep them reversible. Test each migration." +
+		"\n\n## Concurrency\n\nUse contexts for cancellation. " + filler
+	home := createTempSkill(t, "go-skill", body)
+	setTestHome(t, home)
+
+	sections := resolveSkillSections("", "add a database migration for the users table", []string{"go-skill"}, 150)
+	if len(sections) != 1 {
+		t.Fatalf("expected one skill, got %+v", sections)
+	}
+	got := sections[0]
+	if !reflect.DeepEqual(got.included, []string{"Go", "Database migrations"}) {
+		t.Fatalf("included = %v, want the migrations section plus what still fits", got.included)
+	}
+	if len(got.dropped) == 0 || got.tokens > 150 {
+		t.Fatalf("expected dropped sections within budget, got tokens=%d dropped=%v", got.tokens, got.dropped)
+	}
+	if !strings.Contains(got.content, "reversible. Test each migration.\n</skill>") {
+		t.Fatalf("section should be injected whole: %q", got.content)
+	}
+
+	// Without task terms, sections keep document order.
+	sections = resolveSkillSections("", "", []string{"go-skill"}, 150)
+	if len(sections) != 1 || sections[0].included[0] != "Go" {
+		t.Fatalf("unexpected selection without task: %+v", sections)
+	}
+}
+
+func TestResolveSkillSections_AcrossSkills(t *testing.T) {
+	home := createTempSkill(t, "frontend", "# Frontend\n\nComponents and CSS layout.\n\n## Accessibility\n\nAdd aria labels to buttons.")
+	setTestHome(t, home)
+	writeSkillFiles(t, filepath.Join(home, ".claude", "skills"), map[string]string{
+		"backend/SKILL.md": "# Backend\n\nHTTP handlers and SQL queries.\n\n## Caching\n\nCache SQL query results in Redis.",
+	})
+
+	sections := resolveSkillSections("", "cache the sql queries", []string{"frontend", "backend"}, 40)
+	if len(sections) != 1 || sections[0].name != "backend" {
+		t.Fatalf("expected only backend to fit, got %+v", sections)
+	}
+	if !reflect.DeepEqual(sections[0].included, []string{"Backend", "Caching"}) {
+		t.Fatalf("included = %v", sections[0].included)
+	}
+
+	t.Setenv("CODEAGENT_SKILL_TOKENS", "1000")
+	sections = resolveSkillSections("", "cache the sql queries", []string{"frontend", "backend"}, 0)
+	if len(sections) != 2 || sections[0].name != "frontend" || len(sections[0].dropped)+len(sections[1].dropped) != 0 {
+		t.Fatalf("expected both skills whole in list order, got %+v", sections)
+	}
+}
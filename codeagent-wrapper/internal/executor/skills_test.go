@@ -207,7 +207,7 @@ func TestResolveSkillContent_ValidSkill(t *testing.T) {
 	home := createTempSkill(t, "test-skill", "---\nname: test\n---\n\n# Test Skill\nBest practices here.")
 	setTestHome(t, home)
 
-	result := ResolveSkillContent([]string{"test-skill"}, 0)
+	result := ResolveSkillContent("", "", []string{"test-skill"}, 0)
 	if result == "" {
 		t.Fatal("expected non-empty content")
 	}
@@ -229,34 +229,37 @@ func TestResolveSkillContent_NonexistentSkill(t *testing.T) {
 	home := t.TempDir()
 	setTestHome(t, home)
 
-	result := ResolveSkillContent([]string{"nonexistent-skill-xyz"}, 0)
+	result := ResolveSkillContent("", "", []string{"nonexistent-skill-xyz"}, 0)
 	if result != "" {
 		t.Errorf("expected empty for nonexistent skill, got %d bytes", len(result))
 	}
 }
 
 func TestResolveSkillContent_Empty(t *testing.T) {
-	if result := ResolveSkillContent(nil, 0); result != "" {
+	if result := ResolveSkillContent("", "", nil, 0); result != "" {
 		t.Errorf("expected empty for nil, got %q", result)
 	}
-	if result := ResolveSkillContent([]string{}, 0); result != "" {
+	if result := ResolveSkillContent("", "", []string{}, 0); result != "" {
 		t.Errorf("expected empty for empty, got %q", result)
 	}
 }
 
 func TestResolveSkillContent_Budget(t *testing.T) {
-	longBody := strings.Repeat("x", 500)
-	home := createTempSkill(t, "big-skill", "---\nname: big\n---\n\n"+longBody)
+	para := strings.Repeat("x", 200)
+	body := "# Big\n\n" + para + "\n\n## Second\n\n" + para + "\n\n## Third\n\n" + para
+	home := createTempSkill(t, "big-skill", "---\nname: big\n---\n\n"+body)
 	setTestHome(t, home)
 
-	result := ResolveSkillContent([]string{"big-skill"}, 200)
+	result := ResolveSkillContent("", "", []string{"big-skill"}, 100)
 	if result == "" {
 		t.Fatal("expected non-empty even with small budget")
 	}
-	if len(result) > 200 {
-		t.Errorf("result %d bytes exceeds budget 200", len(result))
+	if tokens := estimateTokens(result); tokens > 100 {
+		t.Errorf("result ~%d tokens exceeds budget 100", tokens)
+	}
+	if !strings.Contains(result, "# Big\n\n"+para+"\n</skill>") || strings.Contains(result, "## Second") {
+		t.Errorf("expected only the first whole section, got %q", result)
 	}
-	t.Logf("budget=200, result=%d bytes", len(result))
 }
 
 func TestResolveSkillContent_MultipleSkills(t *testing.T) {
@@ -268,7 +271,7 @@ func TestResolveSkillContent_MultipleSkills(t *testing.T) {
 	}
 	setTestHome(t, home)
 
-	result := ResolveSkillContent([]string{"skill-a", "skill-b"}, 0)
+	result := ResolveSkillContent("", "", []string{"skill-a", "skill-b"}, 0)
 	if result == "" {
 		t.Fatal("expected non-empty for multiple skills")
 	}
@@ -284,7 +287,7 @@ func TestResolveSkillContent_PathTraversal(t *testing.T) {
 	home := t.TempDir()
 	setTestHome(t, home)
 
-	result := ResolveSkillContent([]string{"../../../etc/passwd"}, 0)
+	result := ResolveSkillContent("", "", []string{"../../../etc/passwd"}, 0)
 	if result != "" {
 		t.Errorf("expected empty for path traversal name, got %d bytes", len(result))
 	}
@@ -296,7 +299,7 @@ func TestResolveSkillContent_InvalidNames(t *testing.T) {
 
 	tests := []string{"../bad", "foo/bar", "skill name", "skill.name", "a b"}
 	for _, name := range tests {
-		result := ResolveSkillContent([]string{name}, 0)
+		result := ResolveSkillContent("", "", []string{name}, 0)
 		if result != "" {
 			t.Errorf("expected empty for invalid name %q, got %d bytes", name, len(result))
 		}
@@ -325,7 +328,7 @@ func TestSkillInjectionFormat(t *testing.T) {
 	setTestHome(t, home)
 
 	taskText := "Implement the feature."
-	content := ResolveSkillContent([]string{"test-go"}, 0)
+	content := ResolveSkillContent("", "", []string{"test-go"}, 0)
 	injected := taskText + "\n\n# Domain Best Practices\n\n" + content
 
 	if !strings.Contains(injected, "Implement the feature.") {
@@ -1,6 +1,12 @@
 package executor
 
-import "context"
+import (
+	"context"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+	sandbox "codeagent-wrapper/internal/sandbox"
+)
 
 // ParallelConfig defines the JSON schema for parallel execution.
 type ParallelConfig struct {
@@ -10,24 +16,37 @@ type ParallelConfig struct {
 
 // TaskSpec describes an individual task entry in the parallel config.
 type TaskSpec struct {
-	ID              string          `json:"id"`
-	Task            string          `json:"task"`
-	WorkDir         string          `json:"workdir,omitempty"`
-	Dependencies    []string        `json:"dependencies,omitempty"`
-	SessionID       string          `json:"session_id,omitempty"`
-	Backend         string          `json:"backend,omitempty"`
-	Model           string          `json:"model,omitempty"`
-	ReasoningEffort string          `json:"reasoning_effort,omitempty"`
-	Agent           string          `json:"agent,omitempty"`
-	PromptFile      string          `json:"prompt_file,omitempty"`
-	SkipPermissions bool            `json:"skip_permissions,omitempty"`
-	Worktree        bool            `json:"worktree,omitempty"`
-	AllowedTools    []string        `json:"allowed_tools,omitempty"`
-	DisallowedTools []string        `json:"disallowed_tools,omitempty"`
-	Skills          []string        `json:"skills,omitempty"`
-	Mode            string          `json:"-"`
-	UseStdin        bool            `json:"-"`
-	Context         context.Context `json:"-"`
+	ID              string                `json:"id"`
+	Task            string                `json:"task"`
+	WorkDir         string                `json:"workdir,omitempty"`
+	Dependencies    []string              `json:"dependencies,omitempty"`
+	SessionID       string                `json:"session_id,omitempty"`
+	Backend         string                `json:"backend,omitempty"`
+	Model           string                `json:"model,omitempty"`
+	ReasoningEffort string                `json:"reasoning_effort,omitempty"`
+	Agent           string                `json:"agent,omitempty"`
+	PromptFile      string                `json:"prompt_file,omitempty"`
+	SkipPermissions bool                  `json:"skip_permissions,omitempty"`
+	Worktree        bool                  `json:"worktree,omitempty"`
+	AllowedTools    []string              `json:"allowed_tools,omitempty"`
+	DisallowedTools []string              `json:"disallowed_tools,omitempty"`
+	Skills          []string              `json:"skills,omitempty"`
+	BestOf          []string              `json:"best_of,omitempty"`         // candidate backends; each runs in its own worktree
+	Judge           string                `json:"judge,omitempty"`           // agent that picks the best_of winner
+	Priority        int                   `json:"priority,omitempty"`        // higher starts first among ready tasks
+	HandoffBackend  string                `json:"-"`                         // resume SessionID from this backend by replaying its transcript
+	TranscriptDir   string                `json:"-"`                         // write a normalized JSONL transcript of the run here
+	Sandbox         *sandbox.Policy       `json:"-"`                         // confine the backend with Landlock; nil runs it unconfined
+	Env             map[string]string     `json:"env,omitempty"`             // set for the backend on top of the agent's env
+	EnvPassthrough  []string              `json:"env_passthrough,omitempty"` // glob allowlist of inherited variables
+	EnvUnset        []string              `json:"env_unset,omitempty"`       // glob patterns of variables to remove
+	Limits          config.ResourceLimits `json:"-"`                         // memory, CPU time and process caps on top of the agent's
+	Cache           bool                  `json:"-"`                         // reuse a cached result of an identical task against an unchanged tree
+	RecordDir       string                `json:"-"`                         // save the backend's args, stdin, output and exit code as a cassette here
+	ReplayDir       string                `json:"-"`                         // serve the backend's output from a cassette here instead of running it
+	Mode            string                `json:"-"`
+	UseStdin        bool                  `json:"-"`
+	Context         context.Context       `json:"-"`
 }
 
 // TaskResult captures the execution outcome of a task.
@@ -39,12 +58,23 @@ type TaskResult struct {
 	Error     string `json:"error"`
 	LogPath   string `json:"log_path"`
 	// Structured report fields
-	Coverage       string   `json:"coverage,omitempty"`        // extracted coverage percentage (e.g., "92%")
-	CoverageNum    float64  `json:"coverage_num,omitempty"`    // numeric coverage for comparison
-	CoverageTarget float64  `json:"coverage_target,omitempty"` // target coverage (default 90)
-	FilesChanged   []string `json:"files_changed,omitempty"`   // list of changed files
-	KeyOutput      string   `json:"key_output,omitempty"`      // brief summary of what was done
-	TestsPassed    int      `json:"tests_passed,omitempty"`    // number of tests passed
-	TestsFailed    int      `json:"tests_failed,omitempty"`    // number of tests failed
-	sharedLog      bool
+	Coverage        string            `json:"coverage,omitempty"`          // extracted coverage percentage (e.g., "92%")
+	CoverageNum     float64           `json:"coverage_num,omitempty"`      // numeric coverage for comparison
+	CoverageTarget  float64           `json:"coverage_target,omitempty"`   // target coverage (default 90)
+	FilesChanged    []string          `json:"files_changed,omitempty"`     // list of changed files
+	KeyOutput       string            `json:"key_output,omitempty"`        // brief summary of what was done
+	TestsPassed     int               `json:"tests_passed,omitempty"`      // number of tests passed
+	TestsFailed     int               `json:"tests_failed,omitempty"`      // number of tests failed
+	BestOf          *BestOfResult     `json:"best_of,omitempty"`           // judge verdict for best_of tasks
+	QueueWaitMs     int64             `json:"queue_wait_ms,omitempty"`     // time spent waiting for worker/backend/agent slots
+	CriticalPathPos int               `json:"critical_path_pos,omitempty"` // 1-based position on the critical path (critical-path schedule)
+	CriticalPathLen int               `json:"critical_path_len,omitempty"` // number of tasks on the critical path
+	HandoffFrom     string            `json:"handoff_from,omitempty"`      // session replayed into this one from another backend
+	HandoffBackend  string            `json:"handoff_backend,omitempty"`   // backend of HandoffFrom
+	TranscriptPath  string            `json:"transcript_path,omitempty"`   // normalized JSONL transcript (--transcript-dir)
+	Stragglers      []string          `json:"stragglers,omitempty"`        // processes left in the backend's group after termination, then killed
+	Annotations     map[string]string `json:"annotations,omitempty"`       // added by pre_task and post_task hooks
+	Cached          bool              `json:"cached,omitempty"`            // returned from the result cache without running the backend
+	sharedLog       bool
+	elapsed         time.Duration
 }
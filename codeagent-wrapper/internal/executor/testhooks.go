@@ -5,6 +5,7 @@ import (
 	"os/exec"
 
 	backend "codeagent-wrapper/internal/backend"
+	session "codeagent-wrapper/internal/session"
 )
 
 type CommandRunner = commandRunner
@@ -48,6 +49,18 @@ func SetNewCommandRunner(fn func(context.Context, string, ...string) CommandRunn
 	return func() { newCommandRunner = prev }
 }
 
+// SetRecordSessionFn replaces the session registry writer; nil restores
+// session.Record.
+func SetRecordSessionFn(fn func(session.Entry) error) (restore func()) {
+	prev := recordSessionFn
+	if fn != nil {
+		recordSessionFn = fn
+	} else {
+		recordSessionFn = session.Record
+	}
+	return func() { recordSessionFn = prev }
+}
+
 func WithTaskLogger(ctx context.Context, logger *Logger) context.Context {
 	return withTaskLogger(ctx, logger)
 }
@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	tracing "codeagent-wrapper/internal/tracing"
+)
+
+// endTaskSpan records the outcome of RunCodexTaskWithContext on its span.
+func endTaskSpan(span *tracing.Span, taskSpec TaskSpec, cfg *Config, result TaskResult) {
+	if span == nil {
+		return
+	}
+	span.SetAttributes(
+		tracing.String("codeagent.backend", cfg.Backend),
+		tracing.String("codeagent.model", cfg.Model),
+		tracing.String("codeagent.agent", taskSpec.Agent),
+		tracing.String("codeagent.mode", cfg.Mode),
+		tracing.Int("codeagent.exit_code", result.ExitCode),
+		tracing.String("codeagent.session_id", result.SessionID),
+	)
+	if result.HandoffFrom != "" {
+		span.SetAttributes(tracing.String("codeagent.handoff_from", result.HandoffFrom))
+	}
+	if result.ExitCode != 0 {
+		span.SetError(result.Error)
+	}
+	span.End()
+}
+
+// endSpanOnce returns a function that ends span the first time it is
+// called, adding attrs just before.
+func endSpanOnce(span *tracing.Span) func(attrs ...tracing.Attr) {
+	var once sync.Once
+	return func(attrs ...tracing.Attr) {
+		once.Do(func() {
+			span.SetAttributes(attrs...)
+			span.End()
+		})
+	}
+}
+
+// terminationReason names why ctx stopped a backend.
+func terminationReason(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "cancelled"
+}
+
+// firstReadReader calls onFirst when the first bytes arrive.
+type firstReadReader struct {
+	r       io.Reader
+	onFirst func()
+	seen    bool
+}
+
+func (f *firstReadReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if n > 0 && !f.seen {
+		f.seen = true
+		f.onFirst()
+	}
+	return n, err
+}
@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	parser "codeagent-wrapper/internal/parser"
+
+	"github.com/goccy/go-json"
+)
+
+// unsafeFileChars is replaced in task IDs used in transcript file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// transcriptRecorder writes a normalized JSONL transcript of one task run.
+// It is an io.Writer for the backend's stdout: complete lines are normalized
+// as they arrive.
+type transcriptRecorder struct {
+	mu         sync.Mutex
+	file       *os.File
+	w          *bufio.Writer
+	enc        *json.Encoder
+	normalizer *parser.TranscriptNormalizer
+	pending    bytes.Buffer
+	started    time.Time
+	err        error
+}
+
+// openTranscript creates <dir>/<time>-<task>-<random>.jsonl and writes the
+// prompt entry.
+func openTranscript(dir string, taskSpec TaskSpec, cfg *Config) (*transcriptRecorder, error) {
+	dir = absPathOr(dir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	name := taskSpec.ID
+	if name == "" {
+		name = cfg.Backend
+	}
+	name = unsafeFileChars.ReplaceAllString(name, "_")
+	started := time.Now()
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%s-%s-*.jsonl", started.Format("20060102-150405"), name))
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	r := &transcriptRecorder{
+		file:       f,
+		w:          w,
+		enc:        json.NewEncoder(w),
+		normalizer: parser.NewTranscriptNormalizer(cfg.Backend),
+		started:    started,
+	}
+	r.write(parser.TranscriptEntry{
+		Type:      parser.EntryPrompt,
+		TaskID:    taskSpec.ID,
+		Backend:   cfg.Backend,
+		Model:     cfg.Model,
+		SessionID: cfg.SessionID,
+		WorkDir:   cfg.WorkDir,
+		Text:      taskSpec.Task,
+	})
+	return r, nil
+}
+
+func (r *transcriptRecorder) Path() string {
+	return r.file.Name()
+}
+
+func (r *transcriptRecorder) write(e parser.TranscriptEntry) {
+	if r.err != nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	r.err = r.enc.Encode(e)
+}
+
+func (r *transcriptRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending.Write(p)
+	for {
+		idx := bytes.IndexByte(r.pending.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := r.pending.Next(idx + 1)
+		for _, e := range r.normalizer.Feed(line) {
+			r.write(e)
+		}
+	}
+	// Transcript failures never interrupt the backend's output.
+	return len(p), nil
+}
+
+// Close normalizes what is left of the stream, writes the result entry and
+// closes the file.
+func (r *transcriptRecorder) Close(result TaskResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending.Len() > 0 {
+		for _, e := range r.normalizer.Feed(r.pending.Bytes()) {
+			r.write(e)
+		}
+		r.pending.Reset()
+	}
+	for _, e := range r.normalizer.Flush() {
+		r.write(e)
+	}
+	exitCode := result.ExitCode
+	r.write(parser.TranscriptEntry{
+		Type:       parser.EntryResult,
+		TaskID:     result.TaskID,
+		SessionID:  result.SessionID,
+		DurationMs: time.Since(r.started).Milliseconds(),
+		Text:       result.Message,
+		Error:      result.Error,
+		ExitCode:   &exitCode,
+		IsError:    exitCode != 0,
+	})
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
@@ -0,0 +1,72 @@
+// Package launcher starts a backend through the wrapper itself so that
+// confinement applied between fork and exec holds for the backend and
+// everything it starts: `<wrapper> __launch -- <command> [args...]` joins a
+// cgroup, sets rlimits, applies a Landlock policy and then execs the backend.
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	resources "codeagent-wrapper/internal/resources"
+	sandbox "codeagent-wrapper/internal/sandbox"
+
+	"github.com/goccy/go-json"
+)
+
+// Command is the hidden subcommand that runs the launcher.
+const Command = "__launch"
+
+// SpecEnv carries the JSON Spec from the wrapper to the launcher.
+const SpecEnv = "CODEAGENT_LAUNCH"
+
+// FailureExitCode is the launcher's exit status when it cannot set the
+// backend up as requested.
+const FailureExitCode = 126
+
+// Spec is what the launcher applies before exec.
+type Spec struct {
+	Sandbox *sandbox.Policy    `json:"sandbox,omitempty"`
+	Cgroup  string             `json:"cgroup,omitempty"`
+	Rlimits []resources.Rlimit `json:"rlimits,omitempty"`
+}
+
+// IsZero reports whether the backend can be started directly.
+func (s Spec) IsZero() bool {
+	return s.Sandbox == nil && s.Cgroup == "" && len(s.Rlimits) == 0
+}
+
+// Wrap returns the launcher invocation that runs name with args under spec,
+// and the environment to add for it.
+func Wrap(spec Spec, name string, args []string) (string, []string, map[string]string, error) {
+	if spec.Sandbox != nil {
+		if err := sandbox.Available(); err != nil {
+			return "", nil, nil, err
+		}
+		policy := spec.Sandbox.Normalize()
+		spec.Sandbox = &policy
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("launcher: cannot locate the wrapper executable: %w", err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	argv := append([]string{Command, "--", name}, args...)
+	return self, argv, map[string]string{SpecEnv: string(data)}, nil
+}
+
+// decodeSpec reads the spec the wrapper passed in SpecEnv.
+func decodeSpec(raw string) (Spec, error) {
+	var s Spec
+	if strings.TrimSpace(raw) == "" {
+		return s, fmt.Errorf("%s is not set", SpecEnv)
+	}
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return s, fmt.Errorf("invalid %s: %w", SpecEnv, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	sandbox "codeagent-wrapper/internal/sandbox"
+
+	"golang.org/x/sys/unix"
+)
+
+// Exec applies the spec from SpecEnv to this process and replaces it with
+// argv. It only returns on failure.
+func Exec(argv []string) error {
+	if len(argv) == 0 {
+		return errors.New("launcher: no command to run")
+	}
+	spec, err := decodeSpec(os.Getenv(SpecEnv))
+	if err != nil {
+		return fmt.Errorf("launcher: %w", err)
+	}
+	_ = os.Unsetenv(SpecEnv)
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("launcher: %w", err)
+	}
+
+	if spec.Cgroup != "" {
+		// "0" moves the writing process, which is this one.
+		if err := os.WriteFile(filepath.Join(spec.Cgroup, "cgroup.procs"), []byte("0"), 0o644); err != nil {
+			return fmt.Errorf("launcher: join cgroup %s: %w", spec.Cgroup, err)
+		}
+	}
+	for _, rl := range spec.Rlimits {
+		if err := unix.Setrlimit(rl.Resource, &unix.Rlimit{Cur: rl.Cur, Max: rl.Max}); err != nil {
+			return fmt.Errorf("launcher: setrlimit(%d): %w", rl.Resource, err)
+		}
+	}
+	// Landlock domains and no_new_privs are per thread; the exec must happen
+	// on the thread that was restricted.
+	runtime.LockOSThread()
+	if spec.Sandbox != nil {
+		if err := sandbox.Restrict(*spec.Sandbox); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("launcher: exec %s: %w", path, syscall.Exec(path, argv, os.Environ()))
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package launcher
+
+import "errors"
+
+// Exec is only supported on Linux.
+func Exec([]string) error {
+	return errors.New("launcher: confining backends requires Linux")
+}
@@ -0,0 +1,135 @@
+package launcher
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	resources "codeagent-wrapper/internal/resources"
+	sandbox "codeagent-wrapper/internal/sandbox"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/sys/unix"
+)
+
+// TestMain lets the launcher tests run the test binary as the launcher.
+func TestMain(m *testing.M) {
+	if os.Getenv("LAUNCHER_TEST") == "1" {
+		err := Exec(os.Args[1:])
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(FailureExitCode)
+	}
+	os.Exit(m.Run())
+}
+
+// launch runs sh -c script through the test binary acting as launcher.
+func launch(t *testing.T, spec Spec, script string, args ...string) *exec.Cmd {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	data, _ := json.Marshal(spec)
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(self, append([]string{sh, "-c", script, "sh"}, args...)...)
+	cmd.Env = append(os.Environ(), "LAUNCHER_TEST=1", SpecEnv+"="+string(data))
+	return cmd
+}
+
+func TestExecConfinesWrites(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skip(err)
+	}
+	writable, outside, secret := t.TempDir(), t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(secret, "token"), []byte("data\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := sandbox.Policy{Write: []string{writable, "/dev/null"}, Deny: []string{secret}}.Normalize()
+	script := `echo ok > "$1/in" && echo wrote-in; { echo no > "$2/out"; } 2>/dev/null || echo denied-out; cat "$3/token" 2>/dev/null || echo denied-read; echo "spec=$` + SpecEnv + `"`
+	out, err := launch(t, Spec{Sandbox: &policy}, script, writable, outside, secret).CombinedOutput()
+	if err != nil {
+		t.Fatalf("launcher: %v\n%s", err, out)
+	}
+	if got, want := strings.TrimSpace(string(out)), "wrote-in\ndenied-out\ndenied-read\nspec="; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "out")); !os.IsNotExist(err) {
+		t.Fatalf("write outside the policy succeeded: %v", err)
+	}
+}
+
+func TestExecDeniesPathsBelowWriteRoot(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skip(err)
+	}
+	home := t.TempDir()
+	ssh := filepath.Join(home, ".ssh")
+	for _, dir := range []string{ssh, filepath.Join(home, "src")} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(ssh, "id_rsa"), []byte("key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := sandbox.Policy{Write: []string{home, "/dev/null"}, Deny: []string{ssh}}.Normalize()
+	script := `echo ok > "$1/src/notes" && echo wrote-home; cat "$1/.ssh/id_rsa" 2>/dev/null || echo denied-read; { echo no > "$1/.ssh/x"; } 2>/dev/null || echo denied-write`
+	out, err := launch(t, Spec{Sandbox: &policy}, script, home).CombinedOutput()
+	if err != nil {
+		t.Fatalf("launcher: %v\n%s", err, out)
+	}
+	if got, want := strings.TrimSpace(string(out)), "wrote-home\ndenied-read\ndenied-write"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestExecAppliesCPULimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits are Linux-only")
+	}
+	rl := resources.Rlimit{Resource: unix.RLIMIT_CPU, Cur: 1, Max: 2}
+	err := launch(t, Spec{Rlimits: []resources.Rlimit{rl}}, `while :; do :; done`).Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("busy loop = %v, want it killed", err)
+	}
+	ws := exitErr.Sys().(syscall.WaitStatus)
+	if !ws.Signaled() || (ws.Signal() != syscall.SIGXCPU && ws.Signal() != syscall.SIGKILL) {
+		t.Fatalf("wait status = %v, want SIGXCPU", ws)
+	}
+}
+
+func TestExecRejectsMissingSpec(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the launcher is Linux-only")
+	}
+	t.Setenv(SpecEnv, "")
+	if err := Exec([]string{"true"}); err == nil || !strings.Contains(err.Error(), SpecEnv) {
+		t.Fatalf("Exec without spec = %v", err)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	name, argv, env, err := Wrap(Spec{Cgroup: "/sys/fs/cgroup/x"}, "codex", []string{"e", "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, _ := os.Executable()
+	if name != self || strings.Join(argv, " ") != Command+" -- codex e hi" {
+		t.Fatalf("Wrap = %s %v", name, argv)
+	}
+	got, err := decodeSpec(env[SpecEnv])
+	if err != nil || got.Cgroup != "/sys/fs/cgroup/x" || got.Sandbox != nil {
+		t.Fatalf("spec = %+v, %v", got, err)
+	}
+}
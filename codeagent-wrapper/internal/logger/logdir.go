@@ -0,0 +1,535 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	utils "codeagent-wrapper/internal/utils"
+)
+
+// Retention defaults for CODEAGENT_LOG_DIR. A zero limit disables it.
+const (
+	defaultLogMaxAge   = 30 * 24 * time.Hour
+	defaultLogMaxRuns  = 100
+	defaultLogMaxBytes = 500 << 20
+)
+
+const runIDTimeLayout = "20060102-150405"
+
+var (
+	runIDOnce sync.Once
+	runID     string
+)
+
+// LogRetention limits what CleanupOldLogs keeps in the log directory.
+type LogRetention struct {
+	MaxAge   time.Duration
+	MaxRuns  int
+	MaxBytes int64
+}
+
+// LogFile is one log of a run.
+type LogFile struct {
+	Path       string    `json:"path"`
+	TaskID     string    `json:"task_id,omitempty"` // empty for the run's main log
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modified"`
+	Compressed bool      `json:"compressed,omitempty"`
+}
+
+// LogRun groups the logs written by one wrapper process. In the log
+// directory a run is a <time>-<pid> subfolder; in the temp directory it is
+// the set of files sharing a PID.
+type LogRun struct {
+	ID      string    `json:"id"`
+	Dir     string    `json:"dir"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Active  bool      `json:"active"` // the owning process is still running
+	Files   []LogFile `json:"files"`
+}
+
+// Size is the total size of the run's files.
+func (r LogRun) Size() int64 {
+	var total int64
+	for _, f := range r.Files {
+		total += f.Size
+	}
+	return total
+}
+
+// LastWrite is the newest modification time among the run's files.
+func (r LogRun) LastWrite() time.Time {
+	last := r.Started
+	for _, f := range r.Files {
+		if f.ModTime.After(last) {
+			last = f.ModTime
+		}
+	}
+	return last
+}
+
+// LogDir returns CODEAGENT_LOG_DIR with ~ expanded, or "" when logs go to
+// os.TempDir().
+func LogDir() string {
+	dir := strings.TrimSpace(os.Getenv("CODEAGENT_LOG_DIR"))
+	if dir == "" {
+		return ""
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir
+}
+
+// RunID identifies this process's logs: <start time>-<pid>.
+func RunID() string {
+	runIDOnce.Do(func() {
+		runID = fmt.Sprintf("%s-%d", time.Now().Format(runIDTimeLayout), os.Getpid())
+	})
+	return runID
+}
+
+// logBaseDir is where new log files are created: this run's folder under
+// the log directory, or os.TempDir().
+func logBaseDir() string {
+	if dir := LogDir(); dir != "" {
+		return filepath.Join(dir, RunID())
+	}
+	return os.TempDir()
+}
+
+// ResolveLogRetention reads CODEAGENT_LOG_MAX_AGE (e.g. 30d, 12h),
+// CODEAGENT_LOG_MAX_RUNS and CODEAGENT_LOG_MAX_SIZE (e.g. 500MB). Invalid
+// values keep the default; 0 disables a limit.
+func ResolveLogRetention() LogRetention {
+	r := LogRetention{MaxAge: defaultLogMaxAge, MaxRuns: defaultLogMaxRuns, MaxBytes: defaultLogMaxBytes}
+	if raw := strings.TrimSpace(os.Getenv("CODEAGENT_LOG_MAX_AGE")); raw != "" {
+		if d, err := parseLogAge(raw); err == nil {
+			r.MaxAge = d
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("CODEAGENT_LOG_MAX_RUNS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			r.MaxRuns = n
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("CODEAGENT_LOG_MAX_SIZE")); raw != "" {
+		if n, err := utils.ParseByteSize(raw); err == nil {
+			r.MaxBytes = n
+		}
+	}
+	return r
+}
+
+func parseLogAge(raw string) (time.Duration, error) {
+	if raw == "0" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", raw)
+	}
+	return d, nil
+}
+
+// parseLogName splits <prefix>-<pid>[-<task>].log[.gz] into its PID and
+// task suffix.
+func parseLogName(name string) (pid int, taskID string, compressed, ok bool) {
+	compressed = strings.HasSuffix(name, ".log.gz")
+	core := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".log")
+	if core == name {
+		return 0, "", false, false
+	}
+	for _, prefix := range LogPrefixes() {
+		rest, found := strings.CutPrefix(core, prefix+"-")
+		if !found {
+			continue
+		}
+		pidPart, task, _ := strings.Cut(rest, "-")
+		pid, err := strconv.Atoi(pidPart)
+		if err != nil || pid <= 0 {
+			continue
+		}
+		return pid, task, compressed, true
+	}
+	return 0, "", false, false
+}
+
+// parseRunID extracts the start time and PID from <time>-<pid>.
+func parseRunID(id string) (time.Time, int, bool) {
+	idx := strings.LastIndex(id, "-")
+	if idx <= 0 {
+		return time.Time{}, 0, false
+	}
+	started, err := time.ParseInLocation(runIDTimeLayout, id[:idx], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	pid, err := strconv.Atoi(id[idx+1:])
+	if err != nil || pid <= 0 {
+		return time.Time{}, 0, false
+	}
+	return started, pid, true
+}
+
+// RunActive reports whether the process that owns run is still running.
+func RunActive(run LogRun) bool {
+	path := run.Dir
+	if len(run.Files) > 0 {
+		path = run.Files[0].Path
+	}
+	return runIsActive(run.PID, path)
+}
+
+func runIsActive(pid int, path string) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	return processRunningCheck(pid) && !isPIDReused(path, pid)
+}
+
+// ListRuns returns the runs in the log directory (or the temp directory when
+// none is configured), newest first.
+func ListRuns() ([]LogRun, error) {
+	dir := LogDir()
+	if dir == "" {
+		return listTempRuns()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []LogRun
+	for _, entry := range entries {
+		started, pid, ok := parseRunID(entry.Name())
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		runDir := filepath.Join(dir, entry.Name())
+		if skip, _ := isUnsafeFile(runDir, dir); skip {
+			continue
+		}
+		run := LogRun{ID: entry.Name(), Dir: runDir, PID: pid, Started: started}
+		files, _ := os.ReadDir(runDir)
+		for _, f := range files {
+			_, task, compressed, ok := parseLogName(f.Name())
+			if !ok {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			run.Files = append(run.Files, LogFile{Path: filepath.Join(runDir, f.Name()), TaskID: task, Size: info.Size(), ModTime: info.ModTime(), Compressed: compressed})
+		}
+		sortLogFiles(run.Files)
+		run.Active = RunActive(run)
+		runs = append(runs, run)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// listTempRuns groups the wrapper logs in os.TempDir() by PID.
+func listTempRuns() ([]LogRun, error) {
+	tempDir := os.TempDir()
+	matches, err := listLogFiles(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	byPID := make(map[int]*LogRun)
+	for _, path := range matches {
+		pid, task, _, ok := parseLogName(filepath.Base(path))
+		if !ok {
+			continue
+		}
+		info, err := fileStatFn(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		run := byPID[pid]
+		if run == nil {
+			run = &LogRun{ID: strconv.Itoa(pid), Dir: tempDir, PID: pid, Started: info.ModTime()}
+			byPID[pid] = run
+		}
+		if info.ModTime().Before(run.Started) {
+			run.Started = info.ModTime()
+		}
+		run.Files = append(run.Files, LogFile{Path: path, TaskID: task, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	runs := make([]LogRun, 0, len(byPID))
+	for _, run := range byPID {
+		sortLogFiles(run.Files)
+		run.Active = RunActive(*run)
+		runs = append(runs, *run)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// sortLogFiles puts the main log first, then task logs by name.
+func sortLogFiles(files []LogFile) {
+	sort.Slice(files, func(i, j int) bool {
+		if (files[i].TaskID == "") != (files[j].TaskID == "") {
+			return files[i].TaskID == ""
+		}
+		return files[i].TaskID < files[j].TaskID
+	})
+}
+
+func sortRuns(runs []LogRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].Started.Equal(runs[j].Started) {
+			return runs[i].Started.After(runs[j].Started)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+}
+
+// FindLog resolves ref to a log file: a run ID (or unique prefix of one)
+// selects that run's main log, otherwise ref is matched against task IDs,
+// newest run first. An empty ref selects the newest run.
+func FindLog(ref string) (LogRun, LogFile, error) {
+	runs, err := ListRuns()
+	if err != nil {
+		return LogRun{}, LogFile{}, err
+	}
+	ref = strings.TrimSpace(ref)
+	pick := func(run LogRun) (LogRun, LogFile, error) {
+		if len(run.Files) == 0 {
+			return run, LogFile{}, fmt.Errorf("run %s has no log files", run.ID)
+		}
+		return run, run.Files[0], nil
+	}
+	if len(runs) == 0 {
+		return LogRun{}, LogFile{}, fmt.Errorf("no logs found")
+	}
+	if ref == "" {
+		return pick(runs[0])
+	}
+
+	var prefixed []LogRun
+	for _, run := range runs {
+		if run.ID == ref {
+			return pick(run)
+		}
+		if strings.HasPrefix(run.ID, ref) {
+			prefixed = append(prefixed, run)
+		}
+	}
+	if len(prefixed) == 1 {
+		return pick(prefixed[0])
+	}
+
+	sanitized := sanitizeLogSuffix(ref)
+	for _, run := range runs {
+		for _, f := range run.Files {
+			if f.TaskID != "" && (f.TaskID == ref || f.TaskID == sanitized) {
+				return run, f, nil
+			}
+		}
+	}
+	if len(prefixed) > 1 {
+		return LogRun{}, LogFile{}, fmt.Errorf("%q matches %d runs; use a longer run ID", ref, len(prefixed))
+	}
+	return LogRun{}, LogFile{}, fmt.Errorf("no run or task log matches %q", ref)
+}
+
+// OpenLogFile opens a log for reading, decompressing archived logs.
+func OpenLogFile(f LogFile) (io.ReadCloser, error) {
+	file, err := os.Open(f.Path) // #nosec G304 -- path comes from ListRuns
+	if err != nil {
+		return nil, err
+	}
+	if !f.Compressed {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return readCloser{Reader: gz, close: func() error {
+		gz.Close()
+		return file.Close()
+	}}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// cleanupLogDir archives the logs of finished runs and applies retention to
+// the log directory. Runs still in progress are never touched.
+func cleanupLogDir(dir string, retention LogRetention) (CleanupStats, error) {
+	var stats CleanupStats
+	runs, err := ListRuns()
+	if err != nil {
+		return stats, fmt.Errorf("cleanupOldLogs: %w", err)
+	}
+
+	var errs error
+	var total int64
+	kept := 0
+	for _, run := range runs {
+		stats.Scanned += len(run.Files)
+		if run.Active {
+			stats.Kept += len(run.Files)
+			stats.KeptFiles = append(stats.KeptFiles, run.ID+" (running)")
+			total += run.Size()
+			kept++
+			continue
+		}
+
+		if !archiveRun(&run, dir, &stats, &errs) {
+			continue
+		}
+
+		size := run.Size()
+		expired := retention.MaxAge > 0 && time.Since(run.LastWrite()) > retention.MaxAge
+		tooMany := retention.MaxRuns > 0 && kept >= retention.MaxRuns
+		tooBig := retention.MaxBytes > 0 && total+size > retention.MaxBytes
+		if !expired && !tooMany && !tooBig {
+			total += size
+			kept++
+			stats.Kept += len(run.Files)
+			stats.KeptFiles = append(stats.KeptFiles, run.ID)
+			continue
+		}
+		if err := removeRun(run, dir); err != nil {
+			stats.Errors++
+			logWarn(fmt.Sprintf("cleanupOldLogs: failed to remove run %s: %v", run.ID, err))
+			errs = errors.Join(errs, fmt.Errorf("failed to remove %s: %w", run.ID, err))
+			continue
+		}
+		stats.Deleted += len(run.Files)
+		stats.DeletedFiles = append(stats.DeletedFiles, run.ID)
+	}
+	if errs != nil {
+		return stats, fmt.Errorf("cleanupOldLogs: %w", errs)
+	}
+	return stats, nil
+}
+
+// archiveRun gzips the uncompressed logs of a finished run in place. It
+// returns false when the run has unsafe files and must be left alone.
+func archiveRun(run *LogRun, dir string, stats *CleanupStats, errs *error) bool {
+	// ListRuns leaves out symlinks and other non-regular files, so look at
+	// every log-named entry of the folder again.
+	entries, _ := os.ReadDir(run.Dir)
+	for _, entry := range entries {
+		if _, _, _, ok := parseLogName(entry.Name()); !ok {
+			continue
+		}
+		if skip, reason := isUnsafeFile(filepath.Join(run.Dir, entry.Name()), dir); skip {
+			if reason != "" {
+				logDebug(fmt.Sprintf("cleanupOldLogs: skipping run %s: %s: %s", run.ID, entry.Name(), reason))
+			}
+			stats.Kept += len(run.Files)
+			stats.KeptFiles = append(stats.KeptFiles, run.ID+" (unsafe)")
+			return false
+		}
+	}
+	for i, f := range run.Files {
+		if f.Compressed {
+			continue
+		}
+		archived, err := gzipLogFile(f)
+		if err != nil {
+			stats.Errors++
+			logWarn(fmt.Sprintf("cleanupOldLogs: failed to archive %s: %v", f.Path, err))
+			*errs = errors.Join(*errs, fmt.Errorf("failed to archive %s: %w", filepath.Base(f.Path), err))
+			continue
+		}
+		run.Files[i] = archived
+		stats.Archived++
+		stats.ArchivedFiles = append(stats.ArchivedFiles, filepath.Join(run.ID, filepath.Base(archived.Path)))
+	}
+	return true
+}
+
+// gzipLogFile replaces f with f.gz, keeping its modification time so age
+// retention still sees when the log was last written.
+func gzipLogFile(f LogFile) (LogFile, error) {
+	src, err := os.Open(f.Path) // #nosec G304 -- checked by isUnsafeFile
+	if err != nil {
+		return f, err
+	}
+	defer src.Close()
+
+	dst := f.Path + ".gz"
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".archive-*")
+	if err != nil {
+		return f, err
+	}
+	gz := gzip.NewWriter(tmp)
+	gz.Name = filepath.Base(f.Path)
+	gz.ModTime = f.ModTime
+	_, copyErr := io.Copy(gz, src)
+	closeErr := gz.Close()
+	if err := errors.Join(copyErr, closeErr, tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return f, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return f, err
+	}
+	_ = os.Chtimes(dst, f.ModTime, f.ModTime)
+	if err := removeLogFileFn(f.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return f, err
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		return f, err
+	}
+	return LogFile{Path: dst, TaskID: f.TaskID, Size: info.Size(), ModTime: f.ModTime, Compressed: true}, nil
+}
+
+// removeRun deletes a run's log files and then its folder if nothing else
+// is left in it.
+func removeRun(run LogRun, dir string) error {
+	for _, f := range run.Files {
+		if err := removeLogFileFn(f.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if skip, _ := isUnsafeFile(run.Dir, dir); skip {
+		return nil
+	}
+	if rest, err := os.ReadDir(run.Dir); err == nil && len(rest) > 0 {
+		return nil
+	}
+	if err := os.Remove(run.Dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRunLog creates <dir>/<runID>/codeagent-wrapper-<pid>[-task].log with
+// the given content and modification time.
+func writeRunLog(t *testing.T, dir, runID, task, content string, mtime time.Time) string {
+	t.Helper()
+	_, pid, ok := parseRunID(runID)
+	if !ok {
+		t.Fatalf("bad run ID %q", runID)
+	}
+	name := fmt.Sprintf("codeagent-wrapper-%d", pid)
+	if task != "" {
+		name += "-" + task
+	}
+	path := filepath.Join(dir, runID, name+".log")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func setLogDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	setTempDirEnv(t, t.TempDir())
+	t.Setenv("CODEAGENT_LOG_DIR", dir)
+	return dir
+}
+
+func TestNewLoggerWritesToRunDir(t *testing.T) {
+	dir := setLogDir(t)
+
+	l, err := NewLoggerWithSuffix("task-1")
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix: %v", err)
+	}
+	defer l.Close()
+
+	want := filepath.Join(dir, RunID(), fmt.Sprintf("codeagent-wrapper-%d-task-1.log", os.Getpid()))
+	if l.Path() != want {
+		t.Fatalf("path = %s, want %s", l.Path(), want)
+	}
+}
+
+func TestCleanupLogDirArchivesFinishedRuns(t *testing.T) {
+	dir := setLogDir(t)
+	defer SetProcessRunningCheck(func(pid int) bool { return pid == 222 })()
+	defer SetProcessStartTimeFn(func(int) time.Time { return time.Time{} })()
+
+	now := time.Now()
+	finished := writeRunLog(t, dir, "20260101-100000-111", "", "finished run\n", now.Add(-time.Hour))
+	writeRunLog(t, dir, "20260101-100000-111", "build", "task log\n", now.Add(-time.Hour))
+	running := writeRunLog(t, dir, "20260101-110000-222", "", "still going\n", now)
+
+	stats, err := CleanupOldLogs()
+	if err != nil {
+		t.Fatalf("CleanupOldLogs: %v", err)
+	}
+	if stats.Archived != 2 || stats.Deleted != 0 {
+		t.Fatalf("stats = %+v, want 2 archived and none deleted", stats)
+	}
+	if _, err := os.Stat(finished); !os.IsNotExist(err) {
+		t.Fatalf("finished log should have been replaced by its archive: %v", err)
+	}
+	if _, err := os.Stat(running); err != nil {
+		t.Fatalf("running log must be left alone: %v", err)
+	}
+
+	run, file, err := FindLog("build")
+	if err != nil {
+		t.Fatalf("FindLog: %v", err)
+	}
+	if run.ID != "20260101-100000-111" || !file.Compressed {
+		t.Fatalf("FindLog = %s %+v", run.ID, file)
+	}
+	if got := time.Since(file.ModTime); got < 50*time.Minute {
+		t.Fatalf("archive should keep the original mtime, age = %v", got)
+	}
+	r, err := OpenLogFile(file)
+	if err != nil {
+		t.Fatalf("OpenLogFile: %v", err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	if string(data) != "task log\n" {
+		t.Fatalf("archived content = %q", data)
+	}
+}
+
+func TestCleanupLogDirRetention(t *testing.T) {
+	defer SetProcessRunningCheck(func(int) bool { return false })()
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		retention string // env var=value
+		wantKept  []string
+	}{
+		{"max runs", "CODEAGENT_LOG_MAX_RUNS=2", []string{"20260103-100000-3", "20260102-100000-2"}},
+		{"max age", "CODEAGENT_LOG_MAX_AGE=36h", []string{"20260103-100000-3", "20260102-100000-2"}},
+		{"max size", "CODEAGENT_LOG_MAX_SIZE=1K", []string{"20260103-100000-3"}},
+		{"disabled", "CODEAGENT_LOG_MAX_AGE=0", []string{"20260103-100000-3", "20260102-100000-2", "20260101-100000-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setLogDir(t)
+			key, value, _ := strings.Cut(tt.retention, "=")
+			t.Setenv(key, value)
+			// Incompressible content so the archives stay near 800 bytes.
+			payload := func(seed int) string {
+				var sb strings.Builder
+				x := uint32(seed*7919 + 1)
+				for sb.Len() < 800 {
+					x = x*1664525 + 1013904223
+					sb.WriteByte(byte('!' + x>>24%90))
+				}
+				return sb.String()
+			}
+			writeRunLog(t, dir, "20260101-100000-1", "", payload(1), now.Add(-72*time.Hour))
+			writeRunLog(t, dir, "20260102-100000-2", "", payload(2), now.Add(-24*time.Hour))
+			writeRunLog(t, dir, "20260103-100000-3", "", payload(3), now)
+
+			if _, err := CleanupOldLogs(); err != nil {
+				t.Fatalf("CleanupOldLogs: %v", err)
+			}
+			runs, err := ListRuns()
+			if err != nil {
+				t.Fatalf("ListRuns: %v", err)
+			}
+			var got []string
+			for _, run := range runs {
+				got = append(got, run.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantKept, ",") {
+				t.Fatalf("kept runs = %v, want %v", got, tt.wantKept)
+			}
+			for _, id := range []string{"20260101-100000-1", "20260102-100000-2", "20260103-100000-3"} {
+				kept := strings.Contains(strings.Join(tt.wantKept, ","), id)
+				if _, err := os.Stat(filepath.Join(dir, id)); (err == nil) != kept {
+					t.Fatalf("run dir %s exists = %v, want %v", id, err == nil, kept)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanupLogDirSkipsSymlinks(t *testing.T) {
+	dir := setLogDir(t)
+	defer SetProcessRunningCheck(func(int) bool { return false })()
+	t.Setenv("CODEAGENT_LOG_MAX_AGE", "1h")
+
+	outside := filepath.Join(t.TempDir(), "secret.log")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runDir := filepath.Join(dir, "20260101-100000-5")
+	if err := os.MkdirAll(runDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(runDir, "codeagent-wrapper-5.log")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	if _, err := CleanupOldLogs(); err != nil {
+		t.Fatalf("CleanupOldLogs: %v", err)
+	}
+	if _, err := os.Lstat(link); err != nil {
+		t.Fatalf("symlink should be left in place: %v", err)
+	}
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "secret" {
+		t.Fatalf("symlink target was touched: %q %v", data, err)
+	}
+}
+
+func TestFindLog(t *testing.T) {
+	dir := setLogDir(t)
+	defer SetProcessRunningCheck(func(int) bool { return false })()
+	now := time.Now()
+	writeRunLog(t, dir, "20260101-100000-1", "", "old main", now)
+	writeRunLog(t, dir, "20260101-100000-1", "api", "old api", now)
+	writeRunLog(t, dir, "20260102-100000-2", "", "new main", now)
+	writeRunLog(t, dir, "20260102-100000-2", "api", "new api", now)
+
+	tests := []struct {
+		ref      string
+		wantRun  string
+		wantTask string
+		wantErr  string
+	}{
+		{"", "20260102-100000-2", "", ""},
+		{"20260101-100000-1", "20260101-100000-1", "", ""},
+		{"20260101", "20260101-100000-1", "", ""},
+		{"api", "20260102-100000-2", "api", ""},
+		{"2026010", "", "", "matches 2 runs"},
+		{"missing", "", "", "no run or task log"},
+	}
+	for _, tt := range tests {
+		run, file, err := FindLog(tt.ref)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("FindLog(%q) err = %v, want %q", tt.ref, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("FindLog(%q): %v", tt.ref, err)
+		}
+		if run.ID != tt.wantRun || file.TaskID != tt.wantTask {
+			t.Fatalf("FindLog(%q) = %s/%q, want %s/%q", tt.ref, run.ID, file.TaskID, tt.wantRun, tt.wantTask)
+		}
+	}
+}
@@ -46,12 +46,14 @@ type logEntry struct {
 
 // CleanupStats captures the outcome of a cleanupOldLogs run.
 type CleanupStats struct {
-	Scanned      int
-	Deleted      int
-	Kept         int
-	Errors       int
-	DeletedFiles []string
-	KeptFiles    []string
+	Scanned       int
+	Deleted       int
+	Kept          int
+	Errors        int
+	Archived      int
+	DeletedFiles  []string
+	KeptFiles     []string
+	ArchivedFiles []string
 }
 
 var (
@@ -68,7 +70,8 @@ const maxLogSuffixLen = 64
 var logSuffixCounter atomic.Uint64
 
 // NewLogger creates the async logger and starts the worker goroutine.
-// The log file is created under os.TempDir() using the required naming scheme.
+// The log file is created under os.TempDir() using the required naming scheme,
+// or in this run's folder under CODEAGENT_LOG_DIR when that is set.
 func NewLogger() (*Logger, error) {
 	return NewLoggerWithSuffix("")
 }
@@ -87,7 +90,7 @@ func NewLoggerWithSuffix(suffix string) (*Logger, error) {
 	}
 	filename += ".log"
 
-	path := filepath.Clean(filepath.Join(os.TempDir(), filename))
+	path := filepath.Clean(filepath.Join(logBaseDir(), filename))
 
 	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return nil, err
@@ -447,28 +450,34 @@ func (l *Logger) run() {
 // It includes safety checks for:
 // - PID reuse: Compares file modification time with process start time
 // - Symlink attacks: Ensures files are within TempDir and not symlinks
+// When CODEAGENT_LOG_DIR is set, finished runs there are also archived and
+// pruned according to ResolveLogRetention.
 func cleanupOldLogs() (CleanupStats, error) {
+	stats, err := cleanupTempLogs()
+	dir := LogDir()
+	if dir == "" {
+		return stats, err
+	}
+	dirStats, dirErr := cleanupLogDir(dir, ResolveLogRetention())
+	stats.Scanned += dirStats.Scanned
+	stats.Deleted += dirStats.Deleted
+	stats.Kept += dirStats.Kept
+	stats.Errors += dirStats.Errors
+	stats.Archived += dirStats.Archived
+	stats.DeletedFiles = append(stats.DeletedFiles, dirStats.DeletedFiles...)
+	stats.KeptFiles = append(stats.KeptFiles, dirStats.KeptFiles...)
+	stats.ArchivedFiles = append(stats.ArchivedFiles, dirStats.ArchivedFiles...)
+	return stats, errors.Join(err, dirErr)
+}
+
+func cleanupTempLogs() (CleanupStats, error) {
 	var stats CleanupStats
 	tempDir := os.TempDir()
 
-	prefixes := LogPrefixes()
-
-	seen := make(map[string]struct{})
-	var matches []string
-	for _, prefix := range prefixes {
-		pattern := filepath.Join(tempDir, fmt.Sprintf("%s-*.log", prefix))
-		found, err := globLogFiles(pattern)
-		if err != nil {
-			logWarn(fmt.Sprintf("cleanupOldLogs: failed to list logs: %v", err))
-			return stats, fmt.Errorf("cleanupOldLogs: %w", err)
-		}
-		for _, path := range found {
-			if _, ok := seen[path]; ok {
-				continue
-			}
-			seen[path] = struct{}{}
-			matches = append(matches, path)
-		}
+	matches, err := listLogFiles(tempDir)
+	if err != nil {
+		logWarn(fmt.Sprintf("cleanupOldLogs: failed to list logs: %v", err))
+		return stats, fmt.Errorf("cleanupOldLogs: %w", err)
 	}
 
 	var removeErr error
@@ -546,6 +555,52 @@ func cleanupOldLogs() (CleanupStats, error) {
 	return stats, nil
 }
 
+// listLogFiles returns the wrapper log files in tempDir for every known
+// wrapper name prefix.
+func listLogFiles(tempDir string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var matches []string
+	for _, prefix := range LogPrefixes() {
+		pattern := filepath.Join(tempDir, fmt.Sprintf("%s-*.log", prefix))
+		found, err := globLogFiles(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range found {
+			if _, ok := seen[path]; ok {
+				continue
+			}
+			seen[path] = struct{}{}
+			matches = append(matches, path)
+		}
+	}
+	return matches, nil
+}
+
+// orphanedLogs lists the log files cleanupOldLogs would delete: those whose
+// owning process has exited or whose PID has been reused. Nothing is removed.
+func orphanedLogs() ([]string, error) {
+	tempDir := os.TempDir()
+	matches, err := listLogFiles(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	var orphaned []string
+	for _, path := range matches {
+		if skip, _ := isUnsafeFile(path, tempDir); skip {
+			continue
+		}
+		pid, ok := parsePIDFromLog(path)
+		if !ok {
+			continue
+		}
+		if !processRunningCheck(pid) || isPIDReused(path, pid) {
+			orphaned = append(orphaned, path)
+		}
+	}
+	return orphaned, nil
+}
+
 // isUnsafeFile checks if a file is unsafe to delete (symlink or outside tempDir).
 // Returns (true, reason) if the file should be skipped.
 func isUnsafeFile(path string, tempDir string) (bool, string) {
@@ -657,12 +712,18 @@ func logConcurrencyPlanning(limit, total int) {
 	logger.Info(fmt.Sprintf("parallel: worker_limit=%s total_tasks=%d", renderWorkerLimit(limit), total))
 }
 
-func logConcurrencyState(event, taskID string, active, limit int) {
+func logConcurrencyState(event, taskID string, active, limit int, details ...string) {
 	logger := activeLogger()
 	if logger == nil {
 		return
 	}
-	logger.Debug(fmt.Sprintf("parallel: %s task=%s active=%d limit=%s", event, taskID, active, renderWorkerLimit(limit)))
+	msg := fmt.Sprintf("parallel: %s task=%s active=%d limit=%s", event, taskID, active, renderWorkerLimit(limit))
+	for _, d := range details {
+		if d != "" {
+			msg += " " + d
+		}
+	}
+	logger.Debug(msg)
 }
 
 func renderWorkerLimit(limit int) string {
@@ -674,6 +735,8 @@ func renderWorkerLimit(limit int) string {
 
 func CleanupOldLogs() (CleanupStats, error) { return cleanupOldLogs() }
 
+func OrphanedLogs() ([]string, error) { return orphanedLogs() }
+
 func IsUnsafeFile(path string, tempDir string) (bool, string) { return isUnsafeFile(path, tempDir) }
 
 func IsPIDReused(logPath string, pid int) bool { return isPIDReused(logPath, pid) }
@@ -682,8 +745,8 @@ func ParsePIDFromLog(path string) (int, bool) { return parsePIDFromLog(path) }
 
 func LogConcurrencyPlanning(limit, total int) { logConcurrencyPlanning(limit, total) }
 
-func LogConcurrencyState(event, taskID string, active, limit int) {
-	logConcurrencyState(event, taskID, active, limit)
+func LogConcurrencyState(event, taskID string, active, limit int, details ...string) {
+	logConcurrencyState(event, taskID, active, limit, details...)
 }
 
 func SanitizeLogSuffix(raw string) string { return sanitizeLogSuffix(raw) }
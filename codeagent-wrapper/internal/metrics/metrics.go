@@ -0,0 +1,299 @@
+// Package metrics counts task outcomes, durations and backend terminations
+// and renders them in the Prometheus text exposition format, either served
+// on /metrics or merged into a node_exporter textfile-collector file.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names.
+const (
+	TasksTotal           = "codeagent_tasks_total"
+	TaskDuration         = "codeagent_task_duration_seconds"
+	TaskQueueWait        = "codeagent_task_queue_wait_seconds"
+	TerminationsTotal    = "codeagent_backend_terminations_total"
+	KillsTotal           = "codeagent_backend_kills_total"
+	RunsTotal            = "codeagent_runs_total"
+	RunDuration          = "codeagent_run_duration_seconds"
+	LastRunTimestamp     = "codeagent_last_run_timestamp_seconds"
+	LastRunExitCode      = "codeagent_last_run_exit_code"
+	metricTypeCounter    = "counter"
+	metricTypeGauge      = "gauge"
+	metricTypeHistogram  = "histogram"
+	histogramBucketLabel = "le"
+)
+
+// Task statuses used in the status label of codeagent_tasks_total.
+const (
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+	StatusTimeout   = "timeout"
+	StatusCancelled = "cancelled"
+	StatusSkipped   = "skipped"
+)
+
+// durationBuckets suit backend runs, which take seconds to hours.
+var durationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+
+type familyDef struct {
+	help    string
+	kind    string
+	buckets []float64
+}
+
+var families = map[string]familyDef{
+	TasksTotal:        {"Tasks run, by backend, agent and status (success, failed, timeout, cancelled, skipped).", metricTypeCounter, nil},
+	TaskDuration:      {"Time from task start to result, by backend and agent.", metricTypeHistogram, durationBuckets},
+	TaskQueueWait:     {"Time parallel tasks waited for a worker, backend or agent slot.", metricTypeHistogram, durationBuckets},
+	TerminationsTotal: {"Backends the wrapper had to terminate, by backend and reason (timeout, cancelled, lingering).", metricTypeCounter, nil},
+	KillsTotal:        {"Backends killed because they ignored the termination signal.", metricTypeCounter, nil},
+	RunsTotal:         {"Wrapper invocations, by mode and status.", metricTypeCounter, nil},
+	RunDuration:       {"Wall time of wrapper invocations, by mode.", metricTypeHistogram, durationBuckets},
+	LastRunTimestamp:  {"Unix time the last wrapper invocation finished.", metricTypeGauge, nil},
+	LastRunExitCode:   {"Exit code of the last wrapper invocation.", metricTypeGauge, nil},
+}
+
+// Labels are the label values of one series.
+type Labels map[string]string
+
+// render returns the labels as `k="v",...` sorted by name.
+func (l Labels) render() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+`="`+labelEscaper.Replace(l[k])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type series struct {
+	value   float64
+	buckets []float64 // cumulative counts per bucket, histograms only
+	sum     float64
+	count   float64
+}
+
+// Registry holds the series of every known family.
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]map[string]*series // family -> rendered labels -> series
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{series: make(map[string]map[string]*series)}
+}
+
+// Default is the registry the executor and the CLI record into.
+var Default = NewRegistry()
+
+func (r *Registry) get(name string, labels Labels) (*series, familyDef, bool) {
+	def, ok := families[name]
+	if !ok {
+		return nil, def, false
+	}
+	fam := r.series[name]
+	if fam == nil {
+		fam = make(map[string]*series)
+		r.series[name] = fam
+	}
+	key := labels.render()
+	s := fam[key]
+	if s == nil {
+		s = &series{}
+		if def.kind == metricTypeHistogram {
+			s.buckets = make([]float64, len(def.buckets))
+		}
+		fam[key] = s
+	}
+	return s, def, true
+}
+
+// Add increases a counter.
+func (r *Registry) Add(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, def, ok := r.get(name, labels); ok && def.kind == metricTypeCounter {
+		s.value += delta
+	}
+}
+
+// Inc increases a counter by one.
+func (r *Registry) Inc(name string, labels Labels) { r.Add(name, labels, 1) }
+
+// Set sets a gauge.
+func (r *Registry) Set(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, def, ok := r.get(name, labels); ok && def.kind == metricTypeGauge {
+		s.value = value
+	}
+}
+
+// Observe adds a sample to a histogram.
+func (r *Registry) Observe(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, def, ok := r.get(name, labels)
+	if !ok || def.kind != metricTypeHistogram {
+		return
+	}
+	for i, upper := range def.buckets {
+		if value <= upper {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// WriteText renders the registry in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.series))
+	for name := range r.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		def := families[name]
+		fam := r.series[name]
+		keys := make([]string, 0, len(fam))
+		for k := range fam {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, def.help, name, def.kind)
+		for _, key := range keys {
+			s := fam[key]
+			if def.kind != metricTypeHistogram {
+				fmt.Fprintf(&sb, "%s%s %s\n", name, braces(key), formatValue(s.value))
+				continue
+			}
+			for i, upper := range def.buckets {
+				fmt.Fprintf(&sb, "%s_bucket%s %s\n", name, braces(joinLabels(key, bucketLabel(upper))), formatValue(s.buckets[i]))
+			}
+			fmt.Fprintf(&sb, "%s_bucket%s %s\n", name, braces(joinLabels(key, bucketLabel(math.Inf(1)))), formatValue(s.count))
+			fmt.Fprintf(&sb, "%s_sum%s %s\n", name, braces(key), formatValue(s.sum))
+			fmt.Fprintf(&sb, "%s_count%s %s\n", name, braces(key), formatValue(s.count))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+func bucketLabel(upper float64) string {
+	return histogramBucketLabel + `="` + formatValue(upper) + `"`
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// TaskOutcome is what the executor reports for each task.
+type TaskOutcome struct {
+	Backend  string
+	Agent    string
+	ExitCode int
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Status classifies the outcome for the status label.
+func (o TaskOutcome) Status() string {
+	switch {
+	case o.Skipped:
+		return StatusSkipped
+	case o.ExitCode == 0:
+		return StatusSuccess
+	case o.ExitCode == 124:
+		return StatusTimeout
+	case o.ExitCode == 130:
+		return StatusCancelled
+	}
+	return StatusFailed
+}
+
+// RecordTask records one task outcome in Default.
+func RecordTask(o TaskOutcome) {
+	labels := Labels{"backend": valueOr(o.Backend, "unknown"), "agent": valueOr(o.Agent, "none")}
+	Default.Inc(TasksTotal, Labels{"backend": labels["backend"], "agent": labels["agent"], "status": o.Status()})
+	if o.Skipped {
+		return
+	}
+	Default.Observe(TaskDuration, labels, o.Duration.Seconds())
+}
+
+// RecordQueueWait records how long a parallel task waited to start.
+func RecordQueueWait(backend, agent string, wait time.Duration) {
+	Default.Observe(TaskQueueWait, Labels{"backend": valueOr(backend, "unknown"), "agent": valueOr(agent, "none")}, wait.Seconds())
+}
+
+// RecordTermination counts a backend the wrapper had to stop.
+func RecordTermination(backend, reason string) {
+	Default.Inc(TerminationsTotal, Labels{"backend": valueOr(backend, "unknown"), "reason": reason})
+}
+
+// RecordKill counts a backend that had to be killed.
+func RecordKill(backend string) {
+	Default.Inc(KillsTotal, Labels{"backend": valueOr(backend, "unknown")})
+}
+
+// RecordRun records a finished wrapper invocation.
+func RecordRun(mode string, exitCode int, duration time.Duration, finished time.Time) {
+	status := StatusSuccess
+	if exitCode != 0 {
+		status = StatusFailed
+	}
+	mode = valueOr(mode, "unknown")
+	Default.Inc(RunsTotal, Labels{"mode": mode, "status": status})
+	Default.Observe(RunDuration, Labels{"mode": mode}, duration.Seconds())
+	Default.Set(LastRunTimestamp, nil, float64(finished.Unix()))
+	Default.Set(LastRunExitCode, nil, float64(exitCode))
+}
+
+func valueOr(v, fallback string) string {
+	if strings.TrimSpace(v) == "" {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWriteTextExposition(t *testing.T) {
+	r := NewRegistry()
+	r.Inc(TasksTotal, Labels{"backend": "codex", "agent": "develop", "status": StatusSuccess})
+	r.Inc(TasksTotal, Labels{"backend": "codex", "agent": "develop", "status": StatusSuccess})
+	r.Inc(TerminationsTotal, Labels{"backend": "claude", "reason": `a"b\c` + "\n"})
+	r.Observe(TaskDuration, Labels{"backend": "codex", "agent": "develop"}, 12)
+	r.Set(LastRunExitCode, nil, 3)
+	r.Inc("unknown_metric", nil)
+
+	got := render(t, r)
+	for _, want := range []string{
+		"# TYPE codeagent_tasks_total counter\n",
+		`codeagent_tasks_total{agent="develop",backend="codex",status="success"} 2` + "\n",
+		`codeagent_backend_terminations_total{backend="claude",reason="a\"b\\c\n"} 1` + "\n",
+		"# TYPE codeagent_task_duration_seconds histogram\n",
+		`codeagent_task_duration_seconds_bucket{agent="develop",backend="codex",le="5"} 0` + "\n",
+		`codeagent_task_duration_seconds_bucket{agent="develop",backend="codex",le="15"} 1` + "\n",
+		`codeagent_task_duration_seconds_bucket{agent="develop",backend="codex",le="+Inf"} 1` + "\n",
+		`codeagent_task_duration_seconds_sum{agent="develop",backend="codex"} 12` + "\n",
+		`codeagent_task_duration_seconds_count{agent="develop",backend="codex"} 1` + "\n",
+		"codeagent_last_run_exit_code 3\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "unknown_metric") {
+		t.Fatalf("unknown metrics must be ignored:\n%s", got)
+	}
+}
+
+func TestTaskOutcomeStatus(t *testing.T) {
+	tests := map[string]TaskOutcome{
+		StatusSuccess:   {ExitCode: 0},
+		StatusFailed:    {ExitCode: 1},
+		StatusTimeout:   {ExitCode: 124},
+		StatusCancelled: {ExitCode: 130},
+		StatusSkipped:   {ExitCode: 1, Skipped: true},
+	}
+	for want, o := range tests {
+		if got := o.Status(); got != want {
+			t.Fatalf("Status(%+v) = %s, want %s", o, got, want)
+		}
+	}
+}
+
+func TestWriteTextfileMergesRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codeagent.prom")
+	labels := Labels{"backend": "codex", "agent": `we"ird`, "status": StatusFailed}
+
+	for i := 0; i < 2; i++ {
+		r := NewRegistry()
+		r.Inc(TasksTotal, labels)
+		r.Observe(TaskDuration, Labels{"backend": "codex", "agent": "none"}, 2)
+		r.Set(LastRunExitCode, nil, float64(i+1))
+		if err := r.WriteTextfile(path); err != nil {
+			t.Fatalf("WriteTextfile: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`codeagent_tasks_total{agent="we\"ird",backend="codex",status="failed"} 2` + "\n",
+		`codeagent_task_duration_seconds_bucket{agent="none",backend="codex",le="1"} 0` + "\n",
+		`codeagent_task_duration_seconds_bucket{agent="none",backend="codex",le="5"} 2` + "\n",
+		`codeagent_task_duration_seconds_sum{agent="none",backend="codex"} 4` + "\n",
+		`codeagent_task_duration_seconds_count{agent="none",backend="codex"} 2` + "\n",
+		"codeagent_last_run_exit_code 2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("lock file should be removed: %v", err)
+	}
+}
+
+func TestWriteTextfileConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codeagent.prom")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := NewRegistry()
+			r.Inc(RunsTotal, Labels{"mode": "single", "status": StatusSuccess})
+			if err := r.WriteTextfile(path); err != nil {
+				t.Errorf("WriteTextfile: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, _ := os.ReadFile(path)
+	if want := `codeagent_runs_total{mode="single",status="success"} 8`; !strings.Contains(string(data), want) {
+		t.Fatalf("missing %q in:\n%s", want, data)
+	}
+}
+
+func TestWriteTextfileBreaksStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codeagent.prom")
+	lock := path + ".lock"
+	if err := os.WriteFile(lock, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry()
+	r.Set(LastRunExitCode, nil, 0)
+	if err := r.WriteTextfile(path); err != nil {
+		t.Fatalf("WriteTextfile: %v", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	r := NewRegistry()
+	r.Inc(KillsTotal, Labels{"backend": "gemini"})
+	addr, stop, err := r.Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	defer stop()
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if want := `codeagent_backend_kills_total{backend="gemini"} 1`; !strings.Contains(string(body), want) {
+		t.Fatalf("missing %q in:\n%s", want, body)
+	}
+}
@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	textfileLockWait  = 5 * time.Second
+	textfileLockStale = 30 * time.Second
+)
+
+// WriteTextfile merges the registry into the node_exporter textfile at path:
+// counters and histograms are added to what earlier runs wrote, gauges are
+// replaced. The file is rewritten atomically while holding path+".lock", so
+// concurrent runs do not lose each other's counts. node_exporter only reads
+// files ending in .prom.
+func (r *Registry) WriteTextfile(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	merged := NewRegistry()
+	if data, err := os.ReadFile(path); err == nil {
+		if err := merged.parseText(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	merged.merge(r)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(merged.WriteText(tmp), tmp.Chmod(0o644), tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// merge adds other's counters and histograms to r and copies its gauges.
+func (r *Registry) merge(other *Registry) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, fam := range other.series {
+		def := families[name]
+		for key, src := range fam {
+			dst := r.seriesByKey(name, key, def)
+			switch def.kind {
+			case metricTypeGauge:
+				dst.value = src.value
+			case metricTypeHistogram:
+				for i := range dst.buckets {
+					dst.buckets[i] += src.buckets[i]
+				}
+				dst.sum += src.sum
+				dst.count += src.count
+			default:
+				dst.value += src.value
+			}
+		}
+	}
+}
+
+func (r *Registry) seriesByKey(name, key string, def familyDef) *series {
+	fam := r.series[name]
+	if fam == nil {
+		fam = make(map[string]*series)
+		r.series[name] = fam
+	}
+	s := fam[key]
+	if s == nil {
+		s = &series{}
+		if def.kind == metricTypeHistogram {
+			s.buckets = make([]float64, len(def.buckets))
+		}
+		fam[key] = s
+	}
+	return s
+}
+
+// parseText loads samples of known families from text written by WriteText.
+// Unknown metrics and histogram buckets that no longer exist are dropped.
+func (r *Registry) parseText(rd io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scanner := bufio.NewScanner(rd)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, value, err := parseSample(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		if def, ok := families[name]; ok && def.kind != metricTypeHistogram {
+			r.seriesByKey(name, labels.render(), def).value = value
+			continue
+		}
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			base, found := strings.CutSuffix(name, suffix)
+			def, ok := families[base]
+			if !found || !ok || def.kind != metricTypeHistogram {
+				continue
+			}
+			le := labels[histogramBucketLabel]
+			delete(labels, histogramBucketLabel)
+			s := r.seriesByKey(base, labels.render(), def)
+			switch suffix {
+			case "_sum":
+				s.sum = value
+			case "_count":
+				s.count = value
+			default:
+				for i, upper := range def.buckets {
+					if formatValue(upper) == le {
+						s.buckets[i] = value
+					}
+				}
+			}
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// parseSample splits `name{k="v",...} value` into its parts.
+func parseSample(line string) (string, Labels, float64, error) {
+	labels := Labels{}
+	name := line
+	rest := ""
+	if idx := strings.IndexByte(line, '{'); idx >= 0 {
+		name = line[:idx]
+		var err error
+		labels, rest, err = parseLabels(line[idx+1:])
+		if err != nil {
+			return "", nil, 0, err
+		}
+	} else if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+		name, rest = line[:idx], line[idx:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, fmt.Errorf("missing value in %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("invalid value in %q", line)
+	}
+	return strings.TrimSpace(name), labels, value, nil
+}
+
+// parseLabels reads `k="v",...}` and returns what follows the closing brace.
+func parseLabels(s string) (Labels, string, error) {
+	labels := Labels{}
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+		eq := strings.Index(s, `="`)
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("malformed labels")
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+		var sb strings.Builder
+		closed := false
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					sb.WriteByte('\n')
+				} else {
+					sb.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				s = s[i+1:]
+				closed = true
+				break
+			}
+			sb.WriteByte(c)
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated label value")
+		}
+		labels[key] = sb.String()
+	}
+}
+
+// lockFile takes an exclusive lock by creating path, removing locks left
+// behind by crashed runs.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(textfileLockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > textfileLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,242 @@
+// Package mock is a scriptable stand-in for the backend CLIs. The mock
+// backend runs `<wrapper> __mock [-m model] [-r session] <prompt|->`, which
+// emits the stream of the scripted format so orchestration, skills and the
+// parser can be tested without installing codex, claude, gemini or opencode.
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Command is the hidden subcommand that runs the mock backend.
+const Command = "__mock"
+
+// ScriptFileEnv names a JSON script file; ScriptEnv holds the script inline
+// and is used when ScriptFileEnv is unset.
+const (
+	ScriptFileEnv = "CODEAGENT_MOCK_SCRIPT"
+	ScriptEnv     = "CODEAGENT_MOCK"
+)
+
+// Stream formats.
+const (
+	FormatCodex    = "codex"
+	FormatClaude   = "claude"
+	FormatGemini   = "gemini"
+	FormatOpencode = "opencode"
+)
+
+// Response is what the mock does for one prompt.
+type Response struct {
+	Match        string   `json:"match,omitempty"`         // regexp on the prompt; only used in "responses"
+	Format       string   `json:"format,omitempty"`        // FormatCodex (default), FormatClaude, FormatGemini or FormatOpencode
+	Message      *string  `json:"message,omitempty"`       // default "mock response to: <first prompt line>"; "" emits none
+	SessionID    string   `json:"session_id,omitempty"`    // default the resumed session, else derived from the prompt
+	Delay        string   `json:"delay,omitempty"`         // Go duration before the first event
+	Stderr       []string `json:"stderr,omitempty"`        // lines written to stderr before the events
+	Lines        []string `json:"lines,omitempty"`         // written to stdout verbatim before the events, e.g. malformed JSON
+	OverlongLine int      `json:"overlong_line,omitempty"` // bytes of one oversized line written before the events
+	Linger       string   `json:"linger,omitempty"`        // Go duration to keep running after the last event
+	ExitCode     int      `json:"exit_code,omitempty"`
+}
+
+// Script is the mock's configuration: the first entry of Responses whose
+// Match matches the prompt, else the top-level response.
+type Script struct {
+	Response
+	Responses []Response `json:"responses,omitempty"`
+}
+
+var sleepFn = time.Sleep
+
+// LoadScript reads the script from ScriptFileEnv or ScriptEnv. Without
+// either, every prompt gets the default response.
+func LoadScript() (Script, error) {
+	var s Script
+	data := []byte(strings.TrimSpace(os.Getenv(ScriptEnv)))
+	source := ScriptEnv
+	if path := strings.TrimSpace(os.Getenv(ScriptFileEnv)); path != "" {
+		raw, err := os.ReadFile(path) // #nosec G304 -- script chosen by the user
+		if err != nil {
+			return s, err
+		}
+		data, source = raw, path
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("invalid mock script %s: %w", source, err)
+	}
+	return s, s.validate()
+}
+
+func (s Script) validate() error {
+	if err := s.Response.validate(); err != nil {
+		return err
+	}
+	for i, r := range s.Responses {
+		if _, err := regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("responses[%d]: match: %w", i, err)
+		}
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("responses[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r Response) validate() error {
+	switch r.Format {
+	case "", FormatCodex, FormatClaude, FormatGemini, FormatOpencode:
+	default:
+		return fmt.Errorf("format must be one of codex, claude, gemini, opencode, got %q", r.Format)
+	}
+	for name, raw := range map[string]string{"delay": r.Delay, "linger": r.Linger} {
+		if raw == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(raw); err != nil || d < 0 {
+			return fmt.Errorf("%s: invalid duration %q", name, raw)
+		}
+	}
+	return nil
+}
+
+// Select returns the response for prompt.
+func (s Script) Select(prompt string) Response {
+	for _, r := range s.Responses {
+		if re, err := regexp.Compile(r.Match); err == nil && re.MatchString(prompt) {
+			return r
+		}
+	}
+	return s.Response
+}
+
+// Main runs the mock backend and returns its exit code.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(Command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	model := fs.String("m", "", "model")
+	resume := fs.String("r", "", "session to resume")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(stderr, "usage: %s [-m model] [-r session] <prompt|->\n", Command)
+		return 2
+	}
+	prompt := fs.Arg(0)
+	if prompt == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "mock: read stdin: %v\n", err)
+			return 1
+		}
+		prompt = string(data)
+	}
+
+	script, err := LoadScript()
+	if err != nil {
+		fmt.Fprintf(stderr, "mock: %v\n", err)
+		return 1
+	}
+	r := script.Select(prompt)
+
+	if d, _ := time.ParseDuration(r.Delay); d > 0 {
+		sleepFn(d)
+	}
+	for _, line := range r.Stderr {
+		fmt.Fprintln(stderr, line)
+	}
+	for _, line := range r.Lines {
+		fmt.Fprintln(stdout, line)
+	}
+	if r.OverlongLine > 0 {
+		fmt.Fprintln(stdout, overlongLine(r.OverlongLine))
+	}
+	sessionID := r.SessionID
+	if sessionID == "" {
+		sessionID = *resume
+	}
+	if sessionID == "" {
+		sum := sha256.Sum256([]byte(prompt))
+		sessionID = "mock-" + hex.EncodeToString(sum[:6])
+	}
+	message := "mock response to: " + firstLine(prompt)
+	if r.Message != nil {
+		message = *r.Message
+	}
+	for _, event := range events(r.Format, sessionID, *model, message) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintln(stdout, string(data))
+	}
+	if d, _ := time.ParseDuration(r.Linger); d > 0 {
+		sleepFn(d)
+	}
+	return r.ExitCode
+}
+
+// events returns the stream a backend of format would print for one turn.
+func events(format, sessionID, model, message string) []any {
+	if model == "" {
+		model = "mock"
+	}
+	type obj = map[string]any
+	switch format {
+	case FormatClaude:
+		out := []any{obj{"type": "system", "subtype": "init", "session_id": sessionID, "model": model}}
+		if message != "" {
+			out = append(out, obj{"type": "assistant", "session_id": sessionID, "message": obj{"role": "assistant", "content": []any{obj{"type": "text", "text": message}}}})
+		}
+		return append(out, obj{"type": "result", "subtype": "success", "is_error": false, "session_id": sessionID, "result": message})
+	case FormatGemini:
+		out := []any{obj{"type": "init", "session_id": sessionID, "model": model}}
+		if message != "" {
+			out = append(out, obj{"type": "message", "role": "assistant", "content": message, "delta": true})
+		}
+		return append(out, obj{"type": "result", "status": "success"})
+	case FormatOpencode:
+		out := []any{obj{"type": "step_start", "sessionID": sessionID, "part": obj{"type": "step-start", "sessionID": sessionID}}}
+		if message != "" {
+			out = append(out, obj{"type": "text", "sessionID": sessionID, "part": obj{"type": "text", "sessionID": sessionID, "text": message}})
+		}
+		return append(out, obj{"type": "step_finish", "sessionID": sessionID, "part": obj{"type": "step-finish", "sessionID": sessionID, "reason": "stop"}})
+	default:
+		out := []any{obj{"type": "thread.started", "thread_id": sessionID}, obj{"type": "turn.started"}}
+		if message != "" {
+			out = append(out, obj{"type": "item.completed", "item": obj{"id": "item_0", "type": "agent_message", "text": message}})
+		}
+		return append(out, obj{"type": "turn.completed"})
+	}
+}
+
+// overlongLine is a JSON object of n bytes.
+func overlongLine(n int) string {
+	const head, tail = `{"type":"mock.padding","data":"`, `"}`
+	if pad := n - len(head) - len(tail); pad > 0 {
+		return head + strings.Repeat("x", pad) + tail
+	}
+	return strings.Repeat("x", n)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	if len(s) > 80 {
+		s = s[:80] + "..."
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package mock
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	parser "codeagent-wrapper/internal/parser"
+)
+
+func runMock(t *testing.T, script string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	t.Setenv(ScriptFileEnv, "")
+	t.Setenv(ScriptEnv, script)
+	var out, errOut bytes.Buffer
+	code = Main(args, strings.NewReader("prompt from stdin"), &out, &errOut)
+	return out.String(), errOut.String(), code
+}
+
+func TestMain_FormatsParse(t *testing.T) {
+	for _, format := range []string{FormatCodex, FormatClaude, FormatGemini, FormatOpencode} {
+		t.Run(format, func(t *testing.T) {
+			out, _, code := runMock(t, `{"format":"`+format+`","message":"done","session_id":"sess-1"}`, "--", "do it")
+			if code != 0 {
+				t.Fatalf("exit code = %d", code)
+			}
+			msg, sid := parser.ParseJSONStreamInternal(strings.NewReader(out), nil, nil, nil, nil)
+			if msg != "done" || sid != "sess-1" {
+				t.Fatalf("parsed (%q, %q), want (done, sess-1)\n%s", msg, sid, out)
+			}
+		})
+	}
+}
+
+func TestMain_Defaults(t *testing.T) {
+	out, _, code := runMock(t, "", "-")
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	msg, sid := parser.ParseJSONStreamInternal(strings.NewReader(out), nil, nil, nil, nil)
+	if msg != "mock response to: prompt from stdin" {
+		t.Errorf("message = %q", msg)
+	}
+	if !strings.HasPrefix(sid, "mock-") {
+		t.Errorf("session = %q", sid)
+	}
+
+	out, _, _ = runMock(t, "", "-m", "fast", "-r", "mock-abc", "--", "again")
+	if _, sid := parser.ParseJSONStreamInternal(strings.NewReader(out), nil, nil, nil, nil); sid != "mock-abc" {
+		t.Errorf("resumed session = %q", sid)
+	}
+}
+
+func TestMain_ScriptedFailure(t *testing.T) {
+	out, errOut, code := runMock(t, `{"message":"","stderr":["rate limited"],"lines":["not json"],"overlong_line":100,"exit_code":3}`, "--", "x")
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if errOut != "rate limited\n" {
+		t.Errorf("stderr = %q", errOut)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if lines[0] != "not json" || len(lines[1]) != 100 {
+		t.Errorf("stdout starts %q, %d bytes", lines[0], len(lines[1]))
+	}
+	if strings.Contains(out, "agent_message") {
+		t.Errorf("empty message still emitted an agent_message:\n%s", out)
+	}
+}
+
+func TestMain_DelayAndLinger(t *testing.T) {
+	orig := sleepFn
+	t.Cleanup(func() { sleepFn = orig })
+	var slept []time.Duration
+	sleepFn = func(d time.Duration) { slept = append(slept, d) }
+
+	if _, _, code := runMock(t, `{"delay":"2s","linger":"1m"}`, "--", "x"); code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	if len(slept) != 2 || slept[0] != 2*time.Second || slept[1] != time.Minute {
+		t.Errorf("slept %v", slept)
+	}
+}
+
+func TestScript_Select(t *testing.T) {
+	out, _, _ := runMock(t, `{"message":"fallback","responses":[{"match":"^review","message":"LGTM"},{"match":"test","exit_code":1}]}`, "--", "review the diff")
+	if msg, _ := parser.ParseJSONStreamInternal(strings.NewReader(out), nil, nil, nil, nil); msg != "LGTM" {
+		t.Errorf("matched message = %q", msg)
+	}
+	if _, _, code := runMock(t, `{"responses":[{"match":"test","exit_code":1}]}`, "--", "run the tests"); code != 1 {
+		t.Errorf("matched exit code = %d", code)
+	}
+	out, _, _ = runMock(t, `{"message":"fallback","responses":[{"match":"^review","message":"LGTM"}]}`, "--", "write code")
+	if msg, _ := parser.ParseJSONStreamInternal(strings.NewReader(out), nil, nil, nil, nil); msg != "fallback" {
+		t.Errorf("fallback message = %q", msg)
+	}
+}
+
+func TestLoadScript_Invalid(t *testing.T) {
+	for name, script := range map[string]string{
+		"json":   `{`,
+		"format": `{"format":"cursor"}`,
+		"delay":  `{"delay":"soon"}`,
+		"match":  `{"responses":[{"match":"("}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, errOut, code := runMock(t, script, "--", "x")
+			if code != 1 || !strings.HasPrefix(errOut, "mock: ") {
+				t.Errorf("code %d, stderr %q", code, errOut)
+			}
+		})
+	}
+}
+
+func TestMain_Usage(t *testing.T) {
+	if _, errOut, code := runMock(t, ""); code != 2 || !strings.Contains(errOut, "usage:") {
+		t.Errorf("code %d, stderr %q", code, errOut)
+	}
+}
@@ -0,0 +1,366 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Transcript entry types.
+const (
+	EntryPrompt     = "prompt"
+	EntryAssistant  = "assistant"
+	EntryReasoning  = "reasoning"
+	EntryToolCall   = "tool_call"
+	EntryToolResult = "tool_result"
+	EntryCommand    = "command" // a shell command with its output, reported as one event
+	EntryError      = "error"
+	EntryResult     = "result"
+)
+
+// TranscriptEntry is one line of a normalized task transcript. The schema is
+// the same for every backend; fields that do not apply are omitted.
+type TranscriptEntry struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	// prompt and result entries
+	TaskID     string `json:"task_id,omitempty"`
+	Backend    string `json:"backend,omitempty"`
+	Model      string `json:"model,omitempty"`
+	SessionID  string `json:"session_id,omitempty"`
+	WorkDir    string `json:"workdir,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	Text     string          `json:"text,omitempty"`
+	Tool     string          `json:"tool,omitempty"`
+	CallID   string          `json:"call_id,omitempty"`
+	Input    json.RawMessage `json:"input,omitempty"`
+	Command  string          `json:"command,omitempty"` // shell command of a command or shell tool_call entry
+	Output   string          `json:"output,omitempty"`
+	ExitCode *int            `json:"exit_code,omitempty"`
+	IsError  bool            `json:"is_error,omitempty"`
+}
+
+// TranscriptNormalizer turns one backend's stream-json events into
+// transcript entries. It keeps state between lines because some backends
+// stream assistant text in pieces.
+type TranscriptNormalizer struct {
+	backend   string
+	assistant strings.Builder
+	tools     map[string]string // call ID -> tool name, for results that only carry the ID
+}
+
+// NewTranscriptNormalizer returns a normalizer for the named backend
+// (codex, claude, gemini or opencode).
+func NewTranscriptNormalizer(backend string) *TranscriptNormalizer {
+	return &TranscriptNormalizer{backend: backend, tools: make(map[string]string)}
+}
+
+// Feed normalizes one stdout line. Lines that are not JSON or carry nothing
+// worth keeping yield no entries.
+func (n *TranscriptNormalizer) Feed(line []byte) []TranscriptEntry {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return nil
+	}
+	var entries []TranscriptEntry
+	switch n.backend {
+	case "claude":
+		entries = normalizeClaudeEvent(line)
+	case "gemini":
+		entries = n.feedGemini(line)
+	case "opencode":
+		entries = normalizeOpencodeEvent(line)
+	default:
+		entries = normalizeCodexEvent(line)
+	}
+	for i := range entries {
+		e := &entries[i]
+		switch {
+		case e.Type == EntryToolCall && e.CallID != "":
+			n.tools[e.CallID] = e.Tool
+		case e.Type == EntryToolResult && e.Tool == "":
+			e.Tool = n.tools[e.CallID]
+		}
+	}
+	return entries
+}
+
+// Flush returns entries still buffered at the end of the stream.
+func (n *TranscriptNormalizer) Flush() []TranscriptEntry {
+	if n.assistant.Len() == 0 {
+		return nil
+	}
+	text := n.assistant.String()
+	n.assistant.Reset()
+	return []TranscriptEntry{{Type: EntryAssistant, Text: text}}
+}
+
+// shellCommand returns the command line of a shell tool call, or "".
+func shellCommand(tool string, input json.RawMessage) string {
+	switch strings.ToLower(tool) {
+	case "bash", "shell", "local_shell", "run_shell_command":
+	default:
+		return ""
+	}
+	var args struct {
+		Command json.RawMessage `json:"command"`
+	}
+	if json.Unmarshal(input, &args) != nil {
+		return ""
+	}
+	return commandString(args.Command)
+}
+
+// commandString accepts a command given as a string or an argv list.
+func commandString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var argv []string
+	if json.Unmarshal(raw, &argv) == nil {
+		return strings.Join(argv, " ")
+	}
+	return ""
+}
+
+// rawText renders a tool result that may be a string, a list of content
+// blocks or arbitrary JSON.
+func rawText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &blocks) == nil {
+		var parts []string
+		for _, b := range blocks {
+			if b.Text != "" {
+				parts = append(parts, b.Text)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, "\n")
+		}
+	}
+	return string(raw)
+}
+
+// --- codex exec --json: item.completed events carry the finished items ---
+
+func normalizeCodexEvent(line []byte) []TranscriptEntry {
+	var event struct {
+		Type    string          `json:"type"`
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+		Item    json.RawMessage `json:"item"`
+	}
+	if json.Unmarshal(line, &event) != nil {
+		return nil
+	}
+	switch event.Type {
+	case "error":
+		return []TranscriptEntry{{Type: EntryError, Text: event.Message}}
+	case "turn.failed":
+		var e struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(event.Error, &e)
+		return []TranscriptEntry{{Type: EntryError, Text: e.Message}}
+	case "item.completed":
+	default:
+		return nil
+	}
+
+	var item struct {
+		ID               string          `json:"id"`
+		Type             string          `json:"type"`
+		Text             interface{}     `json:"text"`
+		Command          json.RawMessage `json:"command"`
+		AggregatedOutput string          `json:"aggregated_output"`
+		ExitCode         *int            `json:"exit_code"`
+		Status           string          `json:"status"`
+		Changes          json.RawMessage `json:"changes"`
+		Server           string          `json:"server"`
+		Tool             string          `json:"tool"`
+		Arguments        json.RawMessage `json:"arguments"`
+		Result           json.RawMessage `json:"result"`
+		Error            json.RawMessage `json:"error"`
+		Query            string          `json:"query"`
+		Items            json.RawMessage `json:"items"`
+		Message          string          `json:"message"`
+	}
+	if json.Unmarshal(event.Item, &item) != nil {
+		return nil
+	}
+	switch item.Type {
+	case "agent_message":
+		return []TranscriptEntry{{Type: EntryAssistant, Text: NormalizeText(item.Text)}}
+	case "reasoning":
+		return []TranscriptEntry{{Type: EntryReasoning, Text: NormalizeText(item.Text)}}
+	case "command_execution":
+		return []TranscriptEntry{{
+			Type:     EntryCommand,
+			CallID:   item.ID,
+			Command:  commandString(item.Command),
+			Output:   item.AggregatedOutput,
+			ExitCode: item.ExitCode,
+			IsError:  item.Status == "failed" || (item.ExitCode != nil && *item.ExitCode != 0),
+		}}
+	case "file_change":
+		return []TranscriptEntry{{Type: EntryToolCall, CallID: item.ID, Tool: "file_change", Input: item.Changes, IsError: item.Status == "failed"}}
+	case "mcp_tool_call":
+		tool := item.Tool
+		if item.Server != "" {
+			tool = item.Server + "." + item.Tool
+		}
+		call := TranscriptEntry{Type: EntryToolCall, CallID: item.ID, Tool: tool, Input: item.Arguments}
+		res := TranscriptEntry{Type: EntryToolResult, CallID: item.ID, Tool: tool, Output: rawText(item.Result)}
+		if errText := rawText(item.Error); errText != "" {
+			res.Output, res.IsError = errText, true
+		}
+		return []TranscriptEntry{call, res}
+	case "web_search":
+		input, _ := json.Marshal(map[string]string{"query": item.Query})
+		return []TranscriptEntry{{Type: EntryToolCall, CallID: item.ID, Tool: "web_search", Input: input}}
+	case "todo_list":
+		return []TranscriptEntry{{Type: EntryToolCall, CallID: item.ID, Tool: "todo_list", Input: item.Items}}
+	case "error":
+		return []TranscriptEntry{{Type: EntryError, Text: item.Message}}
+	}
+	return nil
+}
+
+// --- claude --output-format stream-json: assistant/user messages with content blocks ---
+
+func normalizeClaudeEvent(line []byte) []TranscriptEntry {
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(line, &event) != nil || (event.Type != "assistant" && event.Type != "user") {
+		return nil
+	}
+	var blocks []struct {
+		Type      string          `json:"type"`
+		Text      string          `json:"text"`
+		Thinking  string          `json:"thinking"`
+		ID        string          `json:"id"`
+		Name      string          `json:"name"`
+		Input     json.RawMessage `json:"input"`
+		ToolUseID string          `json:"tool_use_id"`
+		Content   json.RawMessage `json:"content"`
+		IsError   bool            `json:"is_error"`
+	}
+	if json.Unmarshal(event.Message.Content, &blocks) != nil {
+		return nil
+	}
+	var entries []TranscriptEntry
+	for _, b := range blocks {
+		switch {
+		case event.Type == "assistant" && b.Type == "text" && b.Text != "":
+			entries = append(entries, TranscriptEntry{Type: EntryAssistant, Text: b.Text})
+		case event.Type == "assistant" && b.Type == "thinking" && b.Thinking != "":
+			entries = append(entries, TranscriptEntry{Type: EntryReasoning, Text: b.Thinking})
+		case event.Type == "assistant" && b.Type == "tool_use":
+			entries = append(entries, TranscriptEntry{Type: EntryToolCall, CallID: b.ID, Tool: b.Name, Input: b.Input, Command: shellCommand(b.Name, b.Input)})
+		case event.Type == "user" && b.Type == "tool_result":
+			entries = append(entries, TranscriptEntry{Type: EntryToolResult, CallID: b.ToolUseID, Output: rawText(b.Content), IsError: b.IsError})
+		}
+	}
+	return entries
+}
+
+// --- gemini -o stream-json: assistant text arrives as deltas ---
+
+func (n *TranscriptNormalizer) feedGemini(line []byte) []TranscriptEntry {
+	var event struct {
+		Type       string          `json:"type"`
+		Role       string          `json:"role"`
+		Content    string          `json:"content"`
+		ToolName   string          `json:"tool_name"`
+		ToolID     string          `json:"tool_id"`
+		Parameters json.RawMessage `json:"parameters"`
+		Status     string          `json:"status"`
+		Output     string          `json:"output"`
+		Message    string          `json:"message"`
+		Error      struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(line, &event) != nil {
+		return nil
+	}
+	if event.Type == "message" && event.Role == "assistant" {
+		n.assistant.WriteString(event.Content)
+		return nil
+	}
+	entries := n.Flush()
+	switch event.Type {
+	case "tool_use":
+		entries = append(entries, TranscriptEntry{Type: EntryToolCall, CallID: event.ToolID, Tool: event.ToolName, Input: event.Parameters, Command: shellCommand(event.ToolName, event.Parameters)})
+	case "tool_result":
+		res := TranscriptEntry{Type: EntryToolResult, CallID: event.ToolID, Output: event.Output, IsError: event.Status == "error"}
+		if res.IsError && res.Output == "" {
+			res.Output = event.Error.Message
+		}
+		entries = append(entries, res)
+	case "error":
+		entries = append(entries, TranscriptEntry{Type: EntryError, Text: event.Message})
+	}
+	return entries
+}
+
+// --- opencode run --format json: text, reasoning and tool parts ---
+
+func normalizeOpencodeEvent(line []byte) []TranscriptEntry {
+	var event struct {
+		Type string `json:"type"`
+		Part struct {
+			Type   string `json:"type"`
+			Text   string `json:"text"`
+			CallID string `json:"callID"`
+			Tool   string `json:"tool"`
+			State  struct {
+				Status string          `json:"status"`
+				Input  json.RawMessage `json:"input"`
+				Output string          `json:"output"`
+				Error  string          `json:"error"`
+			} `json:"state"`
+		} `json:"part"`
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(line, &event) != nil {
+		return nil
+	}
+	part := event.Part
+	switch {
+	case event.Type == "error":
+		return []TranscriptEntry{{Type: EntryError, Text: rawText(event.Error)}}
+	case part.Type == "text" && part.Text != "":
+		return []TranscriptEntry{{Type: EntryAssistant, Text: part.Text}}
+	case part.Type == "reasoning" && part.Text != "":
+		return []TranscriptEntry{{Type: EntryReasoning, Text: part.Text}}
+	case part.Type == "tool" && (part.State.Status == "completed" || part.State.Status == "error"):
+		call := TranscriptEntry{Type: EntryToolCall, CallID: part.CallID, Tool: part.Tool, Input: part.State.Input, Command: shellCommand(part.Tool, part.State.Input)}
+		res := TranscriptEntry{Type: EntryToolResult, CallID: part.CallID, Tool: part.Tool, Output: part.State.Output}
+		if part.State.Status == "error" {
+			res.Output, res.IsError = part.State.Error, true
+		}
+		return []TranscriptEntry{call, res}
+	}
+	return nil
+}
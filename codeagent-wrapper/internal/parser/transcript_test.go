@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// summarize renders entries as "type:tool:command:text/output" for compact
+// comparisons.
+func summarize(entries []TranscriptEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		text := e.Text
+		if e.Output != "" {
+			text = e.Output
+		}
+		s := e.Type + ":" + e.Tool + ":" + e.Command + ":" + text
+		if e.IsError {
+			s += ":error"
+		}
+		out[i] = s
+	}
+	return out
+}
+
+func feedAll(backend string, lines ...string) []string {
+	n := NewTranscriptNormalizer(backend)
+	var entries []TranscriptEntry
+	for _, line := range lines {
+		entries = append(entries, n.Feed([]byte(line))...)
+	}
+	return summarize(append(entries, n.Flush()...))
+}
+
+func TestTranscriptNormalizer(t *testing.T) {
+	tests := []struct {
+		backend string
+		lines   []string
+		want    []string
+	}{
+		{
+			backend: "codex",
+			lines: []string{
+				`{"type":"thread.started","thread_id":"t1"}`,
+				`{"type":"item.started","item":{"id":"i1","type":"command_execution","command":"ls","status":"in_progress"}}`,
+				`{"type":"item.completed","item":{"id":"i0","type":"reasoning","text":"thinking"}}`,
+				`{"type":"item.completed","item":{"id":"i1","type":"command_execution","command":"ls","aggregated_output":"a.go","exit_code":2,"status":"failed"}}`,
+				`{"type":"item.completed","item":{"id":"i2","type":"mcp_tool_call","server":"docs","tool":"search","arguments":{"q":"x"},"result":{"content":[{"type":"text","text":"found"}]}}}`,
+				`{"type":"item.completed","item":{"id":"i3","type":"agent_message","text":"done"}}`,
+				`{"type":"turn.failed","error":{"message":"quota"}}`,
+			},
+			want: []string{
+				"reasoning:::thinking",
+				"command::ls:a.go:error",
+				"tool_call:docs.search::",
+				`tool_result:docs.search::{"content":[{"type":"text","text":"found"}]}`,
+				"assistant:::done",
+				"error:::quota",
+			},
+		},
+		{
+			backend: "claude",
+			lines: []string{
+				`{"type":"system","subtype":"init","session_id":"s1"}`,
+				`{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"checking"},{"type":"tool_use","id":"tu1","name":"Bash","input":{"command":"go test"}}]}}`,
+				`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"tu1","content":[{"type":"text","text":"FAIL"}],"is_error":true}]}}`,
+				`{"type":"result","subtype":"success","result":"fixed","session_id":"s1"}`,
+			},
+			want: []string{
+				"reasoning:::hmm",
+				"assistant:::checking",
+				"tool_call:Bash:go test:",
+				"tool_result:Bash::FAIL:error",
+			},
+		},
+		{
+			backend: "gemini",
+			lines: []string{
+				`{"type":"init","session_id":"g1","model":"gemini-2.5-pro"}`,
+				`{"type":"message","role":"user","content":"hi"}`,
+				`{"type":"message","role":"assistant","content":"Let me ","delta":true}`,
+				`{"type":"message","role":"assistant","content":"look.","delta":true}`,
+				`{"type":"tool_use","tool_name":"run_shell_command","tool_id":"c1","parameters":{"command":"ls"}}`,
+				`{"type":"tool_result","tool_id":"c1","status":"error","error":{"message":"denied"}}`,
+				`{"type":"message","role":"assistant","content":"Done.","delta":true}`,
+				`{"type":"result","status":"success"}`,
+			},
+			want: []string{
+				"assistant:::Let me look.",
+				"tool_call:run_shell_command:ls:",
+				"tool_result:run_shell_command::denied:error",
+				"assistant:::Done.",
+			},
+		},
+		{
+			backend: "opencode",
+			lines: []string{
+				`{"type":"step_start","sessionID":"o1","part":{"type":"step-start"}}`,
+				`{"type":"tool_use","sessionID":"o1","part":{"type":"tool","callID":"c1","tool":"bash","state":{"status":"completed","input":{"command":"pwd"},"output":"/repo"}}}`,
+				`{"type":"text","sessionID":"o1","part":{"type":"text","text":"in /repo"}}`,
+			},
+			want: []string{
+				"tool_call:bash:pwd:",
+				"tool_result:bash::/repo",
+				"assistant:::in /repo",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			got := feedAll(tt.backend, tt.lines...)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestTranscriptNormalizer_IgnoresNonJSON(t *testing.T) {
+	n := NewTranscriptNormalizer("codex")
+	for _, line := range []string{"", "plain text", "{not json", `{"type":"turn.started"}`} {
+		if got := n.Feed([]byte(line)); len(got) != 0 {
+			t.Fatalf("Feed(%q) = %+v", line, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package resources caps the memory, CPU time and process count of a backend
+// and everything it starts. On Linux it uses a cgroup v2 child of the
+// wrapper's own cgroup when memory and pids are delegated to it, and falls
+// back to rlimits otherwise; CPU time is always an rlimit. The launcher
+// applies the plan between fork and exec.
+package resources
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+	utils "codeagent-wrapper/internal/utils"
+)
+
+// Rlimit is a setrlimit(2) call the launcher makes before exec.
+type Rlimit struct {
+	Resource int    `json:"resource"`
+	Cur      uint64 `json:"cur"`
+	Max      uint64 `json:"max"`
+}
+
+// Guard is the enforcement set up for one backend run.
+type Guard struct {
+	Limits  config.ResourceLimits
+	Cgroup  string   // the task's cgroup directory; "" when only rlimits apply
+	Rlimits []Rlimit // set by the launcher
+	// Fallback explains why memory or process limits are not enforced
+	// through a cgroup; empty when they are.
+	Fallback string
+}
+
+// Enforced reports whether the launcher has anything to apply.
+func (g *Guard) Enforced() bool {
+	return g != nil && (g.Cgroup != "" || len(g.Rlimits) > 0)
+}
+
+// Describe summarizes how the limits are enforced, for logs and dry runs.
+func (g *Guard) Describe() string {
+	if g == nil {
+		return ""
+	}
+	if g.Cgroup != "" {
+		return fmt.Sprintf("%s (cgroup %s)", g.Limits, g.Cgroup)
+	}
+	return fmt.Sprintf("%s (rlimits)", g.Limits)
+}
+
+var (
+	memoryPattern = regexp.MustCompile(`(?i)(out of memory|cannot allocate memory|ENOMEM|heap out of memory|MemoryError|memory allocation of \d+ bytes failed)`)
+	procsPattern  = regexp.MustCompile(`(?i)(fork: (retry: )?resource temporarily unavailable|EAGAIN.*(fork|spawn)|(fork|spawn).*EAGAIN|can't fork|cannot fork)`)
+)
+
+// stderrBreaches looks for the messages backends print when an rlimit makes
+// an allocation or a fork fail; those do not kill the process by themselves.
+func stderrBreaches(l config.ResourceLimits, stderr string) []string {
+	var reasons []string
+	if l.Memory > 0 && memoryPattern.MatchString(stderr) {
+		reasons = append(reasons, memoryReason(l, "allocation failed"))
+	}
+	if l.MaxProcs > 0 && procsPattern.MatchString(stderr) {
+		reasons = append(reasons, procsReason(l, "fork failed"))
+	}
+	return reasons
+}
+
+func memoryReason(l config.ResourceLimits, how string) string {
+	return fmt.Sprintf("memory limit %s exceeded (%s)", utils.FormatByteSize(l.Memory), how)
+}
+
+func procsReason(l config.ResourceLimits, how string) string {
+	return fmt.Sprintf("process limit %d reached (%s)", l.MaxProcs, how)
+}
+
+func cpuReason(l config.ResourceLimits, how string) string {
+	return fmt.Sprintf("CPU time limit %ds exceeded (%s)", l.CPUSeconds, how)
+}
+
+func joinBreaches(reasons []string) string {
+	if len(reasons) == 0 {
+		return ""
+	}
+	return "resource limit exceeded: " + strings.Join(reasons, "; ")
+}
@@ -0,0 +1,371 @@
+//go:build linux
+
+package resources
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+
+	"golang.org/x/sys/unix"
+)
+
+// cpuGrace is how long past the soft CPU limit (SIGXCPU) the hard limit
+// (SIGKILL) lands, for backends that catch SIGXCPU.
+const cpuGrace = 5
+
+// Overridable in tests.
+var (
+	cgroupMount     = "" // "" finds the cgroup2 mount in /proc/self/mountinfo
+	procSelfCgroup  = "/proc/self/cgroup"
+	procMountinfo   = "/proc/self/mountinfo"
+	userProcCounter = countUserProcesses
+)
+
+var (
+	parentMu   sync.Mutex
+	parentDir  string   // cgroup new task cgroups are created under
+	leafDir    string   // the "wrapper" leaf the wrapper moved itself into, if it did
+	enabledBy  []string // controllers the wrapper enabled in parentDir after moving
+	taskSerial atomic.Int64
+)
+
+// New sets up enforcement of limits for a backend run named name. It returns
+// nil when there is nothing to limit.
+func New(limits config.ResourceLimits, name string) *Guard {
+	if limits.IsZero() {
+		return nil
+	}
+	g := &Guard{Limits: limits}
+	if limits.CPUSeconds > 0 {
+		soft := uint64(limits.CPUSeconds)
+		g.Rlimits = append(g.Rlimits, Rlimit{Resource: unix.RLIMIT_CPU, Cur: soft, Max: soft + cpuGrace})
+	}
+	if limits.Memory <= 0 && limits.MaxProcs <= 0 {
+		return g
+	}
+
+	dir, err := createCgroup(limits, name)
+	if err == nil {
+		g.Cgroup = dir
+		return g
+	}
+	g.Fallback = "using rlimits: " + err.Error()
+	if limits.Memory > 0 {
+		// RLIMIT_DATA rather than RLIMIT_AS: JavaScript runtimes reserve far
+		// more address space than they ever touch.
+		m := uint64(limits.Memory)
+		g.Rlimits = append(g.Rlimits, Rlimit{Resource: unix.RLIMIT_DATA, Cur: m, Max: m})
+	}
+	if limits.MaxProcs > 0 {
+		// RLIMIT_NPROC counts every process of the user, not just the
+		// backend's, so leave room for the ones already running.
+		n := uint64(limits.MaxProcs + userProcCounter())
+		g.Rlimits = append(g.Rlimits, Rlimit{Resource: unix.RLIMIT_NPROC, Cur: n, Max: n})
+	}
+	return g
+}
+
+// createCgroup makes a cgroup for one backend with memory.max and pids.max
+// set, beneath the wrapper's own cgroup.
+func createCgroup(limits config.ResourceLimits, name string) (string, error) {
+	parent, err := delegatedParent(limits)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(parent, fmt.Sprintf("codeagent-%d-%d-%s", os.Getpid(), taskSerial.Add(1), cgroupName(name)))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cgroup: %w", err)
+	}
+	settings := map[string]string{}
+	if limits.Memory > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.Memory, 10)
+		settings["memory.swap.max"] = "0"
+	}
+	if limits.MaxProcs > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.MaxProcs)
+	}
+	for file, value := range settings {
+		err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0o644)
+		if err != nil && !(file == "memory.swap.max" && errors.Is(err, os.ErrNotExist)) {
+			_ = os.Remove(dir)
+			return "", fmt.Errorf("cgroup: set %s: %w", file, err)
+		}
+	}
+	return dir, nil
+}
+
+// delegatedParent returns the wrapper's cgroup with the needed controllers
+// enabled for its children. cgroup v2 forbids enabling controllers for a
+// cgroup that has processes of its own, so if the wrapper is alone in its
+// cgroup it first moves itself into a "wrapper" leaf.
+func delegatedParent(limits config.ResourceLimits) (string, error) {
+	parentMu.Lock()
+	defer parentMu.Unlock()
+
+	if parentDir == "" {
+		mount, err := findCgroup2Mount()
+		if err != nil {
+			return "", err
+		}
+		own, err := ownCgroup()
+		if err != nil {
+			return "", err
+		}
+		parentDir = filepath.Join(mount, own)
+	}
+
+	var want []string
+	if limits.Memory > 0 {
+		want = append(want, "memory")
+	}
+	if limits.MaxProcs > 0 {
+		want = append(want, "pids")
+	}
+	available, err := os.ReadFile(filepath.Join(parentDir, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("cgroup: %w", err)
+	}
+	enabled, _ := os.ReadFile(filepath.Join(parentDir, "cgroup.subtree_control"))
+	var enable []string
+	for _, c := range want {
+		if !hasField(string(available), c) {
+			return "", fmt.Errorf("cgroup: the %s controller is not delegated to %s", c, parentDir)
+		}
+		if !hasField(string(enabled), c) {
+			enable = append(enable, "+"+c)
+		}
+	}
+	if len(enable) == 0 {
+		return parentDir, nil
+	}
+
+	control := filepath.Join(parentDir, "cgroup.subtree_control")
+	err = os.WriteFile(control, []byte(strings.Join(enable, " ")), 0o644)
+	if errors.Is(err, syscall.EBUSY) && leafDir == "" {
+		if err := leaveParent(); err != nil {
+			return "", err
+		}
+		err = os.WriteFile(control, []byte(strings.Join(enable, " ")), 0o644)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cgroup: enable %s in %s: %w", strings.Join(want, ", "), parentDir, err)
+	}
+	if leafDir != "" {
+		for _, c := range enable {
+			enabledBy = append(enabledBy, strings.TrimPrefix(c, "+"))
+		}
+	}
+	return parentDir, nil
+}
+
+// leaveParent moves the wrapper into a leaf cgroup so parentDir has no
+// processes of its own. It refuses when other processes share the cgroup.
+func leaveParent() error {
+	procs, err := os.ReadFile(filepath.Join(parentDir, "cgroup.procs"))
+	if err != nil {
+		return fmt.Errorf("cgroup: %w", err)
+	}
+	self := strconv.Itoa(os.Getpid())
+	for _, pid := range strings.Fields(string(procs)) {
+		if pid != self {
+			return fmt.Errorf("cgroup: %s has other processes; start the wrapper in a cgroup of its own (e.g. systemd-run --user --scope -p Delegate=yes)", parentDir)
+		}
+	}
+	leaf := filepath.Join(parentDir, "wrapper")
+	if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("cgroup: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(self), 0o644); err != nil {
+		return fmt.Errorf("cgroup: move the wrapper to %s: %w", leaf, err)
+	}
+	leafDir = leaf
+	return nil
+}
+
+// Release undoes leaveParent once every Guard is closed: it disables the
+// controllers the wrapper enabled in its cgroup, moves the wrapper back and
+// removes the "wrapper" leaf. It does nothing if the wrapper never moved.
+func Release() error {
+	parentMu.Lock()
+	defer parentMu.Unlock()
+
+	if leafDir == "" {
+		return nil
+	}
+	if len(enabledBy) > 0 {
+		disable := make([]string, len(enabledBy))
+		for i, c := range enabledBy {
+			disable[i] = "-" + c
+		}
+		if err := os.WriteFile(filepath.Join(parentDir, "cgroup.subtree_control"), []byte(strings.Join(disable, " ")), 0o644); err != nil {
+			return fmt.Errorf("cgroup: disable %s in %s: %w", strings.Join(enabledBy, ", "), parentDir, err)
+		}
+		enabledBy = nil
+	}
+	if err := os.WriteFile(filepath.Join(parentDir, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+		return fmt.Errorf("cgroup: move the wrapper back to %s: %w", parentDir, err)
+	}
+	if err := os.Remove(leafDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cgroup: %w", err)
+	}
+	leafDir = ""
+	return nil
+}
+
+// findCgroup2Mount returns where the unified hierarchy is mounted.
+func findCgroup2Mount() (string, error) {
+	if cgroupMount != "" {
+		return cgroupMount, nil
+	}
+	f, err := os.Open(procMountinfo)
+	if err != nil {
+		return "", fmt.Errorf("cgroup: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 0:30 / /sys/fs/cgroup rw,nosuid - cgroup2 cgroup2 rw
+		pre, post, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		fields, postFields := strings.Fields(pre), strings.Fields(post)
+		if len(fields) >= 5 && len(postFields) >= 1 && postFields[0] == "cgroup2" {
+			return fields[4], nil
+		}
+	}
+	return "", errors.New("cgroup: no cgroup v2 hierarchy is mounted")
+}
+
+// ownCgroup returns the wrapper's path in the unified hierarchy.
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return "", fmt.Errorf("cgroup: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return strings.TrimSpace(path), nil
+		}
+	}
+	return "", errors.New("cgroup: the wrapper is not in a cgroup v2 hierarchy")
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func cgroupName(name string) string {
+	name = unsafeName.ReplaceAllString(name, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		name = "task"
+	}
+	return name
+}
+
+func hasField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// countUserProcesses counts the processes owned by the current user.
+func countUserProcesses() int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	uid := uint32(os.Getuid())
+	n := 0
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid == uid {
+			n++
+		}
+	}
+	return n
+}
+
+// Breach reports the limits the finished backend ran into, or "". state is
+// the backend's exit state when it exited abnormally and may be nil.
+func (g *Guard) Breach(state *os.ProcessState, stderr string) string {
+	if g == nil {
+		return ""
+	}
+	var reasons []string
+	if g.Cgroup != "" {
+		if g.Limits.Memory > 0 && eventCount(g.Cgroup, "memory.events", "oom_kill") > 0 {
+			reasons = append(reasons, memoryReason(g.Limits, "OOM kill"))
+		}
+		if g.Limits.MaxProcs > 0 && eventCount(g.Cgroup, "pids.events", "max") > 0 {
+			reasons = append(reasons, procsReason(g.Limits, "fork refused"))
+		}
+	}
+	if g.Limits.CPUSeconds > 0 && state != nil {
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			switch {
+			case ws.Signal() == syscall.SIGXCPU:
+				reasons = append(reasons, cpuReason(g.Limits, "SIGXCPU"))
+			case ws.Signal() == syscall.SIGKILL && state.UserTime()+state.SystemTime() >= time.Duration(g.Limits.CPUSeconds)*time.Second:
+				reasons = append(reasons, cpuReason(g.Limits, "SIGKILL"))
+			}
+		}
+	}
+	if len(reasons) == 0 && g.Cgroup == "" {
+		reasons = stderrBreaches(g.Limits, stderr)
+	}
+	return joinBreaches(reasons)
+}
+
+// eventCount reads one counter from a cgroup events file.
+func eventCount(dir, file, key string) int64 {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, ok := strings.Cut(line, " "); ok && k == key {
+			n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// Close kills whatever is left in the task's cgroup and removes it.
+func (g *Guard) Close() error {
+	if g == nil || g.Cgroup == "" {
+		return nil
+	}
+	_ = os.WriteFile(filepath.Join(g.Cgroup, "cgroup.kill"), []byte("1"), 0o644)
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(g.Cgroup); err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("cgroup: remove %s: %w", g.Cgroup, err)
+}
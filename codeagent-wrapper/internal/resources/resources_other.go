@@ -0,0 +1,32 @@
+//go:build !linux
+
+package resources
+
+import (
+	"os"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// New returns a guard that enforces nothing: resource limits need Linux.
+func New(limits config.ResourceLimits, name string) *Guard {
+	if limits.IsZero() {
+		return nil
+	}
+	return &Guard{Limits: limits, Fallback: "not enforced: resource limits need Linux"}
+}
+
+// Breach reports the limits the finished backend ran into, judging by its
+// stderr.
+func (g *Guard) Breach(_ *os.ProcessState, stderr string) string {
+	if g == nil {
+		return ""
+	}
+	return joinBreaches(stderrBreaches(g.Limits, stderr))
+}
+
+// Close releases the guard.
+func (g *Guard) Close() error { return nil }
+
+// Release has nothing to undo without cgroups.
+func Release() error { return nil }
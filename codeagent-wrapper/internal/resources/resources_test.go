@@ -0,0 +1,171 @@
+//go:build linux
+
+package resources
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	config "codeagent-wrapper/internal/config"
+
+	"golang.org/x/sys/unix"
+)
+
+// fakeCgroupfs lays out a cgroup v2 hierarchy in a temp dir with the
+// wrapper in /user/app and memory and pids delegated to it.
+func fakeCgroupfs(t *testing.T, controllers string) string {
+	t.Helper()
+	root := t.TempDir()
+	own := filepath.Join(root, "user", "app")
+	if err := os.MkdirAll(own, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, data := range map[string]string{
+		"cgroup.controllers":     controllers,
+		"cgroup.subtree_control": "",
+		"cgroup.procs":           strconv.Itoa(os.Getpid()),
+	} {
+		if err := os.WriteFile(filepath.Join(own, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	self := filepath.Join(t.TempDir(), "cgroup")
+	if err := os.WriteFile(self, []byte("0::/user/app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prevMount, prevSelf, prevParent, prevLeaf, prevEnabled := cgroupMount, procSelfCgroup, parentDir, leafDir, enabledBy
+	cgroupMount, procSelfCgroup, parentDir, leafDir, enabledBy = root, self, "", "", nil
+	t.Cleanup(func() {
+		cgroupMount, procSelfCgroup, parentDir, leafDir, enabledBy = prevMount, prevSelf, prevParent, prevLeaf, prevEnabled
+	})
+	return own
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestNewUsesDelegatedCgroup(t *testing.T) {
+	own := fakeCgroupfs(t, "cpu memory pids")
+	g := New(config.ResourceLimits{Memory: 1 << 30, MaxProcs: 32, CPUSeconds: 60}, "task/1")
+	if g.Fallback != "" || filepath.Dir(g.Cgroup) != own || !strings.HasSuffix(g.Cgroup, "-task_1") {
+		t.Fatalf("guard = %+v", g)
+	}
+	if got := readFile(t, filepath.Join(own, "cgroup.subtree_control")); got != "+memory +pids" {
+		t.Fatalf("subtree_control = %q", got)
+	}
+	for file, want := range map[string]string{"memory.max": "1073741824", "memory.swap.max": "0", "pids.max": "32"} {
+		if got := readFile(t, filepath.Join(g.Cgroup, file)); got != want {
+			t.Fatalf("%s = %q, want %q", file, got, want)
+		}
+	}
+	if len(g.Rlimits) != 1 || g.Rlimits[0] != (Rlimit{Resource: unix.RLIMIT_CPU, Cur: 60, Max: 60 + cpuGrace}) {
+		t.Fatalf("rlimits = %+v, want only the CPU limit", g.Rlimits)
+	}
+
+	if err := os.WriteFile(filepath.Join(g.Cgroup, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Breach(nil, ""); got != "resource limit exceeded: memory limit 1.0G exceeded (OOM kill)" {
+		t.Fatalf("Breach = %q", got)
+	}
+}
+
+func TestReleaseMovesBackFromLeaf(t *testing.T) {
+	own := fakeCgroupfs(t, "memory pids")
+	parentDir = own
+	if err := leaveParent(); err != nil {
+		t.Fatal(err)
+	}
+	leaf := filepath.Join(own, "wrapper")
+	self := strconv.Itoa(os.Getpid())
+	if leafDir != leaf || readFile(t, filepath.Join(leaf, "cgroup.procs")) != self {
+		t.Fatalf("leafDir = %q, want the wrapper moved into %s", leafDir, leaf)
+	}
+	// cgroupfs removes a cgroup's interface files with it; a temp dir does not.
+	if err := os.Remove(filepath.Join(leaf, "cgroup.procs")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(own, "cgroup.procs"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	enabledBy = []string{"memory", "pids"}
+
+	if err := Release(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(own, "cgroup.subtree_control")); got != "-memory -pids" {
+		t.Fatalf("subtree_control = %q", got)
+	}
+	if got := readFile(t, filepath.Join(own, "cgroup.procs")); got != self {
+		t.Fatalf("cgroup.procs = %q, want the wrapper back", got)
+	}
+	if _, err := os.Stat(leaf); !os.IsNotExist(err) {
+		t.Fatalf("leaf cgroup left behind: %v", err)
+	}
+	if leafDir != "" || enabledBy != nil {
+		t.Fatalf("state not reset: %q %v", leafDir, enabledBy)
+	}
+	if err := Release(); err != nil {
+		t.Fatalf("second Release = %v", err)
+	}
+}
+
+func TestNewFallsBackToRlimits(t *testing.T) {
+	fakeCgroupfs(t, "cpu")
+	prev := userProcCounter
+	userProcCounter = func() int { return 10 }
+	defer func() { userProcCounter = prev }()
+
+	g := New(config.ResourceLimits{Memory: 512 << 20, MaxProcs: 5}, "t")
+	if g.Cgroup != "" || !strings.Contains(g.Fallback, "memory controller is not delegated") {
+		t.Fatalf("guard = %+v", g)
+	}
+	want := []Rlimit{
+		{Resource: unix.RLIMIT_DATA, Cur: 512 << 20, Max: 512 << 20},
+		{Resource: unix.RLIMIT_NPROC, Cur: 15, Max: 15},
+	}
+	if len(g.Rlimits) != len(want) || g.Rlimits[0] != want[0] || g.Rlimits[1] != want[1] {
+		t.Fatalf("rlimits = %+v, want %+v", g.Rlimits, want)
+	}
+	if got := g.Breach(nil, "node: fatal error: Cannot allocate memory\n"); !strings.Contains(got, "memory limit 512.0M exceeded (allocation failed)") {
+		t.Fatalf("Breach = %q", got)
+	}
+	if got := g.Breach(nil, "bash: fork: retry: Resource temporarily unavailable\n"); !strings.Contains(got, "process limit 5 reached") {
+		t.Fatalf("Breach = %q", got)
+	}
+	if got := g.Breach(nil, "all good\n"); got != "" {
+		t.Fatalf("Breach = %q, want none", got)
+	}
+}
+
+func TestBreachReportsSIGXCPU(t *testing.T) {
+	err := exec.Command("sh", "-c", "kill -XCPU $$").Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Skipf("sh: %v", err)
+	}
+	g := &Guard{Limits: config.ResourceLimits{CPUSeconds: 30}}
+	if got := g.Breach(exitErr.ProcessState, ""); got != "resource limit exceeded: CPU time limit 30s exceeded (SIGXCPU)" {
+		t.Fatalf("Breach = %q", got)
+	}
+	if got := (&Guard{Limits: config.ResourceLimits{Memory: 1}}).Breach(exitErr.ProcessState, ""); got != "" {
+		t.Fatalf("Breach without a CPU limit = %q", got)
+	}
+}
+
+func TestNewWithoutLimits(t *testing.T) {
+	if g := New(config.ResourceLimits{}, "t"); g != nil {
+		t.Fatalf("New = %+v", g)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+var errUnsupported = errors.New("sandbox: backend sandboxing requires Linux with Landlock")
+
+// Available reports whether backends can be sandboxed here.
+func Available() error { return errUnsupported }
+
+// Restrict is only supported on Linux.
+func Restrict(Policy) error { return errUnsupported }
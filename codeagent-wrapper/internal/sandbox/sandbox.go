@@ -0,0 +1,191 @@
+// Package sandbox confines backend processes on Linux with Landlock. The
+// launcher package calls Restrict between fork and exec, so the restrictions
+// hold for the backend and everything it starts.
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultDeny are read-protected unless the configuration says otherwise.
+var DefaultDeny = []string{"~/.ssh", "~/.gnupg", "~/.aws", "~/.config/gcloud", "~/.kube", "~/.docker"}
+
+// DefaultWrite are device and shared-memory paths most tools expect to be
+// able to write.
+var DefaultWrite = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/tty", "/dev/ptmx", "/dev/pts", "/dev/shm"}
+
+// Policy lists what the backend may access. Write paths are also readable.
+// Read paths are readable except for anything under Deny; Landlock only
+// grants access, so the launcher grants the siblings of each denied path
+// instead of its parent.
+type Policy struct {
+	Write []string `json:"write"`
+	Read  []string `json:"read"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Normalize expands ~, makes paths absolute, resolves symlinks where the path
+// exists and drops duplicates. Read defaults to "/".
+func (p Policy) Normalize() Policy {
+	out := Policy{
+		Write: normalizePaths(p.Write),
+		Read:  normalizePaths(p.Read),
+		Deny:  normalizePaths(p.Deny),
+	}
+	if len(p.Read) == 0 {
+		out.Read = []string{"/"}
+	}
+	return out
+}
+
+var (
+	deniedPattern  = regexp.MustCompile(`(?i)(permission denied|operation not permitted|EACCES|EPERM)`)
+	absPathPattern = regexp.MustCompile(`(?:^|[\s'"(=])(/[^\s'":,)]*)`)
+)
+
+// Violations sorts the stderr lines reporting a refused access, at most limit
+// of each. A line is denied when it names an absolute path the normalized
+// policy p neither lets the backend read nor write, so the sandbox is what
+// refused it. Every other such line is only possible: file modes, git, ssh
+// and the network report the same errors.
+func Violations(stderr string, p Policy, limit int) (denied, possible []string) {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || !deniedPattern.MatchString(line) {
+			continue
+		}
+		if p.outside(line) {
+			if len(denied) < limit {
+				denied = append(denied, line)
+			}
+		} else if len(possible) < limit {
+			possible = append(possible, line)
+		}
+	}
+	return denied, possible
+}
+
+// outside reports whether line names an absolute path that p grants no
+// access to.
+func (p Policy) outside(line string) bool {
+	for _, m := range absPathPattern.FindAllStringSubmatch(line, -1) {
+		path := filepath.Clean(m[1])
+		if !grants(p.Write, path) && (!grants(p.Read, path) || isDenied(path, p.Deny)) {
+			return true
+		}
+	}
+	return false
+}
+
+// grants reports whether path is one of roots or lies below one.
+func grants(roots []string, path string) bool {
+	for _, root := range roots {
+		if path == root || within(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandHome replaces a leading ~ with the user's home directory.
+func ExpandHome(path string) string {
+	path = strings.TrimSpace(path)
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+func normalizePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	out := make([]string, 0, len(paths))
+	for _, raw := range paths {
+		path := ExpandHome(raw)
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		if !seen[path] {
+			seen[path] = true
+			out = append(out, path)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// within reports whether path is strictly below dir.
+func within(path, dir string) bool {
+	if dir == "/" {
+		return path != "/" && strings.HasPrefix(path, "/")
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
+
+func isDenied(path string, deny []string) bool {
+	for _, d := range deny {
+		if path == d || within(path, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedPaths returns the paths to grant access to: each root, or, when a
+// denied path lies below it, the root's entries minus the denied ones,
+// recursively. Symlinked entries that lead into a denied path are dropped.
+func allowedPaths(roots, deny []string) []string {
+	var out []string
+	for _, root := range roots {
+		out = append(out, excludeDenied(root, deny)...)
+	}
+	return out
+}
+
+func excludeDenied(path string, deny []string) []string {
+	if isDenied(path, deny) {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved != path {
+		if isDenied(resolved, deny) {
+			return nil
+		}
+		for _, d := range deny {
+			if within(d, resolved) {
+				return nil
+			}
+		}
+	}
+	covers := false
+	for _, d := range deny {
+		if within(d, path) {
+			covers = true
+			break
+		}
+	}
+	if !covers {
+		return []string{path}
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		out = append(out, excludeDenied(filepath.Join(path, e.Name()), deny)...)
+	}
+	return out
+}
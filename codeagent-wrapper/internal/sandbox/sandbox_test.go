@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func mkfile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("data\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllowedPathsExcludesDenied(t *testing.T) {
+	root := t.TempDir()
+	mkfile(t, filepath.Join(root, "home", ".ssh", "id_ed25519"))
+	mkfile(t, filepath.Join(root, "home", "project", "main.go"))
+	mkfile(t, filepath.Join(root, "home", ".bashrc"))
+	mkfile(t, filepath.Join(root, "etc", "hosts"))
+	if err := os.Symlink(filepath.Join(root, "home", ".ssh"), filepath.Join(root, "home", "keys")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	got := allowedPaths([]string{root}, []string{filepath.Join(root, "home", ".ssh")})
+	sort.Strings(got)
+	want := []string{
+		filepath.Join(root, "etc"),
+		filepath.Join(root, "home", ".bashrc"),
+		filepath.Join(root, "home", "project"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowedPaths = %v, want %v", got, want)
+	}
+
+	if got := allowedPaths([]string{root}, nil); !reflect.DeepEqual(got, []string{root}) {
+		t.Fatalf("without deny = %v", got)
+	}
+	if got := allowedPaths([]string{filepath.Join(root, "home", ".ssh")}, []string{filepath.Join(root, "home")}); len(got) != 0 {
+		t.Fatalf("root inside a denied path = %v", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	p := Policy{Write: []string{"~/work", "~/work/", " "}, Deny: []string{"~/.ssh"}}.Normalize()
+	if !reflect.DeepEqual(p.Write, []string{filepath.Join(home, "work")}) {
+		t.Fatalf("Write = %v", p.Write)
+	}
+	if !reflect.DeepEqual(p.Read, []string{"/"}) {
+		t.Fatalf("Read = %v, want [/]", p.Read)
+	}
+	if !reflect.DeepEqual(p.Deny, []string{filepath.Join(home, ".ssh")}) {
+		t.Fatalf("Deny = %v", p.Deny)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	p := Policy{Write: []string{"/work"}, Read: []string{"/"}, Deny: []string{"/home/u/.ssh"}}
+	stderr := "starting\n" +
+		"cat: /home/u/.ssh/id_rsa: Permission denied\n" +
+		"warning: slow\n" +
+		"git@github.com: Permission denied (publickey).\n" +
+		"open /etc/shadow: permission denied\n" +
+		"touch: cannot touch '/work/out': Permission denied\n" +
+		"bash: /home/u/.ssh/config: Permission denied\n" +
+		"EACCES again\n"
+	denied, possible := Violations(stderr, p, 2)
+	wantDenied := []string{"cat: /home/u/.ssh/id_rsa: Permission denied", "bash: /home/u/.ssh/config: Permission denied"}
+	wantPossible := []string{"git@github.com: Permission denied (publickey).", "open /etc/shadow: permission denied"}
+	if !reflect.DeepEqual(denied, wantDenied) || !reflect.DeepEqual(possible, wantPossible) {
+		t.Fatalf("Violations = %v, %v; want %v, %v", denied, possible, wantDenied, wantPossible)
+	}
+
+	// Paths outside both the read and write roots are the sandbox's doing.
+	narrow := Policy{Write: []string{"/work"}, Read: []string{"/usr"}}
+	if denied, _ := Violations("open /etc/hosts: operation not permitted\n", narrow, 3); len(denied) != 1 {
+		t.Fatalf("denied = %v, want /etc/hosts reported", denied)
+	}
+	if denied, possible := Violations("all good\n", p, 3); denied != nil || possible != nil {
+		t.Fatalf("Violations = %v, %v; want none", denied, possible)
+	}
+}
@@ -0,0 +1,284 @@
+// Package session records backend sessions in ~/.codeagent/sessions so that
+// `resume <session_id>` can pick the backend, model, agent and workdir the
+// session was created with.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Entry is one recorded session.
+type Entry struct {
+	SessionID      string    `json:"session_id"`
+	Backend        string    `json:"backend"`
+	Model          string    `json:"model,omitempty"`
+	Agent          string    `json:"agent,omitempty"`
+	WorkDir        string    `json:"workdir"`
+	Worktree       string    `json:"worktree,omitempty"`
+	TaskID         string    `json:"task_id,omitempty"`
+	HandoffFrom    string    `json:"handoff_from,omitempty"`    // session whose transcript started this one
+	HandoffBackend string    `json:"handoff_backend,omitempty"` // backend of HandoffFrom
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Runs           int       `json:"runs"`
+}
+
+// ResumeDir is the directory a resumed run should use: the worktree when it
+// still exists, otherwise the original workdir. It is empty when neither
+// exists.
+func (e Entry) ResumeDir() string {
+	for _, dir := range []string{e.Worktree, e.WorkDir} {
+		if dir == "" {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+var nowFn = time.Now
+
+const (
+	lockWait  = 5 * time.Second
+	lockStale = 30 * time.Second
+)
+
+// safeFileName matches session IDs usable as file names as-is; others are
+// stored under a hash.
+var safeFileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// Dir returns ~/.codeagent/sessions.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".codeagent", "sessions"), nil
+}
+
+func entryPath(id string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	name := id
+	if !safeFileName.MatchString(id) {
+		sum := sha256.Sum256([]byte(id))
+		name = hex.EncodeToString(sum[:16])
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Record stores e, merging it into an existing entry for the same session:
+// the creation time is kept, the run count grows and empty fields keep
+// their previous values. A session resumed inside its own worktree keeps
+// the original workdir. The entry is updated while holding path+".lock", so
+// parallel tasks resuming the same session do not lose each other's runs.
+func Record(e Entry) error {
+	e.SessionID = strings.TrimSpace(e.SessionID)
+	if e.SessionID == "" {
+		return errors.New("session id is empty")
+	}
+	path, err := entryPath(e.SessionID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := nowFn().UTC()
+	e.CreatedAt, e.UpdatedAt, e.Runs = now, now, 1
+	if prev, err := readEntry(path); err == nil && prev.SessionID == e.SessionID {
+		if prev.Worktree != "" && e.WorkDir == prev.Worktree {
+			e.WorkDir, e.Worktree = prev.WorkDir, prev.Worktree
+		}
+		e.Backend = valueOr(e.Backend, prev.Backend)
+		e.Model = valueOr(e.Model, prev.Model)
+		e.Agent = valueOr(e.Agent, prev.Agent)
+		e.WorkDir = valueOr(e.WorkDir, prev.WorkDir)
+		e.TaskID = valueOr(e.TaskID, prev.TaskID)
+		e.HandoffFrom = valueOr(e.HandoffFrom, prev.HandoffFrom)
+		e.HandoffBackend = valueOr(e.HandoffBackend, prev.HandoffBackend)
+		if !prev.CreatedAt.IsZero() {
+			e.CreatedAt = prev.CreatedAt
+		}
+		e.Runs = prev.Runs + 1
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// lockFile creates path exclusively, waiting for another holder to remove it
+// and taking over locks left behind by a crashed run.
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func valueOr(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+func readEntry(path string) (Entry, error) {
+	var e Entry
+	data, err := os.ReadFile(path) // #nosec G304 -- file under ~/.codeagent/sessions
+	if err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// Lookup returns the entry for id, or nil when the session is not recorded.
+func Lookup(id string) (*Entry, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, nil
+	}
+	path, err := entryPath(id)
+	if err != nil {
+		return nil, err
+	}
+	e, err := readEntry(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if e.SessionID != id {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// List returns all recorded sessions, most recently used first. Unreadable
+// entries are skipped.
+func List() ([]Entry, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(files))
+	for _, path := range files {
+		e, err := readEntry(path)
+		if err != nil || e.SessionID == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].UpdatedAt.Equal(entries[j].UpdatedAt) {
+			return entries[i].UpdatedAt.After(entries[j].UpdatedAt)
+		}
+		return entries[i].SessionID < entries[j].SessionID
+	})
+	return entries, nil
+}
+
+// Remove deletes the entry for id, reporting whether one existed.
+func Remove(id string) (bool, error) {
+	path, err := entryPath(strings.TrimSpace(id))
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Prune removes sessions last used more than olderThan ago (when olderThan
+// is positive) and sessions whose workdir and worktree no longer exist. It
+// returns the removed entries; with dryRun nothing is deleted.
+func Prune(olderThan time.Duration, dryRun bool) ([]Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := nowFn().Add(-olderThan)
+	var pruned []Entry
+	for _, e := range entries {
+		stale := olderThan > 0 && e.UpdatedAt.Before(cutoff)
+		if !stale && e.ResumeDir() != "" {
+			continue
+		}
+		if !dryRun {
+			if _, err := Remove(e.SessionID); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, e)
+	}
+	return pruned, nil
+}
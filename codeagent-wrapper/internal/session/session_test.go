@@ -0,0 +1,142 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setTestHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	return home
+}
+
+func setNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := nowFn
+	nowFn = func() time.Time { return now }
+	t.Cleanup(func() { nowFn = prev })
+}
+
+func TestRecordMergesRuns(t *testing.T) {
+	setTestHome(t)
+	workDir, worktree := t.TempDir(), t.TempDir()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	setNow(t, created)
+	if err := Record(Entry{SessionID: "sess-1", Backend: "claude", Model: "opus", Agent: "develop", WorkDir: workDir, Worktree: worktree, TaskID: "t1"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	// A resume inside the worktree, without agent or task id.
+	setNow(t, created.Add(time.Hour))
+	if err := Record(Entry{SessionID: "sess-1", Backend: "claude", WorkDir: worktree}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	e, err := Lookup("sess-1")
+	if err != nil || e == nil {
+		t.Fatalf("Lookup = %+v, %v", e, err)
+	}
+	if e.WorkDir != workDir || e.Worktree != worktree || e.Model != "opus" || e.Agent != "develop" || e.TaskID != "t1" {
+		t.Fatalf("merged entry = %+v", e)
+	}
+	if e.Runs != 2 || !e.CreatedAt.Equal(created) || !e.UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Fatalf("runs=%d created=%v updated=%v", e.Runs, e.CreatedAt, e.UpdatedAt)
+	}
+	if e.ResumeDir() != worktree {
+		t.Fatalf("ResumeDir = %q, want worktree", e.ResumeDir())
+	}
+
+	if e, err := Lookup("unknown"); e != nil || err != nil {
+		t.Fatalf("Lookup(unknown) = %+v, %v", e, err)
+	}
+}
+
+func TestRecordConcurrentRunsAddUp(t *testing.T) {
+	setTestHome(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Record(Entry{SessionID: "sess-1", Backend: "codex"}); err != nil {
+				t.Errorf("Record: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if e, err := Lookup("sess-1"); err != nil || e == nil || e.Runs != 10 {
+		t.Fatalf("Lookup = %+v, %v", e, err)
+	}
+	if entries, err := List(); err != nil || len(entries) != 1 {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+}
+
+func TestRecordHashesUnsafeIDs(t *testing.T) {
+	home := setTestHome(t)
+	id := "ses/../weird id"
+	if err := Record(Entry{SessionID: id, Backend: "opencode", WorkDir: home}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(home, ".codeagent", "sessions", "*.json"))
+	if len(files) != 1 || strings.Contains(filepath.Base(files[0]), "weird") {
+		t.Fatalf("files = %v", files)
+	}
+	if e, _ := Lookup(id); e == nil || e.Backend != "opencode" {
+		t.Fatalf("Lookup = %+v", e)
+	}
+	if removed, err := Remove(id); !removed || err != nil {
+		t.Fatalf("Remove = %v, %v", removed, err)
+	}
+}
+
+func TestListAndPrune(t *testing.T) {
+	setTestHome(t)
+	live, gone := t.TempDir(), filepath.Join(t.TempDir(), "removed")
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, e := range []Entry{
+		{SessionID: "old", Backend: "codex", WorkDir: live},
+		{SessionID: "gone", Backend: "codex", WorkDir: gone},
+		{SessionID: "fresh", Backend: "codex", WorkDir: live},
+	} {
+		setNow(t, base.Add(time.Duration(i)*24*time.Hour))
+		if err := Record(e); err != nil {
+			t.Fatalf("Record(%s): %v", e.SessionID, err)
+		}
+	}
+
+	entries, err := List()
+	if err != nil || len(entries) != 3 || entries[0].SessionID != "fresh" || entries[2].SessionID != "old" {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+
+	setNow(t, base.Add(3*24*time.Hour+time.Hour))
+	pruned, err := Prune(48*time.Hour, true)
+	if err != nil || len(pruned) != 2 {
+		t.Fatalf("Prune(dry run) = %+v, %v", pruned, err)
+	}
+	if entries, _ := List(); len(entries) != 3 {
+		t.Fatalf("dry run removed entries: %+v", entries)
+	}
+
+	pruned, err = Prune(0, false)
+	if err != nil || len(pruned) != 1 || pruned[0].SessionID != "gone" {
+		t.Fatalf("Prune(0) = %+v, %v", pruned, err)
+	}
+	pruned, _ = Prune(48*time.Hour, false)
+	if len(pruned) != 1 || pruned[0].SessionID != "old" {
+		t.Fatalf("Prune(48h) = %+v", pruned)
+	}
+	if _, err := os.Stat(live); err != nil {
+		t.Fatalf("prune must not touch workdirs: %v", err)
+	}
+}
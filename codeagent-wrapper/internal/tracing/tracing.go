@@ -0,0 +1,328 @@
+// Package tracing records OpenTelemetry-compatible spans for wrapper runs and
+// exports them as OTLP/JSON, either to an OTLP/HTTP collector or to a file.
+// It is configured from the environment and does nothing unless an exporter
+// is set. Trace context reaches nested wrapper invocations through the
+// TRACEPARENT environment variable (W3C trace context).
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParentEnv carries the W3C traceparent of the span that started a
+// backend process.
+const TraceParentEnv = "TRACEPARENT"
+
+// batchSize is how many ended spans are buffered before they are exported
+// in the background.
+const batchSize = 256
+
+type traceID [16]byte
+
+type spanID [8]byte
+
+func (id traceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id spanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id spanID) isZero() bool { return id == spanID{} }
+
+// Attr is a span or event attribute.
+type Attr struct {
+	Key   string
+	Value any // string, int64, bool or float64
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attr { return Attr{Key: key, Value: value} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+type event struct {
+	name  string
+	time  time.Time
+	attrs []Attr
+}
+
+// Span is one timed operation. All methods are safe on a nil *Span, which is
+// what Start returns when tracing is disabled.
+type Span struct {
+	tracer   *tracer
+	traceID  traceID
+	spanID   spanID
+	parentID spanID
+	name     string
+	start    time.Time
+
+	mu       sync.Mutex
+	end      time.Time
+	attrs    []Attr
+	events   []event
+	errMsg   string
+	hasError bool
+	ended    bool
+}
+
+// SetAttributes adds or replaces attributes.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		replaced := false
+		for i := range s.attrs {
+			if s.attrs[i].Key == a.Key {
+				s.attrs[i] = a
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.attrs = append(s.attrs, a)
+		}
+	}
+}
+
+// AddEvent records a point in time within the span.
+func (s *Span) AddEvent(name string, attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event{name: name, time: time.Now(), attrs: attrs})
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hasError = true
+	s.errMsg = msg
+}
+
+// End finishes the span. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.record(s)
+}
+
+// TraceParent renders the span as a W3C traceparent header value.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", s.traceID, s.spanID)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns ctx with s as the parent of spans started from it.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext returns the span stored in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *tracer
+	rootSpan *Span
+)
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active != nil
+}
+
+// Start begins a span. Its parent is the span in ctx, else the run span
+// started with StartRun, else the TRACEPARENT this process was started with.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	activeMu.RLock()
+	t, root := active, rootSpan
+	activeMu.RUnlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, start: time.Now(), spanID: newSpanID()}
+	s.SetAttributes(attrs...)
+	switch parent := SpanFromContext(ctx); {
+	case parent != nil:
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	case root != nil:
+		s.traceID, s.parentID = root.traceID, root.spanID
+	case t.remoteTrace != traceID{}:
+		s.traceID, s.parentID = t.remoteTrace, t.remoteParent
+	default:
+		s.traceID = newTraceID()
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// StartRun begins the span for the whole wrapper invocation; spans started
+// without a parent in their context become its children.
+func StartRun(name string, attrs ...Attr) *Span {
+	_, s := Start(context.Background(), name, attrs...)
+	if s != nil {
+		activeMu.Lock()
+		rootSpan = s
+		activeMu.Unlock()
+	}
+	return s
+}
+
+// RunSpan returns the span started with StartRun, or nil.
+func RunSpan() *Span {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return rootSpan
+}
+
+// Environ returns the variables that hand the trace context of the span in
+// ctx (or the run span) to a child process.
+func Environ(ctx context.Context) map[string]string {
+	s := SpanFromContext(ctx)
+	if s == nil {
+		s = RunSpan()
+	}
+	if s == nil {
+		return nil
+	}
+	return map[string]string{TraceParentEnv: s.TraceParent()}
+}
+
+// Init configures tracing from the environment:
+//
+//	CODEAGENT_TRACE_FILE                 append OTLP/JSON batches to this file
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT   OTLP/HTTP traces URL
+//	OTEL_EXPORTER_OTLP_ENDPOINT          OTLP/HTTP base URL (/v1/traces is appended)
+//	OTEL_EXPORTER_OTLP_[TRACES_]HEADERS  k=v,k2=v2 request headers
+//	OTEL_SERVICE_NAME                    service.name (default codeagent-wrapper)
+//	OTEL_SDK_DISABLED=true or OTEL_TRACES_EXPORTER=none turn tracing off.
+//
+// The returned function exports what is left and must be called before
+// exit. Configuration problems are returned as warnings; tracing continues
+// with the exporters that could be set up.
+func Init(serviceVersion string) (shutdown func(context.Context) error, warnings []string) {
+	noop := func(context.Context) error { return nil }
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("OTEL_SDK_DISABLED")), "true") ||
+		strings.EqualFold(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")), "none") {
+		return noop, nil
+	}
+
+	exporters, warnings := exportersFromEnv()
+	if len(exporters) == 0 {
+		return noop, warnings
+	}
+
+	service := strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME"))
+	if service == "" {
+		service = "codeagent-wrapper"
+	}
+	t := &tracer{
+		exporters: exporters,
+		resource: []Attr{
+			String("service.name", service),
+			String("service.version", serviceVersion),
+			Int("process.pid", os.Getpid()),
+		},
+		version: serviceVersion,
+	}
+	if tid, sid, ok := parseTraceParent(os.Getenv(TraceParentEnv)); ok {
+		t.remoteTrace, t.remoteParent = tid, sid
+	}
+
+	activeMu.Lock()
+	active, rootSpan = t, nil
+	activeMu.Unlock()
+
+	return func(ctx context.Context) error {
+		activeMu.Lock()
+		if active == t {
+			active, rootSpan = nil, nil
+		}
+		activeMu.Unlock()
+		return t.shutdown(ctx)
+	}, warnings
+}
+
+// parseTraceParent parses a W3C traceparent value.
+func parseTraceParent(value string) (traceID, spanID, bool) {
+	var tid traceID
+	var sid spanID
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return tid, sid, false
+	}
+	tb, err1 := hex.DecodeString(parts[1])
+	sb, err2 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || len(tb) != len(tid) || len(sb) != len(sid) {
+		return tid, sid, false
+	}
+	copy(tid[:], tb)
+	copy(sid[:], sb)
+	if tid == (traceID{}) || sid.isZero() {
+		return tid, sid, false
+	}
+	return tid, sid, true
+}
+
+func newTraceID() traceID {
+	var id traceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() spanID {
+	var id spanID
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,205 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func clearTraceEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		"CODEAGENT_TRACE_FILE", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS", "OTEL_EXPORTER_OTLP_PROTOCOL",
+		"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_SDK_DISABLED", "OTEL_TRACES_EXPORTER", "OTEL_SERVICE_NAME", TraceParentEnv,
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func readSpans(t *testing.T, data []byte) []otlpSpan {
+	t.Helper()
+	var spans []otlpSpan
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var req otlpRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			t.Fatalf("invalid OTLP/JSON %q: %v", line, err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func attrValue(span otlpSpan, key string) string {
+	for _, a := range span.Attributes {
+		if a.Key != key {
+			continue
+		}
+		switch {
+		case a.Value.StringValue != nil:
+			return *a.Value.StringValue
+		case a.Value.IntValue != nil:
+			return *a.Value.IntValue
+		case a.Value.BoolValue != nil && *a.Value.BoolValue:
+			return "true"
+		case a.Value.BoolValue != nil:
+			return "false"
+		}
+	}
+	return ""
+}
+
+func TestDisabledWithoutExporter(t *testing.T) {
+	clearTraceEnv(t)
+	shutdown, warnings := Init("test")
+	defer shutdown(context.Background())
+	if Enabled() || len(warnings) != 0 {
+		t.Fatalf("Enabled = %v, warnings = %v", Enabled(), warnings)
+	}
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || SpanFromContext(ctx) != nil || Environ(ctx) != nil {
+		t.Fatal("disabled tracing must not create spans")
+	}
+	span.SetAttributes(String("k", "v"))
+	span.End()
+}
+
+func TestFileExporterRecordsHierarchy(t *testing.T) {
+	clearTraceEnv(t)
+	path := filepath.Join(t.TempDir(), "traces", "run.jsonl")
+	t.Setenv("CODEAGENT_TRACE_FILE", path)
+
+	shutdown, _ := Init("1.2.3")
+	run := StartRun("codeagent.run")
+	ctx, task := Start(context.Background(), "codeagent.task", String("codeagent.backend", "codex"))
+	_, child := Start(ctx, "backend.start")
+	child.AddEvent("kill")
+	child.End()
+	task.SetAttributes(Int("codeagent.exit_code", 1), Bool("ok", false))
+	task.SetError("boom")
+	task.End()
+	task.End()
+	run.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if Enabled() {
+		t.Fatal("shutdown must disable tracing")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"stringValue":"1.2.3"`) {
+		t.Fatalf("resource attributes missing:\n%s", data)
+	}
+	spans := readSpans(t, data)
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+		if s.TraceID != spans[0].TraceID {
+			t.Fatalf("spans belong to different traces: %+v", spans)
+		}
+	}
+	if byName["codeagent.run"].ParentSpanID != "" ||
+		byName["codeagent.task"].ParentSpanID != byName["codeagent.run"].SpanID ||
+		byName["backend.start"].ParentSpanID != byName["codeagent.task"].SpanID {
+		t.Fatalf("unexpected hierarchy: %+v", byName)
+	}
+	got := byName["codeagent.task"]
+	if attrValue(got, "codeagent.backend") != "codex" || attrValue(got, "codeagent.exit_code") != "1" || attrValue(got, "ok") != "false" {
+		t.Fatalf("task attributes = %+v", got.Attributes)
+	}
+	if got.Status.Code != 2 || got.Status.Message != "boom" {
+		t.Fatalf("task status = %+v", got.Status)
+	}
+	if len(byName["backend.start"].Events) != 1 || byName["backend.start"].Events[0].Name != "kill" {
+		t.Fatalf("events = %+v", byName["backend.start"].Events)
+	}
+}
+
+func TestOTLPExporterAndTraceParent(t *testing.T) {
+	clearTraceEnv(t)
+	var body []byte
+	var gotPath, gotType, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType, gotAuth = r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	const parent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	t.Setenv(TraceParentEnv, parent)
+
+	shutdown, warnings := Init("dev")
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "grpc") {
+		t.Fatalf("warnings = %v", warnings)
+	}
+	run := StartRun("codeagent.run")
+	env := Environ(context.Background())
+	run.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if gotPath != "/v1/traces" || gotType != "application/json" || gotAuth != "Bearer token" {
+		t.Fatalf("request path=%q type=%q auth=%q", gotPath, gotType, gotAuth)
+	}
+	spans := readSpans(t, body)
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	if spans[0].TraceID != "0af7651916cd43dd8448eb211c80319c" || spans[0].ParentSpanID != "b7ad6b7169203331" {
+		t.Fatalf("run span does not continue the inherited trace: %+v", spans[0])
+	}
+	if want := "00-0af7651916cd43dd8448eb211c80319c-" + spans[0].SpanID + "-01"; env[TraceParentEnv] != want {
+		t.Fatalf("Environ = %v, want %s", env, want)
+	}
+}
+
+func TestOTLPExporterReportsFailures(t *testing.T) {
+	clearTraceEnv(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", srv.URL+"/custom")
+
+	shutdown, _ := Init("dev")
+	StartRun("codeagent.run").End()
+	if err := shutdown(context.Background()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("shutdown err = %v, want 503", err)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	for value, ok := range map[string]bool{
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01": true,
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01": false,
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01": false,
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b71-01":         false,
+		"": false,
+	} {
+		if _, _, got := parseTraceParent(value); got != ok {
+			t.Fatalf("parseTraceParent(%q) = %v, want %v", value, got, ok)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package utils
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{"0": 0, "1024": 1024, "512K": 512 << 10, "500MB": 500 << 20, "1GiB": 1 << 30, "2g": 2 << 30}
+	for raw, want := range tests {
+		got, err := ParseByteSize(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseByteSize(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	if _, err := ParseByteSize("lots"); err == nil {
+		t.Fatal("expected error for invalid size")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -95,3 +96,177 @@ func CreateWorktree(projectDir string) (*Paths, error) {
 		TaskID: taskID,
 	}, nil
 }
+
+// Diff stages every change in the worktree (including untracked files) and
+// returns the staged diff against HEAD.
+func Diff(dir string) (string, error) {
+	if output, err := execCommand("git", "-C", dir, "add", "-A").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to stage worktree changes: %w\noutput: %s", err, string(output))
+	}
+	output, err := execCommand("git", "-C", dir, "diff", "--cached", "--no-color", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to diff worktree: %w", err)
+	}
+	return string(output), nil
+}
+
+// TreeHash returns the git tree dir's repository would commit if every
+// change, untracked files included, were staged, and dir's path inside the
+// repository. It stages into a temporary index, so the real one is left
+// alone.
+func TreeHash(dir string) (tree, prefix string, err error) {
+	output, err := execCommand("git", "-C", dir, "rev-parse", "--absolute-git-dir", "--show-prefix").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("not a git repository: %s", dir)
+	}
+	lines := strings.SplitN(strings.TrimRight(string(output), "\n"), "\n", 2)
+	gitDir := strings.TrimSpace(lines[0])
+	if len(lines) > 1 {
+		prefix = strings.TrimSpace(lines[1])
+	}
+
+	index, err := os.CreateTemp("", "codeagent-index-*")
+	if err != nil {
+		return "", "", err
+	}
+	indexPath := index.Name()
+	defer os.Remove(indexPath)
+	// Starting from a copy of the real index lets git skip rehashing files
+	// whose stat data is unchanged.
+	if src, err := os.Open(filepath.Join(gitDir, "index")); err == nil {
+		_, _ = io.Copy(index, src)
+		src.Close()
+	}
+	if err := index.Close(); err != nil {
+		return "", "", err
+	}
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+indexPath)
+	add := execCommand("git", "-C", dir, "add", "-A", "--", ":/")
+	add.Env = env
+	if output, err := add.CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to stage changes into a temporary index: %w\noutput: %s", err, string(output))
+	}
+	writeTree := execCommand("git", "-C", dir, "write-tree")
+	writeTree.Env = env
+	output, err = writeTree.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to write tree: %w", err)
+	}
+	return strings.TrimSpace(string(output)), prefix, nil
+}
+
+// CurrentBranch returns the branch checked out in dir, or the short commit
+// hash when HEAD is detached.
+func CurrentBranch(dir string) (string, error) {
+	output, err := execCommand("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read current branch: %w", err)
+	}
+	branch := strings.TrimSpace(string(output))
+	if branch == "HEAD" {
+		output, err = execCommand("git", "-C", dir, "rev-parse", "--short", "HEAD").Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to read HEAD: %w", err)
+		}
+		branch = strings.TrimSpace(string(output))
+	}
+	return branch, nil
+}
+
+// ShortStatus returns `git status --short` for dir.
+func ShortStatus(dir string) (string, error) {
+	output, err := execCommand("git", "-C", dir, "status", "--short").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read git status: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// Commit records all pending changes in the worktree on its branch.
+// It is a no-op when there is nothing to commit.
+func Commit(dir, message string) error {
+	if output, err := execCommand("git", "-C", dir, "add", "-A").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stage worktree changes: %w\noutput: %s", err, string(output))
+	}
+	if err := execCommand("git", "-C", dir, "diff", "--cached", "--quiet").Run(); err == nil {
+		return nil
+	}
+	cmd := execCommand("git", "-C", dir, "commit", "--no-verify", "-m", message)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to commit worktree changes: %w\noutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// Remove deletes the worktree directory and its branch.
+func Remove(paths *Paths) error {
+	if paths == nil {
+		return nil
+	}
+	// Worktrees live at {gitRoot}/.worktrees/do-{task_id}/
+	gitRoot := filepath.Dir(filepath.Dir(paths.Dir))
+	cmd := execCommand("git", "-C", gitRoot, "worktree", "remove", "--force", paths.Dir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove worktree: %w\noutput: %s", err, string(output))
+	}
+	if paths.Branch == "" {
+		return nil
+	}
+	cmd = execCommand("git", "-C", gitRoot, "branch", "-D", paths.Branch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w\noutput: %s", paths.Branch, err, string(output))
+	}
+	return nil
+}
+
+// TaskWorktree is a wrapper-created worktree found by ListTaskWorktrees.
+type TaskWorktree struct {
+	Dir      string
+	Branch   string
+	Prunable bool // directory is gone; `git worktree prune` would drop it
+}
+
+// ListTaskWorktrees returns the do/* worktrees registered in the repository
+// containing dir. Outside a git repository it returns no worktrees.
+func ListTaskWorktrees(dir string) ([]TaskWorktree, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if !isGitRepo(dir) {
+		return nil, nil
+	}
+	output, err := execCommand("git", "-C", dir, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+
+	var found []TaskWorktree
+	var current *TaskWorktree
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if strings.HasPrefix(current.Branch, "do/") || strings.HasPrefix(filepath.Base(current.Dir), "do-") {
+			found = append(found, *current)
+		}
+		current = nil
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "worktree "):
+			flush()
+			current = &TaskWorktree{Dir: strings.TrimPrefix(line, "worktree ")}
+		case current == nil:
+		case strings.HasPrefix(line, "branch "):
+			current.Branch = strings.TrimPrefix(strings.TrimPrefix(line, "branch "), "refs/heads/")
+		case line == "prunable" || strings.HasPrefix(line, "prunable "):
+			current.Prunable = true
+		}
+	}
+	flush()
+	return found, nil
+}
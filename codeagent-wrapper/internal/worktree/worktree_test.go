@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -447,3 +448,208 @@ func (f *fixedReader) Read(p []byte) (n int, err error) {
 	f.pos += n
 	return n, nil
 }
+
+func TestDiffCommitRemove(t *testing.T) {
+	defer resetHooks()
+
+	tmpDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if err := exec.Command("git", append([]string{"-C", tmpDir}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.txt"), []byte("test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command("git", "-C", tmpDir, "add", ".").Run(); err != nil {
+		t.Fatalf("failed to git add: %v", err)
+	}
+	if err := exec.Command("git", "-C", tmpDir, "commit", "-m", "initial").Run(); err != nil {
+		t.Fatalf("failed to git commit: %v", err)
+	}
+
+	paths, err := CreateWorktree(tmpDir)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+
+	// Untracked files must show up in the diff.
+	if err := os.WriteFile(filepath.Join(paths.Dir, "new.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := Diff(paths.Dir)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !regexp.MustCompile(`(?m)^\+hello$`).MatchString(diff) {
+		t.Fatalf("Diff() missing untracked file content:\n%s", diff)
+	}
+
+	if err := Commit(paths.Dir, "candidate"); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	// Nothing left to commit: must be a no-op.
+	if err := Commit(paths.Dir, "again"); err != nil {
+		t.Fatalf("Commit() on clean tree error = %v", err)
+	}
+	out, err := exec.Command("git", "-C", tmpDir, "log", "-1", "--format=%s", paths.Branch).Output()
+	if err != nil || string(out) != "candidate\n" {
+		t.Fatalf("branch head = %q, err %v", out, err)
+	}
+
+	if err := Remove(paths); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(paths.Dir); !os.IsNotExist(err) {
+		t.Fatalf("worktree dir should be removed, stat err = %v", err)
+	}
+	out, _ = exec.Command("git", "-C", tmpDir, "branch", "--list", paths.Branch).Output()
+	if len(out) != 0 {
+		t.Fatalf("branch %s should be deleted", paths.Branch)
+	}
+}
+
+func TestListTaskWorktrees(t *testing.T) {
+	defer resetHooks()
+
+	if got, err := ListTaskWorktrees(t.TempDir()); err != nil || len(got) != 0 {
+		t.Fatalf("non-git dir: got %v, err %v", got, err)
+	}
+
+	tmpDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+		{"commit", "--allow-empty", "-m", "initial"},
+	} {
+		if err := exec.Command("git", append([]string{"-C", tmpDir}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+
+	kept, err := CreateWorktree(tmpDir)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	gone, err := CreateWorktree(tmpDir)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	if err := os.RemoveAll(gone.Dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListTaskWorktrees(tmpDir)
+	if err != nil {
+		t.Fatalf("ListTaskWorktrees() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 task worktrees, got %+v", got)
+	}
+	byBranch := map[string]TaskWorktree{}
+	for _, wt := range got {
+		byBranch[wt.Branch] = wt
+	}
+	if wt := byBranch[kept.Branch]; wt.Prunable || filepath.Base(wt.Dir) != filepath.Base(kept.Dir) {
+		t.Errorf("unexpected entry for kept worktree: %+v", wt)
+	}
+	if wt := byBranch[gone.Branch]; !wt.Prunable {
+		t.Errorf("removed worktree should be prunable: %+v", wt)
+	}
+}
+
+func TestCurrentBranchAndShortStatus(t *testing.T) {
+	defer resetHooks()
+
+	tmpDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-b", "main"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+		{"commit", "--allow-empty", "-m", "initial"},
+	} {
+		if err := exec.Command("git", append([]string{"-C", tmpDir}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "new.txt"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if branch, err := CurrentBranch(tmpDir); err != nil || branch != "main" {
+		t.Fatalf("CurrentBranch() = %q, %v", branch, err)
+	}
+	if status, err := ShortStatus(tmpDir); err != nil || status != "?? new.txt" {
+		t.Fatalf("ShortStatus() = %q, %v", status, err)
+	}
+
+	if err := exec.Command("git", "-C", tmpDir, "checkout", "--detach").Run(); err != nil {
+		t.Fatalf("git checkout --detach: %v", err)
+	}
+	if branch, err := CurrentBranch(tmpDir); err != nil || branch == "HEAD" || branch == "" {
+		t.Fatalf("detached CurrentBranch() = %q, %v", branch, err)
+	}
+
+	if _, err := CurrentBranch(t.TempDir()); err == nil {
+		t.Fatal("expected error outside a git repository")
+	}
+}
+
+func TestTreeHash(t *testing.T) {
+	defer resetHooks()
+
+	tmpDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-b", "main"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if err := exec.Command("git", append([]string{"-C", tmpDir}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	sub := filepath.Join(tmpDir, "sub")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-m", "initial"}} {
+		if err := exec.Command("git", append([]string{"-C", tmpDir}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+
+	clean, prefix, err := TreeHash(sub)
+	if err != nil || prefix != "sub/" {
+		t.Fatalf("TreeHash() = %q, %q, %v", clean, prefix, err)
+	}
+	head, _ := exec.Command("git", "-C", tmpDir, "rev-parse", "HEAD^{tree}").Output()
+	if clean != strings.TrimSpace(string(head)) {
+		t.Fatalf("clean tree = %s, want HEAD's tree %s", clean, head)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "untracked.txt"), []byte("u\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dirty, _, err := TreeHash(sub)
+	if err != nil || dirty == clean {
+		t.Fatalf("untracked file did not change the tree: %q, %v", dirty, err)
+	}
+	if status, _ := ShortStatus(tmpDir); status != "?? untracked.txt" {
+		t.Fatalf("TreeHash touched the real index: status %q", status)
+	}
+	if again, _, _ := TreeHash(tmpDir); again != dirty {
+		t.Fatalf("tree is not stable: %s != %s", again, dirty)
+	}
+
+	if _, _, err := TreeHash(t.TempDir()); err == nil {
+		t.Fatal("expected error outside a git repository")
+	}
+}